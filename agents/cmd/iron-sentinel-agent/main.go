@@ -15,6 +15,7 @@ func main() {
 	var poll time.Duration
 	var triageBin string
 	var outputBase string
+	var signKey string
 
 	flag.StringVar(&serverURL, "server", "https://127.0.0.1:8443", "Server base URL")
 	flag.StringVar(&psk, "psk", "", "Pre-shared key (X-PSK)")
@@ -22,6 +23,7 @@ func main() {
 	flag.DurationVar(&poll, "poll", 10*time.Second, "Poll interval")
 	flag.StringVar(&triageBin, "triage-bin", "./iron-sentinel", "Path to iron-sentinel binary")
 	flag.StringVar(&outputBase, "output", "./agent-evidence", "Local output base directory")
+	flag.StringVar(&signKey, "sign-key", "", "Ed25519 key to sign case manifests with, generated if missing (default: next to the cached enrollment)")
 	flag.Parse()
 
 	cfg := agent.Config{
@@ -31,6 +33,7 @@ func main() {
 		PollEvery:   poll,
 		TriageBin:   triageBin,
 		OutputBase:  outputBase,
+		SignKey:     signKey,
 	}
 
 	if err := agent.Run(cfg); err != nil {
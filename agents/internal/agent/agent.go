@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
+	"crypto/ed25519"
 	"crypto/sha256"
 	"crypto/tls"
 	"encoding/hex"
@@ -21,6 +22,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"iron-sentinel/evidence"
+	"iron-sentinel/version"
 )
 
 type Config struct {
@@ -30,12 +34,17 @@ type Config struct {
 	PollEvery   time.Duration
 	TriageBin   string
 	OutputBase  string
+	// SignKey is the Ed25519 key the agent signs case manifests with. It
+	// is generated at enrollment when missing; the default lives next to
+	// the cached credentials.
+	SignKey string
 }
 
 type enrollRequest struct {
-	Hostname string `json:"hostname"`
-	OS       string `json:"os"`
-	Arch     string `json:"arch"`
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	PublicKey string `json:"public_key,omitempty"`
 }
 
 type enrollResponse struct {
@@ -73,10 +82,16 @@ func Run(cfg Config) error {
 		client.Transport = tr
 	}
 
+	pub, created, err := loadSignKey(&cfg)
+	if err != nil {
+		return err
+	}
+	er := enrollRequest{Hostname: host, OS: runtime.GOOS, Arch: runtime.GOARCH, PublicKey: string(pub)}
+
+	// A new key has to reach the server, so it means a new enrollment.
 	auth, ok := loadAuth(cfg.ServerURL)
-	if !ok {
-		var err error
-		auth, err = enroll(client, cfg, enrollRequest{Hostname: host, OS: runtime.GOOS, Arch: runtime.GOARCH})
+	if !ok || created {
+		auth, err = enroll(client, cfg, er)
 		if err != nil {
 			return err
 		}
@@ -87,7 +102,7 @@ func Run(cfg Config) error {
 		j, err := nextJob(client, cfg, auth)
 		if err != nil {
 			if errors.Is(err, errUnauthorized) {
-				auth2, e2 := enroll(client, cfg, enrollRequest{Hostname: host, OS: runtime.GOOS, Arch: runtime.GOARCH})
+				auth2, e2 := enroll(client, cfg, er)
 				if e2 == nil {
 					auth = auth2
 					_ = saveAuth(cfg.ServerURL, auth)
@@ -104,7 +119,7 @@ func Run(cfg Config) error {
 		}
 		if err := handleJob(client, cfg, auth, j); err != nil {
 			if errors.Is(err, errUnauthorized) {
-				auth2, e2 := enroll(client, cfg, enrollRequest{Hostname: host, OS: runtime.GOOS, Arch: runtime.GOARCH})
+				auth2, e2 := enroll(client, cfg, er)
 				if e2 == nil {
 					auth = auth2
 					_ = saveAuth(cfg.ServerURL, auth)
@@ -193,6 +208,7 @@ func handleJob(client *http.Client, cfg Config, auth authState, j job) error {
 	if err != nil {
 		return err
 	}
+	args = append(args, "--sign-key", cfg.SignKey)
 	cmd := exec.CommandContext(ctx, cfg.TriageBin, args...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -206,6 +222,10 @@ func handleJob(client *http.Client, cfg Config, auth authState, j job) error {
 	}
 
 	archivePath := filepath.Join(outDir, j.JobID+".tar.gz")
+	transfer := map[string]string{"server": cfg.ServerURL, "agent_id": auth.AgentID, "job_id": j.JobID, "archive": filepath.Base(archivePath)}
+	if _, err := evidence.AppendCustody(caseDir, evidence.CustodyUpload, evidence.LocalActor(version.Tool), transfer); err != nil {
+		return err
+	}
 	if err := tarGzDir(caseDir, archivePath); err != nil {
 		return err
 	}
@@ -267,6 +287,10 @@ func tarGzDir(srcDir string, dstFile string) error {
 		}
 		rel, _ := filepath.Rel(root, path)
 		name := filepath.ToSlash(rel)
+		// Originals kept by redaction never leave the host.
+		if name == "restricted" && d.IsDir() {
+			return filepath.SkipDir
+		}
 		if name == "." {
 			name = filepath.Base(root)
 		} else {
@@ -350,6 +374,45 @@ func buildTriageArgs(outputBase string, j job) ([]string, error) {
 		args = append(args, "--ioc-file", p)
 	}
 
+	if v := strings.TrimSpace(j.Args["yara_rules"]); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				args = append(args, "--yara-rules", p)
+			}
+		}
+	}
+	if v := j.Args["yara"]; strings.TrimSpace(v) != "" {
+		p := filepath.Join(outputBase, "rules_"+j.JobID+".yar")
+		if err := os.WriteFile(p, []byte(v), 0o600); err != nil {
+			return nil, err
+		}
+		args = append(args, "--yara-rules", p)
+	}
+	if v := strings.TrimSpace(j.Args["yara_paths"]); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				args = append(args, "--yara-path", p)
+			}
+		}
+	}
+	if v := strings.TrimSpace(j.Args["sigma_rules"]); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				args = append(args, "--sigma-rules", p)
+			}
+		}
+	}
+	if v := strings.TrimSpace(j.Args["baseline"]); v != "" {
+		args = append(args, "--baseline", v)
+	}
+	if v := strings.TrimSpace(j.Args["web_roots"]); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				args = append(args, "--web-root", p)
+			}
+		}
+	}
+
 	if v := strings.TrimSpace(j.Args["snapshot_paths"]); v != "" {
 		for _, p := range strings.Split(v, ",") {
 			p = strings.TrimSpace(p)
@@ -366,6 +429,10 @@ func buildTriageArgs(outputBase string, j job) ([]string, error) {
 		b, _ := strconv.ParseBool(v)
 		args = append(args, "--snapshot-hash="+boolString(b))
 	}
+	if v := strings.TrimSpace(j.Args["snapshot_classify"]); v != "" {
+		b, _ := strconv.ParseBool(v)
+		args = append(args, "--snapshot-classify="+boolString(b))
+	}
 	if v := strings.TrimSpace(j.Args["snapshot_max_file_bytes"]); v != "" {
 		args = append(args, "--snapshot-max-file-bytes", v)
 	}
@@ -375,6 +442,20 @@ func buildTriageArgs(outputBase string, j job) ([]string, error) {
 	if v := strings.TrimSpace(j.Args["snapshot_max_files"]); v != "" {
 		args = append(args, "--snapshot-max-files", v)
 	}
+	if v := strings.TrimSpace(j.Args["redact"]); v != "" {
+		b, _ := strconv.ParseBool(v)
+		args = append(args, "--redact="+boolString(b))
+	}
+	if v := strings.TrimSpace(j.Args["redact_rules"]); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				args = append(args, "--redact-rules", p)
+			}
+		}
+	}
+	if v := strings.TrimSpace(j.Args["redact_originals"]); v != "" {
+		args = append(args, "--redact-originals", v)
+	}
 	if v := strings.TrimSpace(j.Args["timeout"]); v != "" {
 		args = append(args, "--timeout", v)
 	}
@@ -426,6 +507,34 @@ func saveAuth(serverURL string, a authState) error {
 	return os.WriteFile(path, b, 0o600)
 }
 
+// loadSignKey reads the agent's signing key, generating it on first use,
+// and returns the public key in PEM form.
+func loadSignKey(cfg *Config) ([]byte, bool, error) {
+	if cfg.SignKey == "" {
+		path, err := cachePath(cfg.ServerURL)
+		if err != nil {
+			return nil, false, err
+		}
+		cfg.SignKey = strings.TrimSuffix(path, ".json") + ".key"
+	}
+	created := false
+	key, err := evidence.ReadPrivateKey(cfg.SignKey)
+	if errors.Is(err, os.ErrNotExist) {
+		if key, err = evidence.GenerateKey(); err != nil {
+			return nil, false, err
+		}
+		if err = evidence.WritePrivateKey(cfg.SignKey, key); err != nil {
+			return nil, false, err
+		}
+		created = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	pub, err := evidence.MarshalPublicKey(key.Public().(ed25519.PublicKey))
+	return pub, created, err
+}
+
 func cachePath(serverURL string) (string, error) {
 	dir, err := os.UserConfigDir()
 	if err != nil {
@@ -0,0 +1,264 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	maxArchiveDepth = 6
+	// maxSpoolBytes bounds nested zip members copied to a temp file so that
+	// archive/zip can seek in them.
+	maxSpoolBytes = 512 * 1024 * 1024
+	// DefaultBudget caps the decompressed bytes read from one top-level file.
+	DefaultBudget = 8 * 1024 * 1024 * 1024
+)
+
+var ErrLimit = errors.New("archive size limit reached")
+
+type MemberFunc func(name string, r io.Reader) error
+
+// Sniff identifies compressed and archived streams by magic bytes.
+func Sniff(head []byte) string {
+	switch {
+	case len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b:
+		return "gzip"
+	case bytes.HasPrefix(head, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		return "xz"
+	case bytes.HasPrefix(head, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return "zstd"
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")) || bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return "zip"
+	case len(head) >= 262 && string(head[257:262]) == "ustar":
+		return "tar"
+	}
+	return ""
+}
+
+// Walker expands archives recursively and hands every leaf stream to fn,
+// named "outer!inner/path". A compression layer only adds a name element
+// when it wraps a plain file ("syslog.2.gz!syslog.2"), not a tar. The total
+// number of decompressed bytes is capped to defuse decompression bombs.
+// Gzip, tar and zip are read natively; xz and zstd need the xz and zstd
+// binaries on the host. Whatever could not be expanded is listed in Skipped
+// with the reason.
+type Walker struct {
+	Budget  int64
+	Skipped []string
+}
+
+func NewWalker() *Walker {
+	return &Walker{Budget: DefaultBudget}
+}
+
+// WalkFile expands the file at path, naming leaves relative to name. A file
+// that is not an archive is passed to fn as-is under name.
+func (w *Walker) WalkFile(ctx context.Context, name string, path string, fn MemberFunc) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return w.walk(ctx, name, "", f, sizedFile{File: f, size: info.Size()}, 0, fn)
+}
+
+// Walk is WalkFile for an arbitrary stream. Zip archives are spooled to a
+// temporary file since they need random access.
+func (w *Walker) Walk(ctx context.Context, name string, r io.Reader, fn MemberFunc) error {
+	return w.walk(ctx, name, "", r, nil, 0, fn)
+}
+
+func (w *Walker) walk(ctx context.Context, name string, leaf string, r io.Reader, ra readerAtSize, depth int, fn MemberFunc) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, _ := br.Peek(512)
+	format := Sniff(head)
+	if format == "" || depth >= maxArchiveDepth {
+		if leaf != "" {
+			name = leaf
+		}
+		return fn(name, br)
+	}
+
+	switch format {
+	case "gzip":
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return fn(name, br)
+		}
+		defer zr.Close()
+		inner := zr.Name
+		if inner == "" {
+			inner = strings.TrimSuffix(path.Base(name), ".gz")
+		}
+		return w.walk(ctx, name, name+"!"+inner, w.limit(zr), nil, depth+1, fn)
+	case "xz", "zstd":
+		return w.external(ctx, name, format, br, depth, fn)
+	case "tar":
+		tr := tar.NewReader(br)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+				continue
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := w.walk(ctx, name+"!"+hdr.Name, "", tr, nil, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	case "zip":
+		if ra == nil {
+			return w.spoolZip(ctx, name, br, depth, fn)
+		}
+		return w.zip(ctx, name, ra, depth, fn)
+	}
+	return nil
+}
+
+type readerAtSize interface {
+	io.ReaderAt
+	Size() int64
+}
+
+func (w *Walker) zip(ctx context.Context, name string, ra readerAtSize, depth int, fn MemberFunc) error {
+	zr, err := zip.NewReader(ra, ra.Size())
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rc, err := f.Open()
+		if err != nil {
+			w.Skipped = append(w.Skipped, name+"!"+f.Name+": "+err.Error())
+			continue
+		}
+		err = w.walk(ctx, name+"!"+f.Name, "", w.limit(rc), nil, depth+1, fn)
+		_ = rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Walker) spoolZip(ctx context.Context, name string, r io.Reader, depth int, fn MemberFunc) error {
+	tmp, err := os.CreateTemp("", "iron-sentinel-zip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := io.Copy(tmp, io.LimitReader(r, maxSpoolBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxSpoolBytes {
+		w.Skipped = append(w.Skipped, name+": nested zip larger than spool limit")
+		return nil
+	}
+	return w.zip(ctx, name, sizedFile{File: tmp, size: n}, depth, fn)
+}
+
+type sizedFile struct {
+	*os.File
+	size int64
+}
+
+func (f sizedFile) Size() int64 { return f.size }
+
+// external decompresses xz and zstd through the system binaries, which are
+// used when present in the same way evidence hashing uses fast-hash. There
+// is no fallback: without the binary the stream is skipped, and recorded as
+// such. The child is killed as soon as the walk stops early, so that the
+// rest of a decompression bomb is never expanded.
+func (w *Walker) external(ctx context.Context, name string, format string, r io.Reader, depth int, fn MemberFunc) error {
+	bin := format
+	if _, err := exec.LookPath(bin); err != nil {
+		w.Skipped = append(w.Skipped, fmt.Sprintf("%s: %s compressed, %s not installed", name, format, bin))
+		return nil
+	}
+
+	cmd := exec.CommandContext(ctx, bin, "-dc")
+	cmd.Stdin = r
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		w.Skipped = append(w.Skipped, fmt.Sprintf("%s: %s: %v", name, bin, err))
+		return nil
+	}
+	if err := cmd.Start(); err != nil {
+		w.Skipped = append(w.Skipped, fmt.Sprintf("%s: %s: %v", name, bin, err))
+		return nil
+	}
+
+	inner := strings.TrimSuffix(strings.TrimSuffix(path.Base(name), ".xz"), ".zst")
+	werr := w.walk(ctx, name, name+"!"+inner, w.limit(out), nil, depth+1, fn)
+	if werr == nil {
+		werr = ctx.Err()
+	}
+	// Only a child whose output was read to the end is waited for; one that
+	// fn stopped reading early is killed instead of drained.
+	if werr != nil || !drained(out) {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return werr
+	}
+	if err := cmd.Wait(); err != nil {
+		w.Skipped = append(w.Skipped, fmt.Sprintf("%s: %s: %v", name, bin, err))
+	}
+	return nil
+}
+
+func drained(r io.Reader) bool {
+	var b [1]byte
+	n, err := r.Read(b[:])
+	return n == 0 && errors.Is(err, io.EOF)
+}
+
+func (w *Walker) limit(r io.Reader) io.Reader {
+	return &budgetReader{r: r, w: w}
+}
+
+type budgetReader struct {
+	r io.Reader
+	w *Walker
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.w.Budget <= 0 {
+		return 0, ErrLimit
+	}
+	if int64(len(p)) > b.w.Budget {
+		p = p[:b.w.Budget]
+	}
+	n, err := b.r.Read(p)
+	b.w.Budget -= int64(n)
+	return n, err
+}
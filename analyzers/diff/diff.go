@@ -0,0 +1,394 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// Change is one record present in only one state, or present in both with
+// different attributes. Artifact names where the record was read: the after
+// state for added and modified records, the before state for removed ones.
+type Change struct {
+	Kind     string      `json:"kind"`
+	Change   string      `json:"change"`
+	Key      string      `json:"key"`
+	Fields   []string    `json:"fields,omitempty"`
+	Artifact string      `json:"artifact,omitempty"`
+	Before   interface{} `json:"before,omitempty"`
+	After    interface{} `json:"after,omitempty"`
+}
+
+type Counts struct {
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Modified int `json:"modified"`
+}
+
+type Side struct {
+	Source    string `json:"source"`
+	CaseID    string `json:"case_id,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+type Report struct {
+	Before   Side              `json:"before"`
+	After    Side              `json:"after"`
+	Summary  map[string]Counts `json:"summary"`
+	Skipped  map[string]string `json:"skipped,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+	Changes  []Change          `json:"changes"`
+	Finished string            `json:"finished"`
+}
+
+func side(s State) Side {
+	return Side{Source: s.Source, CaseID: s.CaseID, CreatedAt: s.CreatedAt, Hostname: s.Hostname}
+}
+
+// Compare reports what changed from before to after. Kinds missing from
+// either state are skipped rather than reported as wholly added or removed.
+func Compare(before, after State) Report {
+	rep := Report{
+		Before:  side(before),
+		After:   side(after),
+		Summary: map[string]Counts{},
+		Changes: []Change{},
+	}
+	if before.Hostname != "" && after.Hostname != "" && before.Hostname != after.Hostname {
+		rep.Warnings = append(rep.Warnings, fmt.Sprintf("hostnames differ: %s vs %s", before.Hostname, after.Hostname))
+	}
+
+	roots := commonRoots(before.SnapshotRoots, after.SnapshotRoots)
+	for _, kind := range Kinds {
+		_, inBefore := before.Artifacts[kind]
+		_, inAfter := after.Artifacts[kind]
+		switch {
+		case !inBefore && !inAfter:
+			rep.skip(kind, "not collected")
+			continue
+		case !inBefore:
+			rep.skip(kind, "not collected in before")
+			continue
+		case !inAfter:
+			rep.skip(kind, "not collected in after")
+			continue
+		case (kind == KindFile || kind == KindSUID) && len(roots) == 0:
+			rep.skip(kind, "no snapshot path in common")
+			continue
+		}
+
+		var changes []Change
+		switch kind {
+		case KindProcess:
+			changes = compareSet(userProcesses(before.Processes), userProcesses(after.Processes), processKey, nil)
+			markNewImages(changes, before.Processes)
+		case KindListener:
+			changes = compareSet(before.Listeners, after.Listeners, listenerKey, listenerFields)
+		case KindUser:
+			changes = compareSet(before.Users, after.Users, func(u linux.User) string { return u.Name }, userFields)
+		case KindSSHKey:
+			changes = compareSet(before.SSHKeys, after.SSHKeys, sshKeyKey, sshKeyFields)
+		case KindPersistence:
+			changes = compareSet(before.Persistence, after.Persistence, func(e linux.PersistenceEntry) string { return e.Path }, persistenceFields)
+		case KindUnit:
+			changes = compareSet(before.Units, after.Units, func(u linux.Unit) string { return u.Name }, unitFields)
+		case KindPackage:
+			changes = compareSet(before.Packages, after.Packages, packageKey, packageFields)
+		case KindSUID:
+			changes = compareSet(snapshotFiles(before.Files, roots, true), snapshotFiles(after.Files, roots, true), snapshotKey, fileFields)
+		case KindFile:
+			changes = compareSet(snapshotFiles(before.Files, roots, false), snapshotFiles(after.Files, roots, false), snapshotKey, fileFields)
+		}
+
+		var n Counts
+		for i := range changes {
+			changes[i].Kind = kind
+			switch changes[i].Change {
+			case Added:
+				n.Added++
+				changes[i].Artifact = after.Artifacts[kind]
+			case Removed:
+				n.Removed++
+				changes[i].Artifact = before.Artifacts[kind]
+			case Modified:
+				n.Modified++
+				changes[i].Artifact = after.Artifacts[kind]
+			}
+		}
+		rep.Summary[kind] = n
+		rep.Changes = append(rep.Changes, changes...)
+	}
+	rep.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return rep
+}
+
+func (r *Report) skip(kind, reason string) {
+	if r.Skipped == nil {
+		r.Skipped = map[string]string{}
+	}
+	r.Skipped[kind] = reason
+}
+
+// compareSet matches records by key. Duplicate keys, such as several worker
+// processes with the same command line, collapse to the first record.
+func compareSet[T any](before, after []T, key func(T) string, fields func(a, b T) []string) []Change {
+	index := func(recs []T) (map[string]T, []string) {
+		m := map[string]T{}
+		var keys []string
+		for _, r := range recs {
+			k := key(r)
+			if _, dup := m[k]; dup {
+				continue
+			}
+			m[k] = r
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return m, keys
+	}
+	bm, bkeys := index(before)
+	am, akeys := index(after)
+
+	var out []Change
+	for _, k := range akeys {
+		a := am[k]
+		b, ok := bm[k]
+		if !ok {
+			out = append(out, Change{Change: Added, Key: k, After: a})
+			continue
+		}
+		if fields == nil {
+			continue
+		}
+		if f := fields(b, a); len(f) > 0 {
+			out = append(out, Change{Change: Modified, Key: k, Fields: f, Before: b, After: a})
+		}
+	}
+	for _, k := range bkeys {
+		if _, ok := am[k]; !ok {
+			out = append(out, Change{Change: Removed, Key: k, Before: bm[k]})
+		}
+	}
+	return out
+}
+
+// userProcesses drops kernel threads, whose workers come and go constantly.
+func userProcesses(procs []linux.Process) []linux.Process {
+	var out []linux.Process
+	for _, p := range procs {
+		if !p.KernelThread {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// markNewImages flags added processes whose executable did not run at all
+// in the before state with Fields ["exe"]; other added processes are new
+// invocations of software that was already running.
+func markNewImages(changes []Change, before []linux.Process) {
+	ran := map[string]bool{}
+	for _, p := range before {
+		ran[p.Exe] = true
+	}
+	for i, c := range changes {
+		if p, ok := c.After.(linux.Process); ok && c.Change == Added && p.Exe != "" && !ran[p.Exe] {
+			changes[i].Fields = []string{"exe"}
+		}
+	}
+}
+
+// processKey identifies a process by image and command line; PIDs are not
+// stable across collections.
+func processKey(p linux.Process) string {
+	exe := p.Exe
+	if exe == "" {
+		exe = "[" + p.Name + "]"
+	}
+	return strings.TrimSpace(exe + " " + strings.Join(p.Cmdline, " "))
+}
+
+func listenerKey(s linux.Socket) string {
+	return fmt.Sprintf("%s %s:%d", s.Proto, s.LocalAddr, s.LocalPort)
+}
+
+func listenerFields(a, b linux.Socket) []string {
+	var f []string
+	if a.Process != b.Process {
+		f = append(f, "process")
+	}
+	if a.UID != b.UID {
+		f = append(f, "uid")
+	}
+	return f
+}
+
+func userFields(a, b linux.User) []string {
+	var f []string
+	if a.UID != b.UID {
+		f = append(f, "uid")
+	}
+	if a.GID != b.GID {
+		f = append(f, "gid")
+	}
+	if a.Home != b.Home {
+		f = append(f, "home")
+	}
+	if a.Shell != b.Shell {
+		f = append(f, "shell")
+	}
+	if strings.Join(a.Groups, ",") != strings.Join(b.Groups, ",") {
+		f = append(f, "groups")
+	}
+	if a.Password != b.Password && a.Password != "unknown" && b.Password != "unknown" {
+		f = append(f, "password")
+	}
+	return f
+}
+
+func sshKeyKey(k linux.SSHKey) string {
+	return k.User + " " + k.Fingerprint
+}
+
+func sshKeyFields(a, b linux.SSHKey) []string {
+	var f []string
+	if a.Options != b.Options {
+		f = append(f, "options")
+	}
+	if a.Path != b.Path {
+		f = append(f, "path")
+	}
+	return f
+}
+
+func persistenceFields(a, b linux.PersistenceEntry) []string {
+	var f []string
+	if a.SHA256 != b.SHA256 {
+		f = append(f, "sha256")
+	}
+	if a.Target != b.Target {
+		f = append(f, "target")
+	}
+	if a.Mode != b.Mode {
+		f = append(f, "mode")
+	}
+	if a.UID != b.UID {
+		f = append(f, "uid")
+	}
+	if a.ModTime != b.ModTime {
+		f = append(f, "mod_time")
+	}
+	return f
+}
+
+func unitFields(a, b linux.Unit) []string {
+	var f []string
+	if a.SHA256 != b.SHA256 {
+		f = append(f, "sha256")
+	}
+	if a.Path != b.Path {
+		f = append(f, "path")
+	}
+	if a.Enabled != b.Enabled {
+		f = append(f, "enabled")
+	}
+	if a.Masked != b.Masked {
+		f = append(f, "masked")
+	}
+	if strings.Join(a.ExecStart, "\n") != strings.Join(b.ExecStart, "\n") {
+		f = append(f, "exec_start")
+	}
+	return f
+}
+
+// packageKey includes the architecture because multiarch hosts install the
+// same package name more than once.
+func packageKey(p linux.Package) string {
+	if p.Arch == "" {
+		return p.Name
+	}
+	return p.Name + ":" + p.Arch
+}
+
+func packageFields(a, b linux.Package) []string {
+	if a.Version != b.Version {
+		return []string{"version"}
+	}
+	return nil
+}
+
+func snapshotKey(e linux.SnapshotEntry) string { return e.Path }
+
+// fileFields compares content by hash when both sides hashed the file and
+// falls back to size otherwise.
+func fileFields(a, b linux.SnapshotEntry) []string {
+	var f []string
+	if a.SHA256 != "" && b.SHA256 != "" {
+		if a.SHA256 != b.SHA256 {
+			f = append(f, "sha256")
+		}
+	} else if a.SizeBytes != b.SizeBytes {
+		f = append(f, "size")
+	}
+	if a.Mode != b.Mode {
+		f = append(f, "mode")
+	}
+	if a.UID != b.UID {
+		f = append(f, "uid")
+	}
+	if a.ModTime != b.ModTime {
+		f = append(f, "mod_time")
+	}
+	return f
+}
+
+// snapshotFiles selects files and symlinks under roots. Directory mtimes
+// change with every entry added to them and are left out. setID selects
+// setuid/setgid files; the plain file comparison excludes them so each
+// change is reported once.
+func snapshotFiles(entries []linux.SnapshotEntry, roots []string, setID bool) []linux.SnapshotEntry {
+	var out []linux.SnapshotEntry
+	for _, e := range entries {
+		if e.Type != "file" && e.Type != "symlink" {
+			continue
+		}
+		if isSetID(e.Mode) != setID || !underRoots(e.Path, roots) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func commonRoots(a, b []string) []string {
+	var out []string
+	for _, r := range a {
+		for _, o := range b {
+			if r == o {
+				out = append(out, r)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func underRoots(p string, roots []string) bool {
+	for _, r := range roots {
+		r = strings.TrimSuffix(r, "/")
+		if p == r || strings.HasPrefix(p, r+"/") || r == "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package diff
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"iron-sentinel/analyzers/persistence"
+	"iron-sentinel/collectors/linux"
+	"iron-sentinel/findings"
+)
+
+// maxSummaryEvidence bounds the evidence listed on summary findings.
+const maxSummaryEvidence = 20
+
+// Findings turns changes between two cases that matter for an
+// investigation into findings: new accounts, keys, persistence, units, SUID
+// files and listeners, changed file content and process images that were
+// not running before. Added and removed snapshot files are summarized in one
+// finding each, and removed records other than those are left to the report.
+func Findings(rep Report) []findings.Finding {
+	return toFindings("diff", rep, false)
+}
+
+// DriftFindings reports every difference from a baseline. On top of what
+// Findings reports, removals and package changes become low severity
+// findings, since a golden image should not drift at all.
+func DriftFindings(rep Report) []findings.Finding {
+	return toFindings("baseline", rep, true)
+}
+
+func toFindings(analyzer string, rep Report, drift bool) []findings.Finding {
+	var out []findings.Finding
+	add := func(c Change, rule string, sev findings.Severity, title string, attack []string, t string) {
+		f := findings.Finding{
+			Analyzer:    analyzer,
+			Rule:        analyzer + "." + rule,
+			Severity:    sev,
+			Confidence:  findings.ConfidenceHigh,
+			Title:       title,
+			Description: describe(rep, c),
+			Time:        t,
+			Evidence:    []findings.Evidence{evidenceFor(c)},
+			Attack:      attack,
+			Metadata:    map[string]string{"kind": c.Kind, "change": c.Change, "before": rep.Before.Source},
+		}
+		if len(c.Fields) > 0 {
+			f.Metadata["fields"] = strings.Join(c.Fields, ",")
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+
+	seenImages := map[string]bool{}
+	var addedFiles, removedFiles []Change
+	for _, c := range rep.Changes {
+		switch c.Kind {
+		case KindUser:
+			u, _ := c.After.(linux.User)
+			switch c.Change {
+			case Added:
+				sev := findings.SeverityHigh
+				if u.UID == 0 {
+					sev = findings.SeverityCritical
+				}
+				add(c, "user_added", sev, fmt.Sprintf("New user account %s (uid %d)", u.Name, u.UID), []string{"T1136.001"}, "")
+			case Modified:
+				sev := findings.SeverityMedium
+				if u.UID == 0 && slices.Contains(c.Fields, "uid") {
+					sev = findings.SeverityCritical
+				}
+				add(c, "user_modified", sev, fmt.Sprintf("User account %s changed (%s)", u.Name, strings.Join(c.Fields, ", ")), []string{"T1098"}, "")
+			}
+		case KindSSHKey:
+			k, _ := c.After.(linux.SSHKey)
+			switch c.Change {
+			case Added:
+				add(c, "ssh_key_added", findings.SeverityHigh, fmt.Sprintf("New authorized SSH key for %s (%s)", k.User, k.Fingerprint), []string{"T1098.004"}, "")
+			case Modified:
+				add(c, "ssh_key_modified", findings.SeverityMedium, fmt.Sprintf("Authorized SSH key for %s changed (%s)", k.User, strings.Join(c.Fields, ", ")), []string{"T1098.004"}, "")
+			}
+		case KindPersistence:
+			e, _ := c.After.(linux.PersistenceEntry)
+			switch c.Change {
+			case Added:
+				add(c, "persistence_added", findings.SeverityHigh, "New persistence entry "+e.Path, persistenceAttack(e), e.ModTime)
+			case Modified:
+				add(c, "persistence_modified", findings.SeverityMedium, fmt.Sprintf("Persistence entry %s changed (%s)", e.Path, strings.Join(c.Fields, ", ")), persistenceAttack(e), e.ModTime)
+			}
+		case KindUnit:
+			u, _ := c.After.(linux.Unit)
+			switch c.Change {
+			case Added:
+				sev := findings.SeverityMedium
+				if u.Enabled {
+					sev = findings.SeverityHigh
+				}
+				add(c, "unit_added", sev, "New systemd unit "+u.Name, []string{"T1543.002"}, u.ModTime)
+			case Modified:
+				add(c, "unit_modified", findings.SeverityMedium, fmt.Sprintf("Systemd unit %s changed (%s)", u.Name, strings.Join(c.Fields, ", ")), []string{"T1543.002"}, u.ModTime)
+			}
+		case KindPackage:
+			p, _ := c.After.(linux.Package)
+			switch {
+			case c.Change == Added:
+				add(c, "package_added", findings.SeverityLow, fmt.Sprintf("New package %s %s", p.Name, p.Version), nil, "")
+			case c.Change == Modified && drift:
+				old, _ := c.Before.(linux.Package)
+				add(c, "package_changed", findings.SeverityLow, fmt.Sprintf("Package %s changed from %s to %s", p.Name, old.Version, p.Version), nil, "")
+			}
+		case KindSUID:
+			e, _ := c.After.(linux.SnapshotEntry)
+			switch c.Change {
+			case Added:
+				add(c, "suid_added", findings.SeverityHigh, "New setuid/setgid file "+e.Path, []string{"T1548.001"}, e.ModTime)
+			case Modified:
+				add(c, "suid_modified", findings.SeverityHigh, fmt.Sprintf("Setuid/setgid file %s changed (%s)", e.Path, strings.Join(c.Fields, ", ")), []string{"T1548.001"}, e.ModTime)
+			}
+		case KindListener:
+			s, _ := c.After.(linux.Socket)
+			if c.Change == Added {
+				title := fmt.Sprintf("New listening socket %s %s:%d", s.Proto, s.LocalAddr, s.LocalPort)
+				if s.Process != "" {
+					title += fmt.Sprintf(" (%s, pid %d)", s.Process, s.PID)
+				}
+				add(c, "listener_added", findings.SeverityMedium, title, nil, "")
+			}
+		case KindProcess:
+			p, _ := c.After.(linux.Process)
+			if c.Change == Added && slices.Contains(c.Fields, "exe") && !seenImages[p.Exe] {
+				seenImages[p.Exe] = true
+				add(c, "process_image_new", findings.SeverityLow, "Process image not running before: "+p.Exe, nil, p.StartTime)
+			}
+		case KindFile:
+			e, _ := c.After.(linux.SnapshotEntry)
+			switch c.Change {
+			case Added:
+				addedFiles = append(addedFiles, c)
+			case Removed:
+				removedFiles = append(removedFiles, c)
+			case Modified:
+				if slices.Contains(c.Fields, "sha256") || slices.Contains(c.Fields, "size") {
+					add(c, "file_content_changed", findings.SeverityMedium, "File content changed: "+e.Path, nil, e.ModTime)
+				}
+			}
+		}
+	}
+
+	if drift {
+		for _, c := range rep.Changes {
+			if c.Change == Removed && c.Kind != KindFile && c.Kind != KindProcess {
+				add(c, c.Kind+"_removed", findings.SeverityLow, fmt.Sprintf("%s %s removed", c.Kind, c.Key), nil, "")
+			}
+		}
+	}
+
+	for _, group := range []struct {
+		changes []Change
+		rule    string
+		verb    string
+	}{
+		{addedFiles, "files_added", "added"},
+		{removedFiles, "files_removed", "removed"},
+	} {
+		if len(group.changes) == 0 {
+			continue
+		}
+		f := findings.Finding{
+			Analyzer:    analyzer,
+			Rule:        analyzer + "." + group.rule,
+			Severity:    findings.SeverityInfo,
+			Confidence:  findings.ConfidenceHigh,
+			Title:       fmt.Sprintf("%d snapshot files %s", len(group.changes), group.verb),
+			Description: fmt.Sprintf("Compared with %s; the diff report lists every file.", rep.Before.Source),
+			Metadata:    map[string]string{"kind": KindFile, "change": group.verb, "count": fmt.Sprintf("%d", len(group.changes)), "before": rep.Before.Source},
+		}
+		for i, c := range group.changes {
+			if i == maxSummaryEvidence {
+				break
+			}
+			f.Evidence = append(f.Evidence, evidenceFor(c))
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
+
+// evidenceFor points at the record in this case. Removed records only exist
+// in the before state, which metadata.before names.
+func evidenceFor(c Change) findings.Evidence {
+	if c.Change == Removed {
+		return findings.Evidence{Path: c.Key}
+	}
+	return findings.Evidence{Artifact: c.Artifact, Path: c.Key}
+}
+
+func describe(rep Report, c Change) string {
+	against := rep.Before.Source
+	if rep.Before.CaseID != "" {
+		against = fmt.Sprintf("case %s (%s)", rep.Before.CaseID, rep.Before.Source)
+	}
+	if c.Change == Modified {
+		return fmt.Sprintf("%s %s differs from %s in: %s.", c.Kind, c.Key, against, strings.Join(c.Fields, ", "))
+	}
+	return fmt.Sprintf("%s %s is %s compared with %s.", c.Kind, c.Key, c.Change, against)
+}
+
+func persistenceAttack(e linux.PersistenceEntry) []string {
+	return persistence.KindAttack(e.Kind)
+}
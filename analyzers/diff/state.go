@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	KindProcess     = "process"
+	KindListener    = "listener"
+	KindUser        = "user"
+	KindSSHKey      = "ssh_key"
+	KindPersistence = "persistence"
+	KindUnit        = "unit"
+	KindPackage     = "package"
+	KindSUID        = "suid"
+	KindFile        = "file"
+)
+
+// Kinds lists the compared record kinds in report order.
+var Kinds = []string{KindUser, KindSSHKey, KindPersistence, KindUnit, KindSUID, KindListener, KindPackage, KindProcess, KindFile}
+
+// State is the comparable view of one collection. Artifacts maps each kind
+// to the artifact it was read from; a kind without an artifact was not
+// collected and is not compared.
+type State struct {
+	Source        string                   `json:"source"`
+	CaseID        string                   `json:"case_id,omitempty"`
+	CreatedAt     string                   `json:"created_at,omitempty"`
+	Hostname      string                   `json:"hostname,omitempty"`
+	Artifacts     map[string]string        `json:"artifacts"`
+	Processes     []linux.Process          `json:"processes,omitempty"`
+	Listeners     []linux.Socket           `json:"listeners,omitempty"`
+	Users         []linux.User             `json:"users,omitempty"`
+	SSHKeys       []linux.SSHKey           `json:"ssh_keys,omitempty"`
+	Persistence   []linux.PersistenceEntry `json:"persistence,omitempty"`
+	Units         []linux.Unit             `json:"units,omitempty"`
+	Packages      []linux.Package          `json:"packages,omitempty"`
+	SnapshotRoots []string                 `json:"snapshot_roots,omitempty"`
+	Files         []linux.SnapshotEntry    `json:"files,omitempty"`
+}
+
+// Load reads the structured collector output of a case. Only the latest
+// artifact of each collector is used.
+func Load(ctx context.Context, dir string, artifacts []collectors.Artifact) (State, error) {
+	s := State{Source: dir, Artifacts: map[string]string{}}
+
+	load := func(kind, collector, name string, fn func(json.RawMessage) error) error {
+		a, ok, err := collectors.ReadLatest(ctx, dir, artifacts, collector, name, func(line []byte) error {
+			return fn(json.RawMessage(line))
+		})
+		if err != nil || !ok {
+			return err
+		}
+		s.Artifacts[kind] = a.RelativePath
+		return nil
+	}
+
+	if a, ok := collectors.Latest(artifacts, "host_info", "host_info.json"); ok {
+		var info map[string]string
+		if b, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(a.RelativePath))); err == nil && json.Unmarshal(b, &info) == nil {
+			s.Hostname = info["hostname"]
+		}
+	}
+
+	err := load(KindProcess, "processes", "processes.jsonl", func(raw json.RawMessage) error {
+		var p linux.Process
+		if err := json.Unmarshal(raw, &p); err != nil {
+			return err
+		}
+		s.Processes = append(s.Processes, p)
+		return nil
+	})
+	if err == nil {
+		err = load(KindListener, "sockets", "sockets.jsonl", func(raw json.RawMessage) error {
+			var so linux.Socket
+			if err := json.Unmarshal(raw, &so); err != nil {
+				return err
+			}
+			if so.Listening() {
+				s.Listeners = append(s.Listeners, so)
+			}
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindUser, "accounts", "users.jsonl", func(raw json.RawMessage) error {
+			var u linux.User
+			if err := json.Unmarshal(raw, &u); err != nil {
+				return err
+			}
+			s.Users = append(s.Users, u)
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindSSHKey, "accounts", "ssh_keys.jsonl", func(raw json.RawMessage) error {
+			var k linux.SSHKey
+			if err := json.Unmarshal(raw, &k); err != nil {
+				return err
+			}
+			s.SSHKeys = append(s.SSHKeys, k)
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindPersistence, "persistence", "entries.jsonl", func(raw json.RawMessage) error {
+			var e linux.PersistenceEntry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return err
+			}
+			s.Persistence = append(s.Persistence, e)
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindUnit, "units", "units.jsonl", func(raw json.RawMessage) error {
+			var u linux.Unit
+			if err := json.Unmarshal(raw, &u); err != nil {
+				return err
+			}
+			s.Units = append(s.Units, u)
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindPackage, "packages", "packages.jsonl", func(raw json.RawMessage) error {
+			var p linux.Package
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return err
+			}
+			s.Packages = append(s.Packages, p)
+			return nil
+		})
+	}
+	if err == nil {
+		err = load(KindFile, "fs_snapshot", "metadata.jsonl", func(raw json.RawMessage) error {
+			var e linux.SnapshotEntry
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return err
+			}
+			s.Files = append(s.Files, e)
+			return nil
+		})
+	}
+	if err != nil {
+		return State{}, err
+	}
+	if rel, ok := s.Artifacts[KindFile]; ok {
+		// SUID files come from the snapshot, so they cover the same roots.
+		s.Artifacts[KindSUID] = rel
+		if a, ok := collectors.Latest(artifacts, "fs_snapshot", "metadata.jsonl"); ok && a.Metadata["paths"] != "" {
+			s.SnapshotRoots = strings.Split(a.Metadata["paths"], ",")
+		}
+	}
+	return s, nil
+}
+
+// isSetID reports whether a Go file mode string ("urwxr-xr-x") carries the
+// setuid or setgid bit.
+func isSetID(mode string) bool {
+	if len(mode) < 9 {
+		return false
+	}
+	return strings.ContainsAny(mode[:len(mode)-9], "ug")
+}
@@ -0,0 +1,277 @@
+package elfscan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	maxImports = 200
+	maxStrings = 50
+	// packedEntropy is the section entropy above which code is treated as
+	// compressed or encrypted; compiled code rarely exceeds 6.5 bits/byte.
+	packedEntropy = 7.2
+)
+
+type Section struct {
+	Name    string  `json:"name"`
+	Size    uint64  `json:"size"`
+	Entropy float64 `json:"entropy"`
+}
+
+// Binary describes one inspected executable. Path is the file on the host and
+// Artifact the case artifact that led to it, if any.
+type Binary struct {
+	Path        string    `json:"path"`
+	Artifact    string    `json:"artifact,omitempty"`
+	Sources     []string  `json:"sources,omitempty"`
+	SHA256      string    `json:"sha256"`
+	Size        int64     `json:"size"`
+	Class       string    `json:"class"`
+	Arch        string    `json:"arch"`
+	Type        string    `json:"type"`
+	Executable  bool      `json:"executable"`
+	PIE         bool      `json:"pie,omitempty"`
+	Static      bool      `json:"static"`
+	Interpreter string    `json:"interpreter,omitempty"`
+	Libraries   []string  `json:"libraries,omitempty"`
+	Imports     []string  `json:"imports,omitempty"`
+	ImportCount int       `json:"import_count"`
+	Stripped    bool      `json:"stripped"`
+	Go          bool      `json:"go,omitempty"`
+	Entropy     float64   `json:"entropy"`
+	Sections    []Section `json:"sections,omitempty"`
+	Packer      string    `json:"packer,omitempty"`
+	URLs        []string  `json:"urls,omitempty"`
+	IPs         []string  `json:"ips,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+func isELF(head []byte) bool {
+	return len(head) >= 4 && string(head[:4]) == "\x7fELF"
+}
+
+// Inspect parses an ELF image held in memory. debug/elf is not hardened
+// against hostile input, so a panic is reported as a parse error.
+func Inspect(data []byte) (b Binary, err error) {
+	sum := sha256.Sum256(data)
+	b.SHA256 = hex.EncodeToString(sum[:])
+	b.Size = int64(len(data))
+	b.Entropy = round(linux.Entropy(data))
+	b.URLs, b.IPs = embedded(data)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed ELF: %v", r)
+		}
+	}()
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return b, err
+	}
+	defer f.Close()
+
+	b.Class = f.Class.String()
+	b.Arch = strings.TrimPrefix(f.Machine.String(), "EM_")
+	b.Type = strings.TrimPrefix(f.Type.String(), "ET_")
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_INTERP {
+			if raw, err := io.ReadAll(p.Open()); err == nil {
+				b.Interpreter = strings.TrimRight(string(raw), "\x00")
+			}
+		}
+	}
+	b.Libraries, _ = f.ImportedLibraries()
+	// ET_DYN without an interpreter is a shared library unless DT_FLAGS_1
+	// marks it as a static PIE.
+	staticPIE := false
+	if flags, err := f.DynValue(elf.DT_FLAGS_1); err == nil && len(flags) > 0 {
+		staticPIE = b.Interpreter == "" && flags[0]&uint64(elf.DF_1_PIE) != 0
+	}
+	b.Executable = f.Type == elf.ET_EXEC || (f.Type == elf.ET_DYN && (b.Interpreter != "" || staticPIE))
+	b.Static = b.Executable && b.Interpreter == "" && len(b.Libraries) == 0
+	b.PIE = f.Type == elf.ET_DYN && b.Executable
+
+	if syms, err := f.ImportedSymbols(); err == nil {
+		b.ImportCount = len(syms)
+		seen := map[string]bool{}
+		for _, s := range syms {
+			if !seen[s.Name] {
+				seen[s.Name] = true
+				b.Imports = append(b.Imports, s.Name)
+			}
+		}
+		sort.Strings(b.Imports)
+		if len(b.Imports) > maxImports {
+			b.Imports = b.Imports[:maxImports]
+		}
+	}
+
+	b.Stripped = f.Section(".symtab") == nil
+	upxSections := false
+	for _, s := range f.Sections {
+		switch s.Name {
+		case ".go.buildinfo", ".gopclntab", ".note.go.buildid":
+			b.Go = true
+		case "UPX0", "UPX1", "UPX2":
+			upxSections = true
+		}
+		if s.Type == elf.SHT_NOBITS || s.Size == 0 {
+			continue
+		}
+		sec := Section{Name: s.Name, Size: s.Size}
+		if raw, err := s.Data(); err == nil {
+			sec.Entropy = round(linux.Entropy(raw))
+		}
+		b.Sections = append(b.Sections, sec)
+	}
+	b.Packer = packer(data, f, upxSections, b.Sections)
+	return b, nil
+}
+
+// packer recognizes UPX by its section names or the "UPX!" marker UPX
+// leaves near the start and end of the file. Otherwise a high entropy .text
+// section, or a high entropy image without section headers, is what
+// self-extracting stubs look like.
+func packer(data []byte, f *elf.File, upxSections bool, sections []Section) string {
+	head := data
+	if len(head) > 4096 {
+		head = head[:4096]
+	}
+	tail := data
+	if len(tail) > 4096 {
+		tail = tail[len(tail)-4096:]
+	}
+	switch {
+	case upxSections, bytes.Contains(head, []byte("UPX!")), bytes.Contains(tail, []byte("UPX!")):
+		return "UPX"
+	case bytes.Contains(head, []byte("$Info: This file is packed with the UPX")):
+		return "UPX"
+	}
+	for _, s := range sections {
+		if (s.Name == ".text" || s.Name == "") && s.Entropy >= packedEntropy {
+			return "unknown (high entropy code)"
+		}
+	}
+	if len(f.Sections) <= 1 && linux.Entropy(data) >= packedEntropy {
+		return "unknown (no sections, high entropy)"
+	}
+	return ""
+}
+
+func round(f float64) float64 {
+	return math.Round(f*1000) / 1000
+}
+
+var (
+	urlRe  = regexp.MustCompile(`(?i)\b(?:https?|ftp|stratum\+tcp|stratum\+ssl|tcp|udp)://[a-z0-9._~:/?#@!$&'()*+,;=%-]{4,200}`)
+	ipv4Re = regexp.MustCompile(`(?:[0-9]{1,3}\.){3}[0-9]{1,3}(?::[0-9]{1,5})?`)
+)
+
+// embedded extracts URLs and IPv4 addresses from the printable strings of
+// the image. Unspecified and loopback addresses are common constants and are
+// ignored.
+func embedded(data []byte) ([]string, []string) {
+	var urls, ips []string
+	seenURL := map[string]bool{}
+	seenIP := map[string]bool{}
+	forEachString(data, 6, func(s []byte) bool {
+		for _, m := range urlRe.FindAll(s, -1) {
+			u := string(m)
+			if !seenURL[u] && len(urls) < maxStrings {
+				seenURL[u] = true
+				urls = append(urls, u)
+			}
+		}
+		for _, loc := range ipv4Re.FindAllIndex(s, -1) {
+			m := s[loc[0]:loc[1]]
+			if !standaloneIPv4(s, loc[0], loc[1]) {
+				continue
+			}
+			host, _, _ := strings.Cut(string(m), ":")
+			if !validOctets(host) {
+				continue
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || seenIP[string(m)] {
+				continue
+			}
+			if len(ips) < maxStrings {
+				seenIP[string(m)] = true
+				ips = append(ips, string(m))
+			}
+		}
+		return len(urls) < maxStrings || len(ips) < maxStrings
+	})
+	return urls, ips
+}
+
+// standaloneIPv4 rejects matches that are part of a longer dotted number,
+// such as an OID (1.3.6.1.4.1) or a version string (5.4.62.5.1), or of a
+// word. A sentence-ending dot is allowed.
+func standaloneIPv4(s []byte, start, end int) bool {
+	if start > 0 && (isAlnum(s[start-1]) || s[start-1] == '.') {
+		return false
+	}
+	if end < len(s) {
+		if isAlnum(s[end]) || s[end] == ':' {
+			return false
+		}
+		if s[end] == '.' && end+1 < len(s) && isAlnum(s[end+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// validOctets reports whether every octet of a dotted quad is at most 255
+// and written without leading zeros.
+func validOctets(host string) bool {
+	for _, o := range strings.Split(host, ".") {
+		if len(o) > 1 && o[0] == '0' {
+			return false
+		}
+		n, err := strconv.Atoi(o)
+		if err != nil || n > 255 {
+			return false
+		}
+	}
+	return true
+}
+
+// forEachString calls fn for every run of at least min printable ASCII
+// bytes.
+func forEachString(data []byte, min int, fn func([]byte) bool) {
+	start := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && data[i] >= 0x20 && data[i] < 0x7f {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= min {
+			if !fn(data[start:i]) {
+				return
+			}
+		}
+		start = -1
+	}
+}
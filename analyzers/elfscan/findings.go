@@ -0,0 +1,107 @@
+package elfscan
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// unusual reports whether binaries at p are out of place: scratch
+// directories, home directories, or anything under a hidden directory.
+func unusual(p string) bool {
+	if inScratch(p) || strings.HasPrefix(p, "/home/") || strings.HasPrefix(p, "/root/") {
+		return true
+	}
+	parts := strings.Split(p, "/")
+	for _, part := range parts[:len(parts)-1] {
+		if strings.HasPrefix(part, ".") && part != "." && part != ".." {
+			return true
+		}
+	}
+	return false
+}
+
+func inScratch(p string) bool {
+	for _, d := range ScratchDirs {
+		if strings.HasPrefix(p, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Findings flags packed binaries anywhere, statically linked executables in
+// unusual locations and any executable or shared library in a scratch
+// directory. Each binary yields at most one finding, for its most severe
+// trait.
+func Findings(res Result) []findings.Finding {
+	var out []findings.Finding
+	for _, b := range res.Binaries {
+		if b.Error != "" && b.Class == "" {
+			continue
+		}
+		f := findings.Finding{
+			Analyzer: "elf",
+			Evidence: []findings.Evidence{{Artifact: b.Artifact, Path: b.Path, SHA256: b.SHA256}},
+		}
+		switch {
+		case b.Packer != "":
+			f.Rule = "elf.packed"
+			f.Severity = findings.SeverityHigh
+			f.Confidence = findings.ConfidenceLow
+			if b.Packer == "UPX" {
+				f.Confidence = findings.ConfidenceHigh
+			}
+			f.Title = "Packed executable: " + b.Path
+			f.Description = fmt.Sprintf("%s appears packed (%s, entropy %.2f).", b.Path, b.Packer, b.Entropy)
+			f.Attack = []string{"T1027.002"}
+		case b.Static && unusual(b.Path):
+			f.Rule = "elf.static_unusual_location"
+			f.Severity = findings.SeverityHigh
+			f.Confidence = findings.ConfidenceMedium
+			if b.Go {
+				// Go toolchains link statically by default.
+				f.Severity = findings.SeverityMedium
+				f.Confidence = findings.ConfidenceLow
+			}
+			f.Title = "Statically linked executable in unusual location: " + b.Path
+			f.Description = fmt.Sprintf("%s is a statically linked %s executable outside the system binary directories.", b.Path, b.Arch)
+		case inScratch(b.Path) && (b.Type == "EXEC" || b.Type == "DYN"):
+			f.Rule = "elf.scratch_dir"
+			f.Severity = findings.SeverityMedium
+			f.Confidence = findings.ConfidenceMedium
+			f.Title = "Executable in scratch directory: " + b.Path
+			f.Description = fmt.Sprintf("%s is a %s executable in a world-writable directory.", b.Path, b.Arch)
+		default:
+			continue
+		}
+		if len(b.URLs) > 0 || len(b.IPs) > 0 {
+			f.Description += " Embedded network indicators: " + strings.Join(append(append([]string{}, b.URLs...), b.IPs...), ", ") + "."
+		}
+		f.Metadata = map[string]string{
+			"arch":     b.Arch,
+			"static":   fmt.Sprintf("%t", b.Static),
+			"stripped": fmt.Sprintf("%t", b.Stripped),
+			"entropy":  fmt.Sprintf("%.3f", b.Entropy),
+		}
+		if b.Packer != "" {
+			f.Metadata["packer"] = b.Packer
+		}
+		if b.Interpreter != "" {
+			f.Metadata["interpreter"] = b.Interpreter
+		}
+		if len(b.Sources) > 0 {
+			f.Metadata["sources"] = strings.Join(b.Sources, ",")
+		}
+		if len(b.URLs) > 0 {
+			f.Metadata["urls"] = strings.Join(b.URLs, ",")
+		}
+		if len(b.IPs) > 0 {
+			f.Metadata["ips"] = strings.Join(b.IPs, ",")
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
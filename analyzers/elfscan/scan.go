@@ -0,0 +1,327 @@
+package elfscan
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"iron-sentinel/analyzers/archive"
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	DefaultMaxFileSize = 64 * 1024 * 1024
+	// maxWalkEntries bounds each live directory walk.
+	maxWalkEntries = 200000
+)
+
+// ScratchDirs are world-writable locations legitimate software rarely runs
+// binaries from.
+var ScratchDirs = []string{"/tmp", "/var/tmp", "/dev/shm"}
+
+type Options struct {
+	// Live allows reading binaries from the host itself: process images,
+	// unit and persistence ExecStart targets, ScratchDirs and home directories. Only set it
+	// when analyzing on the host the case was collected from.
+	Live        bool
+	Workers     int
+	MaxFileSize int64
+}
+
+type Result struct {
+	Binaries []Binary `json:"binaries"`
+	Scanned  int      `json:"scanned"`
+	Live     bool     `json:"live"`
+	Skipped  []string `json:"skipped,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Finished string   `json:"finished"`
+}
+
+type candidate struct {
+	path     string
+	artifact string
+	sources  []string
+}
+
+// ScanArtifacts inspects ELF files inside the snapshot tarball and, in live
+// mode, the executables the case points at on the host.
+func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	res := Result{Binaries: []Binary{}, Live: opts.Live}
+
+	for _, a := range artifacts {
+		if a.Collector != "fs_snapshot" || path.Base(a.RelativePath) != "files.tar.gz" {
+			continue
+		}
+		bins, skipped, err := scanArchive(ctx, outDir, a, opts.MaxFileSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
+		}
+		res.Scanned += len(bins)
+		res.Binaries = append(res.Binaries, bins...)
+		res.Skipped = append(res.Skipped, skipped...)
+	}
+
+	if opts.Live {
+		cands, errs := liveCandidates(ctx, outDir, artifacts)
+		res.Errors = append(res.Errors, errs...)
+
+		results := make([]Binary, len(cands))
+		ok := make([]bool, len(cands))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					b, inspected, msg := inspectLive(cands[i], opts.MaxFileSize)
+					results[i], ok[i] = b, inspected
+					if msg != "" {
+						mu.Lock()
+						res.Skipped = append(res.Skipped, msg)
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+	feed:
+		for i := range cands {
+			select {
+			case <-ctx.Done():
+				break feed
+			case jobs <- i:
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		for i, b := range results {
+			if ok[i] {
+				res.Scanned++
+				res.Binaries = append(res.Binaries, b)
+			}
+		}
+	}
+
+	sort.Strings(res.Skipped)
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+func scanArchive(ctx context.Context, outDir string, a collectors.Artifact, maxSize int64) ([]Binary, []string, error) {
+	var bins []Binary
+	var skipped []string
+	w := archive.NewWalker()
+	err := w.WalkFile(ctx, a.RelativePath, filepath.Join(outDir, filepath.FromSlash(a.RelativePath)), func(name string, r io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		br := bufio.NewReader(r)
+		head, _ := br.Peek(4)
+		if !isELF(head) {
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(br, maxSize+1))
+		if err != nil {
+			return nil
+		}
+		if int64(len(data)) > maxSize {
+			skipped = append(skipped, name+": exceeds size limit")
+			return nil
+		}
+		b, err := Inspect(data)
+		if err != nil {
+			b.Error = err.Error()
+		}
+		b.Artifact = a.RelativePath
+		b.Path = "/" + strings.TrimPrefix(name[strings.LastIndexByte(name, '!')+1:], "/")
+		b.Sources = []string{"snapshot"}
+		bins = append(bins, b)
+		return nil
+	})
+	return bins, append(skipped, w.Skipped...), err
+}
+
+func inspectLive(c candidate, maxSize int64) (Binary, bool, string) {
+	f, err := os.Open(c.path)
+	if err != nil {
+		return Binary{}, false, ""
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return Binary{}, false, ""
+	}
+	head := make([]byte, 4)
+	if _, err := io.ReadFull(f, head); err != nil || !isELF(head) {
+		return Binary{}, false, ""
+	}
+	if info.Size() > maxSize {
+		return Binary{}, false, c.path + ": exceeds size limit"
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return Binary{}, false, ""
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return Binary{}, false, ""
+	}
+	b, err := Inspect(data)
+	if err != nil {
+		b.Error = err.Error()
+	}
+	b.Path = c.path
+	b.Artifact = c.artifact
+	b.Sources = c.sources
+	return b, true, ""
+}
+
+// liveCandidates gathers executables named by the case's process, unit and
+// persistence inventories plus every file under ScratchDirs and the home directories of
+// root and regular users.
+func liveCandidates(ctx context.Context, outDir string, artifacts []collectors.Artifact) ([]candidate, []string) {
+	byPath := map[string]*candidate{}
+	var order []string
+	add := func(p, artifact, source string) {
+		if p == "" || !filepath.IsAbs(p) {
+			return
+		}
+		p = filepath.Clean(p)
+		c, ok := byPath[p]
+		if !ok {
+			c = &candidate{path: p, artifact: artifact}
+			byPath[p] = c
+			order = append(order, p)
+		}
+		if len(c.sources) < 10 {
+			c.sources = append(c.sources, source)
+		}
+	}
+
+	var errs []string
+	var homes []string
+	read := func(collector, name string, fn func(rel string, raw []byte)) {
+		a, ok := collectors.Latest(artifacts, collector, name)
+		if !ok {
+			return
+		}
+		err := collectors.ReadLines(ctx, outDir, a, func(line []byte) error {
+			fn(a.RelativePath, line)
+			return nil
+		})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", a.RelativePath, err))
+		}
+	}
+	read("processes", "processes.jsonl", func(rel string, raw []byte) {
+		var p linux.Process
+		if json.Unmarshal(raw, &p) != nil || p.Exe == "" {
+			return
+		}
+		exe := p.Exe
+		if p.ExeDeleted {
+			// The image survives only through the process.
+			exe = filepath.Join("/proc", strconv.Itoa(p.PID), "exe")
+		}
+		add(exe, rel, fmt.Sprintf("process:%d", p.PID))
+	})
+	read("units", "units.jsonl", func(rel string, raw []byte) {
+		var u linux.Unit
+		if json.Unmarshal(raw, &u) != nil {
+			return
+		}
+		for _, cmd := range u.ExecStart {
+			add(execTarget(cmd), rel, "unit:"+u.Name)
+		}
+	})
+	read("persistence", "lines.jsonl", func(rel string, raw []byte) {
+		var l linux.PersistenceLine
+		if json.Unmarshal(raw, &l) != nil || l.Kind != linux.PersistenceSystemd {
+			return
+		}
+		if cmd, ok := strings.CutPrefix(l.Text, "ExecStart="); ok {
+			add(execTarget(cmd), rel, fmt.Sprintf("persistence:%s:%d", l.Path, l.Line))
+		}
+	})
+	read("accounts", "users.jsonl", func(rel string, raw []byte) {
+		var u linux.User
+		if json.Unmarshal(raw, &u) != nil {
+			return
+		}
+		if (u.UID == 0 || u.UID >= 1000) && u.Home != "" && u.Home != "/" && !strings.HasPrefix(u.Home, "/nonexistent") {
+			homes = append(homes, u.Home)
+		}
+	})
+
+	dirs := append(append([]string{}, ScratchDirs...), homes...)
+	seenDir := map[string]bool{}
+	for _, dir := range dirs {
+		if seenDir[dir] {
+			continue
+		}
+		seenDir[dir] = true
+		n := 0
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				return nil
+			}
+			if n++; n > maxWalkEntries {
+				return errWalkLimit
+			}
+			if d.Type().IsRegular() {
+				add(p, "", "dir:"+dir)
+			}
+			return nil
+		})
+		if errors.Is(err, errWalkLimit) {
+			errs = append(errs, dir+": walk limit reached")
+		}
+	}
+
+	out := make([]candidate, 0, len(order))
+	for _, p := range order {
+		out = append(out, *byPath[p])
+	}
+	return out, errs
+}
+
+var errWalkLimit = errors.New("walk limit reached")
+
+// execTarget returns the program of an ExecStart line, dropping systemd's
+// "-@:+!" prefixes.
+func execTarget(cmd string) string {
+	fields := strings.Fields(cmd)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimLeft(fields[0], "-@:+!")
+}
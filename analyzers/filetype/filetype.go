@@ -0,0 +1,157 @@
+package filetype
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	RuleExtensionMismatch = "extension_mismatch"
+	RuleHighEntropy       = "high_entropy"
+)
+
+// HighEntropy is the entropy in bits per byte above which file content is
+// treated as compressed or encrypted.
+const HighEntropy = 7.5
+
+// minEntropySize keeps small files out: entropy of a short sample cannot
+// reach the threshold reliably.
+const minEntropySize = 1024
+
+// Hit is a classified file matching a rule. Expected lists the types its
+// extension allows, for mismatches.
+type Hit struct {
+	Rule     string   `json:"rule"`
+	Path     string   `json:"path"`
+	FileType string   `json:"file_type"`
+	Class    string   `json:"class"`
+	Expected []string `json:"expected,omitempty"`
+	Entropy  float64  `json:"entropy"`
+	Size     int64    `json:"size_bytes"`
+	ModTime  string   `json:"mod_time,omitempty"`
+	SHA256   string   `json:"sha256,omitempty"`
+}
+
+type Result struct {
+	Artifact   string         `json:"artifact,omitempty"`
+	Classified int            `json:"classified"`
+	Types      map[string]int `json:"types"`
+	Hits       []Hit          `json:"hits"`
+	Finished   string         `json:"finished"`
+}
+
+// textTypes are what text formats and scripts may be detected as.
+var textTypes = []string{"text", "script"}
+
+// extTypes maps extensions to the types their content may have.
+var extTypes = map[string][]string{
+	".txt": textTypes, ".log": textTypes, ".conf": textTypes, ".cfg": textTypes, ".ini": textTypes,
+	".json": textTypes, ".xml": textTypes, ".yaml": textTypes, ".yml": textTypes, ".csv": textTypes,
+	".md": textTypes, ".html": textTypes, ".htm": textTypes, ".css": textTypes, ".js": textTypes,
+	".php": textTypes, ".sh": textTypes, ".py": textTypes, ".pl": textTypes, ".rb": textTypes,
+	".service": textTypes, ".timer": textTypes, ".socket": textTypes,
+	".png": {"png"}, ".jpg": {"jpeg"}, ".jpeg": {"jpeg"}, ".gif": {"gif"}, ".tif": {"tiff"}, ".tiff": {"tiff"}, ".webp": {"webp"},
+	".pdf": {"pdf"},
+	".zip": {"zip"}, ".jar": {"zip"}, ".war": {"zip"}, ".ear": {"zip"}, ".apk": {"zip"}, ".whl": {"zip"}, ".epub": {"zip"},
+	".docx": {"zip"}, ".xlsx": {"zip"}, ".pptx": {"zip"}, ".odt": {"zip"}, ".ods": {"zip"}, ".odp": {"zip"},
+	".doc": {"ole"}, ".xls": {"ole"}, ".ppt": {"ole"}, ".msi": {"ole"},
+	".gz": {"gzip"}, ".tgz": {"gzip"}, ".bz2": {"bzip2"}, ".xz": {"xz"}, ".zst": {"zstd"}, ".lz4": {"lz4"},
+	".7z": {"7z"}, ".rar": {"rar"}, ".tar": {"tar"},
+	".sqlite": {"sqlite"}, ".sqlite3": {"sqlite"},
+	".exe": {"pe"}, ".dll": {"pe"}, ".sys": {"pe"}, ".class": {"java_class"},
+	// Shared objects may be linker scripts, such as libc.so.
+	".so": {"elf", "text"}, ".o": {"elf"},
+}
+
+// entropyDirs are configuration and log locations that hold text. A file
+// in one of them is only expected to look random when it is an archive,
+// image or database, or key material.
+var entropyDirs = []string{"/etc/", "/usr/local/etc/", "/var/log/", "/var/spool/"}
+
+var keyExts = map[string]bool{
+	".gpg": true, ".pgp": true, ".kbx": true, ".der": true, ".p12": true, ".pfx": true,
+	".jks": true, ".keystore": true, ".keyring": true, ".journal": true, ".journal~": true,
+}
+
+// Scan checks the classified entries of the latest filesystem snapshot.
+// Snapshots taken without classification yield no hits.
+func Scan(ctx context.Context, outDir string, artifacts []collectors.Artifact) (Result, error) {
+	res := Result{Types: map[string]int{}, Hits: []Hit{}}
+	a, ok := collectors.Latest(artifacts, "fs_snapshot", "metadata.jsonl")
+	if !ok {
+		res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+		return res, nil
+	}
+	res.Artifact = a.RelativePath
+	err := collectors.ReadLines(ctx, outDir, a, func(line []byte) error {
+		var e linux.SnapshotEntry
+		if json.Unmarshal(line, &e) != nil || e.Type != "file" || e.FileType == "" {
+			return nil
+		}
+		res.Classified++
+		res.Types[e.FileType]++
+		p := filepath.ToSlash(e.Path)
+		class := linux.FileClass(e.FileType)
+		hit := Hit{Path: p, FileType: e.FileType, Class: class, Entropy: e.Entropy, Size: e.SizeBytes, ModTime: e.ModTime, SHA256: e.SHA256}
+		ext := strings.ToLower(path.Ext(p))
+		// A file is reported once; a mismatch already covers random content
+		// behind a text extension.
+		if expected, ok := extTypes[ext]; ok && mismatch(expected, e.FileType, class, e.Entropy) {
+			hit.Rule, hit.Expected = RuleExtensionMismatch, expected
+			res.Hits = append(res.Hits, hit)
+			return nil
+		}
+		if e.Entropy >= HighEntropy && e.SizeBytes >= minEntropySize && inEntropyDir(p) && !keyExts[ext] {
+			switch class {
+			case linux.ClassArchive, linux.ClassImage, linux.ClassDocument, linux.ClassDatabase:
+			default:
+				hit.Rule = RuleHighEntropy
+				res.Hits = append(res.Hits, hit)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	sort.SliceStable(res.Hits, func(i, j int) bool {
+		if res.Hits[i].Rule != res.Hits[j].Rule {
+			return res.Hits[i].Rule < res.Hits[j].Rule
+		}
+		return res.Hits[i].Path < res.Hits[j].Path
+	})
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+// mismatch reports content that contradicts its extension. Text formats
+// only mismatch recognized binary formats and random data, since logs and
+// configs in other encodings classify as data; binary formats mismatch
+// anything without their signature.
+func mismatch(expected []string, fileType, class string, entropy float64) bool {
+	if class == linux.ClassEmpty || slices.Contains(expected, fileType) {
+		return false
+	}
+	if slices.Contains(expected, "text") {
+		return class != linux.ClassData || entropy >= HighEntropy
+	}
+	return true
+}
+
+func inEntropyDir(p string) bool {
+	for _, d := range entropyDirs {
+		if strings.HasPrefix(p, d) {
+			return true
+		}
+	}
+	return strings.Contains(p, "/.config/")
+}
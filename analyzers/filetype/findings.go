@@ -0,0 +1,58 @@
+package filetype
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/collectors/linux"
+	"iron-sentinel/findings"
+)
+
+// Findings turns every hit into a finding. Executables behind a document,
+// image or text extension are the strongest signal; encrypted or random
+// content where text or a known format belongs is what ransomware leaves.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		f := findings.Finding{
+			Analyzer: "filetype",
+			Rule:     "filetype." + h.Rule,
+			Evidence: []findings.Evidence{{Artifact: res.Artifact, Path: h.Path, SHA256: h.SHA256}},
+			Metadata: map[string]string{
+				"file_type": h.FileType,
+				"class":     h.Class,
+				"entropy":   fmt.Sprintf("%.3f", h.Entropy),
+				"size":      fmt.Sprintf("%d", h.Size),
+			},
+		}
+		if h.ModTime != "" {
+			f.Metadata["mod_time"] = h.ModTime
+		}
+		random := h.Class == linux.ClassEncrypted || h.Entropy >= HighEntropy
+		switch h.Rule {
+		case RuleExtensionMismatch:
+			f.Metadata["expected"] = strings.Join(h.Expected, ",")
+			f.Title = fmt.Sprintf("File content does not match its extension: %s", h.Path)
+			f.Description = fmt.Sprintf("%s is %s content (entropy %.2f) where %s was expected.", h.Path, h.FileType, h.Entropy, strings.Join(h.Expected, " or "))
+			switch {
+			case h.Class == linux.ClassExecutable:
+				f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceHigh
+				f.Attack = []string{"T1036.008"}
+			case random:
+				f.Severity, f.Confidence = findings.SeverityMedium, findings.ConfidenceMedium
+			default:
+				f.Severity, f.Confidence = findings.SeverityLow, findings.ConfidenceMedium
+			}
+		case RuleHighEntropy:
+			f.Title = fmt.Sprintf("High-entropy file in a configuration or log directory: %s", h.Path)
+			f.Description = fmt.Sprintf("%s is %s content with entropy %.2f bits per byte, which suggests encryption.", h.Path, h.FileType, h.Entropy)
+			f.Severity, f.Confidence = findings.SeverityMedium, findings.ConfidenceLow
+			if h.Class == linux.ClassEncrypted {
+				f.Confidence = findings.ConfidenceMedium
+			}
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
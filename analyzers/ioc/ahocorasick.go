@@ -0,0 +1,116 @@
+package ioc
+
+// automaton is a byte-oriented Aho-Corasick matcher. Root transitions are a
+// dense table; deeper nodes use small maps so 50k-pattern feeds stay compact.
+type automaton struct {
+	root  [256]int32
+	nodes []acNode
+	lens  []int
+	fold  bool
+}
+
+type acNode struct {
+	edges map[byte]int32
+	fail  int32
+	out   []int32
+	dict  int32
+}
+
+func newAutomaton(patterns []string, fold bool) *automaton {
+	a := &automaton{fold: fold, lens: make([]int, len(patterns))}
+	a.nodes = append(a.nodes, acNode{dict: -1})
+
+	for i, p := range patterns {
+		a.lens[i] = len(p)
+		cur := int32(0)
+		for j := 0; j < len(p); j++ {
+			c := p[j]
+			if fold {
+				c = lower(c)
+			}
+			n := &a.nodes[cur]
+			if n.edges == nil {
+				n.edges = map[byte]int32{}
+			}
+			next, ok := n.edges[c]
+			if !ok {
+				next = int32(len(a.nodes))
+				n.edges[c] = next
+				a.nodes = append(a.nodes, acNode{dict: -1})
+			}
+			cur = next
+		}
+		a.nodes[cur].out = append(a.nodes[cur].out, int32(i))
+	}
+
+	queue := make([]int32, 0, len(a.nodes))
+	for c := 0; c < 256; c++ {
+		if next, ok := a.nodes[0].edges[byte(c)]; ok {
+			a.root[c] = next
+			a.nodes[next].fail = 0
+			queue = append(queue, next)
+		}
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for c, next := range a.nodes[cur].edges {
+			f := a.nodes[cur].fail
+			for f != 0 {
+				if t, ok := a.nodes[f].edges[c]; ok {
+					f = t
+					break
+				}
+				f = a.nodes[f].fail
+			}
+			if f == 0 {
+				f = a.root[c]
+				if f == next {
+					f = 0
+				}
+			}
+			a.nodes[next].fail = f
+			if len(a.nodes[f].out) > 0 {
+				a.nodes[next].dict = f
+			} else {
+				a.nodes[next].dict = a.nodes[f].dict
+			}
+			queue = append(queue, next)
+		}
+	}
+	return a
+}
+
+func (a *automaton) step(state int32, c byte) int32 {
+	if a.fold {
+		c = lower(c)
+	}
+	for state != 0 {
+		if next, ok := a.nodes[state].edges[c]; ok {
+			return next
+		}
+		state = a.nodes[state].fail
+	}
+	return a.root[c]
+}
+
+// scan feeds buf through the automaton starting at state and reports every
+// pattern ending at each position. It returns the state to resume from.
+func (a *automaton) scan(state int32, buf []byte, fn func(pattern int32, end int)) int32 {
+	for i := 0; i < len(buf); i++ {
+		state = a.step(state, buf[i])
+		for n := state; n > 0; n = a.nodes[n].dict {
+			for _, p := range a.nodes[n].out {
+				fn(p, i+1)
+			}
+		}
+	}
+	return state
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + ('a' - 'A')
+	}
+	return c
+}
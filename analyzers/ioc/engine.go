@@ -0,0 +1,282 @@
+package ioc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+)
+
+// maxChunk bounds how much of a single line is held in memory. Longer lines
+// are scanned in consecutive chunks; literal matches spanning chunks are still
+// found because automaton state carries over.
+const maxChunk = 1024 * 1024
+
+const maxContext = 512
+
+type acEntry struct {
+	ioc   IOC
+	field string
+}
+
+// engine compiles an IOC set once and scans any number of streams. Literal
+// types (strings, domains, plain filenames and process names) go through
+// Aho-Corasick automata; regexes, IPs, CIDRs and globs are checked per line.
+type engine struct {
+	cs        *automaton
+	csEntries []acEntry
+	ci        *automaton
+	ciEntries []acEntry
+
+	regexes  []IOC
+	ips      map[string][]IOC
+	cidrs    []IOC
+	fileGlob []IOC
+	procGlob []IOC
+
+	byType map[string][]IOC
+}
+
+func newEngine(iocs []IOC) *engine {
+	e := &engine{ips: map[string][]IOC{}, byType: map[string][]IOC{}}
+
+	var csPats, ciPats []string
+	for _, c := range iocs {
+		e.byType[c.Type] = append(e.byType[c.Type], c)
+		switch c.Type {
+		case TypeString:
+			csPats = append(csPats, c.Value)
+			e.csEntries = append(e.csEntries, acEntry{ioc: c, field: "line"})
+		case TypeDomain:
+			ciPats = append(ciPats, c.Value)
+			e.ciEntries = append(e.ciEntries, acEntry{ioc: c, field: "domain"})
+		case TypeFilename:
+			if strings.ContainsAny(c.Value, "*?[") {
+				e.fileGlob = append(e.fileGlob, c)
+				continue
+			}
+			ciPats = append(ciPats, c.Value)
+			e.ciEntries = append(e.ciEntries, acEntry{ioc: c, field: "filename"})
+		case TypeProcess:
+			if strings.ContainsAny(c.Value, "*?[") {
+				e.procGlob = append(e.procGlob, c)
+				continue
+			}
+			ciPats = append(ciPats, c.Value)
+			e.ciEntries = append(e.ciEntries, acEntry{ioc: c, field: "process"})
+		case TypeRegex:
+			e.regexes = append(e.regexes, c)
+		case TypeIP:
+			e.ips[c.ip.String()] = append(e.ips[c.ip.String()], c)
+		case TypeCIDR:
+			e.cidrs = append(e.cidrs, c)
+		}
+	}
+	if len(csPats) > 0 {
+		e.cs = newAutomaton(csPats, false)
+	}
+	if len(ciPats) > 0 {
+		e.ci = newAutomaton(ciPats, true)
+	}
+	return e
+}
+
+func (e *engine) hasHashes() bool {
+	return len(e.byType[TypeMD5])+len(e.byType[TypeSHA1])+len(e.byType[TypeSHA256]) > 0
+}
+
+func (e *engine) needsTokens() bool {
+	return len(e.ips)+len(e.cidrs)+len(e.fileGlob)+len(e.procGlob) > 0
+}
+
+type scanState struct {
+	line    int
+	offset  int64
+	csState int32
+	ciState int32
+}
+
+// scanStream reads r in bounded chunks and calls emit for every match with
+// its 1-based line number and byte offset. When snapshot is set, complete
+// lines are also decoded as fs_snapshot metadata records. It returns
+// errStopScan once emit refuses a match, so that the archive walk stops too.
+func (e *engine) scanStream(ctx context.Context, r io.Reader, snapshot bool, emit func(Match) bool) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var st scanState
+	var pending []byte
+	continued := false
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			pending = append(pending, chunk...)
+			if len(pending) < maxChunk {
+				continue
+			}
+			if !e.scanChunk(&st, pending, !continued, false, snapshot, emit) {
+				return errStopScan
+			}
+			continued = true
+			pending = pending[:0]
+			continue
+		}
+		if len(pending) > 0 {
+			pending = append(pending, chunk...)
+			chunk = pending
+		}
+		if len(chunk) > 0 {
+			if !e.scanChunk(&st, chunk, !continued, true, snapshot, emit) {
+				return errStopScan
+			}
+		}
+		pending = pending[:0]
+		continued = false
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (e *engine) scanChunk(st *scanState, chunk []byte, lineStart bool, lineEnd bool, snapshot bool, emit func(Match) bool) bool {
+	if lineStart {
+		st.line++
+		st.csState, st.ciState = 0, 0
+	}
+	base := st.offset
+	st.offset += int64(len(chunk))
+
+	text := chunk
+	for len(text) > 0 && (text[len(text)-1] == '\n' || text[len(text)-1] == '\r') {
+		text = text[:len(text)-1]
+	}
+	line := string(text)
+	ctx := line
+	if len(ctx) > maxContext {
+		ctx = ctx[:maxContext]
+	}
+
+	keep := true
+	send := func(m Match, start int) {
+		if !keep {
+			return
+		}
+		// Snapshot paths are matched structurally by matchSnapshot.
+		if snapshot && m.Field == "filename" {
+			return
+		}
+		m.Line = st.line
+		m.Offset = base + int64(start)
+		if m.FirstLine == "" {
+			m.FirstLine = ctx
+		}
+		keep = emit(m)
+	}
+
+	if e.cs != nil {
+		st.csState = e.cs.scan(st.csState, text, func(p int32, end int) {
+			c := e.csEntries[p].ioc
+			start := end - e.cs.lens[p]
+			if start < 0 {
+				start = 0
+			}
+			send(c.match("line", c.Value, "", 0, ""), start)
+		})
+	}
+	if e.ci != nil {
+		st.ciState = e.ci.scan(st.ciState, text, func(p int32, end int) {
+			ent := e.ciEntries[p]
+			start := end - e.ci.lens[p]
+			if start < 0 {
+				start = 0
+			}
+			var ok bool
+			switch ent.field {
+			case "domain":
+				ok = domainBoundary(text, start, end)
+			case "filename":
+				ok = fileBoundary(text, start, end)
+			case "process":
+				ok = processBoundary(text, start, end)
+			}
+			if !ok {
+				return
+			}
+			value := string(text[start:end])
+			if ent.field == "domain" {
+				from := start
+				for from > 0 && isHostChar(text[from-1]) {
+					from--
+				}
+				value = strings.ToLower(string(text[from:end]))
+			}
+			send(ent.ioc.match(ent.field, value, "", 0, ""), start)
+		})
+	}
+
+	for _, c := range e.regexes {
+		for _, loc := range c.re.FindAllStringIndex(line, -1) {
+			send(c.match("line", line[loc[0]:loc[1]], "", 0, ""), loc[0])
+		}
+	}
+
+	if e.needsTokens() {
+		e.matchTokens(line, send)
+	}
+
+	if snapshot && lineStart && lineEnd {
+		var rec snapshotRecord
+		if json.Unmarshal(text, &rec) == nil {
+			for _, m := range e.matchSnapshot(rec) {
+				send(m, 0)
+			}
+		}
+	}
+	return keep
+}
+
+func (e *engine) matchTokens(line string, send func(Match, int)) {
+	if len(e.ips)+len(e.cidrs) > 0 {
+		forTokens(line, isIPChar, func(tok string, at int) {
+			ip := parseIPToken(tok)
+			if ip == nil {
+				return
+			}
+			for _, c := range e.ips[ip.String()] {
+				send(c.match("ip", ip.String(), "", 0, ""), at)
+			}
+			for _, c := range e.cidrs {
+				if c.net.Contains(ip) {
+					send(c.match("ip", ip.String(), "", 0, ""), at)
+				}
+			}
+		})
+	}
+	if len(e.fileGlob) > 0 {
+		forTokens(line, isPathChar, func(tok string, at int) {
+			base := tok[strings.LastIndexByte(tok, '/')+1:]
+			for _, c := range e.fileGlob {
+				if globMatch(c.Value, base) {
+					send(c.match("filename", tok, "", 0, ""), at)
+				}
+			}
+		})
+	}
+	if len(e.procGlob) > 0 {
+		for _, name := range processNames(line) {
+			for _, c := range e.procGlob {
+				if globMatch(c.Value, name) {
+					send(c.match("process", name, "", 0, ""), strings.Index(line, name))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package ioc
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// detectFeed sniffs the top-level keys of a JSON IOC file.
+func detectFeed(b []byte) string {
+	if b[0] == '[' {
+		var arr []map[string]json.RawMessage
+		if json.Unmarshal(b, &arr) == nil && len(arr) > 0 {
+			if _, ok := arr[0]["Event"]; ok {
+				return "misp"
+			}
+		}
+		return ""
+	}
+
+	var top map[string]json.RawMessage
+	if json.Unmarshal(b, &top) != nil {
+		return ""
+	}
+	if _, ok := top["Event"]; ok {
+		return "misp"
+	}
+	if _, ok := top["response"]; ok {
+		return "misp"
+	}
+	var typ string
+	_ = json.Unmarshal(top["type"], &typ)
+	if typ == "bundle" {
+		return "stix"
+	}
+	return ""
+}
+
+type stixBundle struct {
+	Objects []stixObject `json:"objects"`
+}
+
+type stixObject struct {
+	Type               string `json:"type"`
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Pattern            string `json:"pattern"`
+	PatternType        string `json:"pattern_type"`
+	Revoked            bool   `json:"revoked"`
+	Confidence         int    `json:"confidence"`
+	ExternalReferences []struct {
+		SourceName string `json:"source_name"`
+		URL        string `json:"url"`
+		ExternalID string `json:"external_id"`
+	} `json:"external_references"`
+}
+
+var stixComparison = regexp.MustCompile(`([a-z0-9-]+):([A-Za-z0-9_.'\-]+)\s*(=|LIKE|MATCHES)\s*'((?:[^'\\]|\\.)*)'`)
+
+// parseSTIX turns each comparison in an indicator's pattern into an IOC.
+// Compound patterns are flattened: "[a] AND [b]" yields two IOCs, which may
+// over-match but never misses an observable the indicator names. Values
+// that do not compile are skipped and listed in rejected.
+func parseSTIX(b []byte) (out []IOC, rejected []string, err error) {
+	var bundle stixBundle
+	if err := json.Unmarshal(b, &bundle); err != nil {
+		return nil, nil, err
+	}
+
+	for _, o := range bundle.Objects {
+		if o.Type != "indicator" || o.Revoked {
+			continue
+		}
+		if o.PatternType != "" && o.PatternType != "stix" {
+			continue
+		}
+
+		ref := ""
+		for _, er := range o.ExternalReferences {
+			if er.URL != "" {
+				ref = er.URL
+				break
+			}
+			if er.ExternalID != "" {
+				ref = er.SourceName + ":" + er.ExternalID
+			}
+		}
+
+		for _, m := range stixComparison.FindAllStringSubmatch(o.Pattern, -1) {
+			obj, prop, op := m[1], strings.ToLower(m[2]), m[3]
+			value := strings.ReplaceAll(strings.ReplaceAll(m[4], `\'`, `'`), `\\`, `\`)
+
+			typ := stixType(obj, prop)
+			if typ == "" {
+				continue
+			}
+			switch op {
+			case "MATCHES":
+				typ = TypeRegex
+			case "LIKE":
+				typ = TypeRegex
+				value = "^" + strings.ReplaceAll(strings.ReplaceAll(regexp.QuoteMeta(value), "%", ".*"), "_", ".") + "$"
+			}
+			if typ == TypeIP && strings.Contains(value, "/") {
+				typ = TypeCIDR
+			}
+
+			c := IOC{
+				Type:      typ,
+				Value:     value,
+				Name:      o.Name,
+				Severity:  confidenceSeverity(o.Confidence),
+				Reference: ref,
+				Source:    "stix",
+				SourceID:  o.ID,
+			}
+			if err := c.compile(); err != nil {
+				rejected = append(rejected, fmt.Sprintf("%s: %v", o.ID, err))
+				continue
+			}
+			out = append(out, c)
+		}
+	}
+	return out, rejected, nil
+}
+
+func stixType(obj string, prop string) string {
+	switch obj {
+	case "file":
+		switch prop {
+		case "hashes.'sha-256'", "hashes.sha256", "hashes.'sha256'":
+			return TypeSHA256
+		case "hashes.'sha-1'", "hashes.sha1", "hashes.'sha1'":
+			return TypeSHA1
+		case "hashes.md5", "hashes.'md5'":
+			return TypeMD5
+		case "name":
+			return TypeFilename
+		}
+	case "ipv4-addr", "ipv6-addr":
+		if prop == "value" {
+			return TypeIP
+		}
+	case "domain-name":
+		if prop == "value" {
+			return TypeDomain
+		}
+	case "url":
+		if prop == "value" {
+			return TypeString
+		}
+	case "process":
+		if prop == "name" {
+			return TypeProcess
+		}
+	}
+	return ""
+}
+
+func confidenceSeverity(c int) string {
+	switch {
+	case c >= 85:
+		return "high"
+	case c >= 50:
+		return "medium"
+	case c > 0:
+		return "low"
+	}
+	return ""
+}
+
+type mispEvent struct {
+	ID            string          `json:"id"`
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Attribute     []mispAttribute `json:"Attribute"`
+	Object        []struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"Object"`
+}
+
+type mispAttribute struct {
+	UUID    string          `json:"uuid"`
+	Type    string          `json:"type"`
+	Value   string          `json:"value"`
+	ToIDs   json.RawMessage `json:"to_ids"`
+	Comment string          `json:"comment"`
+}
+
+type mispWrapper struct {
+	Event mispEvent `json:"Event"`
+}
+
+func parseMISP(b []byte) (out []IOC, rejected []string, err error) {
+	var events []mispEvent
+	switch b[0] {
+	case '[':
+		var arr []mispWrapper
+		if err := json.Unmarshal(b, &arr); err != nil {
+			return nil, nil, err
+		}
+		for _, w := range arr {
+			events = append(events, w.Event)
+		}
+	default:
+		var doc struct {
+			Event    *mispEvent    `json:"Event"`
+			Response []mispWrapper `json:"response"`
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, nil, err
+		}
+		if doc.Event != nil {
+			events = append(events, *doc.Event)
+		}
+		for _, w := range doc.Response {
+			events = append(events, w.Event)
+		}
+	}
+
+	for _, ev := range events {
+		attrs := append([]mispAttribute(nil), ev.Attribute...)
+		for _, o := range ev.Object {
+			attrs = append(attrs, o.Attribute...)
+		}
+
+		for _, a := range attrs {
+			if !mispToIDs(a.ToIDs) {
+				continue
+			}
+			for _, c := range mispIOCs(a) {
+				c.Name = ev.Info
+				if a.Comment != "" {
+					c.Name = ev.Info + ": " + a.Comment
+				}
+				c.Severity = mispSeverity(ev.ThreatLevelID)
+				c.Reference = "misp:event/" + ev.UUID
+				c.Source = "misp"
+				c.SourceID = a.UUID
+				if err := c.compile(); err != nil {
+					rejected = append(rejected, fmt.Sprintf("%s: %v", a.UUID, err))
+					continue
+				}
+				out = append(out, c)
+			}
+		}
+	}
+	return out, rejected, nil
+}
+
+func mispToIDs(raw json.RawMessage) bool {
+	s := strings.Trim(string(raw), `"`)
+	return s == "true" || s == "1"
+}
+
+func mispIOCs(a mispAttribute) []IOC {
+	one := func(typ string, v string) []IOC { return []IOC{{Type: typ, Value: v}} }
+	left, right, composite := strings.Cut(a.Value, "|")
+
+	switch a.Type {
+	case "md5", "sha1", "sha256":
+		return one(a.Type, a.Value)
+	case "filename|md5", "filename|sha1", "filename|sha256":
+		if !composite {
+			return nil
+		}
+		return []IOC{{Type: TypeFilename, Value: left}, {Type: strings.TrimPrefix(a.Type, "filename|"), Value: right}}
+	case "ip-src", "ip-dst":
+		if strings.Contains(a.Value, "/") {
+			return one(TypeCIDR, a.Value)
+		}
+		return one(TypeIP, a.Value)
+	case "ip-src|port", "ip-dst|port":
+		return one(TypeIP, left)
+	case "domain", "hostname":
+		return one(TypeDomain, a.Value)
+	case "domain|ip":
+		if !composite {
+			return nil
+		}
+		return []IOC{{Type: TypeDomain, Value: left}, {Type: TypeIP, Value: right}}
+	case "url", "uri", "user-agent", "mutex", "text":
+		return one(TypeString, a.Value)
+	case "filename":
+		return one(TypeFilename, a.Value)
+	case "regexp":
+		return one(TypeRegex, a.Value)
+	}
+	return nil
+}
+
+func mispSeverity(id string) string {
+	switch id {
+	case "1":
+		return "high"
+	case "2":
+		return "medium"
+	case "3":
+		return "low"
+	}
+	return ""
+}
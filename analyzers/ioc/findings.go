@@ -0,0 +1,88 @@
+package ioc
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// maxFindingEvidence caps the evidence references kept per IOC finding.
+const maxFindingEvidence = 20
+
+// Findings groups matches by IOC and artifact into findings. Hash matches
+// are high confidence; literal indicators medium and free-text patterns low.
+func Findings(res Result) []findings.Finding {
+	type key struct{ pattern, typ, artifact string }
+	var order []key
+	groups := map[key]*findings.Finding{}
+	counts := map[key]int{}
+	for _, m := range res.Matches {
+		artifact, member, _ := strings.Cut(m.Artifact, "!")
+		k := key{m.Pattern, m.Type, artifact}
+		f, ok := groups[k]
+		if !ok {
+			name := m.Name
+			if name == "" {
+				name = m.Pattern
+			}
+			f = &findings.Finding{
+				Analyzer:    "ioc",
+				Rule:        m.Type + ":" + m.Pattern,
+				Severity:    findings.ParseSeverity(m.Severity, defaultSeverity(m.Type)),
+				Confidence:  confidence(m.Type),
+				Title:       "IOC match: " + name,
+				Description: fmt.Sprintf("%s indicator %q matched in %s.", m.Type, m.Pattern, artifact),
+				Metadata:    map[string]string{"type": m.Type},
+			}
+			for k, v := range map[string]string{"source": m.Source, "source_id": m.SourceID, "reference": m.Reference} {
+				if v != "" {
+					f.Metadata[k] = v
+				}
+			}
+			groups[k] = f
+			order = append(order, k)
+		}
+		counts[k] += max(m.Count, 1)
+		if len(f.Evidence) >= maxFindingEvidence {
+			continue
+		}
+		e := findings.Evidence{Artifact: artifact, Path: member, Line: m.Line, Excerpt: m.Value}
+		if e.Path == "" {
+			e.Path = m.Path
+		}
+		if strings.HasSuffix(m.Field, "sha256") {
+			e.SHA256, e.Excerpt = m.Value, ""
+		} else if !strings.HasPrefix(m.Field, "artifact.") && !strings.HasPrefix(m.Field, "member.") {
+			e.Offset = findings.At(m.Offset)
+		}
+		f.Evidence = append(f.Evidence, e)
+	}
+
+	out := make([]findings.Finding, 0, len(order))
+	for _, k := range order {
+		f := groups[k]
+		f.Metadata["matches"] = fmt.Sprintf("%d", counts[k])
+		f.SetID()
+		out = append(out, *f)
+	}
+	return out
+}
+
+func defaultSeverity(typ string) findings.Severity {
+	switch typ {
+	case TypeMD5, TypeSHA1, TypeSHA256:
+		return findings.SeverityHigh
+	}
+	return findings.SeverityMedium
+}
+
+func confidence(typ string) string {
+	switch typ {
+	case TypeMD5, TypeSHA1, TypeSHA256:
+		return findings.ConfidenceHigh
+	case TypeString, TypeRegex:
+		return findings.ConfidenceLow
+	}
+	return findings.ConfidenceMedium
+}
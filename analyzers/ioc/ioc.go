@@ -1,101 +1,283 @@
 package ioc
 
 import (
-	"bufio"
 	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"iron-sentinel/analyzers/archive"
 	"iron-sentinel/collectors"
 )
 
+// DefaultMaxMatches is the number of distinct matches recorded per artifact
+// unless Options.MaxMatches says otherwise.
+const DefaultMaxMatches = 1000
+
 type Options struct {
 	IOCFile string
+	// Workers scans this many artifacts concurrently (default 1).
+	Workers int
+	// MaxMatches caps distinct matches recorded per artifact (default
+	// DefaultMaxMatches); a negative value means unlimited.
+	MaxMatches int
 }
 
 type Match struct {
 	Pattern     string `json:"pattern"`
+	Type        string `json:"type"`
+	Field       string `json:"field"`
+	Value       string `json:"value,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Severity    string `json:"severity,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+	Source      string `json:"source,omitempty"`
+	SourceID    string `json:"source_id,omitempty"`
 	Artifact    string `json:"artifact"`
+	Path        string `json:"path,omitempty"`
+	Line        int    `json:"line,omitempty"`
+	Offset      int64  `json:"offset"`
+	Count       int    `json:"count"`
 	FirstLine   string `json:"first_line,omitempty"`
 	CollectedAt string `json:"collected_at"`
 }
 
 type Result struct {
-	IOCFile  string  `json:"ioc_file"`
-	Matches  []Match `json:"matches"`
-	Scanned  int     `json:"scanned"`
-	Finished string  `json:"finished"`
+	IOCFile   string   `json:"ioc_file"`
+	IOCs      int      `json:"iocs"`
+	Rejected  []string `json:"rejected,omitempty"`
+	Matches   []Match  `json:"matches"`
+	Scanned   int      `json:"scanned"`
+	Truncated []string `json:"truncated,omitempty"`
+	Skipped   []string `json:"skipped,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+	Finished  string   `json:"finished"`
+}
+
+type snapshotRecord struct {
+	Path   string `json:"path"`
+	Type   string `json:"type"`
+	SHA256 string `json:"sha256"`
+}
+
+type artifactResult struct {
+	matches   []Match
+	scanned   bool
+	truncated bool
+	skipped   []string
+	errors    []string
 }
 
-func loadPatterns(path string) ([]string, error) {
+var errStopScan = errors.New("stop scan")
+
+func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	iocs, rejected, err := LoadFile(opts.IOCFile)
+	if err != nil {
+		return Result{}, err
+	}
+	e := newEngine(iocs)
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	maxMatches := opts.MaxMatches
+	if maxMatches == 0 {
+		maxMatches = DefaultMaxMatches
+	}
+
+	results := make([]artifactResult, len(artifacts))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = e.scanArtifact(ctx, outDir, artifacts[i], maxMatches)
+			}
+		}()
+	}
+
+feed:
+	for i := range artifacts {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	res := Result{
+		IOCFile:  opts.IOCFile,
+		IOCs:     len(iocs),
+		Rejected: rejected,
+	}
+	for i, r := range results {
+		if !r.scanned {
+			continue
+		}
+		res.Scanned++
+		res.Matches = append(res.Matches, r.matches...)
+		if r.truncated {
+			res.Truncated = append(res.Truncated, artifacts[i].RelativePath)
+		}
+		res.Skipped = append(res.Skipped, r.skipped...)
+		res.Errors = append(res.Errors, r.errors...)
+	}
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+func (e *engine) scanArtifact(ctx context.Context, outDir string, a collectors.Artifact, maxMatches int) artifactResult {
+	path := filepath.Join(outDir, filepath.FromSlash(a.RelativePath))
 	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return artifactResult{}
 	}
 	defer f.Close()
 
-	var patterns []string
-	s := bufio.NewScanner(f)
-	for s.Scan() {
-		line := strings.TrimSpace(s.Text())
-		if line == "" {
-			continue
+	var r artifactResult
+	r.scanned = true
+	// An IOC firing on the same field and path of one file again only
+	// counts towards the first match.
+	seen := map[string]int{}
+	emitAs := func(name string) func(Match) bool {
+		return func(m Match) bool {
+			if ctx.Err() != nil {
+				return false
+			}
+			key := name + "\x00" + m.Type + "\x00" + m.Pattern + "\x00" + m.Field + "\x00" + m.Path
+			if i, ok := seen[key]; ok {
+				r.matches[i].Count++
+				return true
+			}
+			if maxMatches > 0 && len(r.matches) >= maxMatches {
+				r.truncated = true
+				return false
+			}
+			seen[key] = len(r.matches)
+			m.Count = 1
+			m.Artifact = name
+			m.CollectedAt = time.Now().UTC().Format(time.RFC3339Nano)
+			r.matches = append(r.matches, m)
+			return true
 		}
-		patterns = append(patterns, line)
 	}
-	if err := s.Err(); err != nil {
-		return nil, err
+	emit := emitAs(a.RelativePath)
+
+	if e.hasHashes() {
+		sums := map[string]string{TypeSHA256: strings.ToLower(a.SHA256)}
+		if len(e.byType[TypeMD5])+len(e.byType[TypeSHA1]) > 0 || a.SHA256 == "" {
+			sums = hashAll(f)
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return r
+			}
+		}
+		if !e.matchHashes(sums, "artifact", "", emit) {
+			return r
+		}
 	}
-	if len(patterns) == 0 {
-		return nil, errors.New("IOC file contained no patterns")
+
+	snapshot := a.Collector == "fs_snapshot" && filepath.Base(a.RelativePath) == "metadata.jsonl"
+
+	w := archive.NewWalker()
+	err = w.WalkFile(ctx, a.RelativePath, path, func(name string, mr io.Reader) error {
+		if name == a.RelativePath {
+			return e.scanStream(ctx, mr, snapshot, emit)
+		}
+		return e.scanMember(ctx, name, mr, emitAs(name))
+	})
+	if err != nil && !errors.Is(err, errStopScan) && ctx.Err() == nil {
+		r.errors = append(r.errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
 	}
-	return patterns, nil
+	r.skipped = w.Skipped
+	return r
 }
 
-func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
-	_ = ctx
-
-	patterns, err := loadPatterns(opts.IOCFile)
-	if err != nil {
-		return Result{}, err
+// scanMember scans one file extracted from an archive. Members have no
+// manifest hash, so they are hashed while streaming when hash IOCs exist.
+func (e *engine) scanMember(ctx context.Context, name string, r io.Reader, emit func(Match) bool) error {
+	memberPath := name[strings.LastIndexByte(name, '!')+1:]
+	for _, c := range e.byType[TypeFilename] {
+		if globMatch(c.Value, path.Base(memberPath)) || globMatch(c.Value, memberPath) {
+			if !emit(c.match("member.path", memberPath, memberPath, 0, "")) {
+				return errStopScan
+			}
+		}
 	}
 
-	var matches []Match
-	scanned := 0
-	for _, a := range artifacts {
-		path := filepath.Join(outDir, filepath.FromSlash(a.RelativePath))
-		b, err := os.ReadFile(path)
-		if err != nil {
-			continue
+	var hashes *multiHash
+	if e.hasHashes() {
+		hashes = newMultiHash()
+		r = io.TeeReader(r, hashes)
+	}
+	if err := e.scanStream(ctx, r, false, emit); err != nil {
+		return err
+	}
+	if hashes != nil {
+		_, _ = io.Copy(io.Discard, r)
+		if !e.matchHashes(hashes.sums(), "member", memberPath, emit) {
+			return errStopScan
 		}
-		scanned++
-		content := string(b)
-		for _, p := range patterns {
-			if strings.Contains(content, p) {
-				line := ""
-				for _, l := range strings.Split(content, "\n") {
-					if strings.Contains(l, p) {
-						line = l
-						break
-					}
-				}
-				matches = append(matches, Match{
-					Pattern:     p,
-					Artifact:    a.RelativePath,
-					FirstLine:   line,
-					CollectedAt: time.Now().UTC().Format(time.RFC3339Nano),
-				})
+	}
+	return nil
+}
+
+func (e *engine) matchHashes(sums map[string]string, prefix string, path string, emit func(Match) bool) bool {
+	for typ, sum := range sums {
+		for _, c := range e.byType[typ] {
+			if c.Value == sum && !emit(c.match(prefix+"."+typ, sum, path, 0, "")) {
+				return false
 			}
 		}
 	}
+	return true
+}
 
-	return Result{
-		IOCFile:  opts.IOCFile,
-		Matches:  matches,
-		Scanned:  scanned,
-		Finished: time.Now().UTC().Format(time.RFC3339Nano),
-	}, nil
+type multiHash struct {
+	md5, sha1, sha256 hash.Hash
+}
+
+func newMultiHash() *multiHash {
+	return &multiHash{md5: md5.New(), sha1: sha1.New(), sha256: sha256.New()}
+}
+
+func (h *multiHash) Write(p []byte) (int, error) {
+	h.md5.Write(p)
+	h.sha1.Write(p)
+	h.sha256.Write(p)
+	return len(p), nil
+}
+
+func (h *multiHash) sums() map[string]string {
+	return map[string]string{
+		TypeMD5:    hex.EncodeToString(h.md5.Sum(nil)),
+		TypeSHA1:   hex.EncodeToString(h.sha1.Sum(nil)),
+		TypeSHA256: hex.EncodeToString(h.sha256.Sum(nil)),
+	}
+}
+
+func hashAll(r io.Reader) map[string]string {
+	h := newMultiHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil
+	}
+	return h.sums()
 }
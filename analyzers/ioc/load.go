@@ -0,0 +1,251 @@
+package ioc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type IOC struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Name      string `json:"name,omitempty"`
+	Severity  string `json:"severity,omitempty"`
+	Reference string `json:"reference,omitempty"`
+	Source    string `json:"source,omitempty"`
+	SourceID  string `json:"source_id,omitempty"`
+
+	re  *regexp.Regexp
+	net *net.IPNet
+	ip  net.IP
+}
+
+const (
+	TypeString   = "string"
+	TypeRegex    = "regex"
+	TypeDomain   = "domain"
+	TypeIP       = "ip"
+	TypeCIDR     = "cidr"
+	TypeMD5      = "md5"
+	TypeSHA1     = "sha1"
+	TypeSHA256   = "sha256"
+	TypeFilename = "filename"
+	TypeProcess  = "process"
+)
+
+var typeAliases = map[string]string{
+	"string":   TypeString,
+	"str":      TypeString,
+	"regex":    TypeRegex,
+	"re":       TypeRegex,
+	"domain":   TypeDomain,
+	"hostname": TypeDomain,
+	"ip":       TypeIP,
+	"ipv4":     TypeIP,
+	"ipv6":     TypeIP,
+	"cidr":     TypeCIDR,
+	"md5":      TypeMD5,
+	"sha1":     TypeSHA1,
+	"sha256":   TypeSHA256,
+	"hash":     "hash",
+	"filename": TypeFilename,
+	"file":     TypeFilename,
+	"glob":     TypeFilename,
+	"process":  TypeProcess,
+	"proc":     TypeProcess,
+}
+
+// LoadFile reads IOCs from a STIX 2.1 bundle, a MISP event export, a JSON
+// file (an array of IOC objects, or an object with an "iocs" array) or from a
+// text file with one IOC per line. Indicators of a STIX or MISP feed and
+// typed lines of a text file that do not compile are skipped and returned in
+// rejected; in JSON IOC files they are an error.
+//
+// A .json file is always parsed as JSON. Other files are parsed as JSON when
+// they look like it, and as text when that fails, so that a one-per-line
+// list whose first IOC starts with '[' or '{' still loads.
+func LoadFile(path string) (iocs []IOC, rejected []string, err error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".json"):
+		if len(trimmed) == 0 {
+			return nil, nil, errors.New("IOC file contained no patterns")
+		}
+		iocs, rejected, err = parseJSON(trimmed)
+	case len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{'):
+		if iocs, rejected, err = parseJSON(trimmed); err != nil {
+			iocs, rejected, err = parseText(b)
+		}
+	default:
+		iocs, rejected, err = parseText(b)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iocs) == 0 {
+		return nil, rejected, errors.New("IOC file contained no patterns")
+	}
+	return iocs, rejected, nil
+}
+
+func parseJSON(b []byte) ([]IOC, []string, error) {
+	switch detectFeed(b) {
+	case "stix":
+		return parseSTIX(b)
+	case "misp":
+		return parseMISP(b)
+	}
+
+	var raw []IOC
+	if b[0] == '[' {
+		if err := json.Unmarshal(b, &raw); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var doc struct {
+			IOCs []IOC `json:"iocs"`
+		}
+		if err := json.Unmarshal(b, &doc); err != nil {
+			return nil, nil, err
+		}
+		raw = doc.IOCs
+	}
+
+	out := make([]IOC, 0, len(raw))
+	for i, c := range raw {
+		if c.Type == "" {
+			c.Type = TypeString
+		}
+		if err := c.compile(); err != nil {
+			return nil, nil, fmt.Errorf("ioc %d: %w", i+1, err)
+		}
+		out = append(out, c)
+	}
+	return out, nil, nil
+}
+
+// parseText accepts "type:value" lines with optional " | key=value" fields,
+// e.g. "domain:evil.example | name=C2 | severity=high". A line is only typed
+// when it starts with a lower-case type name directly followed by ':' and
+// not by "//", so URIs such as "file:///tmp/x" stay literal. Other lines are
+// literal substrings, which keeps old IOC lists working. Typed lines that do
+// not compile are skipped and returned in rejected with their line number.
+func parseText(b []byte) (out []IOC, rejected []string, err error) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+		c, typed := parseTextLine(line)
+		if err := c.compile(); err != nil {
+			if !typed {
+				return nil, nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			rejected = append(rejected, fmt.Sprintf("line %d: %v", n, err))
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, rejected, s.Err()
+}
+
+func parseTextLine(line string) (IOC, bool) {
+	c := IOC{Type: TypeString, Value: line}
+	typ, rest, ok := strings.Cut(line, ":")
+	if !ok || strings.HasPrefix(rest, "//") {
+		return c, false
+	}
+	t, known := typeAliases[typ]
+	if !known {
+		return c, false
+	}
+	c.Type = t
+	parts := strings.Split(rest, " | ")
+	c.Value = parts[0]
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		switch strings.TrimSpace(strings.ToLower(k)) {
+		case "name":
+			c.Name = strings.TrimSpace(v)
+		case "severity":
+			c.Severity = strings.TrimSpace(v)
+		case "reference", "ref":
+			c.Reference = strings.TrimSpace(v)
+		}
+	}
+	if c.Type != TypeString && c.Type != TypeRegex {
+		c.Value = strings.TrimSpace(c.Value)
+	}
+	return c, true
+}
+
+func (c *IOC) compile() error {
+	if t, ok := typeAliases[strings.ToLower(c.Type)]; ok {
+		c.Type = t
+	} else {
+		return fmt.Errorf("unknown IOC type %q", c.Type)
+	}
+	if c.Value == "" {
+		return errors.New("empty IOC value")
+	}
+
+	switch c.Type {
+	case "hash":
+		switch len(c.Value) {
+		case 32:
+			c.Type = TypeMD5
+		case 40:
+			c.Type = TypeSHA1
+		case 64:
+			c.Type = TypeSHA256
+		default:
+			return fmt.Errorf("hash %q has unrecognised length", c.Value)
+		}
+		c.Value = strings.ToLower(c.Value)
+	case TypeMD5, TypeSHA1, TypeSHA256:
+		c.Value = strings.ToLower(c.Value)
+	case TypeRegex:
+		re, err := regexp.Compile(c.Value)
+		if err != nil {
+			return err
+		}
+		c.re = re
+	case TypeDomain:
+		c.Value = strings.TrimSuffix(strings.ToLower(c.Value), ".")
+		c.Value = strings.TrimPrefix(c.Value, "*.")
+	case TypeIP:
+		ip := net.ParseIP(c.Value)
+		if ip == nil {
+			return fmt.Errorf("invalid IP %q", c.Value)
+		}
+		c.ip = ip
+	case TypeCIDR:
+		_, n, err := net.ParseCIDR(c.Value)
+		if err != nil {
+			return err
+		}
+		c.net = n
+	case TypeFilename:
+		if _, err := path.Match(c.Value, ""); err != nil {
+			return fmt.Errorf("invalid filename glob %q: %w", c.Value, err)
+		}
+	}
+	return nil
+}
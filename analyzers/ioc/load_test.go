@@ -0,0 +1,157 @@
+package ioc
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTextLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		typ   string
+		value string
+		typed bool
+	}{
+		{"evil.example", TypeString, "evil.example", false},
+		{"domain:evil.example", TypeDomain, "evil.example", true},
+		{"ip: 10.0.0.1 ", TypeIP, "10.0.0.1", true},
+		{"regex: a b ", TypeRegex, " a b ", true},
+		{"file:///tmp/x", TypeString, "file:///tmp/x", false},
+		{"http://evil.example/x", TypeString, "http://evil.example/x", false},
+		{"Re: invoice", TypeString, "Re: invoice", false},
+		{"Domain:evil.example", TypeString, "Domain:evil.example", false},
+		{"note:something", TypeString, "note:something", false},
+		{"C:\\Windows\\evil.exe", TypeString, "C:\\Windows\\evil.exe", false},
+	}
+	for _, tt := range tests {
+		c, typed := parseTextLine(tt.line)
+		if c.Type != tt.typ || c.Value != tt.value || typed != tt.typed {
+			t.Errorf("parseTextLine(%q) = %q %q %v, want %q %q %v", tt.line, c.Type, c.Value, typed, tt.typ, tt.value, tt.typed)
+		}
+	}
+}
+
+func TestParseTextFields(t *testing.T) {
+	c, typed := parseTextLine("domain:evil.example | name=C2 | severity=high | ref=https://x/1")
+	if !typed || c.Value != "evil.example" || c.Name != "C2" || c.Severity != "high" || c.Reference != "https://x/1" {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestParseText(t *testing.T) {
+	src := `
+evil.example
+hash:0123456789abcdef0123456789ABCDEF
+ip:999.1.1.1
+regex:(
+cidr:10.0.0.0/8
+hash:abc
+file:///tmp/x
+`
+	out, rejected, err := parseText([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range out {
+		got = append(got, c.Type+"="+c.Value)
+	}
+	want := []string{
+		"string=evil.example",
+		"md5=0123456789abcdef0123456789abcdef",
+		"cidr=10.0.0.0/8",
+		"string=file:///tmp/x",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("iocs =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if len(rejected) != 3 ||
+		!strings.HasPrefix(rejected[0], "line 4: ") ||
+		!strings.HasPrefix(rejected[1], "line 5: ") ||
+		!strings.HasPrefix(rejected[2], "line 7: ") {
+		t.Errorf("rejected = %q, want lines 4, 5 and 7", rejected)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name     string
+		file     string
+		iocs     int
+		rejected int
+		err      string
+	}{
+		{name: "text", file: write("a.txt", "evil.example\nip:10.0.0.1\nip:bad\n"), iocs: 2, rejected: 1},
+		{name: "json array", file: write("b.json", `[{"type":"domain","value":"evil.example"},{"value":"x"}]`), iocs: 2},
+		{name: "json object", file: write("c.json", `{"iocs":[{"type":"ip","value":"10.0.0.1"}]}`), iocs: 1},
+		{name: "json bad ioc", file: write("d.json", `[{"type":"ip","value":"bad"}]`), err: "ioc 1"},
+		{name: "bracket text", file: write("e.txt", "[evil]\nother\n"), iocs: 2},
+		{name: "only rejected", file: write("f.txt", "ip:bad\n"), rejected: 1, err: "no patterns"},
+		{name: "empty", file: write("g.txt", "\n\n"), err: "no patterns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			iocs, rejected, err := LoadFile(tt.file)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if len(iocs) != tt.iocs || len(rejected) != tt.rejected {
+				t.Errorf("iocs=%d rejected=%v, want %d and %d", len(iocs), rejected, tt.iocs, tt.rejected)
+			}
+		})
+	}
+}
+
+func TestScanStream(t *testing.T) {
+	iocs, _, err := parseText([]byte("evil\nip:10.0.0.1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := newEngine(iocs)
+	data := "clean line\nconnect to 10.0.0.1 port 80\nan evil line\nanother evil line\n"
+
+	var got []Match
+	err = e.scanStream(context.Background(), strings.NewReader(data), false, func(m Match) bool {
+		got = append(got, m)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []int
+	for _, m := range got {
+		lines = append(lines, m.Line)
+	}
+	if len(lines) != 3 || lines[0] != 2 || lines[1] != 3 || lines[2] != 4 {
+		t.Errorf("match lines = %v, want [2 3 4]", lines)
+	}
+
+	err = e.scanStream(context.Background(), strings.NewReader(data), false, func(Match) bool { return false })
+	if !errors.Is(err, errStopScan) {
+		t.Errorf("refused emit: err = %v, want errStopScan", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = e.scanStream(ctx, strings.NewReader(data), false, func(Match) bool { return true })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled ctx: err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,174 @@
+package ioc
+
+import (
+	"net"
+	"path"
+	"strings"
+)
+
+func (c IOC) match(field string, value string, path string, line int, text string) Match {
+	return Match{
+		Pattern:   c.Value,
+		Type:      c.Type,
+		Field:     field,
+		Value:     value,
+		Name:      c.Name,
+		Severity:  c.Severity,
+		Reference: c.Reference,
+		Source:    c.Source,
+		SourceID:  c.SourceID,
+		Path:      path,
+		Line:      line,
+		FirstLine: text,
+	}
+}
+
+func (e *engine) matchSnapshot(rec snapshotRecord) []Match {
+	var out []Match
+	if rec.SHA256 != "" {
+		sum := strings.ToLower(rec.SHA256)
+		for _, c := range e.byType[TypeSHA256] {
+			if c.Value == sum {
+				out = append(out, c.match("snapshot.sha256", sum, rec.Path, 0, ""))
+			}
+		}
+	}
+	if rec.Path != "" && rec.Type != "dir" {
+		base := path.Base(rec.Path)
+		for _, c := range e.byType[TypeFilename] {
+			if globMatch(c.Value, base) || globMatch(c.Value, rec.Path) {
+				out = append(out, c.match("snapshot.path", rec.Path, rec.Path, 0, ""))
+			}
+		}
+	}
+	return out
+}
+
+// domainBoundary accepts evil.example inside "x.evil.example/" but not inside
+// "notevil.example" or "evil.example.attacker.net".
+func domainBoundary(text []byte, start int, end int) bool {
+	if start > 0 {
+		b := text[start-1]
+		if b != '.' && isHostChar(b) {
+			return false
+		}
+	}
+	if end < len(text) {
+		b := text[end]
+		if b == '.' {
+			return end+1 >= len(text) || !isHostChar(text[end+1]) || text[end+1] == '.'
+		}
+		if isHostChar(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func fileBoundary(text []byte, start int, end int) bool {
+	if start > 0 {
+		b := text[start-1]
+		if b != '/' && isPathChar(b) {
+			return false
+		}
+	}
+	return end >= len(text) || !isPathChar(text[end])
+}
+
+func processBoundary(text []byte, start int, end int) bool {
+	if start > 0 {
+		b := text[start-1]
+		if b != '/' && b != '[' && b != ' ' && b != '\t' && b != '(' {
+			return false
+		}
+	}
+	if end < len(text) {
+		b := text[end]
+		return b == ' ' || b == '\t' || b == ']' || b == ':' || b == ')' || b == '\x00'
+	}
+	return true
+}
+
+func globMatch(pattern string, name string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.EqualFold(pattern, name)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func parseIPToken(tok string) net.IP {
+	tok = strings.Trim(tok, ".:")
+	if ip := net.ParseIP(tok); ip != nil {
+		return ip
+	}
+	// "1.2.3.4:443" and "[::1]:22" style endpoints.
+	if host, _, err := net.SplitHostPort(tok); err == nil {
+		return net.ParseIP(strings.Trim(host, "[]"))
+	}
+	if i := strings.LastIndexByte(tok, ':'); i > 0 && strings.Count(tok, ":") == 1 {
+		return net.ParseIP(tok[:i])
+	}
+	return nil
+}
+
+// processNames extracts candidate executable names from a process listing
+// line: the basename of each absolute path and of the first word.
+func processNames(line string) []string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	var out []string
+	seen := map[string]bool{}
+	addName := func(s string) {
+		s = strings.Trim(s, "[]():")
+		if s == "" || seen[s] {
+			return
+		}
+		seen[s] = true
+		out = append(out, s)
+	}
+	for _, f := range fields {
+		if strings.HasPrefix(f, "/") {
+			addName(path.Base(f))
+		}
+	}
+	addName(path.Base(fields[0]))
+	// ps aux puts the command in column 11.
+	if len(fields) >= 11 {
+		addName(path.Base(fields[10]))
+	}
+	return out
+}
+
+func forTokens(s string, keep func(byte) bool, fn func(tok string, at int)) {
+	start := -1
+	for i := 0; i < len(s); i++ {
+		if keep(s[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			fn(s[start:i], start)
+			start = -1
+		}
+	}
+	if start >= 0 {
+		fn(s[start:], start)
+	}
+}
+
+func isHostChar(b byte) bool {
+	return b == '.' || b == '-' || b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+func isIPChar(b byte) bool {
+	return b == '.' || b == ':' || b == '[' || b == ']' || (b >= '0' && b <= '9') || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func isPathChar(b byte) bool {
+	return b > ' ' && b != '"' && b != '\'' && b != ',' && b != ';' && b != '=' && b != '(' && b != ')'
+}
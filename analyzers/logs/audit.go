@@ -0,0 +1,115 @@
+package logs
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAuditLine parses a raw auditd record such as
+// "type=SYSCALL msg=audit(1700000000.123:42): arch=c000003e syscall=59 ...".
+// Nested msg='...' payloads (USER_* records) are flattened into Fields.
+func ParseAuditLine(line string) (Record, bool) {
+	if !strings.HasPrefix(line, "type=") {
+		return Record{}, false
+	}
+	i := strings.Index(line, "msg=audit(")
+	if i < 0 {
+		return Record{}, false
+	}
+	j := strings.Index(line[i:], "):")
+	if j < 0 {
+		return Record{}, false
+	}
+	stamp := line[i+len("msg=audit(") : i+j]
+	secs, serial, _ := strings.Cut(stamp, ":")
+	whole, frac, _ := strings.Cut(secs, ".")
+	sec, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return Record{}, false
+	}
+	var nsec int64
+	if frac != "" {
+		for len(frac) < 9 {
+			frac += "0"
+		}
+		nsec, _ = strconv.ParseInt(frac[:9], 10, 64)
+	}
+	ts := time.Unix(sec, nsec).UTC()
+
+	fields := map[string]string{
+		"type":   strings.TrimSpace(line[len("type="):i]),
+		"serial": serial,
+	}
+	body := strings.TrimSpace(line[i+j+2:])
+	parseKV(body, fields)
+	if inner, ok := fields["msg"]; ok {
+		delete(fields, "msg")
+		parseKV(inner, fields)
+	}
+
+	r := Record{
+		Time:    ts,
+		Kind:    "audit",
+		Message: body,
+		Fields:  fields,
+		PID:     fields["pid"],
+		Host:    fields["hostname"],
+	}
+	if v := fields["comm"]; v != "" {
+		r.Program = v
+	} else if v := fields["exe"]; v != "" {
+		r.Program = v
+	}
+	if v := fields["acct"]; v != "" {
+		r.User = v
+	} else if v := fields["AUID"]; v != "" {
+		r.User = v
+	} else if v := fields["auid"]; v != "" {
+		r.User = v
+	}
+	return r, true
+}
+
+func parseKV(s string, out map[string]string) {
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return
+		}
+		key := s[:eq]
+		if strings.ContainsAny(key, " ") {
+			sp := strings.IndexByte(s, ' ')
+			s = s[sp+1:]
+			continue
+		}
+		s = s[eq+1:]
+
+		var val string
+		switch {
+		case strings.HasPrefix(s, "\""):
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		case strings.HasPrefix(s, "'"):
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				val, s = s[1:], ""
+			} else {
+				val, s = s[1:end+1], s[end+2:]
+			}
+		default:
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				val, s = s, ""
+			} else {
+				val, s = s[:end], s[end:]
+			}
+		}
+		out[key] = val
+	}
+}
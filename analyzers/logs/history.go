@@ -0,0 +1,50 @@
+package logs
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadShellHistory emits one record per command. Bash writes "#<epoch>"
+// comment lines when HISTTIMEFORMAT is set and zsh writes
+// ": <epoch>:<duration>;<command>" with EXTENDED_HISTORY; commands without
+// either are emitted with a zero Time.
+func ReadShellHistory(r io.Reader, user string, fn func(Record) error) error {
+	var pending time.Time
+	return readLines(r, func(n int, line string) error {
+		if strings.HasPrefix(line, "#") {
+			if secs, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				pending = time.Unix(secs, 0).UTC()
+				return nil
+			}
+		}
+		if strings.HasPrefix(line, ": ") {
+			if meta, cmd, ok := strings.Cut(line[2:], ";"); ok {
+				secs, _, _ := strings.Cut(meta, ":")
+				if v, err := strconv.ParseInt(secs, 10, 64); err == nil {
+					return fn(Record{
+						Time:    time.Unix(v, 0).UTC(),
+						Kind:    "shell_history",
+						Line:    n,
+						User:    user,
+						Message: cmd,
+					})
+				}
+			}
+		}
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		ts := pending
+		pending = time.Time{}
+		return fn(Record{
+			Time:    ts,
+			Kind:    "shell_history",
+			Line:    n,
+			User:    user,
+			Message: line,
+		})
+	})
+}
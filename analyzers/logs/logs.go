@@ -0,0 +1,115 @@
+package logs
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"iron-sentinel/collectors"
+)
+
+type Record struct {
+	Time     time.Time         `json:"time"`
+	Kind     string            `json:"kind"`
+	Artifact string            `json:"artifact"`
+	Line     int               `json:"line,omitempty"`
+	Host     string            `json:"host,omitempty"`
+	Program  string            `json:"program,omitempty"`
+	PID      string            `json:"pid,omitempty"`
+	User     string            `json:"user,omitempty"`
+	Message  string            `json:"message"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+var ErrUnsupported = errors.New("unsupported log kind")
+
+// Kinds lists the artifact kinds ReadArtifact understands. Collectors set the
+// kind in Artifact.Metadata["kind"].
+var Kinds = []string{"auth", "syslog", "audit", "wtmp", "btmp", "shell_history"}
+
+func Supported(a collectors.Artifact) bool {
+	k := a.Metadata["kind"]
+	for _, s := range Kinds {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
+
+func ReadArtifact(outputDir string, a collectors.Artifact, fn func(Record) error) error {
+	path := filepath.Join(outputDir, filepath.FromSlash(a.RelativePath))
+	ref, err := time.Parse(time.RFC3339Nano, a.CollectedAt)
+	if err != nil {
+		ref = time.Now().UTC()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	emit := func(r Record) error {
+		r.Artifact = a.RelativePath
+		return fn(r)
+	}
+
+	switch kind := a.Metadata["kind"]; kind {
+	case "auth", "syslog":
+		return readLines(f, func(n int, line string) error {
+			r, ok := ParseSyslogLine(line, ref)
+			if !ok {
+				return nil
+			}
+			r.Kind = kind
+			r.Line = n
+			return emit(r)
+		})
+	case "audit":
+		return readLines(f, func(n int, line string) error {
+			r, ok := ParseAuditLine(line)
+			if !ok {
+				return nil
+			}
+			r.Line = n
+			return emit(r)
+		})
+	case "wtmp", "btmp":
+		return ReadUtmp(f, kind, emit)
+	case "shell_history":
+		return ReadShellHistory(f, a.Metadata["user"], emit)
+	default:
+		return ErrUnsupported
+	}
+}
+
+func readLines(r io.Reader, fn func(n int, line string) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	n := 0
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			n++
+			if ferr := fn(n, trimEOL(line)); ferr != nil {
+				return ferr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func trimEOL(s string) string {
+	for len(s) > 0 && (s[len(s)-1] == '\n' || s[len(s)-1] == '\r') {
+		s = s[:len(s)-1]
+	}
+	return s
+}
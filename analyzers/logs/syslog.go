@@ -0,0 +1,89 @@
+package logs
+
+import (
+	"strings"
+	"time"
+)
+
+// ParseSyslogLine understands the classic BSD format ("Jan  2 15:04:05 host
+// prog[pid]: msg") and the RFC3339 timestamps written by rsyslog/journald
+// forwarding. BSD timestamps carry no year, so the year is taken from ref and
+// rolled back when that would put the entry after ref.
+func ParseSyslogLine(line string, ref time.Time) (Record, bool) {
+	if len(line) < 16 {
+		return Record{}, false
+	}
+
+	var ts time.Time
+	var rest string
+
+	if sp := strings.IndexByte(line, ' '); sp > 0 && line[0] >= '0' && line[0] <= '9' {
+		t, err := time.Parse(time.RFC3339Nano, line[:sp])
+		if err != nil {
+			return Record{}, false
+		}
+		ts = t
+		rest = line[sp+1:]
+	} else {
+		t, err := time.ParseInLocation("Jan _2 15:04:05", line[:15], time.Local)
+		if err != nil {
+			return Record{}, false
+		}
+		ts = time.Date(ref.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local)
+		if ts.After(ref.Add(24 * time.Hour)) {
+			ts = ts.AddDate(-1, 0, 0)
+		}
+		rest = strings.TrimLeft(line[15:], " ")
+	}
+
+	r := Record{Time: ts.UTC()}
+
+	host, rest, ok := strings.Cut(rest, " ")
+	if !ok {
+		r.Message = host
+		return r, true
+	}
+	r.Host = host
+
+	tag, msg, ok := strings.Cut(rest, ": ")
+	if !ok || strings.ContainsAny(tag, " ") {
+		r.Message = rest
+		return r, true
+	}
+	if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+		r.Program = tag[:i]
+		r.PID = tag[i+1 : len(tag)-1]
+	} else {
+		r.Program = tag
+	}
+	r.Message = msg
+	r.User = authUser(msg)
+	return r, true
+}
+
+// authUser pulls the account name out of the common sshd/sudo/su messages.
+func authUser(msg string) string {
+	for _, marker := range []string{" for invalid user ", " for user ", " for ", "user=", "USER="} {
+		i := strings.Index(msg, marker)
+		if i < 0 {
+			continue
+		}
+		v := msg[i+len(marker):]
+		if j := strings.IndexAny(v, " ;"); j >= 0 {
+			v = v[:j]
+		}
+		if v != "" {
+			return v
+		}
+	}
+	if strings.HasPrefix(msg, "pam_unix(") {
+		if i := strings.Index(msg, "user "); i >= 0 {
+			v := msg[i+5:]
+			if j := strings.IndexAny(v, " ("); j >= 0 {
+				v = v[:j]
+			}
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package logs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// Linux struct utmp as written by glibc on 64-bit and 32-bit hosts alike.
+const utmpRecordSize = 384
+
+var utmpTypes = map[int16]string{
+	1: "run_level",
+	2: "boot_time",
+	3: "new_time",
+	4: "old_time",
+	5: "init_process",
+	6: "login_process",
+	7: "user_process",
+	8: "dead_process",
+	9: "accounting",
+}
+
+func ReadUtmp(r io.Reader, kind string, fn func(Record) error) error {
+	buf := make([]byte, utmpRecordSize)
+	n := 0
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		n++
+
+		typ := int16(binary.LittleEndian.Uint16(buf[0:2]))
+		pid := int32(binary.LittleEndian.Uint32(buf[4:8]))
+		line := cString(buf[8:40])
+		user := cString(buf[44:76])
+		host := cString(buf[76:332])
+		sec := int32(binary.LittleEndian.Uint32(buf[340:344]))
+		usec := int32(binary.LittleEndian.Uint32(buf[344:348]))
+		if sec == 0 {
+			continue
+		}
+
+		fields := map[string]string{
+			"ut_type": utmpTypes[typ],
+			"tty":     line,
+		}
+		if addr := utmpAddr(buf[348:364]); addr != "" {
+			fields["addr"] = addr
+		}
+
+		msg := utmpTypes[typ]
+		if user != "" {
+			msg += " user=" + user
+		}
+		if line != "" {
+			msg += " tty=" + line
+		}
+		if host != "" {
+			msg += " host=" + host
+		}
+
+		rec := Record{
+			Time:    time.Unix(int64(sec), int64(usec)*1000).UTC(),
+			Kind:    kind,
+			Line:    n,
+			Host:    host,
+			User:    user,
+			Message: msg,
+			Fields:  fields,
+		}
+		if pid != 0 {
+			rec.PID = strconv.Itoa(int(pid))
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func utmpAddr(b []byte) string {
+	if bytes.Equal(b, make([]byte, 16)) {
+		return ""
+	}
+	if bytes.Equal(b[4:], make([]byte, 12)) {
+		return net.IP(b[:4]).String()
+	}
+	return net.IP(b).String()
+}
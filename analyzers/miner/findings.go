@@ -0,0 +1,105 @@
+package miner
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// Findings reports one finding per miner with everything correlated to it.
+// Confidence is high once a stratum URL, miner flags or a miner config
+// point at mining rather than merely a busy process.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Miners))
+	for _, m := range res.Miners {
+		label := m.Exe
+		if label == "" {
+			label = m.Name
+		}
+		if label == "" && len(m.Configs) > 0 {
+			label = m.Configs[0]
+		}
+		f := findings.Finding{
+			Analyzer:   "miner",
+			Rule:       "miner.cryptominer",
+			Severity:   findings.SeverityMedium,
+			Confidence: findings.ConfidenceMedium,
+			Title:      "Cryptocurrency miner: " + label,
+			Attack:     []string{"T1496"},
+			Metadata: map[string]string{
+				"score":   fmt.Sprintf("%d", m.Score),
+				"signals": strings.Join(m.Signals, ","),
+				"pools":   strings.Join(m.Pools, ","),
+				"wallets": strings.Join(m.Wallets, ","),
+			},
+		}
+		switch {
+		case m.Score >= 100:
+			f.Severity = findings.SeverityCritical
+		case m.Score >= 60:
+			f.Severity = findings.SeverityHigh
+		}
+		for _, s := range m.Signals {
+			if s == SignalStratum || s == SignalFlags || s == SignalConfig {
+				f.Confidence = findings.ConfidenceHigh
+			}
+		}
+
+		var b strings.Builder
+		if len(m.PIDs) > 0 {
+			fmt.Fprintf(&b, "%s runs as pid %s", label, joinInts(m.PIDs))
+			if m.User != "" {
+				fmt.Fprintf(&b, " (user %s)", m.User)
+			}
+			fmt.Fprintf(&b, " at %.1f%% CPU.", m.CPUPercent)
+		} else {
+			fmt.Fprintf(&b, "Miner configuration at %s.", strings.Join(m.Configs, ", "))
+		}
+		if len(m.Pools) > 0 {
+			fmt.Fprintf(&b, " Pool: %s.", strings.Join(m.Pools, ", "))
+		}
+		if len(m.Wallets) > 0 {
+			fmt.Fprintf(&b, " Wallet: %s.", strings.Join(m.Wallets, ", "))
+		}
+		fmt.Fprintf(&b, " Signals: %s.", strings.Join(m.Signals, ", "))
+		f.Description = b.String()
+
+		for _, a := range m.Artifacts {
+			e := findings.Evidence{Artifact: a}
+			switch {
+			case strings.HasSuffix(a, "processes.jsonl"):
+				e.Path = m.Exe
+				e.Excerpt = strings.Join(m.Cmdline, " ")
+			case strings.HasSuffix(a, "sockets.jsonl"):
+				e.Excerpt = strings.Join(m.Connections, ", ")
+			default:
+				if len(m.Configs) > 0 {
+					e.Path = m.Configs[0]
+				}
+			}
+			f.Evidence = append(f.Evidence, e)
+		}
+		if len(m.PIDs) > 0 {
+			f.Metadata["pids"] = joinInts(m.PIDs)
+			f.Metadata["cpu_percent"] = fmt.Sprintf("%.1f", m.CPUPercent)
+		}
+		if m.User != "" {
+			f.Metadata["user"] = m.User
+		}
+		if len(m.Configs) > 0 {
+			f.Metadata["configs"] = strings.Join(m.Configs, ",")
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = fmt.Sprintf("%d", n)
+	}
+	return strings.Join(s, ",")
+}
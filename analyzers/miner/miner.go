@@ -0,0 +1,473 @@
+package miner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/archive"
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+// Signal weights. A miner is reported once its signals reach MinScore, so
+// a busy process or a pool port alone is not enough.
+const (
+	weightStratum  = 50
+	weightFlags    = 40
+	weightConfig   = 40
+	weightName     = 30
+	weightPoolPort = 25
+	weightHighCPU  = 20
+	weightWallet   = 20
+	MinScore       = 40
+	// HighCPU is the lifetime CPU percentage that counts as busy.
+	HighCPU       = 50.0
+	maxConfigSize = 1 << 20
+)
+
+const (
+	SignalStratum  = "stratum_url"
+	SignalFlags    = "miner_flags"
+	SignalConfig   = "miner_config"
+	SignalName     = "miner_name"
+	SignalPoolPort = "pool_port"
+	SignalHighCPU  = "high_cpu"
+	SignalWallet   = "wallet"
+)
+
+type Options struct {
+	// Live reads config.json next to suspect processes on the host.
+	Live bool
+}
+
+// Miner correlates the processes of one executable with their pool
+// connections and configuration. A miner found only through a config file
+// has no processes.
+type Miner struct {
+	Exe         string   `json:"exe,omitempty"`
+	Name        string   `json:"name,omitempty"`
+	PIDs        []int    `json:"pids,omitempty"`
+	User        string   `json:"user,omitempty"`
+	CPUPercent  float64  `json:"cpu_percent,omitempty"`
+	Cmdline     []string `json:"cmdline,omitempty"`
+	Pools       []string `json:"pools"`
+	Wallets     []string `json:"wallets"`
+	Connections []string `json:"connections,omitempty"`
+	Configs     []string `json:"configs,omitempty"`
+	Signals     []string `json:"signals"`
+	Score       int      `json:"score"`
+	Artifacts   []string `json:"artifacts"`
+}
+
+type Result struct {
+	Miners   []Miner  `json:"miners"`
+	Configs  int      `json:"configs"`
+	Live     bool     `json:"live"`
+	Skipped  []string `json:"skipped,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Finished string   `json:"finished"`
+}
+
+// PoolPorts are the default ports of public mining pools.
+var PoolPorts = map[int]bool{
+	3333: true, 3334: true, 3357: true, 4444: true, 5555: true, 6666: true, 7777: true,
+	9999: true, 10128: true, 14433: true, 14444: true, 45560: true, 45700: true,
+}
+
+var minerNames = map[string]bool{
+	"xmrig": true, "xmrig-notls": true, "xmr-stak": true, "xmr-stak-rx": true, "minerd": true,
+	"cpuminer": true, "cpuminer-multi": true, "ccminer": true, "ethminer": true, "t-rex": true,
+	"nbminer": true, "lolminer": true, "phoenixminer": true, "nanominer": true, "srbminer-multi": true,
+	"teamredminer": true, "gminer": true, "bminer": true, "cgminer": true, "bfgminer": true,
+	"sgminer": true, "kdevtmpfsi": true, "kinsing": true,
+}
+
+// minerFlags only appear on miner command lines.
+var minerFlags = map[string]bool{
+	"--donate-level": true, "--donate-over-proxy": true, "--randomx-mode": true, "--randomx-1gb-pages": true,
+	"--cpu-priority": true, "--cpu-max-threads-hint": true, "--max-cpu-usage": true, "--nicehash": true,
+	"--coin": true, "--rig-id": true, "--cpu-affinity": true, "--no-huge-pages": true,
+}
+
+var (
+	stratumRe = regexp.MustCompile(`(?i)stratum[0-9]?\+(?:tcp|ssl|tls)://[^\s"',;]+`)
+	algoRe    = regexp.MustCompile(`(?i)^(rx/|cn/|cn-|randomx|cryptonight|kawpow|ethash|etchash|argon2|ghostrider|autolykos)`)
+	walletRes = []*regexp.Regexp{
+		regexp.MustCompile(`\b[48][0-9AB][1-9A-HJ-NP-Za-km-z]{93}(?:[1-9A-HJ-NP-Za-km-z]{11})?\b`), // Monero
+		regexp.MustCompile(`\bbc1[ac-hj-np-z02-9]{25,59}\b`),                                       // Bitcoin bech32
+		regexp.MustCompile(`\b0x[0-9a-fA-F]{40}\b`),                                                // Ethereum
+	}
+)
+
+// Scan correlates processes, sockets and miner configuration of a case.
+func Scan(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	res := Result{Miners: []Miner{}, Live: opts.Live}
+	read := func(collector, name string, fn func(raw []byte)) string {
+		a, ok := collectors.Latest(artifacts, collector, name)
+		if !ok {
+			return ""
+		}
+		err := collectors.ReadLines(ctx, outDir, a, func(line []byte) error {
+			fn(line)
+			return nil
+		})
+		if err != nil {
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
+		}
+		return a.RelativePath
+	}
+
+	var procs []linux.Process
+	procRel := read("processes", "processes.jsonl", func(raw []byte) {
+		var p linux.Process
+		if json.Unmarshal(raw, &p) == nil && !p.KernelThread {
+			procs = append(procs, p)
+		}
+	})
+	conns := map[int][]string{}
+	sockRel := read("sockets", "sockets.jsonl", func(raw []byte) {
+		var s linux.Socket
+		if json.Unmarshal(raw, &s) != nil || s.PID == 0 || !PoolPorts[s.RemotePort] {
+			return
+		}
+		if s.State == "ESTABLISHED" || s.State == "SYN_SENT" {
+			conns[s.PID] = append(conns[s.PID], fmt.Sprintf("%s:%d", s.RemoteAddr, s.RemotePort))
+		}
+	})
+
+	byKey := map[string]*Miner{}
+	var order []string
+	for _, p := range procs {
+		sig := processSignals(p, conns[p.PID])
+		if sig.score == 0 {
+			continue
+		}
+		key := p.Exe
+		if key == "" {
+			key = "pid:" + strconv.Itoa(p.PID)
+		}
+		m, ok := byKey[key]
+		if !ok {
+			m = &Miner{Exe: p.Exe, Name: p.Name, User: p.User, Cmdline: p.Cmdline}
+			byKey[key] = m
+			order = append(order, key)
+		}
+		m.PIDs = append(m.PIDs, p.PID)
+		if p.CPUPercent > m.CPUPercent {
+			m.CPUPercent = p.CPUPercent
+		}
+		m.Pools = appendNew(m.Pools, sig.pools...)
+		m.Wallets = appendNew(m.Wallets, sig.wallets...)
+		m.Connections = appendNew(m.Connections, conns[p.PID]...)
+		m.Signals = appendNew(m.Signals, sig.signals...)
+		m.Artifacts = appendNew(m.Artifacts, procRel)
+		if len(conns[p.PID]) > 0 {
+			m.Artifacts = appendNew(m.Artifacts, sockRel)
+		}
+	}
+
+	// Configs found next to a suspect executable or its working directory
+	// belong to that miner; the rest stand alone.
+	dirs := map[string]string{}
+	for _, p := range procs {
+		key := p.Exe
+		if key == "" {
+			key = "pid:" + strconv.Itoa(p.PID)
+		}
+		if _, ok := byKey[key]; !ok {
+			continue
+		}
+		if p.Exe != "" {
+			dirs[path.Dir(p.Exe)] = key
+		}
+		if p.Cwd != "" && p.Cwd != "/" {
+			dirs[p.Cwd] = key
+		}
+	}
+	attach := func(c config) {
+		res.Configs++
+		key, ok := dirs[path.Dir(c.path)]
+		if !ok {
+			key = "config:" + c.path
+			byKey[key] = &Miner{}
+			order = append(order, key)
+		}
+		m := byKey[key]
+		m.Configs = appendNew(m.Configs, c.path)
+		m.Pools = appendNew(m.Pools, c.pools...)
+		m.Wallets = appendNew(m.Wallets, c.wallets...)
+		m.Signals = appendNew(m.Signals, SignalConfig)
+		m.Artifacts = appendNew(m.Artifacts, c.artifact)
+	}
+
+	for _, a := range artifacts {
+		if a.Collector != "fs_snapshot" || path.Base(a.RelativePath) != "files.tar.gz" {
+			continue
+		}
+		w := archive.NewWalker()
+		err := w.WalkFile(ctx, a.RelativePath, filepath.Join(outDir, filepath.FromSlash(a.RelativePath)), func(name string, r io.Reader) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			p := "/" + strings.TrimPrefix(name[strings.LastIndexByte(name, '!')+1:], "/")
+			if !strings.HasSuffix(strings.ToLower(p), ".json") {
+				return nil
+			}
+			data, err := io.ReadAll(io.LimitReader(r, maxConfigSize+1))
+			if err != nil || len(data) > maxConfigSize {
+				return nil
+			}
+			if c, ok := parseConfig(data); ok {
+				c.path, c.artifact = p, a.RelativePath
+				attach(c)
+			}
+			return nil
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
+		}
+		res.Skipped = append(res.Skipped, w.Skipped...)
+	}
+
+	if opts.Live {
+		var live []string
+		for d := range dirs {
+			live = append(live, filepath.Join(d, "config.json"))
+		}
+		sort.Strings(live)
+		for _, p := range live {
+			if containsConfig(byKey, filepath.ToSlash(p)) {
+				continue
+			}
+			data, err := readLimited(p)
+			if err != nil {
+				continue
+			}
+			if c, ok := parseConfig(data); ok {
+				c.path, c.artifact = filepath.ToSlash(p), "live"
+				attach(c)
+			}
+		}
+	}
+
+	for _, key := range order {
+		m := byKey[key]
+		if len(m.Wallets) > 0 {
+			m.Signals = appendNew(m.Signals, SignalWallet)
+		}
+		if len(m.Pools) == 0 {
+			m.Pools = append(m.Pools, m.Connections...)
+		}
+		for _, s := range m.Signals {
+			m.Score += weight(s)
+		}
+		if m.Score < MinScore {
+			continue
+		}
+		if m.Pools == nil {
+			m.Pools = []string{}
+		}
+		if m.Wallets == nil {
+			m.Wallets = []string{}
+		}
+		sort.Ints(m.PIDs)
+		res.Miners = append(res.Miners, *m)
+	}
+	sort.SliceStable(res.Miners, func(i, j int) bool { return res.Miners[i].Score > res.Miners[j].Score })
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+type signals struct {
+	signals []string
+	pools   []string
+	wallets []string
+	score   int
+}
+
+func processSignals(p linux.Process, conns []string) signals {
+	var s signals
+	add := func(name string) {
+		if !slices.Contains(s.signals, name) {
+			s.signals = append(s.signals, name)
+			s.score += weight(name)
+		}
+	}
+	line := strings.Join(p.Cmdline, " ")
+	if urls := stratumRe.FindAllString(line, -1); len(urls) > 0 {
+		add(SignalStratum)
+		s.pools = appendNew(s.pools, urls...)
+	}
+	flagged := false
+	for i, arg := range p.Cmdline {
+		flag, val, hasVal := strings.Cut(arg, "=")
+		if !hasVal && i+1 < len(p.Cmdline) {
+			val = p.Cmdline[i+1]
+		}
+		switch {
+		case minerFlags[flag]:
+			flagged = true
+		case (flag == "-a" || flag == "--algo") && algoRe.MatchString(val):
+			flagged = true
+		}
+	}
+	if flagged {
+		add(SignalFlags)
+	}
+	if minerNames[strings.ToLower(path.Base(p.Exe))] || minerNames[strings.ToLower(p.Name)] {
+		add(SignalName)
+	}
+	if len(conns) > 0 {
+		add(SignalPoolPort)
+	}
+	// Pool and login flags are common words; only trust them on a command
+	// line that is already a miner's.
+	if s.score > 0 {
+		for i := 0; i+1 < len(p.Cmdline); i++ {
+			switch p.Cmdline[i] {
+			case "-o", "--url":
+				if !strings.Contains(p.Cmdline[i+1], "://") {
+					s.pools = appendNew(s.pools, p.Cmdline[i+1])
+				}
+			case "-u", "--user", "-O", "--userpass":
+				s.wallets = appendNew(s.wallets, strings.SplitN(p.Cmdline[i+1], ":", 2)[0])
+			}
+		}
+	}
+	s.wallets = appendNew(s.wallets, wallets(line)...)
+	if len(s.wallets) > 0 && s.score == 0 {
+		s.wallets = nil // a wallet-shaped string alone proves nothing
+	}
+	if s.score > 0 && p.CPUPercent >= HighCPU {
+		add(SignalHighCPU)
+	}
+	return s
+}
+
+type config struct {
+	path     string
+	artifact string
+	pools    []string
+	wallets  []string
+}
+
+// parseConfig recognizes miner configuration: XMRig-style "pools" lists,
+// "donate-level", or stratum URLs anywhere in the document.
+func parseConfig(data []byte) (config, bool) {
+	var c config
+	var doc any
+	if json.Unmarshal(data, &doc) != nil {
+		return c, false
+	}
+	miner := false
+	var walk func(key string, v any)
+	walk = func(key string, v any) {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, child := range t {
+				lk := strings.ToLower(k)
+				if lk == "pools" || lk == "donate-level" || lk == "donate_level" {
+					miner = true
+				}
+				walk(lk, child)
+			}
+		case []any:
+			for _, child := range t {
+				walk(key, child)
+			}
+		case string:
+			if stratumRe.MatchString(t) {
+				miner = true
+			}
+			switch key {
+			case "url", "pool", "pool_address", "pool-url":
+				c.pools = appendNew(c.pools, t)
+			case "user", "wallet", "login", "address", "wallet_address":
+				c.wallets = appendNew(c.wallets, strings.SplitN(t, ".", 2)[0])
+			}
+		}
+	}
+	walk("", doc)
+	if !miner {
+		return c, false
+	}
+	c.wallets = appendNew(c.wallets, wallets(string(data))...)
+	sort.Strings(c.pools)
+	return c, true
+}
+
+func wallets(s string) []string {
+	var out []string
+	for _, re := range walletRes {
+		out = appendNew(out, re.FindAllString(s, -1)...)
+	}
+	return out
+}
+
+func weight(signal string) int {
+	switch signal {
+	case SignalStratum:
+		return weightStratum
+	case SignalFlags:
+		return weightFlags
+	case SignalConfig:
+		return weightConfig
+	case SignalName:
+		return weightName
+	case SignalPoolPort:
+		return weightPoolPort
+	case SignalHighCPU:
+		return weightHighCPU
+	case SignalWallet:
+		return weightWallet
+	}
+	return 0
+}
+
+func containsConfig(miners map[string]*Miner, p string) bool {
+	for _, m := range miners {
+		if slices.Contains(m.Configs, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func readLimited(p string) ([]byte, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("%s: exceeds size limit", p)
+	}
+	return data, nil
+}
+
+func appendNew(list []string, values ...string) []string {
+	for _, v := range values {
+		if v != "" && !slices.Contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
@@ -0,0 +1,64 @@
+package persistence
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// Findings turns every hit into a finding. Severity follows the score;
+// confidence is high when a trait rare in legitimate persistence matched,
+// such as a reverse shell or a download piped to a shell.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		f := findings.Finding{
+			Analyzer:   "persistence",
+			Severity:   findings.SeverityLow,
+			Confidence: findings.ConfidenceLow,
+			Attack:     h.Attack,
+			Evidence:   []findings.Evidence{{Artifact: h.Artifact, Path: h.Path, Line: h.Line, SHA256: h.SHA256, Excerpt: h.Text}},
+			Metadata: map[string]string{
+				"kind":   h.Kind,
+				"score":  fmt.Sprintf("%d", h.Score),
+				"traits": strings.Join(h.Traits, ","),
+			},
+		}
+		switch {
+		case h.Score >= 70:
+			f.Severity = findings.SeverityCritical
+		case h.Score >= 50:
+			f.Severity = findings.SeverityHigh
+		case h.Score >= 30:
+			f.Severity = findings.SeverityMedium
+		}
+		for _, t := range h.Traits {
+			if strong(t) {
+				f.Confidence = findings.ConfidenceHigh
+				break
+			}
+			if h.Score >= 30 {
+				f.Confidence = findings.ConfidenceMedium
+			}
+		}
+		if h.Line > 0 {
+			f.Rule = "persistence.suspicious_line"
+			f.Title = fmt.Sprintf("Suspicious %s persistence: %s:%d", h.Kind, h.Path, h.Line)
+			f.Description = fmt.Sprintf("Line %d of %s (%s) scored %d.", h.Line, h.Path, strings.Join(h.Traits, ", "), h.Score)
+		} else {
+			f.Rule = "persistence.suspicious_file"
+			f.Title = fmt.Sprintf("Suspicious %s persistence file: %s", h.Kind, h.Path)
+			f.Description = fmt.Sprintf("%s (%s) scored %d.", h.Path, strings.Join(h.Traits, ", "), h.Score)
+		}
+		if h.ModTime != "" {
+			f.Metadata["mod_time"] = h.ModTime
+		}
+		if h.Package != "" {
+			f.Metadata["package"] = h.Package
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
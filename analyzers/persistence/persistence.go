@@ -0,0 +1,184 @@
+package persistence
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+// RecentWindow is how long before collection a file must have changed to
+// count as recently modified.
+const RecentWindow = 30 * 24 * time.Hour
+
+// Hit is a scored persistence line, or a whole file when Line is 0. Score
+// includes the traits of the file itself.
+type Hit struct {
+	Path     string   `json:"path"`
+	Kind     string   `json:"kind"`
+	Line     int      `json:"line,omitempty"`
+	Text     string   `json:"text,omitempty"`
+	Score    int      `json:"score"`
+	Traits   []string `json:"traits"`
+	Attack   []string `json:"attack,omitempty"`
+	Artifact string   `json:"artifact"`
+	SHA256   string   `json:"sha256,omitempty"`
+	ModTime  string   `json:"mod_time,omitempty"`
+	Package  string   `json:"package,omitempty"`
+}
+
+type Result struct {
+	Entries int `json:"entries"`
+	Lines   int `json:"lines"`
+	// Reference is the collection time recent modifications are measured
+	// from; OwnersKnown says whether package ownership was recorded.
+	Reference   string   `json:"reference"`
+	OwnersKnown bool     `json:"owners_known"`
+	Hits        []Hit    `json:"hits"`
+	Errors      []string `json:"errors,omitempty"`
+	Finished    string   `json:"finished"`
+}
+
+// Scan scores the persistence entries and lines of a case. collected is the
+// time the case was collected.
+func Scan(ctx context.Context, outDir string, artifacts []collectors.Artifact, collected time.Time) (Result, error) {
+	res := Result{Hits: []Hit{}, Reference: collected.UTC().Format(time.RFC3339Nano)}
+
+	entries := map[string]linux.PersistenceEntry{}
+	entriesRel := ""
+	if a, ok := collectors.Latest(artifacts, "persistence", "entries.jsonl"); ok {
+		entriesRel = a.RelativePath
+		res.OwnersKnown = a.Metadata["package_managers"] != ""
+		err := collectors.ReadLines(ctx, outDir, a, func(raw []byte) error {
+			var e linux.PersistenceEntry
+			if json.Unmarshal(raw, &e) == nil {
+				entries[e.Path] = e
+			}
+			return nil
+		})
+		if err != nil {
+			res.Errors = append(res.Errors, a.RelativePath+": "+err.Error())
+		}
+	}
+	res.Entries = len(entries)
+
+	fileTraits := map[string][]string{}
+	fileScore := map[string]int{}
+	for p, e := range entries {
+		if e.Target != "" {
+			continue
+		}
+		if hiddenFile(e) {
+			fileTraits[p] = append(fileTraits[p], "hidden_file")
+			fileScore[p] += weightHiddenFile
+		}
+		if res.OwnersKnown && e.Package == "" && systemPath(p) && recent(e.ModTime, collected) {
+			fileTraits[p] = append(fileTraits[p], "unowned_recent")
+			fileScore[p] += weightUnownedRecent
+		}
+	}
+
+	withLines := map[string]bool{}
+	if a, ok := collectors.Latest(artifacts, "persistence", "lines.jsonl"); ok {
+		err := collectors.ReadLines(ctx, outDir, a, func(raw []byte) error {
+			var l linux.PersistenceLine
+			if json.Unmarshal(raw, &l) != nil {
+				return nil
+			}
+			res.Lines++
+			matched := match(l)
+			if len(matched) == 0 {
+				return nil
+			}
+			e := entries[l.Path]
+			h := Hit{
+				Path:     l.Path,
+				Kind:     l.Kind,
+				Line:     l.Line,
+				Text:     l.Text,
+				Artifact: a.RelativePath,
+				SHA256:   e.SHA256,
+				ModTime:  e.ModTime,
+				Package:  e.Package,
+				Attack:   append([]string{}, KindAttack(l.Kind)...),
+			}
+			for _, r := range matched {
+				h.Score += r.Weight
+				h.Traits = append(h.Traits, r.Name)
+				for _, id := range r.Attack {
+					if !slices.Contains(h.Attack, id) {
+						h.Attack = append(h.Attack, id)
+					}
+				}
+			}
+			h.Score += fileScore[l.Path]
+			h.Traits = append(h.Traits, fileTraits[l.Path]...)
+			withLines[l.Path] = true
+			res.Hits = append(res.Hits, h)
+			return nil
+		})
+		if err != nil {
+			res.Errors = append(res.Errors, a.RelativePath+": "+err.Error())
+		}
+	}
+
+	for p, traits := range fileTraits {
+		if withLines[p] {
+			continue
+		}
+		e := entries[p]
+		res.Hits = append(res.Hits, Hit{
+			Path:     p,
+			Kind:     e.Kind,
+			Score:    fileScore[p],
+			Traits:   traits,
+			Attack:   KindAttack(e.Kind),
+			Artifact: entriesRel,
+			SHA256:   e.SHA256,
+			ModTime:  e.ModTime,
+			Package:  e.Package,
+		})
+	}
+
+	sort.SliceStable(res.Hits, func(i, j int) bool {
+		a, b := res.Hits[i], res.Hits[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Line < b.Line
+	})
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+// hiddenFile reports a dot file in a persistence directory. Shell profiles
+// in home directories are dot files by nature and are not counted.
+func hiddenFile(e linux.PersistenceEntry) bool {
+	if !strings.HasPrefix(path.Base(e.Path), ".") {
+		return false
+	}
+	return e.Kind != linux.PersistenceProfile || strings.HasPrefix(e.Path, "/etc/")
+}
+
+// systemPath reports whether p is a location package managers install to.
+// User crontabs and home directories never belong to a package.
+func systemPath(p string) bool {
+	return strings.HasPrefix(p, "/etc/") || strings.HasPrefix(p, "/usr/") || strings.HasPrefix(p, "/lib/")
+}
+
+func recent(modTime string, collected time.Time) bool {
+	t, err := time.Parse(time.RFC3339Nano, modTime)
+	if err != nil {
+		return false
+	}
+	return !t.Before(collected.Add(-RecentWindow))
+}
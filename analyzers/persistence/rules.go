@@ -0,0 +1,146 @@
+package persistence
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"iron-sentinel/collectors/linux"
+)
+
+// rule is a trait of a persistence command. Kinds limits it to some
+// persistence kinds; Whole matches the full line rather than the command.
+type rule struct {
+	Name    string
+	Weight  int
+	Strong  bool
+	Kinds   []string
+	Whole   bool
+	Attack  []string
+	Pattern *regexp.Regexp
+}
+
+var rules = []rule{
+	{Name: "reverse_shell", Weight: 70, Strong: true, Attack: []string{"T1059.004"},
+		Pattern: regexp.MustCompile(`/dev/(tcp|udp)/|\bnc(at)?\b[^|;]*\s-[ec]\s|\bmkfifo\b.*\bnc(at)?\b|\bsocat\b.*\bexec:|\b(ba)?sh\s+-i\b.*[<>]|python[0-9.]*\s+-c.*socket.*connect|perl\s+-e.*[Ss]ocket|\bphp\s+-r.*fsockopen|ruby\s+-rsocket`)},
+	{Name: "downloader_pipe", Weight: 50, Strong: true, Attack: []string{"T1105"},
+		Pattern: regexp.MustCompile(`\b(curl|wget)\b[^|]*\|\s*(sudo\s+)?(ba|da|z|k)?sh\b|\b(ba)?sh\s+(-c\s+["']?\$\(|<\(\s*)(curl|wget)\b`)},
+	{Name: "download_exec", Weight: 40, Attack: []string{"T1105"},
+		Pattern: regexp.MustCompile(`\b(curl|wget)\b.*(&&|;)\s*(chmod\s+\+?[0-7]*x|\./|/tmp/|/var/tmp/|/dev/shm/|(ba)?sh\s)`)},
+	{Name: "ld_preload", Weight: 50, Strong: true, Kinds: []string{linux.PersistencePreload}, Whole: true, Attack: []string{"T1574.006"},
+		Pattern: regexp.MustCompile(`\S`)},
+	{Name: "ld_preload_env", Weight: 40, Whole: true, Attack: []string{"T1574.006"},
+		Pattern: regexp.MustCompile(`\bLD_PRELOAD=`)},
+	{Name: "tmp_exec", Weight: 40,
+		Pattern: regexp.MustCompile(`(^|[;&|(]\s*|\b(sh|bash|dash|zsh|nohup|exec|setsid|python[0-9.]*|perl)\s+)(/tmp|/var/tmp|/dev/shm)/`)},
+	{Name: "base64_decode", Weight: 30, Attack: []string{"T1140"},
+		Pattern: regexp.MustCompile(`\bbase64\s+(-d|--decode|-D)\b|\bopenssl\s+(enc\s+)?-?base64\s.*-d\b|b64decode|\bxxd\s+-r`)},
+	{Name: "hidden_path", Weight: 25, Kinds: []string{linux.PersistenceCron, linux.PersistenceSystemd, linux.PersistenceRC, linux.PersistenceAutostart},
+		Pattern: regexp.MustCompile(`(^|[\s;&|=("'])(/[^\s;&|/]+)*/\.[A-Za-z0-9_][^\s;&|/]*`)},
+}
+
+const (
+	weightHiddenFile    = 25
+	weightUnownedRecent = 20
+)
+
+// strong reports whether a trait is specific enough to stand on its own.
+func strong(name string) bool {
+	for _, r := range rules {
+		if r.Name == name {
+			return r.Strong
+		}
+	}
+	return false
+}
+
+// KindAttack maps a persistence kind to its ATT&CK technique.
+func KindAttack(kind string) []string {
+	switch kind {
+	case linux.PersistenceCron:
+		return []string{"T1053.003"}
+	case linux.PersistenceSystemd:
+		return []string{"T1543.002"}
+	case linux.PersistenceRC:
+		return []string{"T1037.004"}
+	case linux.PersistenceProfile:
+		return []string{"T1546.004"}
+	case linux.PersistencePreload:
+		return []string{"T1574.006"}
+	case linux.PersistenceAutostart:
+		return []string{"T1547.013"}
+	}
+	return nil
+}
+
+var envAssign = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// command returns the part of a persistence line that runs: the command of
+// a crontab entry, the value of a systemd or desktop Exec setting, or the
+// whole line of a script. It is empty for lines that run nothing, such as
+// crontab variable assignments.
+func command(l linux.PersistenceLine) string {
+	text := l.Text
+	switch l.Kind {
+	case linux.PersistenceCron:
+		if !crontabFile(l.Path) {
+			return text
+		}
+		if envAssign.MatchString(text) {
+			return ""
+		}
+		fields := strings.Fields(text)
+		skip := 5
+		switch {
+		case path.Base(l.Path) == "anacrontab":
+			skip = 3
+		case strings.HasPrefix(text, "@"):
+			skip = 1
+		}
+		if systemCrontab(l.Path) {
+			skip++ // user field
+		}
+		if len(fields) <= skip {
+			return ""
+		}
+		return strings.Join(fields[skip:], " ")
+	case linux.PersistenceSystemd, linux.PersistenceAutostart:
+		key, val, ok := strings.Cut(text, "=")
+		if !ok || !strings.HasPrefix(key, "Exec") {
+			return ""
+		}
+		return strings.TrimLeft(strings.TrimSpace(val), "-@:+!")
+	}
+	return text
+}
+
+// crontabFile reports whether p holds crontab lines rather than a script
+// run by run-parts.
+func crontabFile(p string) bool {
+	return p == "/etc/crontab" || p == "/etc/anacrontab" || strings.HasPrefix(p, "/etc/cron.d/") || strings.HasPrefix(p, "/var/spool/cron/")
+}
+
+// systemCrontab reports whether p uses the system format with a user field.
+func systemCrontab(p string) bool {
+	return p == "/etc/crontab" || strings.HasPrefix(p, "/etc/cron.d/")
+}
+
+// match applies the rules to one line.
+func match(l linux.PersistenceLine) []rule {
+	cmd := command(l)
+	var out []rule
+	for _, r := range rules {
+		if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, l.Kind) {
+			continue
+		}
+		target := cmd
+		if r.Whole {
+			target = l.Text
+		}
+		if target != "" && r.Pattern.MatchString(target) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
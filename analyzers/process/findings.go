@@ -0,0 +1,73 @@
+package process
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// Findings turns every anomaly into a finding. Masquerading and shells
+// spawned by services rarely have innocent explanations; argv[0]
+// mismatches and random-looking names are leads rather than conclusions.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Anomalies))
+	for _, a := range res.Anomalies {
+		f := findings.Finding{
+			Analyzer:    "process",
+			Rule:        "process." + a.Rule,
+			Description: a.Detail + ".",
+			Evidence: []findings.Evidence{{
+				Artifact: res.Artifact,
+				Path:     a.Exe,
+				Excerpt:  fmt.Sprintf("pid %d ppid %d: %s", a.PID, a.PPID, strings.Join(a.Cmdline, " ")),
+			}},
+			Metadata: map[string]string{
+				"pid":  fmt.Sprintf("%d", a.PID),
+				"ppid": fmt.Sprintf("%d", a.PPID),
+				"name": a.Name,
+				"euid": fmt.Sprintf("%d", a.EUID),
+			},
+		}
+		label := fmt.Sprintf("%s (pid %d)", a.Name, a.PID)
+		switch a.Rule {
+		case RuleKernelMasquerade:
+			f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceHigh
+			f.Title = "Process masquerading as a kernel thread: " + label
+			f.Attack = []string{"T1036.004"}
+		case RuleArgv0Mismatch:
+			f.Severity, f.Confidence = findings.SeverityMedium, findings.ConfidenceLow
+			f.Title = "Process argv[0] differs from its executable: " + label
+			f.Attack = []string{"T1036"}
+		case RuleInlineCode:
+			f.Severity, f.Confidence = findings.SeverityMedium, findings.ConfidenceLow
+			if a.Suspicious {
+				f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceMedium
+			}
+			f.Title = "Interpreter running inline code: " + label
+			f.Attack = []string{"T1059"}
+		case RuleServiceShell:
+			f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceMedium
+			f.Title = "Service spawned a shell or network tool: " + label
+			f.Attack = []string{"T1505.003", "T1059.004"}
+		case RuleRootWritable:
+			f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceMedium
+			f.Title = "Root process running from a user-writable path: " + label
+		case RuleRandomName:
+			f.Severity, f.Confidence = findings.SeverityLow, findings.ConfidenceLow
+			f.Title = "Process with a random-looking name: " + label
+		}
+		if a.User != "" {
+			f.Metadata["user"] = a.User
+		}
+		if a.Exe != "" {
+			f.Metadata["exe"] = a.Exe
+		}
+		if a.Parent != "" {
+			f.Metadata["parent"] = a.Parent
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
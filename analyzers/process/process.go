@@ -0,0 +1,273 @@
+package process
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	RuleKernelMasquerade = "kernel_thread_masquerade"
+	RuleArgv0Mismatch    = "argv0_mismatch"
+	RuleInlineCode       = "interpreter_inline_code"
+	RuleServiceShell     = "service_spawned_shell"
+	RuleRootWritable     = "root_from_writable_path"
+	RuleRandomName       = "high_entropy_name"
+)
+
+// Anomaly is one rule matching one process. Parent describes the parent
+// process when it was found in the inventory.
+type Anomaly struct {
+	Rule    string   `json:"rule"`
+	PID     int      `json:"pid"`
+	PPID    int      `json:"ppid"`
+	Name    string   `json:"name"`
+	Exe     string   `json:"exe,omitempty"`
+	Cmdline []string `json:"cmdline,omitempty"`
+	User    string   `json:"user,omitempty"`
+	EUID    int      `json:"euid"`
+	Parent  string   `json:"parent,omitempty"`
+	Detail  string   `json:"detail"`
+	// Suspicious is set for inline code that also downloads, decodes or
+	// opens sockets.
+	Suspicious bool `json:"suspicious,omitempty"`
+}
+
+type Result struct {
+	Artifact  string    `json:"artifact"`
+	Processes int       `json:"processes"`
+	Anomalies []Anomaly `json:"anomalies"`
+	Finished  string    `json:"finished"`
+}
+
+// interpreters run inline code given with the listed flags.
+var interpreters = map[string][]string{
+	"python": {"-c"},
+	"perl":   {"-e", "-E"},
+	"ruby":   {"-e"},
+	"php":    {"-r"},
+	"node":   {"-e", "--eval", "-p", "--print"},
+	"lua":    {"-e"},
+	"sh":     {"-c"},
+	"bash":   {"-c"},
+	"dash":   {"-c"},
+	"zsh":    {"-c"},
+	"ksh":    {"-c"},
+	"ash":    {"-c"},
+}
+
+// shells only count with inline code that looks hostile, since services
+// and cron run "sh -c" all the time.
+var shells = map[string]bool{"sh": true, "bash": true, "dash": true, "zsh": true, "ksh": true, "ash": true, "csh": true, "tcsh": true, "fish": true}
+
+// services are web, application and database servers that should not
+// spawn shells or network tools.
+var services = map[string]bool{
+	"nginx": true, "apache2": true, "httpd": true, "lighttpd": true, "caddy": true,
+	"php-fpm": true, "php-cgi": true, "uwsgi": true, "gunicorn": true,
+	"java": true, "tomcat": true, "catalina": true,
+	"mysqld": true, "mariadbd": true, "postgres": true, "redis-server": true, "mongod": true,
+}
+
+// spawnedTools are what an attacker runs from a compromised service.
+var spawnedTools = map[string]bool{"nc": true, "ncat": true, "netcat": true, "socat": true, "curl": true, "wget": true, "python": true, "perl": true, "whoami": true, "id": true, "uname": true}
+
+// multiCall binaries choose behavior by argv[0].
+var multiCall = map[string]bool{"busybox": true, "toybox": true, "coreutils": true}
+
+var (
+	hostileInline = regexp.MustCompile(`(?i)/dev/(tcp|udp)/|\bsocket\b|base64|b64decode|\b(curl|wget)\b|\bnc(at)?\s|\bexec\(|pty\.spawn|urllib|requests\.get|fsockopen|\beval\(`)
+	versionSuffix = regexp.MustCompile(`[0-9.]+$`)
+)
+
+// writablePrefixes are user-writable locations root should not run from.
+var writablePrefixes = []string{"/tmp/", "/var/tmp/", "/dev/shm/", "/home/", "/run/user/", "/var/www/"}
+
+// Scan reads the process inventory of a case and applies every rule.
+func Scan(ctx context.Context, outDir string, artifacts []collectors.Artifact) (Result, error) {
+	res := Result{Anomalies: []Anomaly{}}
+	var procs []linux.Process
+	a, ok, err := collectors.ReadLatest(ctx, outDir, artifacts, "processes", "processes.jsonl", func(line []byte) error {
+		var p linux.Process
+		if json.Unmarshal(line, &p) == nil {
+			procs = append(procs, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return res, fmt.Errorf("%s: %w", a.RelativePath, err)
+	}
+	if ok {
+		res.Artifact = a.RelativePath
+	}
+	res.Processes = len(procs)
+
+	byPID := map[int]linux.Process{}
+	for _, p := range procs {
+		byPID[p.PID] = p
+	}
+	for _, p := range procs {
+		if p.KernelThread {
+			continue
+		}
+		parent, hasParent := byPID[p.PPID]
+		add := func(rule, detail string) *Anomaly {
+			a := Anomaly{
+				Rule: rule, PID: p.PID, PPID: p.PPID, Name: p.Name, Exe: p.Exe,
+				Cmdline: p.Cmdline, User: p.User, EUID: p.EUID, Detail: detail,
+			}
+			if hasParent {
+				a.Parent = fmt.Sprintf("%s (pid %d)", parent.Name, parent.PID)
+			}
+			res.Anomalies = append(res.Anomalies, a)
+			return &res.Anomalies[len(res.Anomalies)-1]
+		}
+		argv0 := ""
+		if len(p.Cmdline) > 0 {
+			argv0 = p.Cmdline[0]
+		}
+		exeBase := path.Base(p.Exe)
+		image := baseName(exeBase)
+		if p.Exe == "" {
+			image = baseName(path.Base(argv0))
+		}
+
+		if bracketed(argv0) || bracketed(p.Name) {
+			add(RuleKernelMasquerade, fmt.Sprintf("user-space process presents itself as kernel thread %q", firstNonEmpty(argv0, p.Name)))
+		} else if p.Exe != "" && argv0 != "" && !multiCall[exeBase] && !p.ExeDeleted && argv0Differs(argv0, exeBase) {
+			add(RuleArgv0Mismatch, fmt.Sprintf("argv[0] %q does not match executable %s", argv0, p.Exe))
+		}
+
+		if flags, ok := interpreters[image]; ok {
+			if code, flag, found := inlineCode(p.Cmdline, flags); found {
+				hostile := hostileInline.MatchString(code)
+				if hostile || !shells[image] {
+					a := add(RuleInlineCode, fmt.Sprintf("%s %s %s", image, flag, truncate(code, 200)))
+					a.Suspicious = hostile
+				}
+			}
+		}
+
+		if hasParent && services[baseName(firstNonEmpty(path.Base(parent.Exe), parent.Name))] && (shells[image] || spawnedTools[image]) {
+			add(RuleServiceShell, fmt.Sprintf("%s spawned %s", firstNonEmpty(parent.Exe, parent.Name), firstNonEmpty(p.Exe, p.Name)))
+		}
+
+		if (p.EUID == 0 || p.UID == 0) && p.Exe != "" && writablePath(p.Exe) {
+			add(RuleRootWritable, fmt.Sprintf("running as root from %s", p.Exe))
+		}
+
+		if randomName(p.Name) {
+			add(RuleRandomName, fmt.Sprintf("process name %q looks random (entropy %.2f)", p.Name, linux.Entropy([]byte(p.Name))))
+		}
+	}
+
+	sort.SliceStable(res.Anomalies, func(i, j int) bool { return res.Anomalies[i].PID < res.Anomalies[j].PID })
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+func bracketed(s string) bool {
+	return len(s) > 2 && s[0] == '[' && strings.HasSuffix(s, "]")
+}
+
+// baseName normalizes an executable name: versioned interpreters such as
+// python3.11 become python.
+func baseName(name string) string {
+	name = strings.TrimPrefix(name, "-")
+	trimmed := versionSuffix.ReplaceAllString(name, "")
+	if _, ok := interpreters[trimmed]; ok {
+		return trimmed
+	}
+	if trimmed == "php-fpm" || trimmed == "postgres" {
+		return trimmed
+	}
+	return name
+}
+
+// argv0Differs compares argv[0] with the executable name. Login shells
+// ("-bash"), versioned names and daemons that rewrite their title
+// ("sshd: root@pts/0", "nginx: worker process") are not differences.
+func argv0Differs(argv0, exeBase string) bool {
+	a := strings.TrimPrefix(argv0, "-")
+	if i := strings.IndexAny(a, ": "); i > 0 {
+		a = a[:i]
+	}
+	a = path.Base(a)
+	if a == "" || a == "." {
+		return false
+	}
+	return !strings.HasPrefix(exeBase, a) && !strings.HasPrefix(a, exeBase) && !strings.HasPrefix(baseName(exeBase), baseName(a))
+}
+
+func inlineCode(cmdline []string, flags []string) (code, flag string, found bool) {
+	for i := 1; i < len(cmdline); i++ {
+		arg := cmdline[i]
+		for _, f := range flags {
+			if arg == f && i+1 < len(cmdline) {
+				return cmdline[i+1], f, true
+			}
+			// Short flags may be bundled, as in "bash -lc".
+			if len(f) == 2 && strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && strings.HasSuffix(arg, f[1:]) && i+1 < len(cmdline) {
+				return cmdline[i+1], f, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func writablePath(p string) bool {
+	for _, w := range writablePrefixes {
+		if strings.HasPrefix(p, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// randomName flags names like "x8fk2q9zl1" that mix letters and digits
+// with high entropy and no word separators. comm is cut at 15 bytes, so
+// shorter names carry too little signal.
+func randomName(name string) bool {
+	if len(name) < 8 || strings.ContainsAny(name, "-_.:/@ ") {
+		return false
+	}
+	letters, digits := 0, 0
+	for _, c := range name {
+		switch {
+		case c >= '0' && c <= '9':
+			digits++
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+			letters++
+		default:
+			return false
+		}
+	}
+	if letters == 0 || digits < 2 || float64(digits)/float64(len(name)) > 0.8 {
+		return false
+	}
+	return linux.Entropy([]byte(name)) >= 2.9
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,140 @@
+package ransomware
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// maxEvidence caps the files cited by one finding.
+const maxEvidence = 10
+
+// Findings reports the assessment: one finding for the encryption as a
+// whole, one per ransom note name and one per burst of modifications.
+func Findings(res Result) []findings.Finding {
+	var out []findings.Finding
+	if res.Encrypted > 0 {
+		f := findings.Finding{
+			Analyzer:   "ransomware",
+			Rule:       "ransomware.encrypted_files",
+			Severity:   findings.SeverityMedium,
+			Confidence: findings.ConfidenceLow,
+			Title:      fmt.Sprintf("Files that look encrypted: %d in %d directories", res.Encrypted, res.Directories),
+			Attack:     []string{"T1486"},
+			Metadata: map[string]string{
+				"encrypted":   fmt.Sprintf("%d", res.Encrypted),
+				"directories": fmt.Sprintf("%d", res.Directories),
+				"earliest":    res.Earliest,
+				"latest":      res.Latest,
+				"first_file":  res.FirstFile,
+				"extensions":  names(res.Extensions, 5),
+				"top_dirs":    names(res.AffectedDirs, 5),
+				"classified":  fmt.Sprintf("%t", res.Classified),
+			},
+		}
+		if res.Detected {
+			f.Rule = "ransomware.mass_encryption"
+			f.Severity = findings.SeverityCritical
+			f.Confidence = findings.ConfidenceMedium
+			if len(res.Notes) > 0 || res.Encrypted >= 5*MinEncrypted {
+				f.Confidence = findings.ConfidenceHigh
+			}
+			f.Title = fmt.Sprintf("Mass encryption: %d files in %d directories", res.Encrypted, res.Directories)
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%d files look encrypted (extensions: %s), first %s at %s, last at %s.", res.Encrypted, names(res.Extensions, 5), res.FirstFile, res.Earliest, res.Latest)
+		if len(res.Notes) > 0 {
+			fmt.Fprintf(&b, " %d ransom note names found, %q in %d directories.", len(res.Notes), res.Notes[0].Name, res.Notes[0].Dirs)
+		}
+		if o := res.PatientZero; o != nil {
+			fmt.Fprintf(&b, " Likely patient zero: %s owns %d of the %s.", ownerName(o), o.Files, strings.ReplaceAll(o.Basis, "_", " "))
+			f.Metadata["patient_zero_uid"] = fmt.Sprintf("%d", o.UID)
+			if o.User != "" {
+				f.Metadata["patient_zero_user"] = o.User
+			}
+		}
+		f.Description = b.String()
+		for _, e := range res.EncryptedFiles {
+			if len(f.Evidence) == maxEvidence {
+				break
+			}
+			f.Evidence = append(f.Evidence, findings.Evidence{Artifact: res.Artifact, Path: e.Path, Excerpt: e.Reason + " " + e.ModTime})
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+
+	for _, n := range res.Notes {
+		f := findings.Finding{
+			Analyzer:    "ransomware",
+			Rule:        "ransomware.ransom_note",
+			Severity:    findings.SeverityMedium,
+			Confidence:  findings.ConfidenceLow,
+			Title:       fmt.Sprintf("Possible ransom note: %s", n.Name),
+			Description: fmt.Sprintf("%s was found in %d directories, first written at %s.", n.Name, n.Dirs, n.Earliest),
+			Attack:      []string{"T1486"},
+			Metadata: map[string]string{
+				"name":     n.Name,
+				"copies":   fmt.Sprintf("%d", len(n.Paths)),
+				"dirs":     fmt.Sprintf("%d", n.Dirs),
+				"earliest": n.Earliest,
+			},
+		}
+		if n.Dirs >= NoteDirs {
+			f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceHigh
+		}
+		for _, p := range n.Paths {
+			if len(f.Evidence) == maxEvidence {
+				break
+			}
+			f.Evidence = append(f.Evidence, findings.Evidence{Artifact: res.Artifact, Path: p})
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+
+	for _, b := range res.Bursts {
+		f := findings.Finding{
+			Analyzer:    "ransomware",
+			Rule:        "ransomware.mtime_burst",
+			Severity:    findings.SeverityLow,
+			Confidence:  findings.ConfidenceLow,
+			Title:       fmt.Sprintf("Burst of file modifications: %d files from %s to %s", b.Files, b.Start, b.End),
+			Description: fmt.Sprintf("%d files in %d directories were modified between %s and %s; %d of them look encrypted.", b.Files, b.Dirs, b.Start, b.End, b.Encrypted),
+			Evidence:    []findings.Evidence{{Artifact: res.Artifact}},
+			Metadata: map[string]string{
+				"start":     b.Start,
+				"end":       b.End,
+				"files":     fmt.Sprintf("%d", b.Files),
+				"encrypted": fmt.Sprintf("%d", b.Encrypted),
+				"dirs":      fmt.Sprintf("%d", b.Dirs),
+			},
+		}
+		if b.Encrypted > 0 {
+			f.Severity, f.Confidence = findings.SeverityHigh, findings.ConfidenceMedium
+			f.Attack = []string{"T1486"}
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
+
+func names(counts []Count, n int) string {
+	var out []string
+	for i, c := range counts {
+		if i == n {
+			break
+		}
+		out = append(out, fmt.Sprintf("%s (%d)", c.Name, c.Files))
+	}
+	return strings.Join(out, ", ")
+}
+
+func ownerName(o *Owner) string {
+	if o.User != "" {
+		return fmt.Sprintf("%s (uid %d)", o.User, o.UID)
+	}
+	return fmt.Sprintf("uid %d", o.UID)
+}
@@ -0,0 +1,454 @@
+package ransomware
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/filetype"
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	// MinEncrypted is how many encrypted files make a mass-encryption event
+	// on their own, without ransom notes.
+	MinEncrypted = 20
+	// MinCluster is how many files must share an unknown appended extension
+	// before they count as encrypted.
+	MinCluster = 10
+	// NoteDirs is how many directories a ransom note must appear in to count
+	// as repeated.
+	NoteDirs = 3
+	// BurstFiles is how many files modified within one minute make a burst.
+	BurstFiles = 50
+)
+
+const (
+	ReasonRansomExtension   = "ransom_extension"
+	ReasonAppendedExtension = "appended_extension"
+	ReasonHighEntropy       = "high_entropy"
+)
+
+// maxListed caps the encrypted files listed in the result; counts cover all.
+const maxListed = 1000
+
+type File struct {
+	Path     string  `json:"path"`
+	Reason   string  `json:"reason"`
+	Size     int64   `json:"size_bytes"`
+	ModTime  string  `json:"mod_time"`
+	UID      int     `json:"uid"`
+	FileType string  `json:"file_type,omitempty"`
+	Entropy  float64 `json:"entropy,omitempty"`
+}
+
+// Note is a ransom note file name and every directory it was found in.
+type Note struct {
+	Name     string   `json:"name"`
+	Paths    []string `json:"paths"`
+	Dirs     int      `json:"dirs"`
+	Earliest string   `json:"earliest"`
+}
+
+type Count struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+}
+
+// Burst is a run of consecutive minutes in which at least BurstFiles files
+// were modified each minute.
+type Burst struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Files     int    `json:"files"`
+	Encrypted int    `json:"encrypted"`
+	Dirs      int    `json:"dirs"`
+}
+
+// Owner is the likely patient-zero account. Basis says whether it owns the
+// ransom notes or the encrypted files.
+type Owner struct {
+	UID   int    `json:"uid"`
+	User  string `json:"user,omitempty"`
+	Home  string `json:"home,omitempty"`
+	Files int    `json:"files"`
+	Basis string `json:"basis"`
+}
+
+type Result struct {
+	Artifact string `json:"artifact,omitempty"`
+	Files    int    `json:"files"`
+	// Classified is set when the snapshot carries file types and entropy;
+	// without them only extensions and notes are used.
+	Classified     bool    `json:"classified"`
+	Detected       bool    `json:"detected"`
+	Encrypted      int     `json:"encrypted"`
+	EncryptedFiles []File  `json:"encrypted_files"`
+	Extensions     []Count `json:"extensions"`
+	Notes          []Note  `json:"notes"`
+	Directories    int     `json:"directories"`
+	AffectedDirs   []Count `json:"affected_dirs"`
+	Earliest       string  `json:"earliest,omitempty"`
+	Latest         string  `json:"latest,omitempty"`
+	FirstFile      string  `json:"first_file,omitempty"`
+	PatientZero    *Owner  `json:"patient_zero,omitempty"`
+	Bursts         []Burst `json:"bursts"`
+	Finished       string  `json:"finished"`
+}
+
+var noteRe = regexp.MustCompile(`(?i)(decrypt|ransom|(restore|recover)[_ -]?(my|your|the|all)?[_ -]?(files|data)|how[_ -]?to[_ -]?(back|get|unlock|restore|recover)|your[_ -]?files|files[_ -]?(are[_ -]?)?encrypted|unlock[_ -]?(files|instructions)|^_readme\.txt$|^!+.*!+\.|read[_ -]?me[_ -]?now)`)
+
+var noteExts = map[string]bool{"": true, ".txt": true, ".html": true, ".htm": true, ".hta": true, ".rtf": true, ".md": true}
+
+// dataExts are extensions of the user data ransomware goes after. An
+// unknown extension appended to one of them is a sign of encryption.
+var dataExts = map[string]bool{
+	".txt": true, ".log": true, ".csv": true, ".json": true, ".xml": true, ".yaml": true, ".yml": true,
+	".conf": true, ".cfg": true, ".ini": true, ".md": true, ".html": true, ".htm": true, ".rtf": true,
+	".pdf": true, ".doc": true, ".docx": true, ".xls": true, ".xlsx": true, ".ppt": true, ".pptx": true,
+	".odt": true, ".ods": true, ".odp": true, ".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+	".bmp": true, ".tif": true, ".tiff": true, ".svg": true, ".sql": true, ".sqlite": true, ".db": true,
+	".mdb": true, ".accdb": true, ".pst": true, ".eml": true, ".zip": true, ".tar": true, ".gz": true,
+	".7z": true, ".rar": true, ".bak": true, ".php": true, ".py": true, ".sh": true, ".js": true,
+	".java": true, ".c": true, ".h": true, ".go": true, ".rb": true, ".pl": true,
+	".vmdk": true, ".vmx": true, ".vdi": true, ".qcow2": true, ".img": true, ".iso": true,
+	".mp3": true, ".mp4": true, ".mov": true, ".avi": true, ".key": true, ".pem": true,
+}
+
+// opaqueExts have no signature FileType knows or may legitimately hold
+// random data, so their entropy says nothing about encryption.
+var opaqueExts = map[string]bool{
+	".mp3": true, ".mp4": true, ".mov": true, ".avi": true, ".img": true, ".iso": true, ".vmdk": true,
+	".vdi": true, ".qcow2": true, ".db": true, ".bak": true, ".pst": true, ".mdb": true, ".accdb": true,
+}
+
+// ransomExts are extensions known ransomware families append.
+var ransomExts = map[string]bool{
+	".encrypted": true, ".locked": true, ".crypt": true, ".crypted": true, ".cry": true, ".enc": true,
+	".lockbit": true, ".ryk": true, ".conti": true, ".wncry": true, ".wnry": true, ".djvu": true,
+	".babyk": true, ".akira": true, ".royal": true, ".hive": true, ".basta": true, ".clop": true,
+	".cl0p": true, ".rhysida": true, ".medusa": true, ".crypto": true, ".pay": true, ".locky": true,
+}
+
+// commonExts are appended by editors, package managers, rotation and
+// backups rather than ransomware.
+var commonExts = map[string]bool{
+	".bak": true, ".old": true, ".orig": true, ".tmp": true, ".swp": true, ".save": true, ".backup": true,
+	".dist": true, ".dpkg-old": true, ".dpkg-dist": true, ".dpkg-new": true, ".rpmnew": true, ".rpmsave": true,
+	".ucf-dist": true, ".ucf-old": true, ".gz": true, ".bz2": true, ".xz": true, ".zst": true, ".zip": true,
+	".7z": true, ".gpg": true, ".asc": true, ".sig": true, ".sha256": true, ".md5": true, ".part": true,
+	".in": true, ".template": true, ".example": true, ".sample": true, ".default": true, ".disabled": true,
+	".j2": true, ".tpl": true, ".erb": true, ".var": true, ".lock": true, ".new": true, ".tar": true,
+}
+
+// systemDirs are written by package managers in bulk and are left out of
+// notes, appended extensions and bursts.
+var systemDirs = []string{
+	"/usr/", "/lib/", "/lib32/", "/lib64/", "/libx32/", "/bin/", "/sbin/", "/boot/", "/snap/",
+	"/var/cache/", "/var/lib/dpkg/", "/var/lib/apt/", "/var/lib/rpm/", "/var/lib/dnf/", "/var/lib/yum/",
+}
+
+type entry struct {
+	linux.SnapshotEntry
+	path string
+	mod  time.Time
+}
+
+// Scan assesses the latest filesystem snapshot of a case for mass
+// encryption.
+func Scan(ctx context.Context, outDir string, artifacts []collectors.Artifact) (Result, error) {
+	res := Result{EncryptedFiles: []File{}, Extensions: []Count{}, Notes: []Note{}, AffectedDirs: []Count{}, Bursts: []Burst{}}
+
+	var files []entry
+	a, _, err := collectors.ReadLatest(ctx, outDir, artifacts, "fs_snapshot", "metadata.jsonl", func(raw []byte) error {
+		var e linux.SnapshotEntry
+		if json.Unmarshal(raw, &e) != nil || e.Type != "file" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, e.ModTime)
+		if err != nil {
+			return nil
+		}
+		if e.FileType != "" {
+			res.Classified = true
+		}
+		files = append(files, entry{SnapshotEntry: e, path: filepath.ToSlash(e.Path), mod: t.UTC()})
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+	res.Artifact = a.RelativePath
+	res.Files = len(files)
+
+	// Appended extensions count once enough files share them.
+	appended := map[string]int{}
+	for _, f := range files {
+		if ext, ok := appendedExt(f.path); ok {
+			appended[ext]++
+		}
+	}
+
+	notes := map[string]*Note{}
+	noteTimes := map[string]time.Time{}
+	var noteFiles []entry
+	var encrypted []entry
+	reasons := map[string]string{}
+	for _, f := range files {
+		base := path.Base(f.path)
+		if !system(f.path) && f.SizeBytes < 1<<20 && noteExts[strings.ToLower(path.Ext(base))] && noteRe.MatchString(base) {
+			key := strings.ToLower(base)
+			n, ok := notes[key]
+			if !ok {
+				n = &Note{Name: base}
+				notes[key] = n
+			}
+			n.Paths = append(n.Paths, f.path)
+			if t, ok := noteTimes[key]; !ok || f.mod.Before(t) {
+				noteTimes[key] = f.mod
+				n.Earliest = f.mod.Format(time.RFC3339Nano)
+			}
+			noteFiles = append(noteFiles, f)
+			continue
+		}
+		reason := ""
+		if ext, ok := appendedExt(f.path); ok {
+			switch {
+			case ransomExts[ext]:
+				reason = ReasonRansomExtension
+			case appended[ext] >= MinCluster:
+				reason = ReasonAppendedExtension
+			}
+		}
+		if reason == "" && randomContent(f) {
+			reason = ReasonHighEntropy
+		}
+		if reason != "" {
+			encrypted = append(encrypted, f)
+			reasons[f.path] = reason
+		}
+	}
+
+	for _, n := range notes {
+		dirs := map[string]bool{}
+		for _, p := range n.Paths {
+			dirs[path.Dir(p)] = true
+		}
+		n.Dirs = len(dirs)
+		sort.Strings(n.Paths)
+		res.Notes = append(res.Notes, *n)
+	}
+	sort.Slice(res.Notes, func(i, j int) bool {
+		if res.Notes[i].Dirs != res.Notes[j].Dirs {
+			return res.Notes[i].Dirs > res.Notes[j].Dirs
+		}
+		return res.Notes[i].Name < res.Notes[j].Name
+	})
+
+	sort.Slice(encrypted, func(i, j int) bool { return encrypted[i].mod.Before(encrypted[j].mod) })
+	res.Encrypted = len(encrypted)
+	exts := map[string]int{}
+	dirs := map[string]int{}
+	var earliest, latest time.Time
+	span := func(t time.Time) {
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	for _, f := range encrypted {
+		if len(res.EncryptedFiles) < maxListed {
+			res.EncryptedFiles = append(res.EncryptedFiles, File{
+				Path: f.path, Reason: reasons[f.path], Size: f.SizeBytes, ModTime: f.ModTime,
+				UID: f.UID, FileType: f.FileType, Entropy: f.Entropy,
+			})
+		}
+		exts[strings.ToLower(path.Ext(f.path))]++
+		dirs[path.Dir(f.path)]++
+		span(f.mod)
+	}
+	if len(encrypted) > 0 {
+		res.FirstFile = encrypted[0].path
+	}
+	for _, f := range noteFiles {
+		dirs[path.Dir(f.path)]++
+		span(f.mod)
+	}
+	res.Extensions = top(exts, 10)
+	res.Directories = len(dirs)
+	res.AffectedDirs = top(dirs, 25)
+	if !earliest.IsZero() {
+		res.Earliest = earliest.Format(time.RFC3339Nano)
+		res.Latest = latest.Format(time.RFC3339Nano)
+	}
+
+	repeated := false
+	for _, n := range res.Notes {
+		if n.Dirs >= NoteDirs {
+			repeated = true
+		}
+	}
+	res.Detected = res.Encrypted >= MinEncrypted || (repeated && res.Encrypted > 0)
+
+	users := map[int]linux.User{}
+	// Without the accounts, patient zero is reported by UID alone.
+	_, _, _ = collectors.ReadLatest(ctx, outDir, artifacts, "accounts", "users.jsonl", func(raw []byte) error {
+		var u linux.User
+		if json.Unmarshal(raw, &u) == nil {
+			users[u.UID] = u
+		}
+		return nil
+	})
+	// Notes are written by the ransomware itself, so their owner is the
+	// account it ran as; encrypted files may keep their original owners.
+	switch {
+	case len(noteFiles) > 0:
+		res.PatientZero = owner(noteFiles, "ransom_notes", users)
+	case len(encrypted) > 0:
+		res.PatientZero = owner(encrypted, "encrypted_files", users)
+	}
+
+	res.Bursts = bursts(files, reasons)
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+// appendedExt returns the last extension of p when it follows a data
+// extension, as in report.docx.lockbit, and is not a usual suffix.
+func appendedExt(p string) (string, bool) {
+	if system(p) {
+		return "", false
+	}
+	base := strings.ToLower(path.Base(p))
+	outer := path.Ext(base)
+	inner := path.Ext(strings.TrimSuffix(base, outer))
+	if outer == "" || inner == "" || !dataExts[inner] || dataExts[outer] || commonExts[outer] {
+		return "", false
+	}
+	if strings.Trim(outer[1:], "0123456789") == "" {
+		return "", false // rotated logs: syslog.1
+	}
+	if (inner == ".html" || inner == ".htm") && len(outer) == 3 {
+		return "", false // content negotiation: index.html.en
+	}
+	return outer, true
+}
+
+// randomContent reports a file whose extension promises structured data
+// but whose classified content is random. Compressed formats such as PNG or
+// DOCX are random by design; losing their signature is what gives
+// encryption away, since intact ones classify as images or archives.
+func randomContent(f entry) bool {
+	if f.FileType == "" || f.Entropy < filetype.HighEntropy || f.SizeBytes < 1024 {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(f.path))
+	if !dataExts[ext] || opaqueExts[ext] {
+		return false
+	}
+	class := linux.FileClass(f.FileType)
+	return class == linux.ClassData || class == linux.ClassEncrypted
+}
+
+func system(p string) bool {
+	for _, d := range systemDirs {
+		if strings.HasPrefix(p, d) {
+			return true
+		}
+	}
+	return false
+}
+
+func bursts(files []entry, reasons map[string]string) []Burst {
+	type minute struct {
+		files, encrypted int
+		dirs             map[string]bool
+	}
+	minutes := map[int64]*minute{}
+	for _, f := range files {
+		if system(f.path) {
+			continue
+		}
+		k := f.mod.Truncate(time.Minute).Unix()
+		m, ok := minutes[k]
+		if !ok {
+			m = &minute{dirs: map[string]bool{}}
+			minutes[k] = m
+		}
+		m.files++
+		m.dirs[path.Dir(f.path)] = true
+		if reasons[f.path] != "" {
+			m.encrypted++
+		}
+	}
+	var keys []int64
+	for k, m := range minutes {
+		if m.files >= BurstFiles {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	out := []Burst{}
+	var dirs map[string]bool
+	for i, k := range keys {
+		m := minutes[k]
+		if i == 0 || k-keys[i-1] > 60 {
+			out = append(out, Burst{Start: time.Unix(k, 0).UTC().Format(time.RFC3339)})
+			dirs = map[string]bool{}
+		}
+		b := &out[len(out)-1]
+		b.End = time.Unix(k+59, 0).UTC().Format(time.RFC3339)
+		b.Files += m.files
+		b.Encrypted += m.encrypted
+		for d := range m.dirs {
+			dirs[d] = true
+		}
+		b.Dirs = len(dirs)
+	}
+	return out
+}
+
+// owner picks the uid owning most files; ties go to the lowest.
+func owner(files []entry, basis string, users map[int]linux.User) *Owner {
+	counts := map[int]int{}
+	for _, f := range files {
+		counts[f.UID]++
+	}
+	best, n := -1, 0
+	for u, c := range counts {
+		if c > n || (c == n && u < best) {
+			best, n = u, c
+		}
+	}
+	o := &Owner{UID: best, Files: n, Basis: basis}
+	if u, ok := users[best]; ok {
+		o.User, o.Home = u.Name, u.Home
+	}
+	return o
+}
+
+func top(counts map[string]int, n int) []Count {
+	out := make([]Count, 0, len(counts))
+	for k, v := range counts {
+		out = append(out, Count{Name: k, Files: v})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Files != out[j].Files {
+			return out[i].Files > out[j].Files
+		}
+		return out[i].Name < out[j].Name
+	})
+	if len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
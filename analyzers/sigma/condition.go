@@ -0,0 +1,188 @@
+package sigma
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type condNode interface {
+	eval(match func(name string) bool) bool
+}
+
+type boolNode struct {
+	op   string
+	l, r condNode
+}
+
+func (n *boolNode) eval(match func(string) bool) bool {
+	if n.op == "and" {
+		return n.l.eval(match) && n.r.eval(match)
+	}
+	return n.l.eval(match) || n.r.eval(match)
+}
+
+type notNode struct{ x condNode }
+
+func (n *notNode) eval(match func(string) bool) bool { return !n.x.eval(match) }
+
+type searchNode struct{ name string }
+
+func (n *searchNode) eval(match func(string) bool) bool { return match(n.name) }
+
+// ofNode implements "1 of selection*", "all of them" and friends; n is the
+// number of searches required, or -1 for all.
+type ofNode struct {
+	n     int
+	names []string
+}
+
+func (o *ofNode) eval(match func(string) bool) bool {
+	hits := 0
+	for _, name := range o.names {
+		if match(name) {
+			hits++
+			if o.n > 0 && hits >= o.n {
+				return true
+			}
+		} else if o.n < 0 {
+			return false
+		}
+	}
+	return o.n < 0
+}
+
+type condParser struct {
+	toks  []string
+	pos   int
+	names []string
+}
+
+func parseCondition(s string, names []string) (condNode, error) {
+	if strings.Contains(s, "|") {
+		return nil, fmt.Errorf("aggregation expressions are not supported")
+	}
+	s = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(s)
+	p := &condParser{toks: strings.Fields(s), names: names}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos])
+	}
+	return n, nil
+}
+
+func (p *condParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *condParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *condParser) parseOr() (condNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "or") {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &boolNode{op: "or", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *condParser) parseAnd() (condNode, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for strings.EqualFold(p.peek(), "and") {
+		p.next()
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &boolNode{op: "and", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *condParser) parseNot() (condNode, error) {
+	if strings.EqualFold(p.peek(), "not") {
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *condParser) parsePrimary() (condNode, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of condition")
+	case t == "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing )")
+		}
+		return x, nil
+	case t == ")":
+		return nil, fmt.Errorf("unexpected )")
+	}
+
+	if strings.EqualFold(p.peek(), "of") {
+		p.next()
+		n := -1
+		switch {
+		case strings.EqualFold(t, "all"):
+		case strings.EqualFold(t, "any"):
+			n = 1
+		default:
+			v, err := strconv.Atoi(t)
+			if err != nil || v < 1 {
+				return nil, fmt.Errorf("invalid quantifier %q", t)
+			}
+			n = v
+		}
+		target := p.next()
+		var names []string
+		for _, name := range p.names {
+			if target == "them" && !strings.HasPrefix(name, "_") {
+				names = append(names, name)
+			} else if ok, _ := path.Match(target, name); ok && target != "them" {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("%q matches no search identifier", target)
+		}
+		return &ofNode{n: n, names: names}, nil
+	}
+
+	for _, name := range p.names {
+		if name == t {
+			return &searchNode{name: t}, nil
+		}
+	}
+	return nil, fmt.Errorf("undefined search identifier %q", t)
+}
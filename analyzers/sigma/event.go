@@ -0,0 +1,248 @@
+package sigma
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/logs"
+)
+
+// Event is a log record as seen by Sigma rules: a logsource plus a flat set
+// of fields. Keyword searches match against Message.
+type Event struct {
+	Time     time.Time
+	Artifact string
+	Line     int
+	Category string
+	Services []string
+	Message  string
+	Fields   map[string]string
+}
+
+func (e *Event) get(field string) (string, bool) {
+	if v, ok := e.Fields[field]; ok {
+		return v, true
+	}
+	for k, v := range e.Fields {
+		if strings.EqualFold(k, field) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (e *Event) hasService(s string) bool {
+	for _, x := range e.Services {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// programServices maps syslog program names to Sigma linux services.
+var programServices = map[string]string{
+	"sshd":  "sshd",
+	"sudo":  "sudo",
+	"su":    "su",
+	"cron":  "cron",
+	"CRON":  "cron",
+	"crond": "cron",
+}
+
+// supportedServices and supportedCategories are the linux logsources that
+// events are produced for.
+var (
+	supportedServices   = []string{"auditd", "auth", "syslog", "sshd", "sudo", "su", "cron"}
+	supportedCategories = []string{"process_creation"}
+)
+
+func recordEvent(r logs.Record) Event {
+	e := Event{Time: r.Time, Artifact: r.Artifact, Line: r.Line, Message: r.Message, Fields: map[string]string{}}
+	for k, v := range r.Fields {
+		e.Fields[k] = v
+	}
+	switch r.Kind {
+	case "auth", "syslog":
+		e.Services = []string{r.Kind}
+		if s, ok := programServices[r.Program]; ok {
+			e.Services = append(e.Services, s)
+		}
+	case "audit":
+		e.Services = []string{"auditd"}
+		if r.Fields["type"] == "EXECVE" {
+			for k, v := range r.Fields {
+				if isExecveArg(k) {
+					e.Fields[k] = decodeAuditArg(r.Message, k, v)
+				}
+			}
+		}
+		return e
+	}
+	for k, v := range map[string]string{"host": r.Host, "program": r.Program, "pid": r.PID, "user": r.User} {
+		if v != "" {
+			e.Fields[k] = v
+		}
+	}
+	return e
+}
+
+func isExecveArg(k string) bool {
+	if len(k) < 2 || k[0] != 'a' {
+		return false
+	}
+	_, err := strconv.Atoi(k[1:])
+	return err == nil
+}
+
+// decodeAuditArg undoes auditd's hex encoding of EXECVE arguments, which is
+// used for any argument that auditd did not write as a quoted string.
+func decodeAuditArg(body, key, v string) string {
+	if strings.HasPrefix(body, key+"=\"") || strings.Contains(body, " "+key+"=\"") {
+		return v
+	}
+	if len(v)%2 != 0 {
+		return v
+	}
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return v
+	}
+	return string(b)
+}
+
+// historyProcess turns a shell history line into a process_creation event.
+// History has no resolved path, so a bare command name becomes "/name":
+// rules written as Image|endswith: '/curl' then match it as they match
+// auditd events.
+func historyProcess(r logs.Record) Event {
+	cmd := r.Message
+	return Event{
+		Time:     r.Time,
+		Artifact: r.Artifact,
+		Line:     r.Line,
+		Category: "process_creation",
+		Message:  cmd,
+		Fields:   map[string]string{"CommandLine": cmd, "Image": historyImage(cmd), "User": r.User},
+	}
+}
+
+// historyImage returns the program of a command line, skipping leading
+// VAR=value assignments.
+func historyImage(cmd string) string {
+	for _, f := range strings.Fields(cmd) {
+		if isAssignment(f) {
+			continue
+		}
+		f = strings.Trim(f, `"'`)
+		if f == "" || strings.Contains(f, "/") {
+			return f
+		}
+		return "/" + f
+	}
+	return ""
+}
+
+func isAssignment(f string) bool {
+	name, _, ok := strings.Cut(f, "=")
+	if !ok || name == "" {
+		return false
+	}
+	for i, c := range name {
+		if c != '_' && !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && (i == 0 || !(c >= '0' && c <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// auditAssembler groups the records of one audit event (SYSCALL, EXECVE,
+// CWD, ...) by serial and turns executions into process_creation events.
+// Parent image and command line are resolved from earlier executions seen
+// in the same log.
+type auditAssembler struct {
+	serial   string
+	records  []logs.Record
+	images   map[string]string
+	cmdlines map[string]string
+	emit     func(Event)
+}
+
+func newAuditAssembler(emit func(Event)) *auditAssembler {
+	return &auditAssembler{images: map[string]string{}, cmdlines: map[string]string{}, emit: emit}
+}
+
+func (a *auditAssembler) add(r logs.Record) {
+	if s := r.Fields["serial"]; s != a.serial {
+		a.flush()
+		a.serial = s
+	}
+	a.records = append(a.records, r)
+}
+
+func (a *auditAssembler) flush() {
+	defer func() { a.records = nil }()
+	var syscall, execve, cwd *logs.Record
+	for i := range a.records {
+		switch a.records[i].Fields["type"] {
+		case "SYSCALL":
+			syscall = &a.records[i]
+		case "EXECVE":
+			execve = &a.records[i]
+		case "CWD":
+			cwd = &a.records[i]
+		}
+	}
+	if execve == nil {
+		return
+	}
+
+	argc, _ := strconv.Atoi(execve.Fields["argc"])
+	var args []string
+	for i := 0; i < argc; i++ {
+		k := "a" + strconv.Itoa(i)
+		v, ok := execve.Fields[k]
+		if !ok {
+			break
+		}
+		args = append(args, decodeAuditArg(execve.Message, k, v))
+	}
+	cmd := strings.Join(args, " ")
+
+	f := map[string]string{"CommandLine": cmd}
+	if syscall != nil {
+		pid, ppid := syscall.Fields["pid"], syscall.Fields["ppid"]
+		f["Image"] = syscall.Fields["exe"]
+		f["ProcessId"] = pid
+		f["ParentProcessId"] = ppid
+		f["User"] = syscall.Fields["uid"]
+		f["LogonId"] = syscall.Fields["ses"]
+		if v := syscall.Fields["UID"]; v != "" {
+			f["User"] = v
+		}
+		if v, ok := a.images[ppid]; ok {
+			f["ParentImage"] = v
+			f["ParentCommandLine"] = a.cmdlines[ppid]
+		}
+		a.images[pid] = f["Image"]
+		a.cmdlines[pid] = cmd
+	}
+	if cwd != nil {
+		f["CurrentDirectory"] = cwd.Fields["cwd"]
+	}
+	for k, v := range f {
+		if v == "" {
+			delete(f, k)
+		}
+	}
+	a.emit(Event{
+		Time:     execve.Time,
+		Artifact: execve.Artifact,
+		Line:     execve.Line,
+		Category: "process_creation",
+		Message:  cmd,
+		Fields:   f,
+	})
+}
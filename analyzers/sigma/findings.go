@@ -0,0 +1,44 @@
+package sigma
+
+import (
+	"fmt"
+
+	"iron-sentinel/findings"
+)
+
+// Findings turns each rule match into a finding. Sigma levels map directly
+// to severities and the rule status sets the confidence.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Matches))
+	for _, m := range res.Matches {
+		rule := m.RuleID
+		if rule == "" {
+			rule = m.Title
+		}
+		f := findings.Finding{
+			Analyzer:    "sigma",
+			Rule:        rule,
+			Severity:    findings.ParseSeverity(m.Level, findings.SeverityMedium),
+			Confidence:  confidence(m.Status),
+			Title:       m.Title,
+			Description: fmt.Sprintf("Sigma rule matched a %s event in %s.", m.Logsource, m.Artifact),
+			Time:        m.Time,
+			Evidence:    []findings.Evidence{{Artifact: m.Artifact, Line: m.Line, Excerpt: m.Message}},
+			Attack:      m.Attack,
+			Metadata:    map[string]string{"rule_file": m.RuleFile, "logsource": m.Logsource},
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
+
+func confidence(status string) string {
+	switch status {
+	case "stable":
+		return findings.ConfidenceHigh
+	case "experimental":
+		return findings.ConfidenceLow
+	}
+	return findings.ConfidenceMedium
+}
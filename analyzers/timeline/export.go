@@ -0,0 +1,355 @@
+package timeline
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Filter struct {
+	From  time.Time
+	To    time.Time
+	Types []string
+}
+
+func ReadJSONL(path string) ([]Event, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(s.Bytes(), &ev); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	return events, s.Err()
+}
+
+func (f Filter) Apply(events []Event) []Event {
+	types := map[string]bool{}
+	for _, t := range f.Types {
+		types[t] = true
+	}
+
+	var out []Event
+	for _, ev := range events {
+		if len(types) > 0 && !types[ev.Type] {
+			continue
+		}
+		if !f.From.IsZero() || !f.To.IsZero() {
+			t, err := time.Parse(time.RFC3339Nano, ev.Time)
+			if err != nil {
+				continue
+			}
+			if !f.From.IsZero() && t.Before(f.From) {
+				continue
+			}
+			if !f.To.IsZero() && t.After(f.To) {
+				continue
+			}
+		}
+		out = append(out, ev)
+	}
+	return out
+}
+
+var ExportFormats = []string{"bodyfile", "mactime", "l2tcsv", "timesketch"}
+
+func Export(w io.Writer, events []Event, format string) error {
+	switch format {
+	case "bodyfile":
+		return writeBodyfile(w, events)
+	case "mactime":
+		return writeMactime(w, events)
+	case "l2tcsv":
+		return writeL2TCSV(w, events)
+	case "timesketch":
+		return writeTimesketch(w, events)
+	default:
+		return fmt.Errorf("unknown export format %q (want %s)", format, strings.Join(ExportFormats, "|"))
+	}
+}
+
+type bodyfileEntry struct {
+	path  string
+	inode string
+	mode  string
+	uid   string
+	gid   string
+	size  int64
+	sha   string
+	times [4]int64
+}
+
+// writeBodyfile emits Sleuthkit 3.x bodyfile lines
+// (MD5|name|inode|mode|UID|GID|size|atime|mtime|ctime|crtime). Only
+// filesystem events carry the per-file times a bodyfile needs, so the
+// per-timestamp file_macb events are folded back into one line per file.
+func writeBodyfile(w io.Writer, events []Event) error {
+	byPath := map[string]*bodyfileEntry{}
+	var order []string
+	for _, ev := range events {
+		if ev.Type != "file_macb" || len(ev.MACB) != 4 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, ev.Time)
+		if err != nil {
+			continue
+		}
+		e, ok := byPath[ev.Path]
+		if !ok {
+			e = &bodyfileEntry{
+				path:  ev.Path,
+				inode: ev.Metadata["inode"],
+				mode:  ev.Metadata["mode"],
+				uid:   ev.Metadata["uid"],
+				gid:   ev.Metadata["gid"],
+				size:  ev.SizeBytes,
+				sha:   ev.SHA256,
+			}
+			byPath[ev.Path] = e
+			order = append(order, ev.Path)
+		}
+		for i, c := range ev.MACB {
+			if c != '.' {
+				e.times[i] = t.Unix()
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, p := range order {
+		e := byPath[p]
+		inode := e.inode
+		if inode == "" {
+			inode = "0"
+		}
+		// bodyfile order is atime|mtime|ctime|crtime; MACB order is m,a,c,b.
+		_, _ = fmt.Fprintf(bw, "0|%s|%s|%s|%s|%s|%d|%d|%d|%d|%d\n",
+			e.path, inode, e.mode, orZero(e.uid), orZero(e.gid), e.size,
+			e.times[1], e.times[0], e.times[2], e.times[3])
+	}
+	return bw.Flush()
+}
+
+// writeMactime matches `mactime -d` output. Non-filesystem events use the
+// event type and message as the file name so logs interleave with files.
+func writeMactime(w io.Writer, events []Event) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"Date", "Size", "Type", "Mode", "UID", "GID", "Meta", "File Name"})
+	for _, ev := range events {
+		t, err := time.Parse(time.RFC3339Nano, ev.Time)
+		if err != nil {
+			continue
+		}
+		macb := ev.MACB
+		if macb == "" {
+			macb = "...."
+		}
+		_ = cw.Write([]string{
+			t.UTC().Format("Mon Jan 02 2006 15:04:05"),
+			fmt.Sprintf("%d", ev.SizeBytes),
+			macb,
+			ev.Metadata["mode"],
+			orZero(ev.Metadata["uid"]),
+			orZero(ev.Metadata["gid"]),
+			orZero(ev.Metadata["inode"]),
+			eventName(ev),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeL2TCSV(w io.Writer, events []Event) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"date", "time", "timezone", "MACB", "source", "sourcetype", "type", "user", "host", "short", "desc", "version", "filename", "inode", "notes", "format", "extra"})
+	for _, ev := range events {
+		t, err := time.Parse(time.RFC3339Nano, ev.Time)
+		if err != nil {
+			continue
+		}
+		t = t.UTC()
+		source, sourceType := l2tSource(ev)
+		macb := strings.ToUpper(ev.MACB)
+		if macb == "" {
+			macb = "...."
+		}
+		filename := ev.Path
+		if filename == "" {
+			filename = ev.Artifact
+		}
+		_ = cw.Write([]string{
+			t.Format("01/02/2006"),
+			t.Format("15:04:05"),
+			"UTC",
+			macb,
+			source,
+			sourceType,
+			timestampDesc(ev),
+			ev.Metadata["user"],
+			ev.Metadata["host"],
+			shortDesc(ev),
+			eventName(ev),
+			"2",
+			filename,
+			orDash(ev.Metadata["inode"]),
+			"-",
+			"iron-sentinel",
+			extra(ev),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeTimesketch(w io.Writer, events []Event) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, ev := range events {
+		t, err := time.Parse(time.RFC3339Nano, ev.Time)
+		if err != nil {
+			continue
+		}
+		doc := map[string]interface{}{}
+		for k, v := range ev.Metadata {
+			doc[k] = v
+		}
+		doc["message"] = eventName(ev)
+		doc["datetime"] = t.UTC().Format(time.RFC3339Nano)
+		doc["timestamp"] = t.UnixMicro()
+		doc["timestamp_desc"] = timestampDesc(ev)
+		doc["data_type"] = ev.Type
+		if ev.Source != "" {
+			doc["source_short"] = ev.Source
+		}
+		if ev.Path != "" {
+			doc["path"] = ev.Path
+		}
+		if ev.Artifact != "" {
+			doc["artifact"] = ev.Artifact
+		}
+		if ev.SHA256 != "" {
+			doc["sha256"] = ev.SHA256
+		}
+		if ev.MACB != "" {
+			doc["macb"] = ev.MACB
+		}
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func eventName(ev Event) string {
+	if ev.Type == "file_macb" {
+		return ev.Path
+	}
+	name := "[" + ev.Type + "]"
+	if ev.Message != "" {
+		return name + " " + ev.Message
+	}
+	if ev.Artifact != "" {
+		return name + " " + ev.Artifact
+	}
+	return name
+}
+
+func shortDesc(ev Event) string {
+	s := eventName(ev)
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+	return s
+}
+
+var macbDescs = []string{"Content Modification Time", "Last Access Time", "Metadata Modification Time", "Creation Time"}
+
+func timestampDesc(ev Event) string {
+	if ev.Type == "file_macb" {
+		var parts []string
+		for i, c := range ev.MACB {
+			if c != '.' && i < len(macbDescs) {
+				parts = append(parts, macbDescs[i])
+			}
+		}
+		return strings.Join(parts, "; ")
+	}
+	switch ev.Type {
+	case "artifact_collected":
+		return "Collection Time"
+	case "triage_started", "triage_finished":
+		return "Triage Time"
+	case "login", "logout", "failed_login", "boot":
+		return "Event Time"
+	default:
+		return "Entry Written"
+	}
+}
+
+func l2tSource(ev Event) (string, string) {
+	switch ev.Source {
+	case "fs_snapshot":
+		return "FILE", "File stat"
+	case "auth":
+		return "LOG", "Auth Log"
+	case "syslog":
+		return "LOG", "Syslog"
+	case "audit":
+		return "LOG", "Audit Log"
+	case "wtmp", "btmp":
+		return "LOG", "UTMP session"
+	case "shell_history":
+		return "HIST", "Shell History"
+	case "custody":
+		return "IRON", "Chain of Custody"
+	}
+	return "IRON", "Iron-Sentinel"
+}
+
+func extra(ev Event) string {
+	keys := make([]string, 0, len(ev.Metadata))
+	for k := range ev.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys)+1)
+	for _, k := range keys {
+		parts = append(parts, k+": "+ev.Metadata[k])
+	}
+	if ev.SHA256 != "" {
+		parts = append(parts, "sha256: "+ev.SHA256)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,89 @@
+package timeline
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"iron-sentinel/collectors"
+)
+
+type snapshotRecord struct {
+	Path      string `json:"path"`
+	Type      string `json:"type"`
+	SizeBytes int64  `json:"size_bytes"`
+	Mode      string `json:"mode"`
+	ModTime   string `json:"mod_time"`
+	ATime     string `json:"atime"`
+	CTime     string `json:"ctime"`
+	BTime     string `json:"btime"`
+	Inode     uint64 `json:"inode"`
+	UID       int    `json:"uid"`
+	GID       int    `json:"gid"`
+	SHA256    string `json:"sha256"`
+}
+
+// snapshotEvents emits one event per distinct timestamp of each snapshot
+// entry, with the MACB flags collapsed mactime-style ("m.c." etc.).
+func snapshotEvents(ctx context.Context, outputDir string, a collectors.Artifact) ([]Event, error) {
+	var events []Event
+	err := collectors.ReadLines(ctx, outputDir, a, func(line []byte) error {
+		var rec snapshotRecord
+		if json.Unmarshal(line, &rec) == nil {
+			events = append(events, macbEvents(a.RelativePath, rec)...)
+		}
+		return nil
+	})
+	return events, err
+}
+
+func macbEvents(artifact string, rec snapshotRecord) []Event {
+	stamps := []string{rec.ModTime, rec.ATime, rec.CTime, rec.BTime}
+	letters := "macb"
+
+	var order []time.Time
+	flags := map[time.Time][]byte{}
+	for i, st := range stamps {
+		if st == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, st)
+		if err != nil || t.Unix() <= 0 {
+			continue
+		}
+		t = t.UTC()
+		fl, ok := flags[t]
+		if !ok {
+			fl = []byte("....")
+			order = append(order, t)
+		}
+		fl[i] = letters[i]
+		flags[t] = fl
+	}
+
+	events := make([]Event, 0, len(order))
+	for _, t := range order {
+		md := map[string]string{
+			"file_type": rec.Type,
+			"mode":      rec.Mode,
+			"uid":       strconv.Itoa(rec.UID),
+			"gid":       strconv.Itoa(rec.GID),
+		}
+		if rec.Inode != 0 {
+			md["inode"] = strconv.FormatUint(rec.Inode, 10)
+		}
+		events = append(events, Event{
+			Time:      t.Format(time.RFC3339Nano),
+			Type:      "file_macb",
+			Source:    "fs_snapshot",
+			MACB:      string(flags[t]),
+			Path:      rec.Path,
+			Artifact:  artifact,
+			SHA256:    rec.SHA256,
+			SizeBytes: rec.SizeBytes,
+			Metadata:  md,
+		})
+	}
+	return events
+}
@@ -6,36 +6,54 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
+	"iron-sentinel/analyzers/logs"
 	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
 )
 
 type Event struct {
 	Time        string            `json:"time"`
 	Type        string            `json:"type"`
+	Source      string            `json:"source,omitempty"`
+	MACB        string            `json:"macb,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Message     string            `json:"message,omitempty"`
 	Artifact    string            `json:"artifact,omitempty"`
 	Collector   string            `json:"collector,omitempty"`
 	SHA256      string            `json:"sha256,omitempty"`
 	SizeBytes   int64             `json:"size_bytes,omitempty"`
 	Metadata    map[string]string `json:"metadata,omitempty"`
 	CollectedAt string            `json:"collected_at,omitempty"`
+	// Custody is the hash of the custody log entry a custody event comes
+	// from.
+	Custody string `json:"custody,omitempty"`
 }
 
 type Options struct {
-	CaseID    string
-	StartedAt time.Time
+	CaseID       string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	RelativePath string
 }
 
 func WriteJSONL(ctx context.Context, outputDir string, artifacts []collectors.Artifact, opts Options) (string, error) {
-	_ = ctx
-
-	rel := filepath.ToSlash(filepath.Join("analysis", "timeline.jsonl"))
+	rel := opts.RelativePath
+	if rel == "" {
+		rel = filepath.ToSlash(filepath.Join("analysis", "timeline.jsonl"))
+	}
 	path := filepath.Join(outputDir, rel)
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return "", err
 	}
 
+	events, err := Build(ctx, outputDir, artifacts, opts)
+	if err != nil {
+		return "", err
+	}
+
 	f, err := os.Create(path)
 	if err != nil {
 		return "", err
@@ -44,22 +62,66 @@ func WriteJSONL(ctx context.Context, outputDir string, artifacts []collectors.Ar
 
 	w := bufio.NewWriter(f)
 	enc := json.NewEncoder(w)
+	for _, ev := range events {
+		_ = enc.Encode(ev)
+	}
 
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// Build returns the merged host and collection events sorted by time. Host
+// events come from filesystem snapshot MACB times and parsed logs, wtmp and
+// shell history; collection events bracket the triage run itself.
+func Build(ctx context.Context, outputDir string, artifacts []collectors.Artifact, opts Options) ([]Event, error) {
 	started := opts.StartedAt
 	if started.IsZero() {
 		started = time.Now().UTC()
 	}
 
-	_ = enc.Encode(Event{
+	events := []Event{{
 		Time: started.UTC().Format(time.RFC3339Nano),
 		Type: "triage_started",
 		Metadata: map[string]string{
 			"case_id": opts.CaseID,
 		},
-	})
+	}}
+
+	for _, a := range artifacts {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		switch {
+		case a.Collector == "fs_snapshot" && filepath.Base(a.RelativePath) == "metadata.jsonl":
+			evs, err := snapshotEvents(ctx, outputDir, a)
+			if err == nil {
+				events = append(events, evs...)
+			}
+		case logs.Supported(a):
+			_ = logs.ReadArtifact(outputDir, a, func(r logs.Record) error {
+				// Untimed records (plain shell history) cannot be placed.
+				if r.Time.IsZero() {
+					return nil
+				}
+				events = append(events, recordEvent(r))
+				return nil
+			})
+		}
+	}
+
+	if entries, err := evidence.ReadCustody(filepath.Join(outputDir, evidence.CustodyFile)); err == nil {
+		for _, e := range entries {
+			events = append(events, custodyEvent(e))
+		}
+	}
 
 	for _, a := range artifacts {
-		_ = enc.Encode(Event{
+		events = append(events, Event{
 			Time:        a.CollectedAt,
 			Type:        "artifact_collected",
 			Artifact:    a.RelativePath,
@@ -71,8 +133,12 @@ func WriteJSONL(ctx context.Context, outputDir string, artifacts []collectors.Ar
 		})
 	}
 
-	_ = enc.Encode(Event{
-		Time: time.Now().UTC().Format(time.RFC3339Nano),
+	finished := opts.FinishedAt
+	if finished.IsZero() {
+		finished = time.Now().UTC()
+	}
+	events = append(events, Event{
+		Time: finished.UTC().Format(time.RFC3339Nano),
 		Type: "triage_finished",
 		Metadata: map[string]string{
 			"case_id":   opts.CaseID,
@@ -80,10 +146,115 @@ func WriteJSONL(ctx context.Context, outputDir string, artifacts []collectors.Ar
 		},
 	})
 
-	if err := w.Flush(); err != nil {
-		return "", err
+	sortEvents(events)
+	return events, nil
+}
+
+func sortEvents(events []Event) {
+	keys := make([]time.Time, len(events))
+	for i, ev := range events {
+		keys[i], _ = time.Parse(time.RFC3339Nano, ev.Time)
+	}
+	idx := make([]int, len(events))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return keys[idx[i]].Before(keys[idx[j]]) })
+
+	sorted := make([]Event, len(events))
+	for i, k := range idx {
+		sorted[i] = events[k]
+	}
+	copy(events, sorted)
+}
+
+var recordTypes = map[string]string{
+	"auth":          "auth_log",
+	"syslog":        "syslog",
+	"audit":         "audit",
+	"btmp":          "failed_login",
+	"shell_history": "shell_command",
+}
+
+var utmpEventTypes = map[string]string{
+	"user_process": "login",
+	"dead_process": "logout",
+	"boot_time":    "boot",
+	"run_level":    "run_level",
+}
+
+func recordEvent(r logs.Record) Event {
+	typ := recordTypes[r.Kind]
+	if r.Kind == "wtmp" {
+		typ = utmpEventTypes[r.Fields["ut_type"]]
+		if typ == "" {
+			typ = "wtmp"
+		}
+	}
+
+	md := map[string]string{}
+	for k, v := range r.Fields {
+		md[k] = v
+	}
+	if r.Host != "" {
+		md["host"] = r.Host
+	}
+	if r.Program != "" {
+		md["program"] = r.Program
+	}
+	if r.PID != "" {
+		md["pid"] = r.PID
+	}
+	if r.User != "" {
+		md["user"] = r.User
+	}
+	if r.Line > 0 {
+		md["line"] = fmtInt(r.Line)
+	}
+	if len(md) == 0 {
+		md = nil
+	}
+
+	return Event{
+		Time:     r.Time.UTC().Format(time.RFC3339Nano),
+		Type:     typ,
+		Source:   r.Kind,
+		Message:  r.Message,
+		Artifact: r.Artifact,
+		Metadata: md,
+	}
+}
+
+func custodyEvent(e evidence.CustodyEntry) Event {
+	md := map[string]string{"seq": fmtInt(e.Seq)}
+	for k, v := range e.Detail {
+		md[k] = v
+	}
+	if e.Actor.User != "" {
+		md["user"] = e.Actor.User
+	}
+	if e.Actor.UID != "" {
+		md["uid"] = e.Actor.UID
+	}
+	if e.Actor.Host != "" {
+		md["host"] = e.Actor.Host
+	}
+	if e.Actor.Tool != "" {
+		md["tool"] = e.Actor.Tool
+	}
+	msg := e.Action + " by " + e.Actor.User
+	if e.Actor.Host != "" {
+		msg += "@" + e.Actor.Host
+	}
+	return Event{
+		Time:     e.Time,
+		Type:     "custody_" + e.Action,
+		Source:   "custody",
+		Message:  msg,
+		Artifact: evidence.CustodyFile,
+		Metadata: md,
+		Custody:  e.Hash,
 	}
-	return rel, nil
 }
 
 func fmtInt(i int) string {
@@ -0,0 +1,66 @@
+package webshell
+
+import (
+	"fmt"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+// MinScore is the lowest score reported as a finding; weak traits such as
+// an encoded blob alone stay in the scan result only.
+const MinScore = 25
+
+// Findings reports every script scoring at least MinScore. Severity follows
+// the score; confidence is high when a trait that is rare in legitimate code
+// matched, such as command execution on request input.
+func Findings(res Result) []findings.Finding {
+	var out []findings.Finding
+	for _, file := range res.Files {
+		if file.Score < MinScore {
+			continue
+		}
+		f := findings.Finding{
+			Analyzer:   "webshell",
+			Rule:       "webshell.suspicious_script",
+			Severity:   findings.SeverityLow,
+			Confidence: findings.ConfidenceLow,
+			Title:      "Possible webshell: " + file.Path,
+			Attack:     []string{"T1505.003"},
+		}
+		switch {
+		case file.Score >= 100:
+			f.Severity = findings.SeverityCritical
+		case file.Score >= 60:
+			f.Severity = findings.SeverityHigh
+		case file.Score >= 40:
+			f.Severity = findings.SeverityMedium
+		}
+		var names []string
+		for _, t := range file.Traits {
+			names = append(names, t.Name)
+			if strong(t.Name) {
+				f.Confidence = findings.ConfidenceHigh
+			} else if f.Confidence == findings.ConfidenceLow && file.Score >= 40 {
+				f.Confidence = findings.ConfidenceMedium
+			}
+			ev := findings.Evidence{Artifact: file.Artifact, Path: file.Path, Line: t.Line, SHA256: file.SHA256, Excerpt: t.Name}
+			if t.Snippet != "" {
+				ev.Excerpt += ": " + t.Snippet
+			}
+			f.Evidence = append(f.Evidence, ev)
+		}
+		f.Description = fmt.Sprintf("%s %s script scored %d: %s.", file.Path, file.Language, file.Score, strings.Join(names, ", "))
+		f.Metadata = map[string]string{
+			"score":    fmt.Sprintf("%d", file.Score),
+			"language": file.Language,
+			"traits":   strings.Join(names, ","),
+		}
+		if file.ModTime != "" {
+			f.Metadata["mod_time"] = file.ModTime
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
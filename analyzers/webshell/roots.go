@@ -0,0 +1,160 @@
+package webshell
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultRoots are common document roots; entries are glob patterns.
+var DefaultRoots = []string{
+	"/var/www",
+	"/srv/www",
+	"/srv/http",
+	"/usr/share/nginx/html",
+	"/usr/local/apache2/htdocs",
+	"/opt/lampp/htdocs",
+	"/var/lib/tomcat*/webapps",
+	"/opt/tomcat*/webapps",
+	"/usr/local/tomcat/webapps",
+}
+
+// ConfigDirs hold nginx and Apache configuration whose root, alias and
+// DocumentRoot directives name further web roots.
+var ConfigDirs = []string{
+	"/etc/nginx",
+	"/usr/local/etc/nginx",
+	"/etc/apache2",
+	"/etc/httpd",
+	"/usr/local/apache2/conf",
+}
+
+// isServerConfig reports whether p is a web server configuration file.
+func isServerConfig(p string) bool {
+	for _, d := range ConfigDirs {
+		if strings.HasPrefix(p, d+"/") {
+			return strings.HasSuffix(p, ".conf") || strings.Contains(p, "/sites-enabled/") || strings.Contains(p, "/sites-available/")
+		}
+	}
+	return false
+}
+
+// configRoots extracts document roots from nginx "root" and "alias"
+// directives and Apache "DocumentRoot" and "Alias" directives. Paths built
+// from variables cannot be resolved and are skipped.
+func configRoots(data []byte) []string {
+	var out []string
+	s := bufio.NewScanner(bytes.NewReader(data))
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(line), ";"))
+		if len(fields) < 2 {
+			continue
+		}
+		var p string
+		switch strings.ToLower(fields[0]) {
+		case "root", "documentroot":
+			p = fields[1]
+		case "alias":
+			// nginx: alias <path>; Apache: Alias <url> <path>
+			p = fields[len(fields)-1]
+		default:
+			continue
+		}
+		p = strings.Trim(p, `"'`)
+		if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "$%{") {
+			continue
+		}
+		if p = path.Clean(p); p != "/" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// liveRoots resolves the web roots on this host: the explicit roots, the
+// DefaultRoots that exist and the roots named by server configuration.
+func liveRoots(explicit []string) []string {
+	var roots []string
+	roots = append(roots, explicit...)
+	for _, pattern := range DefaultRoots {
+		matches, _ := filepath.Glob(pattern)
+		roots = append(roots, matches...)
+	}
+	for _, dir := range ConfigDirs {
+		_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !isServerConfig(p) {
+				return nil
+			}
+			if b, err := os.ReadFile(p); err == nil {
+				roots = append(roots, configRoots(b)...)
+			}
+			return nil
+		})
+	}
+	var out []string
+	for _, r := range normalizeRoots(roots) {
+		if info, err := os.Stat(r); err == nil && info.IsDir() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// normalizeRoots cleans and sorts roots and drops those nested in another.
+func normalizeRoots(roots []string) []string {
+	seen := map[string]bool{}
+	var clean []string
+	for _, r := range roots {
+		r = path.Clean(r)
+		if r == "/" || r == "." || seen[r] {
+			continue
+		}
+		seen[r] = true
+		clean = append(clean, r)
+	}
+	sort.Strings(clean)
+	var out []string
+	for _, r := range clean {
+		if len(out) > 0 && underRoot(r, out[len(out)-1]) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+func underRoot(p, root string) bool {
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
+// inRoots matches p against roots, which may be glob patterns.
+func inRoots(p string, roots []string) bool {
+	for _, r := range roots {
+		if !strings.ContainsAny(r, "*?[") {
+			if underRoot(p, r) {
+				return true
+			}
+			continue
+		}
+		// Match the pattern against the same number of leading elements.
+		n := strings.Count(r, "/")
+		parts := strings.SplitN(p, "/", n+2)
+		if len(parts) <= n {
+			continue
+		}
+		if ok, _ := path.Match(r, strings.Join(parts[:n+1], "/")); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,213 @@
+package webshell
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	LangPHP    = "php"
+	LangJSP    = "jsp"
+	LangASP    = "asp"
+	LangPython = "python"
+	LangPerl   = "perl"
+)
+
+var extLang = map[string]string{
+	".php": LangPHP, ".php3": LangPHP, ".php4": LangPHP, ".php5": LangPHP, ".php7": LangPHP,
+	".phtml": LangPHP, ".phar": LangPHP, ".inc": LangPHP,
+	".jsp": LangJSP, ".jspx": LangJSP, ".jspf": LangJSP,
+	".asp": LangASP, ".aspx": LangASP, ".ashx": LangASP, ".asmx": LangASP, ".cer": LangASP,
+	".py": LangPython,
+	".pl": LangPerl, ".pm": LangPerl, ".cgi": LangPerl,
+}
+
+// language returns the script language of p by extension. Python and Perl
+// files are only of interest inside web roots, which the caller checks; a
+// .cgi script is Python when its shebang says so.
+func language(p string, head []byte) string {
+	lang := extLang[strings.ToLower(path.Ext(p))]
+	if strings.HasSuffix(strings.ToLower(p), ".cgi") {
+		first, _, _ := strings.Cut(string(head), "\n")
+		if strings.HasPrefix(first, "#!") && strings.Contains(first, "python") {
+			return LangPython
+		}
+	}
+	return lang
+}
+
+// serverSide reports whether lang is only ever executed by a web server, as
+// opposed to general purpose scripting languages.
+func serverSide(lang string) bool {
+	return lang == LangPHP || lang == LangJSP || lang == LangASP
+}
+
+// rule is one webshell trait. Pattern is matched per line; when Context is
+// set it must also match somewhere in the file, which is how "command
+// execution" becomes "command execution with request input".
+type rule struct {
+	Name    string
+	Weight  int
+	Strong  bool
+	Langs   []string
+	Pattern *regexp.Regexp
+	Context *regexp.Regexp
+}
+
+const (
+	// phpInput is request input, including attacker chosen headers.
+	phpInput  = `(\$_(GET|POST|REQUEST|COOKIE|FILES)\b|\$_SERVER\s*\[\s*['"]HTTP_)`
+	phpDecode = `(base64_decode|gzinflate|gzuncompress|gzdecode|str_rot13|strrev|hex2bin|convert_uudecode)`
+)
+
+var rules = []rule{
+	{Name: "eval_input", Weight: 60, Strong: true, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)\b(eval|assert|create_function)\s*\(\s*@?\s*(stripslashes\s*\(\s*)?` + phpInput)},
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)\b(system|exec|shell_exec|passthru|popen|proc_open|pcntl_exec)\s*\([^;]{0,80}` + phpInput)},
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile("`[^`]*" + phpInput)},
+	{Name: "dynamic_call", Weight: 60, Strong: true, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(phpInput + `\s*\[[^\]]+\]\s*\(`)},
+	{Name: "eval_decode", Weight: 40, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)\b(eval|assert)\s*\(\s*@?\s*` + phpDecode + `\s*\(`)},
+	{Name: "preg_replace_eval", Weight: 40, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)preg_replace\s*\(\s*['"]/[^'"]*/[a-z]*e[a-z]*['"]`)},
+	{Name: "decode_chain", Weight: 20, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)` + phpDecode + `\s*\(\s*` + phpDecode + `\s*\(`)},
+	{Name: "upload_handler", Weight: 10, Langs: []string{LangPHP},
+		Pattern: regexp.MustCompile(`(?i)\bmove_uploaded_file\s*\(`)},
+
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangJSP},
+		Pattern: regexp.MustCompile(`(Runtime\.getRuntime\(\)\.exec|new\s+ProcessBuilder)\s*\(`),
+		Context: regexp.MustCompile(`request\.get(Parameter|Header|InputStream|Reader)`)},
+	{Name: "class_loader", Weight: 50, Strong: true, Langs: []string{LangJSP},
+		Pattern: regexp.MustCompile(`\bdefineClass\s*\(`),
+		Context: regexp.MustCompile(`(?i)base64|Cipher\.getInstance`)},
+
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangASP},
+		Pattern: regexp.MustCompile(`(?i)(Process\.Start|ProcessStartInfo|WScript\.Shell|cmd\.exe\s*/c)`),
+		Context: regexp.MustCompile(`(?i)\bRequest\s*(\.\s*(Form|QueryString|Item|Params)|\()`)},
+	{Name: "eval_input", Weight: 60, Strong: true, Langs: []string{LangASP},
+		Pattern: regexp.MustCompile(`(?i)\b(eval|execute)\s*\(\s*Request`)},
+	{Name: "assembly_load", Weight: 50, Strong: true, Langs: []string{LangASP},
+		Pattern: regexp.MustCompile(`(?i)Assembly\.Load\s*\(`),
+		Context: regexp.MustCompile(`(?i)Convert\.FromBase64String|Request`)},
+
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangPython},
+		Pattern: regexp.MustCompile(`\b(os\.system|os\.popen|subprocess\.(call|run|Popen|check_output))\s*\(`),
+		Context: regexp.MustCompile(`request\.(args|form|values|GET|POST)|cgi\.FieldStorage`)},
+	{Name: "eval_input", Weight: 60, Strong: true, Langs: []string{LangPython},
+		Pattern: regexp.MustCompile(`\b(eval|exec)\s*\(`),
+		Context: regexp.MustCompile(`request\.(args|form|values|GET|POST)|cgi\.FieldStorage`)},
+	{Name: "eval_decode", Weight: 40, Langs: []string{LangPython},
+		Pattern: regexp.MustCompile(`\b(exec|eval)\s*\(\s*(base64\.b64decode|zlib\.decompress|codecs\.decode|marshal\.loads)`)},
+
+	{Name: "exec_input", Weight: 60, Strong: true, Langs: []string{LangPerl},
+		Pattern: regexp.MustCompile("(\\bsystem\\s*\\(|\\bexec\\s*\\(|\\bqx\\s*[{(/]|`[^`]*\\$)"),
+		Context: regexp.MustCompile(`\bparam\s*\(|QUERY_STRING|CGI->new`)},
+	{Name: "eval_decode", Weight: 40, Langs: []string{LangPerl},
+		Pattern: regexp.MustCompile(`\beval\s*\(?\s*(decode_base64|unpack|pack)\b`)},
+
+	{Name: "shell_signature", Weight: 50, Strong: true,
+		Pattern: regexp.MustCompile(`(?i)(FilesMan|c99shell|r57shell|b374k|\bWSO\s*[0-9]|Uname:.{0,40}Safe mode|\bweevely|China\s*Chopper|AntSword|Godzilla|Behinder)`)},
+	{Name: "long_encoded_string", Weight: 15,
+		Pattern: regexp.MustCompile(`[A-Za-z0-9+/]{300,}={0,2}`)},
+	{Name: "hex_escapes", Weight: 15,
+		Pattern: regexp.MustCompile(`(\\x[0-9a-fA-F]{2}){20,}|(?i)(chr\s*\(\s*\d+\s*\)\s*\.\s*){8,}`)},
+}
+
+const (
+	// entropyThreshold is the bits per byte above which a script is treated
+	// as obfuscated; readable source sits around 4.5 to 5.2.
+	entropyThreshold = 5.6
+	longLine         = 4000
+	maxSnippet       = 160
+)
+
+// Trait is one matched rule. Line is 1-based and 0 for file-level traits.
+type Trait struct {
+	Name    string `json:"name"`
+	Weight  int    `json:"weight"`
+	Line    int    `json:"line,omitempty"`
+	Snippet string `json:"snippet,omitempty"`
+}
+
+// score matches the rules for lang against data. Each trait counts once, at
+// its first match.
+func score(lang string, data []byte) ([]Trait, float64) {
+	var traits []Trait
+	seen := map[string]bool{}
+	text := string(data)
+	var applicable []rule
+	for _, r := range rules {
+		if len(r.Langs) > 0 && !slices.Contains(r.Langs, lang) {
+			continue
+		}
+		if r.Context != nil && !r.Context.MatchString(text) {
+			continue
+		}
+		applicable = append(applicable, r)
+	}
+
+	long := false
+	for i, line := range strings.Split(text, "\n") {
+		if len(line) > longLine && !long {
+			long = true
+			traits = append(traits, Trait{Name: "long_line", Weight: 10, Line: i + 1, Snippet: snippet(line, 0, 0)})
+		}
+		for _, r := range applicable {
+			if seen[r.Name] {
+				continue
+			}
+			loc := r.Pattern.FindStringIndex(line)
+			if loc == nil {
+				continue
+			}
+			seen[r.Name] = true
+			traits = append(traits, Trait{Name: r.Name, Weight: r.Weight, Line: i + 1, Snippet: snippet(line, loc[0], loc[1])})
+		}
+	}
+
+	h := linux.Entropy(data)
+	if len(data) >= 512 && h >= entropyThreshold {
+		traits = append(traits, Trait{Name: "high_entropy", Weight: 15})
+	}
+	return traits, h
+}
+
+// strong reports whether a trait is specific enough to stand on its own.
+func strong(name string) bool {
+	for _, r := range rules {
+		if r.Name == name {
+			return r.Strong
+		}
+	}
+	return false
+}
+
+func snippet(line string, start, end int) string {
+	if end-start > maxSnippet {
+		end = start + maxSnippet
+	}
+	pad := (maxSnippet - (end - start)) / 2
+	from, to := start-pad, end+pad
+	if from < 0 {
+		from = 0
+	}
+	if to > len(line) {
+		to = len(line)
+	}
+	s := strings.TrimSpace(strings.ToValidUTF8(line[from:to], "?"))
+	if from > 0 {
+		s = "..." + s
+	}
+	if to < len(line) {
+		s += "..."
+	}
+	return s
+}
@@ -0,0 +1,371 @@
+package webshell
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"iron-sentinel/analyzers/archive"
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+)
+
+const (
+	// DefaultMaxFileSize skips larger scripts; webshells are small.
+	DefaultMaxFileSize = 2 << 20
+	// maxWalkEntries bounds the live walk of each root.
+	maxWalkEntries = 500000
+	// maxPending bounds the Python and Perl sources held back while the
+	// snapshot is read, until its server configuration has been seen.
+	maxPending = 64 << 20
+	// outlierAge is how much newer than its siblings' median a file must be
+	// to count as recently dropped.
+	outlierAge  = 30 * 24 * time.Hour
+	minSiblings = 3
+)
+
+type Options struct {
+	// Live walks the web roots of this host. Only set it when analyzing on
+	// the host the case was collected from.
+	Live bool
+	// Roots are web roots in addition to DefaultRoots and those found in
+	// nginx and Apache configuration.
+	Roots       []string
+	Workers     int
+	MaxFileSize int64
+}
+
+// File is a script with at least one trait. Score is the sum of the trait
+// weights.
+type File struct {
+	Path     string  `json:"path"`
+	Artifact string  `json:"artifact,omitempty"`
+	Language string  `json:"language"`
+	Size     int64   `json:"size"`
+	ModTime  string  `json:"mod_time,omitempty"`
+	SHA256   string  `json:"sha256"`
+	Entropy  float64 `json:"entropy"`
+	Score    int     `json:"score"`
+	Traits   []Trait `json:"traits"`
+}
+
+type Result struct {
+	Roots    []string `json:"roots"`
+	Files    []File   `json:"files"`
+	Scanned  int      `json:"scanned"`
+	Live     bool     `json:"live"`
+	Skipped  []string `json:"skipped,omitempty"`
+	Errors   []string `json:"errors,omitempty"`
+	Finished string   `json:"finished"`
+}
+
+type pending struct {
+	path, artifact, lang string
+	data                 []byte
+}
+
+// ScanArtifacts scores web scripts found in the snapshot archive and, in
+// live mode, under the web roots of the host.
+func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	res := Result{Files: []File{}, Live: opts.Live}
+	mtimes, err := snapshotTimes(ctx, outDir, artifacts)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Result{}, ctx.Err()
+		}
+		res.Errors = append(res.Errors, err.Error())
+	}
+	seen := map[string]bool{}
+
+	// Server-side languages are scored wherever they are; Python and Perl
+	// only inside web roots, which the snapshot's own server configuration
+	// may extend.
+	cfgRoots := append([]string{}, opts.Roots...)
+	var held []pending
+	heldBytes := 0
+	for _, a := range artifacts {
+		if a.Collector != "fs_snapshot" || path.Base(a.RelativePath) != "files.tar.gz" {
+			continue
+		}
+		w := archive.NewWalker()
+		err := w.WalkFile(ctx, a.RelativePath, filepath.Join(outDir, filepath.FromSlash(a.RelativePath)), func(name string, r io.Reader) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			p := "/" + strings.TrimPrefix(name[strings.LastIndexByte(name, '!')+1:], "/")
+			if isServerConfig(p) {
+				if b, err := io.ReadAll(io.LimitReader(r, 1<<20)); err == nil {
+					cfgRoots = append(cfgRoots, configRoots(b)...)
+				}
+				return nil
+			}
+			if extLang[strings.ToLower(path.Ext(p))] == "" {
+				return nil
+			}
+			data, err := io.ReadAll(io.LimitReader(r, opts.MaxFileSize+1))
+			if err != nil {
+				return nil
+			}
+			if int64(len(data)) > opts.MaxFileSize {
+				res.Skipped = append(res.Skipped, p+": exceeds size limit")
+				return nil
+			}
+			lang := language(p, data)
+			if !serverSide(lang) {
+				if heldBytes+len(data) > maxPending {
+					res.Skipped = append(res.Skipped, p+": too many scripts to hold")
+					return nil
+				}
+				heldBytes += len(data)
+				held = append(held, pending{path: p, artifact: a.RelativePath, lang: lang, data: data})
+				return nil
+			}
+			res.Scanned++
+			seen[p] = true
+			if f, ok := scoreFile(p, a.RelativePath, lang, data, mtimes.file[p], mtimes.siblings(p)); ok {
+				res.Files = append(res.Files, f)
+			}
+			return nil
+		})
+		res.Skipped = append(res.Skipped, w.Skipped...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, ctx.Err()
+			}
+			res.Errors = append(res.Errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
+		}
+	}
+	snapRoots := normalizeRoots(append(append([]string{}, DefaultRoots...), cfgRoots...))
+	for _, h := range held {
+		if !inRoots(h.path, snapRoots) {
+			continue
+		}
+		res.Scanned++
+		seen[h.path] = true
+		if f, ok := scoreFile(h.path, h.artifact, h.lang, h.data, mtimes.file[h.path], mtimes.siblings(h.path)); ok {
+			res.Files = append(res.Files, f)
+		}
+	}
+	res.Roots = snapRoots
+
+	if opts.Live {
+		res.Roots = liveRoots(opts.Roots)
+		times := snapTimes{file: map[string]time.Time{}, dir: map[string][]time.Time{}}
+		var paths []string
+		for _, root := range res.Roots {
+			n := 0
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				if err != nil {
+					return nil
+				}
+				if n++; n > maxWalkEntries {
+					return errWalkLimit
+				}
+				if !d.Type().IsRegular() {
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					times.add(p, info.ModTime())
+				}
+				if extLang[strings.ToLower(path.Ext(p))] != "" && !seen[p] {
+					seen[p] = true
+					paths = append(paths, p)
+				}
+				return nil
+			})
+			if errors.Is(err, errWalkLimit) {
+				res.Errors = append(res.Errors, root+": walk limit reached")
+			} else if err != nil {
+				return Result{}, err
+			}
+		}
+
+		files := make([]File, len(paths))
+		found := make([]bool, len(paths))
+		scanned := make([]bool, len(paths))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					f, ok, done, msg := scoreLive(paths[i], opts.MaxFileSize, times)
+					files[i], found[i], scanned[i] = f, ok, done
+					if msg != "" {
+						mu.Lock()
+						res.Skipped = append(res.Skipped, msg)
+						mu.Unlock()
+					}
+				}
+			}()
+		}
+	feed:
+		for i := range paths {
+			select {
+			case <-ctx.Done():
+				break feed
+			case jobs <- i:
+			}
+		}
+		close(jobs)
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		for i := range paths {
+			if scanned[i] {
+				res.Scanned++
+			}
+			if found[i] {
+				res.Files = append(res.Files, files[i])
+			}
+		}
+	}
+
+	sort.SliceStable(res.Files, func(i, j int) bool {
+		if res.Files[i].Score != res.Files[j].Score {
+			return res.Files[i].Score > res.Files[j].Score
+		}
+		return res.Files[i].Path < res.Files[j].Path
+	})
+	sort.Strings(res.Skipped)
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+var errWalkLimit = errors.New("walk limit reached")
+
+func scoreLive(p string, maxSize int64, times snapTimes) (File, bool, bool, string) {
+	info, err := os.Stat(p)
+	if err != nil || !info.Mode().IsRegular() {
+		return File{}, false, false, ""
+	}
+	if info.Size() > maxSize {
+		return File{}, false, false, p + ": exceeds size limit"
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return File{}, false, false, ""
+	}
+	f, ok := scoreFile(p, "", language(p, data), data, info.ModTime(), times.siblings(p))
+	return f, ok, true, ""
+}
+
+// scoreFile scores one script. It reports false when no trait matched. The
+// mtime outlier trait counts on its own, so a script dropped long after the
+// rest of the application is listed even when its content looks clean.
+func scoreFile(p, artifact, lang string, data []byte, mod time.Time, siblings []time.Time) (File, bool) {
+	traits, h := score(lang, data)
+	if t, ok := mtimeOutlier(mod, siblings); ok {
+		traits = append(traits, t)
+	}
+	if len(traits) == 0 {
+		return File{}, false
+	}
+	sum := sha256.Sum256(data)
+	f := File{
+		Path:     p,
+		Artifact: artifact,
+		Language: lang,
+		Size:     int64(len(data)),
+		SHA256:   hex.EncodeToString(sum[:]),
+		Entropy:  float64(int(h*1000+0.5)) / 1000,
+		Traits:   traits,
+	}
+	if !mod.IsZero() {
+		f.ModTime = mod.UTC().Format(time.RFC3339Nano)
+	}
+	for _, t := range traits {
+		f.Score += t.Weight
+	}
+	return f, true
+}
+
+// mtimeOutlier flags a file modified well after most of its siblings, as
+// happens when a shell is dropped into an application deployed long ago.
+func mtimeOutlier(mod time.Time, siblings []time.Time) (Trait, bool) {
+	if mod.IsZero() || len(siblings) < minSiblings {
+		return Trait{}, false
+	}
+	sorted := append([]time.Time{}, siblings...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	median := sorted[len(sorted)/2]
+	if mod.Sub(median) < outlierAge {
+		return Trait{}, false
+	}
+	return Trait{
+		Name:    "mtime_outlier",
+		Weight:  15,
+		Snippet: fmt.Sprintf("modified %s, %d siblings median %s", mod.UTC().Format("2006-01-02"), len(siblings), median.UTC().Format("2006-01-02")),
+	}, true
+}
+
+// snapTimes holds the modification times of regular files, by path and by
+// directory.
+type snapTimes struct {
+	file map[string]time.Time
+	dir  map[string][]time.Time
+}
+
+func (st snapTimes) add(p string, t time.Time) {
+	st.file[p] = t
+	st.dir[path.Dir(p)] = append(st.dir[path.Dir(p)], t)
+}
+
+// snapshotTimes reads the latest snapshot metadata.
+func snapshotTimes(ctx context.Context, outDir string, artifacts []collectors.Artifact) (snapTimes, error) {
+	st := snapTimes{file: map[string]time.Time{}, dir: map[string][]time.Time{}}
+	a, _, err := collectors.ReadLatest(ctx, outDir, artifacts, "fs_snapshot", "metadata.jsonl", func(line []byte) error {
+		var e linux.SnapshotEntry
+		if json.Unmarshal(line, &e) != nil || e.Type != "file" {
+			return nil
+		}
+		if t, err := time.Parse(time.RFC3339Nano, e.ModTime); err == nil {
+			st.add(filepath.ToSlash(e.Path), t)
+		}
+		return nil
+	})
+	if err != nil {
+		return st, fmt.Errorf("%s: %w", a.RelativePath, err)
+	}
+	return st, nil
+}
+
+// siblings returns the modification times of the other files in p's
+// directory.
+func (st snapTimes) siblings(p string) []time.Time {
+	own, ok := st.file[p]
+	var out []time.Time
+	for _, t := range st.dir[path.Dir(p)] {
+		if ok && t.Equal(own) {
+			ok = false
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
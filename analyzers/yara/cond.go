@@ -0,0 +1,562 @@
+package yara
+
+import "strings"
+
+type quantKind int
+
+const (
+	qAny quantKind = iota
+	qAll
+	qNone
+	qCount
+	qPercent
+)
+
+type quantifier struct {
+	kind quantKind
+	n    node
+}
+
+func (p *parser) parseExpr() (node, error) {
+	return p.parseOr()
+}
+
+func (p *parser) parseOr() (node, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := p.accept("or")
+		if err != nil || !ok {
+			return l, err
+		}
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = &binNode{op: "or", l: l, r: r}
+	}
+}
+
+func (p *parser) parseAnd() (node, error) {
+	l, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := p.accept("and")
+		if err != nil || !ok {
+			return l, err
+		}
+		r, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		l = &binNode{op: "and", l: l, r: r}
+	}
+}
+
+func (p *parser) parseNot() (node, error) {
+	if ok, err := p.accept("not"); err != nil {
+		return nil, err
+	} else if ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "not", x: x}, nil
+	}
+	if ok, err := p.accept("defined"); err != nil {
+		return nil, err
+	} else if ok {
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: "defined", x: x}, nil
+	}
+	return p.parseRel()
+}
+
+var relOps = []string{"==", "!=", "<=", ">=", "<", ">", "contains", "icontains", "startswith", "istartswith", "endswith", "iendswith", "iequals"}
+
+func (p *parser) parseRel() (node, error) {
+	l, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return nil, err
+		}
+		op := ""
+		for _, o := range relOps {
+			if (t.kind == tPunct || t.kind == tIdent) && t.text == o {
+				op = o
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		p.lx.Next()
+		r, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		l = &binNode{op: op, l: l, r: r}
+	}
+}
+
+// binaryLevels lists arithmetic and bitwise operators from loosest to
+// tightest binding.
+var binaryLevels = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "\\", "%"},
+}
+
+func (p *parser) parseBinary(level int) (node, error) {
+	if level == len(binaryLevels) {
+		return p.parseUnary()
+	}
+	l, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return nil, err
+		}
+		op := ""
+		if t.kind == tPunct {
+			for _, o := range binaryLevels[level] {
+				if t.text == o {
+					op = o
+				}
+			}
+		}
+		if op == "" {
+			return l, nil
+		}
+		p.lx.Next()
+		// "50% of them" reads as a percentage, not a modulo.
+		if op == "%" {
+			if nt, err := p.lx.Peek(); err == nil && nt.kind == tIdent && nt.text == "of" {
+				return p.parseOf(quantifier{kind: qPercent, n: l})
+			}
+		}
+		r, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l = &binNode{op: op, l: l, r: r}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t, err := p.lx.Peek()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tPunct && (t.text == "-" || t.text == "~") {
+		p.lx.Next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: t.text, x: x}, nil
+	}
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if nt, err := p.lx.Peek(); err == nil && nt.kind == tIdent && nt.text == "of" {
+		return p.parseOf(quantifier{kind: qCount, n: x})
+	}
+	return x, nil
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t, err := p.lx.Next()
+	if err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case tInt:
+		return &litNode{v: intValue(t.ival)}, nil
+	case tString:
+		return &litNode{v: value{kind: kStr, s: t.text}}, nil
+	case tStrID:
+		return p.parseStringRef(t)
+	case tCount:
+		s, err := p.lookupString(t, "#")
+		if err != nil {
+			return nil, err
+		}
+		n := &countNode{s: s}
+		if ok, err := p.accept("in"); err != nil {
+			return nil, err
+		} else if ok {
+			if n.lo, n.hi, err = p.parseRange(); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	case tOffset, tLength:
+		prefix := "@"
+		if t.kind == tLength {
+			prefix = "!"
+		}
+		s, err := p.lookupString(t, prefix)
+		if err != nil {
+			return nil, err
+		}
+		n := &occurrenceNode{s: s, length: t.kind == tLength}
+		if ok, err := p.accept("["); err != nil {
+			return nil, err
+		} else if ok {
+			if n.idx, err = p.parseExpr(); err != nil {
+				return nil, err
+			}
+			if _, err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		}
+		return n, nil
+	case tPunct:
+		if t.text == "(" {
+			x, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	case tIdent:
+		return p.parseIdent(t)
+	}
+	return nil, p.errorf(t, "unexpected %s in condition", t)
+}
+
+func (p *parser) parseIdent(t token) (node, error) {
+	switch t.text {
+	case "true":
+		return &litNode{v: boolValue(true)}, nil
+	case "false":
+		return &litNode{v: boolValue(false)}, nil
+	case "filesize":
+		return &filesizeNode{}, nil
+	case "entrypoint":
+		return &litNode{v: value{}}, nil
+	case "them":
+		return nil, p.errorf(t, "them is only valid in an of expression")
+	case "any":
+		return p.parseOf(quantifier{kind: qAny})
+	case "all":
+		return p.parseOf(quantifier{kind: qAll})
+	case "none":
+		return p.parseOf(quantifier{kind: qNone})
+	case "for":
+		return p.parseFor()
+	}
+	if n, ok := readFuncs[t.text]; ok {
+		if _, err := p.expect("("); err != nil {
+			return nil, err
+		}
+		off, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		r := n
+		r.off = off
+		return &r, nil
+	}
+	for i := len(p.vars) - 1; i >= 0; i-- {
+		if p.vars[i] == t.text {
+			return &varNode{name: t.text}, nil
+		}
+	}
+	if nt, err := p.lx.Peek(); err == nil && nt.kind == tPunct && nt.text == "." {
+		return nil, p.errorf(t, "module %q is not supported", t.text)
+	}
+	if idx, ok := p.rs.names[t.text]; ok {
+		return &ruleNode{idx: idx}, nil
+	}
+	return nil, p.errorf(t, "undefined identifier %q", t.text)
+}
+
+var readFuncs = map[string]readNode{
+	"uint8": {size: 1}, "uint16": {size: 2}, "uint32": {size: 4},
+	"int8": {size: 1, signed: true}, "int16": {size: 2, signed: true}, "int32": {size: 4, signed: true},
+	"uint16be": {size: 2, be: true}, "uint32be": {size: 4, be: true},
+	"int16be": {size: 2, signed: true, be: true}, "int32be": {size: 4, signed: true, be: true},
+}
+
+func (p *parser) parseStringRef(t token) (node, error) {
+	s, err := p.lookupString(t, "$")
+	if err != nil {
+		return nil, err
+	}
+	n := &stringNode{s: s}
+	if ok, err := p.accept("at"); err != nil {
+		return nil, err
+	} else if ok {
+		if n.at, err = p.parseBinary(0); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	if ok, err := p.accept("in"); err != nil {
+		return nil, err
+	} else if ok {
+		if n.lo, n.hi, err = p.parseRange(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// lookupString resolves $a, #a, @a and !a references. A bare prefix refers
+// to the string bound by the innermost for..of loop and resolves to nil.
+func (p *parser) lookupString(t token, prefix string) (*String, error) {
+	id := "$" + strings.TrimPrefix(t.text, prefix)
+	if id == "$" {
+		if !p.inForOf() {
+			return nil, p.errorf(t, "%s used outside of a for..of loop", t.text)
+		}
+		return nil, nil
+	}
+	if strings.HasSuffix(id, "*") {
+		return nil, p.errorf(t, "wildcard %s is only valid in a string set", t.text)
+	}
+	for _, s := range p.rule.strings {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+	return nil, p.errorf(t, "undefined string %s", id)
+}
+
+func (p *parser) inForOf() bool {
+	for _, v := range p.vars {
+		if v == "$" {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) parseRange() (node, node, error) {
+	if _, err := p.expect("("); err != nil {
+		return nil, nil, err
+	}
+	lo, err := p.parseBinary(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := p.expect(".."); err != nil {
+		return nil, nil, err
+	}
+	hi, err := p.parseBinary(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, nil, err
+	}
+	return lo, hi, nil
+}
+
+// parseStringSet parses "them" or a parenthesized list of string
+// identifiers, which may end in a * wildcard.
+func (p *parser) parseStringSet() ([]*String, error) {
+	if ok, err := p.accept("them"); err != nil {
+		return nil, err
+	} else if ok {
+		if len(p.rule.strings) == 0 {
+			return nil, p.errorf(token{line: p.lx.line}, "them used in a rule without strings")
+		}
+		return p.rule.strings, nil
+	}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var set []*String
+	for {
+		t, err := p.lx.Next()
+		if err != nil {
+			return nil, err
+		}
+		if t.kind != tStrID {
+			return nil, p.errorf(t, "expected string identifier, found %s", t)
+		}
+		n := len(set)
+		prefix, wild := strings.CutSuffix(t.text, "*")
+		for _, s := range p.rule.strings {
+			if s.ID == t.text || (wild && strings.HasPrefix(s.ID, prefix)) {
+				set = append(set, s)
+			}
+		}
+		if len(set) == n {
+			return nil, p.errorf(t, "undefined string %s", t.text)
+		}
+		if ok, err := p.accept(","); err != nil {
+			return nil, err
+		} else if !ok {
+			break
+		}
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+func (p *parser) parseOf(q quantifier) (node, error) {
+	if _, err := p.expect("of"); err != nil {
+		return nil, err
+	}
+	set, err := p.parseStringSet()
+	if err != nil {
+		return nil, err
+	}
+	n := &ofNode{q: q, set: set}
+	if ok, err := p.accept("in"); err != nil {
+		return nil, err
+	} else if ok {
+		if n.lo, n.hi, err = p.parseRange(); err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+func (p *parser) parseQuantifier() (quantifier, error) {
+	t, err := p.lx.Peek()
+	if err != nil {
+		return quantifier{}, err
+	}
+	if t.kind == tIdent {
+		switch t.text {
+		case "any":
+			p.lx.Next()
+			return quantifier{kind: qAny}, nil
+		case "all":
+			p.lx.Next()
+			return quantifier{kind: qAll}, nil
+		case "none":
+			p.lx.Next()
+			return quantifier{kind: qNone}, nil
+		}
+	}
+	n, err := p.parsePrimary()
+	if err != nil {
+		return quantifier{}, err
+	}
+	if ok, err := p.accept("%"); err != nil {
+		return quantifier{}, err
+	} else if ok {
+		return quantifier{kind: qPercent, n: n}, nil
+	}
+	return quantifier{kind: qCount, n: n}, nil
+}
+
+func (p *parser) parseFor() (node, error) {
+	q, err := p.parseQuantifier()
+	if err != nil {
+		return nil, err
+	}
+	t, err := p.lx.Next()
+	if err != nil {
+		return nil, err
+	}
+	if t.kind == tIdent && t.text == "of" {
+		set, err := p.parseStringSet()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		body, err := p.parseLoopBody("$")
+		if err != nil {
+			return nil, err
+		}
+		return &forOfNode{q: q, set: set, body: body}, nil
+	}
+	if t.kind != tIdent {
+		return nil, p.errorf(t, "expected loop variable, found %s", t)
+	}
+	if _, err := p.expect("in"); err != nil {
+		return nil, err
+	}
+	n := &forInNode{q: q, name: t.text}
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	first, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := p.accept(".."); err != nil {
+		return nil, err
+	} else if ok {
+		n.lo = first
+		if n.hi, err = p.parseBinary(0); err != nil {
+			return nil, err
+		}
+	} else {
+		n.items = []node{first}
+		for {
+			ok, err := p.accept(",")
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			item, err := p.parseBinary(0)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if n.body, err = p.parseLoopBody(t.text); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (p *parser) parseLoopBody(name string) (node, error) {
+	p.vars = append(p.vars, name)
+	defer func() { p.vars = p.vars[:len(p.vars)-1] }()
+	if _, err := p.expect("("); err != nil {
+		return nil, err
+	}
+	body, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
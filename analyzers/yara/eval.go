@@ -0,0 +1,496 @@
+package yara
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+type valueKind int
+
+const (
+	kUndef valueKind = iota
+	kBool
+	kInt
+	kStr
+)
+
+type value struct {
+	kind valueKind
+	i    int64
+	s    string
+}
+
+func intValue(i int64) value { return value{kind: kInt, i: i} }
+
+func boolValue(b bool) value {
+	if b {
+		return value{kind: kBool, i: 1}
+	}
+	return value{kind: kBool}
+}
+
+func (v value) truth() bool {
+	switch v.kind {
+	case kBool, kInt:
+		return v.i != 0
+	case kStr:
+		return v.s != ""
+	}
+	return false
+}
+
+func (v value) number() (int64, bool) {
+	if v.kind == kInt || v.kind == kBool {
+		return v.i, true
+	}
+	return 0, false
+}
+
+// scanCtx holds per-target state while rule conditions are evaluated.
+type scanCtx struct {
+	data    []byte
+	lower   []byte
+	cache   map[*String][]occurrence
+	results []bool
+	vars    map[string]int64
+	current []*String
+	// hexBudget is the hex matching work left for the target; exhausted
+	// holds the strings whose search stopped early.
+	hexBudget int
+	exhausted map[*String]bool
+}
+
+func newScanCtx(data []byte, rules int) *scanCtx {
+	return &scanCtx{
+		data:      data,
+		cache:     map[*String][]occurrence{},
+		results:   make([]bool, rules),
+		vars:      map[string]int64{},
+		hexBudget: maxTargetHexSteps,
+		exhausted: map[*String]bool{},
+	}
+}
+
+func (c *scanCtx) occurrences(s *String) []occurrence {
+	if s == nil {
+		s = c.current[len(c.current)-1]
+	}
+	if o, ok := c.cache[s]; ok {
+		return o
+	}
+	o, exhausted := s.find(c.data, func() []byte {
+		if c.lower == nil {
+			c.lower = asciiLower(c.data)
+		}
+		return c.lower
+	}, &c.hexBudget)
+	if exhausted {
+		c.exhausted[s] = true
+	}
+	c.cache[s] = o
+	return o
+}
+
+type node interface {
+	eval(c *scanCtx) value
+}
+
+type litNode struct{ v value }
+
+func (n *litNode) eval(*scanCtx) value { return n.v }
+
+type filesizeNode struct{}
+
+func (n *filesizeNode) eval(c *scanCtx) value { return intValue(int64(len(c.data))) }
+
+type varNode struct{ name string }
+
+func (n *varNode) eval(c *scanCtx) value {
+	v, ok := c.vars[n.name]
+	if !ok {
+		return value{}
+	}
+	return intValue(v)
+}
+
+type ruleNode struct{ idx int }
+
+func (n *ruleNode) eval(c *scanCtx) value { return boolValue(c.results[n.idx]) }
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+func (n *unaryNode) eval(c *scanCtx) value {
+	v := n.x.eval(c)
+	switch n.op {
+	case "defined":
+		return boolValue(v.kind != kUndef)
+	case "not":
+		if v.kind == kUndef {
+			return value{}
+		}
+		return boolValue(!v.truth())
+	}
+	i, ok := v.number()
+	if !ok {
+		return value{}
+	}
+	if n.op == "-" {
+		return intValue(-i)
+	}
+	return intValue(^i)
+}
+
+type binNode struct {
+	op   string
+	l, r node
+}
+
+func (n *binNode) eval(c *scanCtx) value {
+	switch n.op {
+	case "and":
+		if !n.l.eval(c).truth() {
+			return boolValue(false)
+		}
+		return boolValue(n.r.eval(c).truth())
+	case "or":
+		if n.l.eval(c).truth() {
+			return boolValue(true)
+		}
+		return boolValue(n.r.eval(c).truth())
+	}
+
+	l, r := n.l.eval(c), n.r.eval(c)
+	if l.kind == kUndef || r.kind == kUndef {
+		return value{}
+	}
+	if l.kind == kStr || r.kind == kStr {
+		if l.kind != r.kind {
+			return value{}
+		}
+		return stringOp(n.op, l.s, r.s)
+	}
+	a, b := l.i, r.i
+	switch n.op {
+	case "==":
+		return boolValue(a == b)
+	case "!=":
+		return boolValue(a != b)
+	case "<":
+		return boolValue(a < b)
+	case "<=":
+		return boolValue(a <= b)
+	case ">":
+		return boolValue(a > b)
+	case ">=":
+		return boolValue(a >= b)
+	case "+":
+		return intValue(a + b)
+	case "-":
+		return intValue(a - b)
+	case "*":
+		return intValue(a * b)
+	case "\\":
+		if b == 0 {
+			return value{}
+		}
+		return intValue(a / b)
+	case "%":
+		if b == 0 {
+			return value{}
+		}
+		return intValue(a % b)
+	case "&":
+		return intValue(a & b)
+	case "|":
+		return intValue(a | b)
+	case "^":
+		return intValue(a ^ b)
+	case "<<":
+		if b < 0 {
+			return value{}
+		}
+		if b >= 64 {
+			return intValue(0)
+		}
+		return intValue(a << uint(b))
+	case ">>":
+		if b < 0 {
+			return value{}
+		}
+		if b >= 64 {
+			return intValue(0)
+		}
+		return intValue(a >> uint(b))
+	}
+	return value{}
+}
+
+func stringOp(op, a, b string) value {
+	switch op {
+	case "==":
+		return boolValue(a == b)
+	case "!=":
+		return boolValue(a != b)
+	case "<":
+		return boolValue(a < b)
+	case "<=":
+		return boolValue(a <= b)
+	case ">":
+		return boolValue(a > b)
+	case ">=":
+		return boolValue(a >= b)
+	case "contains":
+		return boolValue(strings.Contains(a, b))
+	case "icontains":
+		return boolValue(strings.Contains(strings.ToLower(a), strings.ToLower(b)))
+	case "startswith":
+		return boolValue(strings.HasPrefix(a, b))
+	case "istartswith":
+		return boolValue(strings.HasPrefix(strings.ToLower(a), strings.ToLower(b)))
+	case "endswith":
+		return boolValue(strings.HasSuffix(a, b))
+	case "iendswith":
+		return boolValue(strings.HasSuffix(strings.ToLower(a), strings.ToLower(b)))
+	case "iequals":
+		return boolValue(strings.EqualFold(a, b))
+	}
+	return value{}
+}
+
+func evalRange(c *scanCtx, lo, hi node) (int64, int64, bool) {
+	l, lok := lo.eval(c).number()
+	h, hok := hi.eval(c).number()
+	return l, h, lok && hok
+}
+
+type stringNode struct {
+	s      *String
+	at     node
+	lo, hi node
+}
+
+func (n *stringNode) eval(c *scanCtx) value {
+	occ := c.occurrences(n.s)
+	switch {
+	case n.at != nil:
+		at, ok := n.at.eval(c).number()
+		if !ok {
+			return value{}
+		}
+		for _, o := range occ {
+			if int64(o.offset) == at {
+				return boolValue(true)
+			}
+		}
+		return boolValue(false)
+	case n.lo != nil:
+		lo, hi, ok := evalRange(c, n.lo, n.hi)
+		if !ok {
+			return value{}
+		}
+		return boolValue(countIn(occ, lo, hi) > 0)
+	}
+	return boolValue(len(occ) > 0)
+}
+
+func countIn(occ []occurrence, lo, hi int64) int {
+	count := 0
+	for _, o := range occ {
+		if int64(o.offset) >= lo && int64(o.offset) <= hi {
+			count++
+		}
+	}
+	return count
+}
+
+type countNode struct {
+	s      *String
+	lo, hi node
+}
+
+func (n *countNode) eval(c *scanCtx) value {
+	occ := c.occurrences(n.s)
+	if n.lo != nil {
+		lo, hi, ok := evalRange(c, n.lo, n.hi)
+		if !ok {
+			return value{}
+		}
+		return intValue(int64(countIn(occ, lo, hi)))
+	}
+	return intValue(int64(len(occ)))
+}
+
+// occurrenceNode implements @a[i] and !a[i]; indexes are 1-based.
+type occurrenceNode struct {
+	s      *String
+	idx    node
+	length bool
+}
+
+func (n *occurrenceNode) eval(c *scanCtx) value {
+	occ := c.occurrences(n.s)
+	i := int64(1)
+	if n.idx != nil {
+		var ok bool
+		if i, ok = n.idx.eval(c).number(); !ok {
+			return value{}
+		}
+	}
+	if i < 1 || i > int64(len(occ)) {
+		return value{}
+	}
+	if n.length {
+		return intValue(int64(occ[i-1].length))
+	}
+	return intValue(int64(occ[i-1].offset))
+}
+
+type readNode struct {
+	size   int
+	signed bool
+	be     bool
+	off    node
+}
+
+func (n *readNode) eval(c *scanCtx) value {
+	off, ok := n.off.eval(c).number()
+	if !ok || off < 0 || off+int64(n.size) > int64(len(c.data)) {
+		return value{}
+	}
+	b := c.data[off : off+int64(n.size)]
+	var v uint32
+	switch {
+	case n.size == 1:
+		v = uint32(b[0])
+	case n.size == 2 && n.be:
+		v = uint32(binary.BigEndian.Uint16(b))
+	case n.size == 2:
+		v = uint32(binary.LittleEndian.Uint16(b))
+	case n.be:
+		v = binary.BigEndian.Uint32(b)
+	default:
+		v = binary.LittleEndian.Uint32(b)
+	}
+	if !n.signed {
+		return intValue(int64(v))
+	}
+	switch n.size {
+	case 1:
+		return intValue(int64(int8(v)))
+	case 2:
+		return intValue(int64(int16(v)))
+	}
+	return intValue(int64(int32(v)))
+}
+
+// satisfied reports whether hits out of total items satisfy q.
+func (q quantifier) satisfied(c *scanCtx, hits, total int) value {
+	switch q.kind {
+	case qAny:
+		return boolValue(hits > 0)
+	case qAll:
+		return boolValue(hits == total)
+	case qNone:
+		return boolValue(hits == 0)
+	}
+	n, ok := q.n.eval(c).number()
+	if !ok {
+		return value{}
+	}
+	if q.kind == qPercent {
+		return boolValue(int64(hits)*100 >= n*int64(total))
+	}
+	return boolValue(int64(hits) >= n)
+}
+
+type ofNode struct {
+	q      quantifier
+	set    []*String
+	lo, hi node
+}
+
+func (n *ofNode) eval(c *scanCtx) value {
+	var lo, hi int64
+	if n.lo != nil {
+		var ok bool
+		if lo, hi, ok = evalRange(c, n.lo, n.hi); !ok {
+			return value{}
+		}
+	}
+	hits := 0
+	for _, s := range n.set {
+		occ := c.occurrences(s)
+		if (n.lo == nil && len(occ) > 0) || (n.lo != nil && countIn(occ, lo, hi) > 0) {
+			hits++
+		}
+	}
+	return n.q.satisfied(c, hits, len(n.set))
+}
+
+type forOfNode struct {
+	q    quantifier
+	set  []*String
+	body node
+}
+
+func (n *forOfNode) eval(c *scanCtx) value {
+	hits := 0
+	for _, s := range n.set {
+		c.current = append(c.current, s)
+		if n.body.eval(c).truth() {
+			hits++
+		}
+		c.current = c.current[:len(c.current)-1]
+	}
+	return n.q.satisfied(c, hits, len(n.set))
+}
+
+// maxLoopIterations bounds for..in loops over integer ranges.
+const maxLoopIterations = 1 << 20
+
+type forInNode struct {
+	q      quantifier
+	name   string
+	lo, hi node
+	items  []node
+	body   node
+}
+
+func (n *forInNode) eval(c *scanCtx) value {
+	var values []int64
+	if n.lo != nil {
+		lo, hi, ok := evalRange(c, n.lo, n.hi)
+		if !ok || hi-lo >= maxLoopIterations {
+			return value{}
+		}
+		for i := lo; i <= hi; i++ {
+			values = append(values, i)
+		}
+	} else {
+		for _, item := range n.items {
+			v, ok := item.eval(c).number()
+			if !ok {
+				return value{}
+			}
+			values = append(values, v)
+		}
+	}
+
+	saved, shadowed := c.vars[n.name]
+	hits := 0
+	for _, v := range values {
+		c.vars[n.name] = v
+		if n.body.eval(c).truth() {
+			hits++
+		}
+	}
+	if shadowed {
+		c.vars[n.name] = saved
+	} else {
+		delete(c.vars, n.name)
+	}
+	return n.q.satisfied(c, hits, len(values))
+}
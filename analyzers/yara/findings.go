@@ -0,0 +1,100 @@
+package yara
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"iron-sentinel/findings"
+)
+
+var attackID = regexp.MustCompile(`(?i)\bT\d{4}(\.\d{3})?\b`)
+
+// Findings turns each rule match into a finding. Severity comes from a
+// "severity" meta value or a 0-100 "score"; ATT&CK technique IDs are taken
+// from tags and from "mitre_attack", "attack" or "technique" meta values.
+func Findings(res Result) []findings.Finding {
+	out := make([]findings.Finding, 0, len(res.Matches))
+	for _, m := range res.Matches {
+		f := findings.Finding{
+			Analyzer:    "yara",
+			Rule:        m.Rule,
+			Severity:    severity(m.Meta),
+			Confidence:  findings.ConfidenceMedium,
+			Title:       "YARA rule matched: " + m.Rule,
+			Description: metaString(m.Meta, "description"),
+			Attack:      attack(m),
+		}
+		if f.Description == "" {
+			f.Description = fmt.Sprintf("Rule %s matched %s.", m.Rule, m.Target)
+		}
+		path := m.Target
+		if m.Artifact != "" {
+			_, path, _ = strings.Cut(m.Target, "!")
+		}
+		if len(m.Strings) == 0 {
+			f.Evidence = []findings.Evidence{{Artifact: m.Artifact, Path: path}}
+		}
+		for _, s := range m.Strings {
+			f.Evidence = append(f.Evidence, findings.Evidence{
+				Artifact: m.Artifact,
+				Path:     path,
+				Offset:   findings.At(s.Offset),
+				Excerpt:  s.ID + ": " + s.Data,
+			})
+		}
+		if len(m.Tags) > 0 {
+			f.Metadata = map[string]string{"tags": strings.Join(m.Tags, ",")}
+		}
+		f.SetID()
+		out = append(out, f)
+	}
+	return out
+}
+
+func metaString(meta map[string]interface{}, key string) string {
+	if s, ok := meta[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+func severity(meta map[string]interface{}) findings.Severity {
+	if s := metaString(meta, "severity"); s != "" {
+		return findings.ParseSeverity(s, findings.SeverityMedium)
+	}
+	if score, ok := meta["score"].(int64); ok {
+		switch {
+		case score >= 90:
+			return findings.SeverityCritical
+		case score >= 70:
+			return findings.SeverityHigh
+		case score >= 40:
+			return findings.SeverityMedium
+		default:
+			return findings.SeverityLow
+		}
+	}
+	return findings.SeverityMedium
+}
+
+func attack(m Match) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(s string) {
+		for _, id := range attackID.FindAllString(s, -1) {
+			id = strings.ToUpper(id)
+			if !seen[id] {
+				seen[id] = true
+				out = append(out, id)
+			}
+		}
+	}
+	for _, t := range m.Tags {
+		add(t)
+	}
+	for _, k := range []string{"mitre_attack", "attack", "technique"} {
+		add(metaString(m.Meta, k))
+	}
+	return out
+}
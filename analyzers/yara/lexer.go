@@ -0,0 +1,306 @@
+package yara
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokKind int
+
+const (
+	tEOF tokKind = iota
+	tIdent
+	tString
+	tInt
+	tStrID
+	tCount
+	tOffset
+	tLength
+	tPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	ival int64
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tEOF:
+		return "end of file"
+	case tString:
+		return strconv.Quote(t.text)
+	case tInt:
+		return strconv.FormatInt(t.ival, 10)
+	}
+	return t.text
+}
+
+// lexer tokenizes rule source on demand. Hex and regex strings are context
+// dependent, so the parser reads them with rawHex and rawRegex instead.
+type lexer struct {
+	src  string
+	pos  int
+	line int
+	peek *token
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1}
+}
+
+func (l *lexer) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", l.line, fmt.Sprintf(format, args...))
+}
+
+func (l *lexer) skipSpace() error {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case strings.HasPrefix(l.src[l.pos:], "//"):
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "/*"):
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return l.errorf("unterminated comment")
+			}
+			l.line += strings.Count(l.src[l.pos:l.pos+2+end], "\n")
+			l.pos += end + 4
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+func (l *lexer) Peek() (token, error) {
+	if l.peek != nil {
+		return *l.peek, nil
+	}
+	t, err := l.lex()
+	if err != nil {
+		return t, err
+	}
+	l.peek = &t
+	return t, nil
+}
+
+func (l *lexer) Next() (token, error) {
+	if l.peek != nil {
+		t := *l.peek
+		l.peek = nil
+		return t, nil
+	}
+	return l.lex()
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+var puncts = []string{"..", "==", "!=", "<=", ">=", "<<", ">>", "(", ")", "{", "}", "[", "]", ",", ":", "=", "<", ">", "+", "-", "*", "\\", "%", "&", "|", "^", "~", "."}
+
+func (l *lexer) lex() (token, error) {
+	if err := l.skipSpace(); err != nil {
+		return token{}, err
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tEOF, line: l.line}, nil
+	}
+	start := l.pos
+	c := l.src[l.pos]
+
+	switch {
+	case c == '$' || c == '#' || c == '@' || (c == '!' && l.pos+1 < len(l.src) && l.src[l.pos+1] != '='):
+		l.pos++
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		if c == '$' && l.pos < len(l.src) && l.src[l.pos] == '*' {
+			l.pos++
+		}
+		kind := map[byte]tokKind{'$': tStrID, '#': tCount, '@': tOffset, '!': tLength}[c]
+		return token{kind: kind, text: l.src[start:l.pos], line: l.line}, nil
+	case isIdentStart(c):
+		for l.pos < len(l.src) && isIdentChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tIdent, text: l.src[start:l.pos], line: l.line}, nil
+	case c >= '0' && c <= '9':
+		return l.lexNumber()
+	case c == '"':
+		s, err := l.lexQuoted()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tString, text: s, line: l.line}, nil
+	}
+
+	for _, p := range puncts {
+		if strings.HasPrefix(l.src[l.pos:], p) {
+			l.pos += len(p)
+			return token{kind: tPunct, text: p, line: l.line}, nil
+		}
+	}
+	return token{}, l.errorf("unexpected character %q", c)
+}
+
+func (l *lexer) lexNumber() (token, error) {
+	start := l.pos
+	base := 10
+	if strings.HasPrefix(l.src[l.pos:], "0x") || strings.HasPrefix(l.src[l.pos:], "0X") {
+		base = 16
+		l.pos += 2
+		start = l.pos
+		for l.pos < len(l.src) && strings.IndexByte("0123456789abcdefABCDEF", l.src[l.pos]) >= 0 {
+			l.pos++
+		}
+	} else if strings.HasPrefix(l.src[l.pos:], "0o") {
+		base = 8
+		l.pos += 2
+		start = l.pos
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '7' {
+			l.pos++
+		}
+	} else {
+		for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+			l.pos++
+		}
+	}
+	v, err := strconv.ParseInt(l.src[start:l.pos], base, 64)
+	if err != nil {
+		return token{}, l.errorf("invalid number %q", l.src[start:l.pos])
+	}
+	if strings.HasPrefix(l.src[l.pos:], "KB") {
+		v *= 1024
+		l.pos += 2
+	} else if strings.HasPrefix(l.src[l.pos:], "MB") {
+		v *= 1024 * 1024
+		l.pos += 2
+	}
+	return token{kind: tInt, ival: v, text: l.src[start:l.pos], line: l.line}, nil
+}
+
+func (l *lexer) lexQuoted() (string, error) {
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return b.String(), nil
+		case '\n':
+			return "", l.errorf("unterminated string")
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return "", l.errorf("unterminated string")
+			}
+			e := l.src[l.pos+1]
+			l.pos += 2
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '\\', '"':
+				b.WriteByte(e)
+			case 'x':
+				if l.pos+2 > len(l.src) {
+					return "", l.errorf("invalid \\x escape")
+				}
+				v, err := strconv.ParseUint(l.src[l.pos:l.pos+2], 16, 8)
+				if err != nil {
+					return "", l.errorf("invalid \\x escape")
+				}
+				b.WriteByte(byte(v))
+				l.pos += 2
+			default:
+				return "", l.errorf("unknown escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf("unterminated string")
+}
+
+// rawHex returns the body of a hex string; the opening brace is next.
+func (l *lexer) rawHex() (string, error) {
+	if err := l.skipSpace(); err != nil {
+		return "", err
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '{' {
+		return "", l.errorf("expected hex string")
+	}
+	end := strings.IndexByte(l.src[l.pos:], '}')
+	if end < 0 {
+		return "", l.errorf("unterminated hex string")
+	}
+	body := l.src[l.pos+1 : l.pos+end]
+	l.line += strings.Count(body, "\n")
+	l.pos += end + 1
+	return body, nil
+}
+
+// rawRegex returns the pattern and trailing flags of /pattern/flags.
+func (l *lexer) rawRegex() (string, string, error) {
+	if err := l.skipSpace(); err != nil {
+		return "", "", err
+	}
+	if l.pos >= len(l.src) || l.src[l.pos] != '/' {
+		return "", "", l.errorf("expected regular expression")
+	}
+	i := l.pos + 1
+	for i < len(l.src) && l.src[i] != '/' {
+		if l.src[i] == '\\' {
+			i++
+		}
+		if i < len(l.src) && l.src[i] == '\n' {
+			return "", "", l.errorf("unterminated regular expression")
+		}
+		i++
+	}
+	if i >= len(l.src) {
+		return "", "", l.errorf("unterminated regular expression")
+	}
+	pat := l.src[l.pos+1 : i]
+	i++
+	fs := i
+	for i < len(l.src) && (l.src[i] == 'i' || l.src[i] == 's') {
+		i++
+	}
+	l.pos = i
+	return pat, l.src[fs:i], nil
+}
+
+// peekByte returns the next non-space source byte without consuming it.
+func (l *lexer) peekByte() (byte, error) {
+	if l.peek != nil {
+		return 0, l.errorf("internal: token already buffered")
+	}
+	if err := l.skipSpace(); err != nil {
+		return 0, err
+	}
+	if l.pos >= len(l.src) {
+		return 0, nil
+	}
+	return l.src[l.pos], nil
+}
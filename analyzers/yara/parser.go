@@ -0,0 +1,509 @@
+package yara
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Rule is a compiled YARA rule.
+type Rule struct {
+	Name    string
+	Tags    []string
+	Meta    map[string]interface{}
+	Private bool
+	Global  bool
+
+	strings   []*String
+	condition node
+}
+
+// Ruleset is an ordered collection of rules loaded from one or more files.
+type Ruleset struct {
+	Rules []*Rule
+	names map[string]int
+}
+
+type parser struct {
+	lx      *lexer
+	rs      *Ruleset
+	rule    *Rule
+	modules map[string]bool
+	vars    []string
+}
+
+// Parse compiles YARA source into rules appended to rs.
+func (rs *Ruleset) Parse(src string) error {
+	if rs.names == nil {
+		rs.names = map[string]int{}
+	}
+	p := &parser{lx: newLexer(src), rs: rs, modules: map[string]bool{}}
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return err
+		}
+		if t.kind == tEOF {
+			return nil
+		}
+		if err := p.parseTop(); err != nil {
+			return err
+		}
+	}
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) expect(text string) (token, error) {
+	t, err := p.lx.Next()
+	if err != nil {
+		return t, err
+	}
+	if (t.kind != tPunct && t.kind != tIdent) || t.text != text {
+		return t, p.errorf(t, "expected %q, found %s", text, t)
+	}
+	return t, nil
+}
+
+func (p *parser) accept(text string) (bool, error) {
+	t, err := p.lx.Peek()
+	if err != nil {
+		return false, err
+	}
+	if (t.kind == tPunct || t.kind == tIdent) && t.text == text {
+		p.lx.Next()
+		return true, nil
+	}
+	return false, nil
+}
+
+func (p *parser) parseTop() error {
+	t, err := p.lx.Next()
+	if err != nil {
+		return err
+	}
+	if t.kind == tIdent && (t.text == "import" || t.text == "include") {
+		s, err := p.lx.Next()
+		if err != nil {
+			return err
+		}
+		if s.kind != tString {
+			return p.errorf(s, "expected string after %s", t.text)
+		}
+		if t.text == "include" {
+			return p.errorf(t, "include is not supported")
+		}
+		p.modules[s.text] = true
+		return nil
+	}
+
+	r := &Rule{Meta: map[string]interface{}{}}
+	for t.kind == tIdent && (t.text == "private" || t.text == "global") {
+		if t.text == "private" {
+			r.Private = true
+		} else {
+			r.Global = true
+		}
+		if t, err = p.lx.Next(); err != nil {
+			return err
+		}
+	}
+	if t.kind != tIdent || t.text != "rule" {
+		return p.errorf(t, "expected rule, found %s", t)
+	}
+	name, err := p.lx.Next()
+	if err != nil {
+		return err
+	}
+	if name.kind != tIdent {
+		return p.errorf(name, "expected rule name, found %s", name)
+	}
+	if _, dup := p.rs.names[name.text]; dup {
+		return p.errorf(name, "duplicate rule %q", name.text)
+	}
+	r.Name = name.text
+
+	if ok, err := p.accept(":"); err != nil {
+		return err
+	} else if ok {
+		for {
+			t, err := p.lx.Peek()
+			if err != nil {
+				return err
+			}
+			if t.kind != tIdent {
+				break
+			}
+			p.lx.Next()
+			r.Tags = append(r.Tags, t.text)
+		}
+	}
+	if _, err := p.expect("{"); err != nil {
+		return err
+	}
+	p.rule = r
+	defer func() { p.rule = nil }()
+
+	for {
+		t, err := p.lx.Next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tPunct && t.text == "}" {
+			break
+		}
+		if t.kind != tIdent {
+			return p.errorf(t, "unexpected %s in rule %s", t, r.Name)
+		}
+		if _, err := p.expect(":"); err != nil {
+			return err
+		}
+		switch t.text {
+		case "meta":
+			err = p.parseMeta(r)
+		case "strings":
+			err = p.parseStrings(r)
+		case "condition":
+			r.condition, err = p.parseExpr()
+		default:
+			err = p.errorf(t, "unknown section %q", t.text)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if r.condition == nil {
+		return fmt.Errorf("rule %s: missing condition", r.Name)
+	}
+	p.rs.names[r.Name] = len(p.rs.Rules)
+	p.rs.Rules = append(p.rs.Rules, r)
+	return nil
+}
+
+func (p *parser) parseMeta(r *Rule) error {
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return err
+		}
+		if t.kind != tIdent || t.text == "strings" || t.text == "condition" {
+			return nil
+		}
+		p.lx.Next()
+		if _, err := p.expect("="); err != nil {
+			return err
+		}
+		v, err := p.lx.Next()
+		if err != nil {
+			return err
+		}
+		neg := false
+		if v.kind == tPunct && v.text == "-" {
+			neg = true
+			if v, err = p.lx.Next(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case v.kind == tString:
+			r.Meta[t.text] = v.text
+		case v.kind == tInt:
+			if neg {
+				v.ival = -v.ival
+			}
+			r.Meta[t.text] = v.ival
+		case v.kind == tIdent && (v.text == "true" || v.text == "false"):
+			r.Meta[t.text] = v.text == "true"
+		default:
+			return p.errorf(v, "invalid meta value %s", v)
+		}
+	}
+}
+
+func (p *parser) parseStrings(r *Rule) error {
+	anon := 0
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return err
+		}
+		if t.kind != tStrID {
+			return nil
+		}
+		p.lx.Next()
+		id := t.text
+		if id == "$" {
+			anon++
+			id = fmt.Sprintf("$_anon%d", anon)
+		}
+		for _, s := range r.strings {
+			if s.ID == id {
+				return p.errorf(t, "duplicate string %s", id)
+			}
+		}
+		if _, err := p.expect("="); err != nil {
+			return err
+		}
+		c, err := p.lx.peekByte()
+		if err != nil {
+			return err
+		}
+		s := &String{ID: id}
+		switch c {
+		case '"':
+			v, err := p.lx.Next()
+			if err != nil {
+				return err
+			}
+			s.kind = textString
+			s.text = []byte(v.text)
+		case '{':
+			body, err := p.lx.rawHex()
+			if err != nil {
+				return err
+			}
+			s.kind = hexString
+			if s.hex, err = parseHex(body); err != nil {
+				return p.errorf(t, "%s: %v", id, err)
+			}
+		case '/':
+			pat, flags, err := p.lx.rawRegex()
+			if err != nil {
+				return err
+			}
+			s.kind = regexString
+			s.pattern = pat
+			s.nocase = strings.Contains(flags, "i")
+			s.dotall = strings.Contains(flags, "s")
+		default:
+			return p.errorf(t, "%s: expected string, hex string or regular expression", id)
+		}
+		if err := p.parseModifiers(s); err != nil {
+			return err
+		}
+		if err := s.compile(); err != nil {
+			return p.errorf(t, "%s: %v", id, err)
+		}
+		r.strings = append(r.strings, s)
+	}
+}
+
+func (p *parser) parseModifiers(s *String) error {
+	for {
+		t, err := p.lx.Peek()
+		if err != nil {
+			return err
+		}
+		if t.kind != tIdent {
+			return nil
+		}
+		switch t.text {
+		case "nocase":
+			s.nocase = true
+		case "wide":
+			s.wide = true
+		case "ascii":
+			s.ascii = true
+		case "fullword":
+			s.fullword = true
+		case "private":
+			s.private = true
+		case "xor":
+			p.lx.Next()
+			s.xor, s.xorMin, s.xorMax = true, 0, 255
+			if ok, err := p.accept("("); err != nil {
+				return err
+			} else if ok {
+				lo, err := p.lx.Next()
+				if err != nil {
+					return err
+				}
+				if lo.kind != tInt {
+					return p.errorf(lo, "invalid xor range")
+				}
+				s.xorMin, s.xorMax = int(lo.ival), int(lo.ival)
+				if ok, _ := p.accept("-"); ok {
+					hi, err := p.lx.Next()
+					if err != nil {
+						return err
+					}
+					if hi.kind != tInt {
+						return p.errorf(hi, "invalid xor range")
+					}
+					s.xorMax = int(hi.ival)
+				}
+				if _, err := p.expect(")"); err != nil {
+					return err
+				}
+				if s.xorMin < 0 || s.xorMax > 255 || s.xorMin > s.xorMax {
+					return p.errorf(t, "invalid xor range")
+				}
+			}
+			continue
+		default:
+			if t.text == "base64" || t.text == "base64wide" {
+				return p.errorf(t, "modifier %s is not supported", t.text)
+			}
+			return nil
+		}
+		p.lx.Next()
+	}
+}
+
+func parseHex(body string) ([]hexTok, error) {
+	toks, rest, err := parseHexSeq(strings.TrimSpace(body), false)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("unexpected %q in hex string", rest)
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("empty hex string")
+	}
+	if toks[0].kind == hexJump || toks[len(toks)-1].kind == hexJump {
+		return nil, fmt.Errorf("hex string cannot start or end with a jump")
+	}
+	return toks, nil
+}
+
+func parseHexSeq(s string, inAlt bool) ([]hexTok, string, error) {
+	var toks []hexTok
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return toks, "", nil
+		}
+		switch s[0] {
+		case '|', ')':
+			if !inAlt {
+				return nil, s, fmt.Errorf("unexpected %q in hex string", s[0])
+			}
+			return toks, s, nil
+		case '(':
+			var alt hexTok
+			alt.kind = hexAlt
+			s = s[1:]
+			for {
+				seq, rest, err := parseHexSeq(s, true)
+				if err != nil {
+					return nil, "", err
+				}
+				alt.alts = append(alt.alts, seq)
+				if rest == "" {
+					return nil, "", fmt.Errorf("unterminated alternation")
+				}
+				s = rest[1:]
+				if rest[0] == ')' {
+					break
+				}
+			}
+			toks = append(toks, alt)
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated jump")
+			}
+			j, err := parseJump(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, "", err
+			}
+			toks = append(toks, j)
+			s = s[end+1:]
+		default:
+			neg := false
+			if s[0] == '~' {
+				neg = true
+				s = s[1:]
+			}
+			if len(s) < 2 {
+				return nil, "", fmt.Errorf("truncated hex byte")
+			}
+			t := hexTok{kind: hexByte, not: neg}
+			for i := 0; i < 2; i++ {
+				t.mask <<= 4
+				t.b <<= 4
+				c := s[i]
+				if c == '?' {
+					continue
+				}
+				v, err := strconv.ParseUint(string(c), 16, 8)
+				if err != nil {
+					return nil, "", fmt.Errorf("invalid hex byte %q", s[:2])
+				}
+				t.mask |= 0xf
+				t.b |= byte(v)
+			}
+			toks = append(toks, t)
+			s = s[2:]
+		}
+	}
+}
+
+func parseJump(s string) (hexTok, error) {
+	t := hexTok{kind: hexJump, max: -1}
+	lo, hi, isRange := strings.Cut(s, "-")
+	lo, hi = strings.TrimSpace(lo), strings.TrimSpace(hi)
+	if lo != "" {
+		v, err := strconv.Atoi(lo)
+		if err != nil || v < 0 {
+			return t, fmt.Errorf("invalid jump [%s]", s)
+		}
+		t.min = v
+	}
+	if !isRange {
+		if lo == "" {
+			return t, fmt.Errorf("invalid jump [%s]", s)
+		}
+		t.max = t.min
+		return t, nil
+	}
+	if hi != "" {
+		v, err := strconv.Atoi(hi)
+		if err != nil || v < t.min {
+			return t, fmt.Errorf("invalid jump [%s]", s)
+		}
+		t.max = v
+	}
+	return t, nil
+}
+
+var highByteEscape = regexp.MustCompile(`\\x[89a-fA-F][0-9a-fA-F]`)
+
+// regexpFor translates a YARA regular expression into Go syntax. The two
+// dialects agree on everything rules commonly use, but Go matches \x escapes
+// above 0x7f as UTF-8 code points, so those are rejected rather than
+// silently matching the wrong bytes.
+func regexpFor(pat string, nocase, dotall bool) (*regexp.Regexp, error) {
+	if highByteEscape.MatchString(pat) {
+		return nil, fmt.Errorf("regular expression byte escapes above \\x7f are not supported; use a hex string")
+	}
+	var b strings.Builder
+	if nocase || dotall {
+		b.WriteString("(?")
+		if nocase {
+			b.WriteString("i")
+		}
+		if dotall {
+			b.WriteString("s")
+		}
+		b.WriteString(")")
+	}
+	for i := 0; i < len(pat); i++ {
+		if pat[i] == '\\' && i+1 < len(pat) && pat[i+1] == '/' {
+			b.WriteByte('/')
+			i++
+			continue
+		}
+		if pat[i] == '\\' && i+1 < len(pat) {
+			b.WriteString(pat[i : i+2])
+			i++
+			continue
+		}
+		b.WriteByte(pat[i])
+	}
+	return regexp.Compile(b.String())
+}
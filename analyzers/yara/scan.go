@@ -0,0 +1,363 @@
+package yara
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"iron-sentinel/analyzers/archive"
+	"iron-sentinel/collectors"
+)
+
+const (
+	// DefaultMaxFileSize is the largest target read into memory for a scan.
+	DefaultMaxFileSize = 64 << 20
+	// maxReportedStrings caps the string matches listed per rule and string.
+	maxReportedStrings = 16
+	maxMatchData       = 64
+)
+
+// livePathExcludes are pseudo filesystems never walked for --yara-path.
+var livePathExcludes = []string{"/proc", "/sys", "/dev", "/run"}
+
+type Options struct {
+	RuleFiles []string
+	// Paths are live files or directories scanned in addition to artifacts.
+	Paths   []string
+	Workers int
+	// MaxFileSize skips larger targets (default DefaultMaxFileSize).
+	MaxFileSize int64
+}
+
+type StringMatch struct {
+	ID     string `json:"id"`
+	Offset int64  `json:"offset"`
+	Length int    `json:"length"`
+	Data   string `json:"data"`
+}
+
+type Match struct {
+	Rule        string                 `json:"rule"`
+	Tags        []string               `json:"tags,omitempty"`
+	Meta        map[string]interface{} `json:"meta,omitempty"`
+	Target      string                 `json:"target"`
+	Artifact    string                 `json:"artifact,omitempty"`
+	Strings     []StringMatch          `json:"strings,omitempty"`
+	CollectedAt string                 `json:"collected_at,omitempty"`
+}
+
+type Result struct {
+	RuleFiles []string `json:"rule_files"`
+	Rules     int      `json:"rules"`
+	Matches   []Match  `json:"matches"`
+	Scanned   int      `json:"scanned"`
+	Skipped   []string `json:"skipped,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+	Finished  string   `json:"finished"`
+}
+
+// LoadFiles compiles rules from files, or from the .yar and .yara files in
+// directories. Rule names must be unique across all inputs.
+func LoadFiles(paths []string) (*Ruleset, error) {
+	rs := &Ruleset{}
+	for _, p := range paths {
+		files := []string{p}
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			files = nil
+			entries, err := os.ReadDir(p)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				ext := strings.ToLower(filepath.Ext(e.Name()))
+				if !e.IsDir() && (ext == ".yar" || ext == ".yara") {
+					files = append(files, filepath.Join(p, e.Name()))
+				}
+			}
+		}
+		for _, f := range files {
+			b, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if err := rs.Parse(string(b)); err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+		}
+	}
+	if len(rs.Rules) == 0 {
+		return nil, errors.New("YARA rules contained no rules")
+	}
+	return rs, nil
+}
+
+// Scan evaluates every rule against data. Target and artifact fields of the
+// returned matches are left empty.
+func (rs *Ruleset) Scan(data []byte) []Match {
+	out, _ := rs.scan(data)
+	return out
+}
+
+// scan is Scan that also names the hex strings whose search ran out of
+// budget, so that callers can report the target as partly scanned.
+func (rs *Ruleset) scan(data []byte) ([]Match, []string) {
+	c := newScanCtx(data, len(rs.Rules))
+	global := true
+	for i, r := range rs.Rules {
+		c.results[i] = r.condition.eval(c).truth()
+		if r.Global && !c.results[i] {
+			global = false
+			break
+		}
+	}
+	var partial []string
+	for _, r := range rs.Rules {
+		for _, s := range r.strings {
+			if c.exhausted[s] {
+				partial = append(partial, fmt.Sprintf("rule %s string %s: hex matching budget exhausted", r.Name, s.ID))
+			}
+		}
+	}
+	if !global {
+		return nil, partial
+	}
+
+	var out []Match
+	for i, r := range rs.Rules {
+		if !c.results[i] || r.Private {
+			continue
+		}
+		m := Match{Rule: r.Name, Tags: r.Tags}
+		if len(r.Meta) > 0 {
+			m.Meta = r.Meta
+		}
+		for _, s := range r.strings {
+			if s.private {
+				continue
+			}
+			occ := c.occurrences(s)
+			if len(occ) > maxReportedStrings {
+				occ = occ[:maxReportedStrings]
+			}
+			for _, o := range occ {
+				m.Strings = append(m.Strings, StringMatch{
+					ID:     s.ID,
+					Offset: int64(o.offset),
+					Length: o.length,
+					Data:   printable(data[o.offset : o.offset+o.length]),
+				})
+			}
+		}
+		out = append(out, m)
+	}
+	return out, partial
+}
+
+// printable renders matched bytes as text when they are printable ASCII and
+// as hex otherwise.
+func printable(b []byte) string {
+	if len(b) > maxMatchData {
+		b = b[:maxMatchData]
+	}
+	for _, c := range b {
+		if c > unicode.MaxASCII || (!unicode.IsPrint(rune(c)) && c != '\t') {
+			return "hex:" + hex.EncodeToString(b)
+		}
+	}
+	return string(b)
+}
+
+type target struct {
+	artifact collectors.Artifact
+	live     string
+}
+
+type targetResult struct {
+	matches []Match
+	scanned int
+	skipped []string
+	errors  []string
+}
+
+// ScanArtifacts runs the rules over collected artifacts, expanding archives
+// such as the snapshot file tarball, and over any live paths in opts.
+func ScanArtifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	rs, err := LoadFiles(opts.RuleFiles)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.MaxFileSize <= 0 {
+		opts.MaxFileSize = DefaultMaxFileSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	var targets []target
+	for _, a := range artifacts {
+		targets = append(targets, target{artifact: a})
+	}
+	var walkErrs []string
+	for _, p := range opts.Paths {
+		files, err := liveFiles(ctx, p)
+		if err != nil {
+			walkErrs = append(walkErrs, err.Error())
+		}
+		for _, f := range files {
+			targets = append(targets, target{live: f})
+		}
+	}
+
+	results := make([]targetResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if targets[i].live != "" {
+					results[i] = rs.scanLive(targets[i].live, opts.MaxFileSize)
+				} else {
+					results[i] = rs.scanArtifact(ctx, outDir, targets[i].artifact, opts.MaxFileSize)
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range targets {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	res := Result{RuleFiles: opts.RuleFiles, Rules: len(rs.Rules), Matches: []Match{}, Errors: walkErrs}
+	for _, r := range results {
+		res.Scanned += r.scanned
+		res.Matches = append(res.Matches, r.matches...)
+		res.Skipped = append(res.Skipped, r.skipped...)
+		res.Errors = append(res.Errors, r.errors...)
+	}
+	res.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+	return res, nil
+}
+
+func (rs *Ruleset) scanArtifact(ctx context.Context, outDir string, a collectors.Artifact, maxSize int64) targetResult {
+	var r targetResult
+	path := filepath.Join(outDir, filepath.FromSlash(a.RelativePath))
+	w := archive.NewWalker()
+	err := w.WalkFile(ctx, a.RelativePath, path, func(name string, mr io.Reader) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := io.ReadAll(io.LimitReader(mr, maxSize+1))
+		if err != nil {
+			r.errors = append(r.errors, fmt.Sprintf("%s: %v", name, err))
+			return nil
+		}
+		if int64(len(data)) > maxSize {
+			r.skipped = append(r.skipped, name+": exceeds size limit")
+			return nil
+		}
+		r.scanned++
+		matches, partial := rs.scan(data)
+		for _, p := range partial {
+			r.skipped = append(r.skipped, name+": "+p)
+		}
+		for _, m := range matches {
+			m.Target = name
+			m.Artifact = a.RelativePath
+			m.CollectedAt = time.Now().UTC().Format(time.RFC3339Nano)
+			r.matches = append(r.matches, m)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		r.errors = append(r.errors, fmt.Sprintf("%s: %v", a.RelativePath, err))
+	}
+	r.skipped = append(r.skipped, w.Skipped...)
+	return r
+}
+
+func (rs *Ruleset) scanLive(path string, maxSize int64) targetResult {
+	var r targetResult
+	f, err := os.Open(path)
+	if err != nil {
+		r.errors = append(r.errors, err.Error())
+		return r
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		r.errors = append(r.errors, fmt.Sprintf("%s: %v", path, err))
+		return r
+	}
+	if int64(len(data)) > maxSize {
+		r.skipped = append(r.skipped, path+": exceeds size limit")
+		return r
+	}
+	r.scanned = 1
+	matches, partial := rs.scan(data)
+	for _, p := range partial {
+		r.skipped = append(r.skipped, path+": "+p)
+	}
+	for _, m := range matches {
+		m.Target = path
+		m.CollectedAt = time.Now().UTC().Format(time.RFC3339Nano)
+		r.matches = append(r.matches, m)
+	}
+	return r
+}
+
+// liveFiles lists regular files under root, skipping pseudo filesystems.
+func liveFiles(ctx context.Context, root string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			for _, ex := range livePathExcludes {
+				if p == ex && p != root {
+					return filepath.SkipDir
+				}
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
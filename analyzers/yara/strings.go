@@ -0,0 +1,282 @@
+package yara
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"sort"
+)
+
+type stringKind int
+
+const (
+	textString stringKind = iota
+	hexString
+	regexString
+)
+
+type hexKind int
+
+const (
+	hexByte hexKind = iota
+	hexJump
+	hexAlt
+)
+
+type hexTok struct {
+	kind hexKind
+	b    byte
+	mask byte
+	not  bool
+	min  int
+	max  int
+	alts [][]hexTok
+}
+
+// maxOccurrences bounds the matches recorded per string and target.
+const maxOccurrences = 10000
+
+// Hex strings with jumps and alternatives backtrack. maxHexSteps bounds the
+// work spent on one hex string per target and maxTargetHexSteps the work on
+// all of them; a string that runs out reports the occurrences found so far.
+const (
+	maxHexSteps       = 1 << 24
+	maxTargetHexSteps = 1 << 26
+)
+
+// String is a rule string definition.
+type String struct {
+	ID string
+
+	kind     stringKind
+	text     []byte
+	hex      []hexTok
+	pattern  string
+	nocase   bool
+	dotall   bool
+	wide     bool
+	ascii    bool
+	fullword bool
+	private  bool
+	xor      bool
+	xorMin   int
+	xorMax   int
+
+	needles [][]byte
+	re      *regexp.Regexp
+}
+
+type occurrence struct {
+	offset int
+	length int
+}
+
+func (s *String) compile() error {
+	switch s.kind {
+	case textString:
+		var forms [][]byte
+		if s.ascii || !s.wide {
+			forms = append(forms, s.text)
+		}
+		if s.wide {
+			forms = append(forms, widen(s.text))
+		}
+		for _, f := range forms {
+			if !s.xor {
+				s.needles = append(s.needles, f)
+				continue
+			}
+			for k := s.xorMin; k <= s.xorMax; k++ {
+				n := make([]byte, len(f))
+				for i := range f {
+					n[i] = f[i] ^ byte(k)
+				}
+				s.needles = append(s.needles, n)
+			}
+		}
+		if s.nocase {
+			for i, n := range s.needles {
+				s.needles[i] = asciiLower(n)
+			}
+		}
+	case hexString:
+		if s.nocase || s.wide || s.ascii || s.fullword || s.xor {
+			return errHexModifier
+		}
+	case regexString:
+		if s.wide || s.xor {
+			return errRegexModifier
+		}
+		re, err := regexpFor(s.pattern, s.nocase, s.dotall)
+		if err != nil {
+			return err
+		}
+		s.re = re
+	}
+	return nil
+}
+
+var (
+	errHexModifier   = errors.New("hex strings only accept the private modifier")
+	errRegexModifier = errors.New("wide and xor are not supported on regular expressions")
+)
+
+func widen(b []byte) []byte {
+	out := make([]byte, 0, len(b)*2)
+	for _, c := range b {
+		out = append(out, c, 0)
+	}
+	return out
+}
+
+// find returns every occurrence of s in data. lower is data folded to lower
+// case, computed once per target and only when a nocase string needs it.
+// Hex strings draw on budget, the steps left for the target, and find
+// reports whether the search stopped early because it ran out.
+func (s *String) find(data []byte, lower func() []byte, budget *int) ([]occurrence, bool) {
+	var out []occurrence
+	exhausted := false
+	switch s.kind {
+	case textString:
+		hay := data
+		if s.nocase {
+			hay = lower()
+		}
+		for _, n := range s.needles {
+			if len(n) == 0 {
+				continue
+			}
+			for i := 0; i <= len(hay)-len(n) && len(out) < maxOccurrences; {
+				j := bytes.Index(hay[i:], n)
+				if j < 0 {
+					break
+				}
+				off := i + j
+				if !s.fullword || isFullword(data, off, len(n), s.wide && len(n) >= 2 && n[1] == 0) {
+					out = append(out, occurrence{offset: off, length: len(n)})
+				}
+				i = off + 1
+			}
+		}
+		if len(s.needles) > 1 {
+			sortOccurrences(out)
+		}
+	case hexString:
+		m := hexMatcher{data: data, steps: min(maxHexSteps, *budget)}
+		start := m.steps
+		first := s.hex[0]
+		for i := 0; i < len(data) && len(out) < maxOccurrences; i++ {
+			if first.kind == hexByte && first.mask == 0xff && !first.not {
+				j := bytes.IndexByte(data[i:], first.b)
+				if j < 0 {
+					break
+				}
+				i += j
+			}
+			end, ok := m.match(s.hex, i)
+			if m.steps <= 0 {
+				exhausted = true
+				break
+			}
+			if ok {
+				out = append(out, occurrence{offset: i, length: end - i})
+			}
+		}
+		*budget -= start - max(m.steps, 0)
+	case regexString:
+		for _, loc := range s.re.FindAllIndex(data, maxOccurrences) {
+			if !s.fullword || isFullword(data, loc[0], loc[1]-loc[0], false) {
+				out = append(out, occurrence{offset: loc[0], length: loc[1] - loc[0]})
+			}
+		}
+	}
+	return out, exhausted
+}
+
+// hexMatcher matches hex strings by backtracking, counting down steps for
+// every token compared and every jump length tried.
+type hexMatcher struct {
+	data  []byte
+	steps int
+}
+
+// match reports the end of the match of toks at pos. It fails once steps
+// run out; the caller tells that apart by checking steps.
+func (m *hexMatcher) match(toks []hexTok, pos int) (int, bool) {
+	for len(toks) > 0 {
+		if m.steps--; m.steps <= 0 {
+			return 0, false
+		}
+		t := toks[0]
+		switch t.kind {
+		case hexByte:
+			if pos >= len(m.data) || (m.data[pos]&t.mask == t.b) == t.not {
+				return 0, false
+			}
+			pos++
+			toks = toks[1:]
+		case hexJump:
+			hi := t.max
+			if hi < 0 || hi > len(m.data)-pos {
+				hi = len(m.data) - pos
+			}
+			for n := t.min; n <= hi; n++ {
+				if end, ok := m.match(toks[1:], pos+n); ok {
+					return end, true
+				}
+				if m.steps <= 0 {
+					return 0, false
+				}
+			}
+			return 0, false
+		case hexAlt:
+			for _, alt := range t.alts {
+				seq := make([]hexTok, 0, len(alt)+len(toks)-1)
+				seq = append(append(seq, alt...), toks[1:]...)
+				if end, ok := m.match(seq, pos); ok {
+					return end, true
+				}
+				if m.steps <= 0 {
+					return 0, false
+				}
+			}
+			return 0, false
+		}
+	}
+	return pos, true
+}
+
+// asciiLower folds ASCII letters only, keeping offsets aligned with the
+// original bytes; bytes.ToLower would rewrite invalid UTF-8.
+func asciiLower(b []byte) []byte {
+	out := make([]byte, len(b))
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isFullword(data []byte, off, n int, wide bool) bool {
+	step := 1
+	if wide {
+		step = 2
+	}
+	if off >= step && isWordByte(data[off-step]) {
+		return false
+	}
+	if end := off + n; end < len(data) && isWordByte(data[end]) {
+		return false
+	}
+	return true
+}
+
+func sortOccurrences(o []occurrence) {
+	sort.Slice(o, func(i, j int) bool { return o[i].offset < o[j].offset })
+}
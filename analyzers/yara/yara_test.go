@@ -0,0 +1,161 @@
+package yara
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func compile(t *testing.T, src string) *Ruleset {
+	t.Helper()
+	rs := &Ruleset{}
+	if err := rs.Parse(src); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	return rs
+}
+
+func matched(ms []Match) []string {
+	var names []string
+	for _, m := range ms {
+		names = append(names, m.Rule)
+	}
+	return names
+}
+
+func TestScan(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+		data string
+		want bool
+	}{
+		{"text", `rule r { strings: $a = "evil" condition: $a }`, "an evil thing", true},
+		{"text absent", `rule r { strings: $a = "evil" condition: $a }`, "a good thing", false},
+		{"nocase", `rule r { strings: $a = "EVIL" nocase condition: $a }`, "an eViL thing", true},
+		{"wide", `rule r { strings: $a = "ab" wide condition: $a }`, "x\x61\x00\x62\x00y", true},
+		{"wide only", `rule r { strings: $a = "ab" wide condition: $a }`, "xaby", false},
+		{"fullword", `rule r { strings: $a = "cat" fullword condition: $a }`, "concatenate", false},
+		{"fullword hit", `rule r { strings: $a = "cat" fullword condition: $a }`, "the cat sat", true},
+		{"xor", `rule r { strings: $a = "abc" xor condition: $a }`, string([]byte{'a' ^ 7, 'b' ^ 7, 'c' ^ 7}), true},
+		{"hex", `rule r { strings: $a = { 4D 5A ?? 00 } condition: $a }`, "MZ\x90\x00", true},
+		{"hex nibble", `rule r { strings: $a = { 4? 5A } condition: $a }`, "MZ", true},
+		{"hex jump", `rule r { strings: $a = { 41 [2-4] 42 } condition: $a }`, "AxxxB", true},
+		{"hex jump too long", `rule r { strings: $a = { 41 [2-4] 42 } condition: $a }`, "AxxxxxB", false},
+		{"hex jump too short", `rule r { strings: $a = { 41 [2-4] 42 } condition: $a }`, "AxB", false},
+		{"hex alternative", `rule r { strings: $a = { 41 ( 42 | 43 44 ) 45 } condition: $a }`, "ACDE", true},
+		{"regex", `rule r { strings: $a = /ev[il]{2}/ condition: $a }`, "an evil thing", true},
+		{"regex nocase", `rule r { strings: $a = /EVIL/i condition: $a }`, "an evil thing", true},
+		{"count", `rule r { strings: $a = "x" condition: #a == 3 }`, "xaxbx", true},
+		{"at", `rule r { strings: $a = "b" condition: $a at 2 }`, "aab", true},
+		{"at miss", `rule r { strings: $a = "b" condition: $a at 1 }`, "aab", false},
+		{"in", `rule r { strings: $a = "b" condition: $a in (0..2) }`, "aab", true},
+		{"offset", `rule r { strings: $a = "b" condition: @a[2] == 3 }`, "abab", true},
+		{"filesize", `rule r { condition: filesize < 4 }`, "abc", true},
+		{"uint16", `rule r { condition: uint16(0) == 0x5A4D }`, "MZ", true},
+		{"uint32be", `rule r { condition: uint32be(0) == 0x7F454C46 }`, "\x7fELF", true},
+		{"any of them", `rule r { strings: $a = "x" $b = "y" condition: any of them }`, "y", true},
+		{"all of them", `rule r { strings: $a = "x" $b = "y" condition: all of them }`, "y", false},
+		{"2 of set", `rule r { strings: $a1 = "x" $a2 = "y" $b = "z" condition: 2 of ($a*) }`, "xyq", true},
+		{"for of", `rule r { strings: $a = "x" $b = "y" condition: for any of them : ( # > 1 ) }`, "xyy", true},
+		{"for in", `rule r { strings: $a = "x" condition: for all i in (1..#a) : ( @a[i] % 2 == 0 ) }`, "x.x.x", true},
+		{"not", `rule r { strings: $a = "x" condition: not $a }`, "abc", true},
+		{"contains", `rule r { meta: s = "x" condition: "hello" contains "ell" }`, "", true},
+		{"arithmetic", `rule r { condition: (1 + 2) * 3 == 9 and 7 \ 2 == 3 }`, "", true},
+		{"bitwise", `rule r { condition: (0x0f & 0x3c) == 0x0c and 1 << 4 == 16 }`, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := len(compile(t, tt.rule).Scan([]byte(tt.data))) > 0
+			if got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanRuleReferences(t *testing.T) {
+	rs := compile(t, `
+private rule mz { condition: uint16(0) == 0x5A4D }
+rule packed : pe upx {
+	meta:
+		author = "ir"
+		score = 70
+	strings:
+		$upx = "UPX!"
+	condition:
+		mz and $upx
+}
+rule other { condition: not packed }
+`)
+	ms := rs.Scan([]byte("MZ....UPX!"))
+	if got := matched(ms); len(got) != 1 || got[0] != "packed" {
+		t.Fatalf("rules = %v, want [packed]", got)
+	}
+	m := ms[0]
+	if strings.Join(m.Tags, ",") != "pe,upx" {
+		t.Errorf("tags = %v", m.Tags)
+	}
+	if m.Meta["author"] != "ir" || m.Meta["score"] != int64(70) {
+		t.Errorf("meta = %v", m.Meta)
+	}
+	if len(m.Strings) != 1 || m.Strings[0].Offset != 6 || m.Strings[0].Data != "UPX!" {
+		t.Errorf("strings = %+v", m.Strings)
+	}
+}
+
+func TestScanGlobal(t *testing.T) {
+	rs := compile(t, `
+global rule small { condition: filesize < 10 }
+rule any { condition: true }
+`)
+	if got := matched(rs.Scan([]byte("tiny"))); len(got) != 2 {
+		t.Errorf("small target: rules = %v, want both", got)
+	}
+	if got := rs.Scan(bytes.Repeat([]byte("x"), 20)); len(got) != 0 {
+		t.Errorf("large target: rules = %v, want none", matched(got))
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"undefined string", `rule r { condition: $a }`, "line 1"},
+		{"duplicate rule", `rule r { condition: true } rule r { condition: true }`, "r"},
+		{"unknown rule", `rule r { condition: missing }`, "missing"},
+		{"unterminated hex", `rule r { strings: $a = { 41 condition: $a }`, "line"},
+		{"odd hex", `rule r { strings: $a = { 4 } condition: $a }`, "line"},
+		{"bad jump", `rule r { strings: $a = { 41 [4-2] 42 } condition: $a }`, "line"},
+		{"bad regex", `rule r { strings: $a = /(/ condition: $a }`, "line"},
+		{"module", "import \"pe\"\nrule r { condition: pe.is_dll() }", "pe"},
+		{"include", `include "other.yar"`, "include"},
+		{"missing condition", `rule r { strings: $a = "x" }`, "missing condition"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Ruleset{}).Parse(tt.src)
+			if err == nil {
+				t.Fatal("parse succeeded")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestHexBudget(t *testing.T) {
+	// Every 41 starts a search that tries all jump lengths before failing on
+	// the missing 42, so the work is far above the per-string budget.
+	rs := compile(t, `rule r { strings: $a = { 41 [0-200] 41 [0-200] 41 [0-200] 42 } condition: $a }`)
+	ms, partial := rs.scan(bytes.Repeat([]byte("A"), 1<<16))
+	if len(ms) != 0 {
+		t.Errorf("rules = %v, want none", matched(ms))
+	}
+	if len(partial) != 1 || !strings.Contains(partial[0], "budget exhausted") {
+		t.Errorf("partial = %v, want the string reported as exhausted", partial)
+	}
+}
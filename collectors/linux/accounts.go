@@ -0,0 +1,259 @@
+package linux
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// User is one line of accounts/users.jsonl. Password is "set", "locked",
+// "empty" or "unknown" when /etc/shadow is unreadable; hashes are never
+// recorded.
+type User struct {
+	Name     string   `json:"name"`
+	UID      int      `json:"uid"`
+	GID      int      `json:"gid"`
+	Gecos    string   `json:"gecos,omitempty"`
+	Home     string   `json:"home"`
+	Shell    string   `json:"shell"`
+	Groups   []string `json:"groups,omitempty"`
+	Password string   `json:"password"`
+}
+
+// SSHKey is one line of accounts/ssh_keys.jsonl.
+type SSHKey struct {
+	User        string `json:"user"`
+	Path        string `json:"path"`
+	Line        int    `json:"line"`
+	Type        string `json:"type"`
+	Fingerprint string `json:"fingerprint"`
+	Comment     string `json:"comment,omitempty"`
+	Options     string `json:"options,omitempty"`
+}
+
+// AccountsCollector records local users, their groups and the public keys
+// authorized to log in as them.
+type AccountsCollector struct{}
+
+func NewAccountsCollector() *AccountsCollector { return &AccountsCollector{} }
+
+func (c *AccountsCollector) Name() string { return "accounts" }
+
+func (c *AccountsCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	_ = ctx
+
+	users, err := readUsers("/etc/passwd", "/etc/shadow", "/etc/group")
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []SSHKey
+	for _, h := range listHomeDirs("/etc/passwd") {
+		for _, name := range []string{"authorized_keys", "authorized_keys2"} {
+			keys = append(keys, readAuthorizedKeys(h.User, filepath.Join(h.Dir, ".ssh", name))...)
+		}
+	}
+
+	var artifacts []collectors.Artifact
+	write := func(name string, n int, encode func(*json.Encoder) error) error {
+		var buf bytes.Buffer
+		if err := encode(json.NewEncoder(&buf)); err != nil {
+			return err
+		}
+		rel := filepath.ToSlash(filepath.Join("accounts", name))
+		out := filepath.Join(rc.OutputDir, rel)
+		if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+			return err
+		}
+		sha, size, err := evidence.SHA256File(out)
+		if err != nil {
+			return err
+		}
+		artifacts = append(artifacts, collectors.Artifact{
+			RelativePath: rel,
+			Collector:    c.Name(),
+			CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+			SizeBytes:    size,
+			SHA256:       sha,
+			Metadata:     map[string]string{"records": intToString(n)},
+		})
+		return nil
+	}
+
+	if err := write("users.jsonl", len(users), func(enc *json.Encoder) error {
+		for _, u := range users {
+			if err := enc.Encode(u); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := write("ssh_keys.jsonl", len(keys), func(enc *json.Encoder) error {
+		for _, k := range keys {
+			if err := enc.Encode(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+func readUsers(passwdPath, shadowPath, groupPath string) ([]User, error) {
+	b, err := os.ReadFile(passwdPath)
+	if err != nil {
+		return nil, err
+	}
+
+	shadow := map[string]string{}
+	if sb, err := os.ReadFile(shadowPath); err == nil {
+		for _, line := range strings.Split(string(sb), "\n") {
+			fields := strings.Split(line, ":")
+			if len(fields) >= 2 {
+				shadow[fields[0]] = passwordState(fields[1])
+			}
+		}
+	}
+
+	groups := map[string][]string{}
+	gids := map[int]string{}
+	if gb, err := os.ReadFile(groupPath); err == nil {
+		for _, line := range strings.Split(string(gb), "\n") {
+			fields := strings.Split(line, ":")
+			if len(fields) < 4 {
+				continue
+			}
+			if gid, err := strconv.Atoi(fields[2]); err == nil {
+				gids[gid] = fields[0]
+			}
+			for _, m := range strings.Split(fields[3], ",") {
+				if m = strings.TrimSpace(m); m != "" {
+					groups[m] = append(groups[m], fields[0])
+				}
+			}
+		}
+	}
+
+	var users []User
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u := User{Name: fields[0], Gecos: fields[4], Home: fields[5], Shell: fields[6], Password: "unknown"}
+		u.UID, _ = strconv.Atoi(fields[2])
+		u.GID, _ = strconv.Atoi(fields[3])
+		if state, ok := shadow[u.Name]; ok && fields[1] == "x" {
+			u.Password = state
+		} else if fields[1] != "x" {
+			u.Password = passwordState(fields[1])
+		}
+		if g, ok := gids[u.GID]; ok {
+			u.Groups = append(u.Groups, g)
+		}
+		for _, g := range groups[u.Name] {
+			if len(u.Groups) == 0 || u.Groups[0] != g {
+				u.Groups = append(u.Groups, g)
+			}
+		}
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func passwordState(field string) string {
+	switch {
+	case field == "":
+		return "empty"
+	case strings.HasPrefix(field, "!") || strings.HasPrefix(field, "*"):
+		return "locked"
+	}
+	return "set"
+}
+
+var sshKeyTypes = map[string]bool{
+	"ssh-rsa":                            true,
+	"ssh-dss":                            true,
+	"ssh-ed25519":                        true,
+	"ecdsa-sha2-nistp256":                true,
+	"ecdsa-sha2-nistp384":                true,
+	"ecdsa-sha2-nistp521":                true,
+	"sk-ssh-ed25519@openssh.com":         true,
+	"sk-ecdsa-sha2-nistp256@openssh.com": true,
+}
+
+// readAuthorizedKeys parses an authorized_keys file. Lines may start with a
+// comma-separated option list, which can itself contain quoted spaces.
+func readAuthorizedKeys(user string, path string) []SSHKey {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var out []SSHKey
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	n := 0
+	for s.Scan() {
+		n++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var options string
+		if first, _, _ := strings.Cut(line, " "); !sshKeyTypes[first] {
+			options, line = splitKeyOptions(line)
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		blob, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil {
+			continue
+		}
+		sum := sha256.Sum256(blob)
+		k := SSHKey{
+			User:        user,
+			Path:        path,
+			Line:        n,
+			Type:        fields[0],
+			Fingerprint: "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]),
+			Options:     options,
+		}
+		if len(fields) > 2 {
+			k.Comment = strings.Join(fields[2:], " ")
+		}
+		out = append(out, k)
+	}
+	return out
+}
+
+func splitKeyOptions(line string) (string, string) {
+	quoted := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case (r == ' ' || r == '\t') && !quoted:
+			return line[:i], strings.TrimSpace(line[i+1:])
+		}
+	}
+	return line, ""
+}
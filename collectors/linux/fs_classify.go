@@ -0,0 +1,169 @@
+package linux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"unicode/utf8"
+)
+
+// ClassifyBytes is how much of each file the classification pass reads.
+// Entropy is measured over this prefix, which is also where ransomware that
+// encrypts files partially writes first.
+const ClassifyBytes = 1 << 20
+
+// File classes group the types FileType reports.
+const (
+	ClassExecutable = "executable"
+	ClassScript     = "script"
+	ClassArchive    = "archive"
+	ClassDocument   = "document"
+	ClassImage      = "image"
+	ClassDatabase   = "database"
+	ClassEncrypted  = "encrypted"
+	ClassText       = "text"
+	ClassData       = "data"
+	ClassEmpty      = "empty"
+)
+
+type magic struct {
+	offset int
+	sig    string
+	typ    string
+}
+
+// magics are checked in order; the first match wins.
+var magics = []magic{
+	{0, "\x7fELF", "elf"},
+	{0, "\xfe\xed\xfa\xce", "macho"},
+	{0, "\xfe\xed\xfa\xcf", "macho"},
+	{0, "\xce\xfa\xed\xfe", "macho"},
+	{0, "\xcf\xfa\xed\xfe", "macho"},
+	{0, "#!", "script"},
+	{0, "\x1f\x8b", "gzip"},
+	{0, "BZh", "bzip2"},
+	{0, "\xfd7zXZ\x00", "xz"},
+	{0, "\x28\xb5\x2f\xfd", "zstd"},
+	{0, "\x04\x22\x4d\x18", "lz4"},
+	{0, "PK\x03\x04", "zip"},
+	{0, "PK\x05\x06", "zip"},
+	{0, "7z\xbc\xaf\x27\x1c", "7z"},
+	{0, "Rar!\x1a\x07", "rar"},
+	{257, "ustar", "tar"},
+	{0, "%PDF-", "pdf"},
+	{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1", "ole"},
+	{0, "\x89PNG\r\n\x1a\n", "png"},
+	{0, "\xff\xd8\xff", "jpeg"},
+	{0, "GIF87a", "gif"},
+	{0, "GIF89a", "gif"},
+	{0, "II*\x00", "tiff"},
+	{0, "MM\x00*", "tiff"},
+	{0, "SQLite format 3\x00", "sqlite"},
+	{0, "LUKS\xba\xbe", "luks"},
+	{3, "-FVE-FS-", "bitlocker"},
+	{0, "age-encryption.org/v1\n", "age"},
+	{0, "-----BEGIN AGE ENCRYPTED FILE-----", "age"},
+	{0, "-----BEGIN PGP MESSAGE-----", "pgp"},
+	{0, "Salted__", "openssl"},
+}
+
+var fileClasses = map[string]string{
+	"elf": ClassExecutable, "pe": ClassExecutable, "macho": ClassExecutable, "java_class": ClassExecutable,
+	"script": ClassScript,
+	"gzip":   ClassArchive, "bzip2": ClassArchive, "xz": ClassArchive, "zstd": ClassArchive, "lz4": ClassArchive,
+	"zip": ClassArchive, "7z": ClassArchive, "rar": ClassArchive, "tar": ClassArchive,
+	"pdf": ClassDocument, "ole": ClassDocument,
+	"png": ClassImage, "jpeg": ClassImage, "gif": ClassImage, "tiff": ClassImage, "webp": ClassImage,
+	"sqlite": ClassDatabase,
+	"luks":   ClassEncrypted, "bitlocker": ClassEncrypted, "age": ClassEncrypted, "pgp": ClassEncrypted, "openssl": ClassEncrypted,
+	"text": ClassText, "data": ClassData, "empty": ClassEmpty,
+}
+
+// FileClass returns the class of a type reported by FileType.
+func FileClass(fileType string) string {
+	if c, ok := fileClasses[fileType]; ok {
+		return c
+	}
+	return ClassData
+}
+
+// FileType identifies a file from its leading bytes. Anything without a
+// known signature is "text" when it decodes as UTF-8 without NUL bytes and
+// "data" otherwise.
+func FileType(head []byte) string {
+	if len(head) == 0 {
+		return "empty"
+	}
+	for _, m := range magics {
+		if len(head) >= m.offset+len(m.sig) && string(head[m.offset:m.offset+len(m.sig)]) == m.sig {
+			return m.typ
+		}
+	}
+	switch {
+	case len(head) >= 12 && string(head[:4]) == "RIFF" && string(head[8:12]) == "WEBP":
+		return "webp"
+	case len(head) >= 64 && string(head[:2]) == "MZ":
+		// The PE header offset sits at 0x3c; a bare MZ is too weak to trust.
+		off := int(binary.LittleEndian.Uint32(head[0x3c:0x40]))
+		if off > 0 && off+4 <= len(head) && string(head[off:off+4]) == "PE\x00\x00" {
+			return "pe"
+		}
+	case len(head) >= 8 && string(head[:4]) == "\xca\xfe\xba\xbe":
+		// Java classes and fat Mach-O share a magic; a fat binary has a
+		// small architecture count where a class has its version.
+		if binary.BigEndian.Uint32(head[4:8]) < 20 {
+			return "macho"
+		}
+		return "java_class"
+	}
+	sample := head
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+		// Do not count a rune cut at the sample boundary as invalid.
+		for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if bytes.IndexByte(sample, 0) < 0 && utf8.Valid(sample) {
+		return "text"
+	}
+	return "data"
+}
+
+// Entropy returns the Shannon entropy of data in bits per byte.
+func Entropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	var counts [256]int
+	for _, c := range data {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(data))
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// classifyPath reads up to ClassifyBytes of path into buf and returns the
+// file type and the entropy of what was read.
+func classifyPath(path string, buf []byte) (string, float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", 0, err
+	}
+	return FileType(buf[:n]), math.Round(Entropy(buf[:n])*1000) / 1000, nil
+}
@@ -30,6 +30,7 @@ type SnapshotOptions struct {
 	Paths         []string
 	Mode          SnapshotMode
 	HashFiles     bool
+	Classify      bool
 	MaxFileBytes  int64
 	MaxTotalBytes int64
 	MaxFiles      int
@@ -45,17 +46,27 @@ func NewFilesystemSnapshotCollector(opts SnapshotOptions) *FilesystemSnapshotCol
 
 func (c *FilesystemSnapshotCollector) Name() string { return "fs_snapshot" }
 
-type snapshotEntry struct {
+// SnapshotEntry is one line of snapshot/metadata.jsonl.
+type SnapshotEntry struct {
 	Path       string `json:"path"`
 	Type       string `json:"type"`
 	SizeBytes  int64  `json:"size_bytes"`
 	Mode       string `json:"mode"`
 	ModTime    string `json:"mod_time"`
+	ATime      string `json:"atime,omitempty"`
+	CTime      string `json:"ctime,omitempty"`
+	BTime      string `json:"btime,omitempty"`
+	Inode      uint64 `json:"inode,omitempty"`
 	UID        int    `json:"uid,omitempty"`
 	GID        int    `json:"gid,omitempty"`
 	SHA256     string `json:"sha256,omitempty"`
 	Copied     bool   `json:"copied"`
 	CopyReason string `json:"copy_reason,omitempty"`
+
+	// FileType and Entropy are set by the classification pass. Entropy is
+	// measured over the first ClassifyBytes of the file.
+	FileType string  `json:"file_type,omitempty"`
+	Entropy  float64 `json:"entropy,omitempty"`
 }
 
 func isExcluded(path string) bool {
@@ -163,6 +174,11 @@ func (c *FilesystemSnapshotCollector) Collect(ctx context.Context, rc collectors
 	}
 	defer closeTar()
 
+	var classifyBuf []byte
+	if c.opts.Classify {
+		classifyBuf = make([]byte, ClassifyBytes)
+	}
+
 	errStopWalk := errors.New("stop_walk")
 	filesSeen := 0
 	var totalCopied int64
@@ -205,13 +221,14 @@ func (c *FilesystemSnapshotCollector) Collect(ctx context.Context, rc collectors
 				return nil
 			}
 
-			entry := snapshotEntry{
+			entry := SnapshotEntry{
 				Path:      path,
 				SizeBytes: info.Size(),
 				Mode:      info.Mode().String(),
 				ModTime:   info.ModTime().UTC().Format(time.RFC3339Nano),
 				Copied:    false,
 			}
+			fillStatTimes(path, info, &entry)
 
 			if info.Mode()&os.ModeSymlink != 0 {
 				entry.Type = "symlink"
@@ -238,6 +255,11 @@ func (c *FilesystemSnapshotCollector) Collect(ctx context.Context, rc collectors
 					}
 				}
 			}
+			if c.opts.Classify {
+				if typ, ent, cerr := classifyPath(path, classifyBuf); cerr == nil {
+					entry.FileType, entry.Entropy = typ, ent
+				}
+			}
 
 			if mode == SnapshotCopyFiles {
 				if info.Size() > maxFileBytes {
@@ -310,6 +332,7 @@ func (c *FilesystemSnapshotCollector) Collect(ctx context.Context, rc collectors
 			"files_seen":      intToString(filesSeen),
 			"total_copied":    int64ToString(totalCopied),
 			"hash_files":      boolToString(c.opts.HashFiles),
+			"classify":        boolToString(c.opts.Classify),
 			"max_file_bytes":  int64ToString(maxFileBytes),
 			"max_total_bytes": int64ToString(maxTotalBytes),
 		},
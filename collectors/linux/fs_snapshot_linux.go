@@ -0,0 +1,108 @@
+package linux
+
+import (
+	"io/fs"
+	"runtime"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	atFDCWD           = -100
+	atSymlinkNoFollow = 0x100
+	statxBasicStats   = 0x7ff
+	statxBTime        = 0x800
+)
+
+// statx is not exposed by the syscall package on most architectures.
+var statxSysnum = map[string]uintptr{
+	"amd64":   332,
+	"arm64":   291,
+	"riscv64": 291,
+	"loong64": 291,
+	"386":     383,
+	"arm":     397,
+	"ppc64":   383,
+	"ppc64le": 383,
+	"s390x":   379,
+}
+
+type statxTimestamp struct {
+	Sec  int64
+	Nsec uint32
+	_    int32
+}
+
+type statxBuf struct {
+	Mask           uint32
+	Blksize        uint32
+	Attributes     uint64
+	Nlink          uint32
+	UID            uint32
+	GID            uint32
+	Mode           uint16
+	_              uint16
+	Ino            uint64
+	Size           uint64
+	Blocks         uint64
+	AttributesMask uint64
+	Atime          statxTimestamp
+	Btime          statxTimestamp
+	Ctime          statxTimestamp
+	Mtime          statxTimestamp
+	_              [16]uint64
+}
+
+func statxPath(path string) (statxBuf, bool) {
+	var st statxBuf
+	nr, ok := statxSysnum[runtime.GOARCH]
+	if !ok {
+		return st, false
+	}
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return st, false
+	}
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(nr, uintptr(dirfd), uintptr(unsafe.Pointer(p)), atSymlinkNoFollow, statxBasicStats|statxBTime, uintptr(unsafe.Pointer(&st)), 0)
+	if errno != 0 {
+		return st, false
+	}
+	return st, true
+}
+
+func formatStatxTime(ts statxTimestamp) string {
+	return time.Unix(ts.Sec, int64(ts.Nsec)).UTC().Format(time.RFC3339Nano)
+}
+
+func fillStatTimes(path string, info fs.FileInfo, entry *SnapshotEntry) {
+	if st, ok := statxPath(path); ok {
+		entry.ATime = formatStatxTime(st.Atime)
+		entry.CTime = formatStatxTime(st.Ctime)
+		if st.Mask&statxBTime != 0 && st.Btime.Sec != 0 {
+			entry.BTime = formatStatxTime(st.Btime)
+		}
+		entry.Inode = st.Ino
+		entry.UID = int(st.UID)
+		entry.GID = int(st.GID)
+		return
+	}
+
+	sys, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	entry.ATime = time.Unix(int64(sys.Atim.Sec), int64(sys.Atim.Nsec)).UTC().Format(time.RFC3339Nano)
+	entry.CTime = time.Unix(int64(sys.Ctim.Sec), int64(sys.Ctim.Nsec)).UTC().Format(time.RFC3339Nano)
+	entry.Inode = uint64(sys.Ino)
+	entry.UID = int(sys.Uid)
+	entry.GID = int(sys.Gid)
+}
+
+func fileOwner(info fs.FileInfo) int {
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(sys.Uid)
+	}
+	return -1
+}
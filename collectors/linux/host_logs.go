@@ -0,0 +1,159 @@
+package linux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// utmpRecordSize is the size of a struct utmp record in wtmp and btmp.
+const utmpRecordSize = 384
+
+type HostLogsCollector struct {
+	maxBytes int64
+}
+
+func NewHostLogsCollector() *HostLogsCollector {
+	return &HostLogsCollector{maxBytes: 50 * 1024 * 1024}
+}
+
+func (c *HostLogsCollector) Name() string { return "host_logs" }
+
+func (c *HostLogsCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	cands := []struct {
+		src  string
+		kind string
+		// record is the size of the fixed-length records of a binary log;
+		// a truncated copy starts on a record boundary.
+		record int64
+	}{
+		{src: "/var/log/auth.log", kind: "auth"},
+		{src: "/var/log/secure", kind: "auth"},
+		{src: "/var/log/syslog", kind: "syslog"},
+		{src: "/var/log/messages", kind: "syslog"},
+		{src: "/var/log/kern.log", kind: "syslog"},
+		{src: "/var/log/audit/audit.log", kind: "audit"},
+		{src: "/var/log/wtmp", kind: "wtmp", record: utmpRecordSize},
+		{src: "/var/log/btmp", kind: "btmp", record: utmpRecordSize},
+	}
+
+	var artifacts []collectors.Artifact
+	for _, cand := range cands {
+		select {
+		case <-ctx.Done():
+			return artifacts, ctx.Err()
+		default:
+		}
+
+		rel := filepath.ToSlash(filepath.Join("logs", filepath.Base(cand.src)))
+		dst := filepath.Join(rc.OutputDir, rel)
+		truncated, err := copyTail(cand.src, dst, c.maxBytes, cand.record)
+		if err != nil {
+			continue
+		}
+		sha, size, err := evidence.SHA256File(dst)
+		if err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, collectors.Artifact{
+			RelativePath: rel,
+			Collector:    c.Name(),
+			CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+			SizeBytes:    size,
+			SHA256:       sha,
+			Metadata: map[string]string{
+				"source":    cand.src,
+				"kind":      cand.kind,
+				"truncated": boolToString(truncated),
+			},
+		})
+	}
+	return artifacts, nil
+}
+
+// copyTail copies at most maxBytes from the end of src, since the most recent
+// log entries are the ones that matter during triage. With a record size,
+// the copy starts at a multiple of it so that no record is cut in half;
+// without one the file is text and the copy starts after the first newline
+// at or past the cut, so that it never begins with half a line.
+func copyTail(src string, dst string, maxBytes int64, record int64) (bool, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return false, err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() {
+		return false, os.ErrInvalid
+	}
+
+	var r io.Reader = in
+	truncated := false
+	if maxBytes > 0 && info.Size() > maxBytes {
+		off := info.Size() - maxBytes
+		if record > 0 {
+			off = (off + record - 1) / record * record
+		} else {
+			// Start one byte early: a cut right after a newline keeps
+			// its first line.
+			off--
+		}
+		if _, err := in.Seek(off, io.SeekStart); err != nil {
+			return false, err
+		}
+		if record <= 0 {
+			br := bufio.NewReader(in)
+			if err := skipLine(br); err != nil {
+				return false, err
+			}
+			r = br
+		}
+		truncated = true
+	}
+
+	if err := evidence.EnsureParent(dst); err != nil {
+		return false, err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return false, err
+	}
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes)
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return false, err
+	}
+	if err := out.Close(); err != nil {
+		return false, err
+	}
+	return truncated, os.Rename(tmp, dst)
+}
+
+// skipLine discards everything up to and including the next newline.
+func skipLine(br *bufio.Reader) error {
+	for {
+		_, err := br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+}
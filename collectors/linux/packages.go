@@ -0,0 +1,259 @@
+package linux
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// Package is one line of packages/packages.jsonl.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	Arch    string `json:"arch,omitempty"`
+	Manager string `json:"manager"`
+}
+
+// PackagesCollector lists installed packages from the dpkg and apk
+// databases, or through rpm on RPM-based hosts.
+type PackagesCollector struct{}
+
+func NewPackagesCollector() *PackagesCollector { return &PackagesCollector{} }
+
+func (c *PackagesCollector) Name() string { return "packages" }
+
+func (c *PackagesCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	var pkgs []Package
+	var managers []string
+	if p, err := readDpkgStatus("/var/lib/dpkg/status"); err == nil {
+		pkgs = append(pkgs, p...)
+		managers = append(managers, "dpkg")
+	}
+	if p, err := readApkInstalled("/lib/apk/db/installed"); err == nil {
+		pkgs = append(pkgs, p...)
+		managers = append(managers, "apk")
+	}
+	if out, err := runCmd(ctx, "rpm", "-qa", "--qf", "%{NAME}\t%{VERSION}-%{RELEASE}\t%{ARCH}\n"); err == nil {
+		n := len(pkgs)
+		for _, line := range strings.Split(string(out), "\n") {
+			f := strings.Split(line, "\t")
+			if len(f) == 3 && f[0] != "" {
+				pkgs = append(pkgs, Package{Name: f[0], Version: f[1], Arch: f[2], Manager: "rpm"})
+			}
+		}
+		// rpm is often installed on Debian hosts with an empty database.
+		if len(pkgs) > n {
+			managers = append(managers, "rpm")
+		}
+	}
+	if len(managers) == 0 {
+		return nil, errors.New("no package database found")
+	}
+	sort.SliceStable(pkgs, func(i, j int) bool { return pkgs[i].Name < pkgs[j].Name })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, p := range pkgs {
+		if err := enc.Encode(p); err != nil {
+			return nil, err
+		}
+	}
+	rel := filepath.ToSlash(filepath.Join("packages", "packages.jsonl"))
+	out := filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err := evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	return []collectors.Artifact{{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata: map[string]string{
+			"packages": intToString(len(pkgs)),
+			"managers": strings.Join(managers, ","),
+		},
+	}}, nil
+}
+
+// readDpkgStatus parses the RFC 822 style stanzas of the dpkg status file,
+// keeping packages whose status is "install ok installed".
+func readDpkgStatus(path string) ([]Package, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []Package
+	var cur Package
+	var status string
+	flush := func() {
+		if cur.Name != "" && strings.HasSuffix(status, " installed") {
+			cur.Manager = "dpkg"
+			out = append(out, cur)
+		}
+		cur, status = Package{}, ""
+	}
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") {
+			continue
+		}
+		val = strings.TrimSpace(val)
+		switch key {
+		case "Package":
+			cur.Name = val
+		case "Version":
+			cur.Version = val
+		case "Architecture":
+			cur.Arch = val
+		case "Status":
+			status = val
+		}
+	}
+	flush()
+	return out, s.Err()
+}
+
+// readApkInstalled parses Alpine's installed database, where each package
+// is a block of single-letter fields.
+func readApkInstalled(path string) ([]Package, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []Package
+	var cur Package
+	for _, line := range strings.Split(string(b)+"\n", "\n") {
+		if line == "" {
+			if cur.Name != "" {
+				cur.Manager = "apk"
+				out = append(out, cur)
+			}
+			cur = Package{}
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		switch line[0] {
+		case 'P':
+			cur.Name = line[2:]
+		case 'V':
+			cur.Version = line[2:]
+		case 'A':
+			cur.Arch = line[2:]
+		}
+	}
+	return out, nil
+}
+
+// packageOwners maps each of paths owned by an installed package to the
+// package name, using the dpkg file lists, the apk database or rpm -qf.
+// managers names the databases consulted; without any, ownership is
+// unknown rather than absent.
+func packageOwners(ctx context.Context, paths []string) (owners map[string]string, managers []string) {
+	owners = map[string]string{}
+	want := map[string]string{}
+	for _, p := range paths {
+		for _, alt := range usrMergeAliases(p) {
+			want[alt] = p
+		}
+	}
+
+	if lists, _ := filepath.Glob("/var/lib/dpkg/info/*.list"); len(lists) > 0 {
+		managers = append(managers, "dpkg")
+		for _, l := range lists {
+			pkg := strings.TrimSuffix(filepath.Base(l), ".list")
+			pkg, _, _ = strings.Cut(pkg, ":")
+			f, err := os.Open(l)
+			if err != nil {
+				continue
+			}
+			s := bufio.NewScanner(f)
+			for s.Scan() {
+				if p, ok := want[s.Text()]; ok {
+					owners[p] = pkg
+				}
+			}
+			f.Close()
+		}
+	}
+
+	if b, err := os.ReadFile("/lib/apk/db/installed"); err == nil {
+		managers = append(managers, "apk")
+		var pkg, dir string
+		for _, line := range strings.Split(string(b), "\n") {
+			if len(line) < 2 || line[1] != ':' {
+				continue
+			}
+			switch line[0] {
+			case 'P':
+				pkg = line[2:]
+			case 'F':
+				dir = "/" + line[2:]
+			case 'R':
+				if p, ok := want[filepath.Join(dir, line[2:])]; ok {
+					owners[p] = pkg
+				}
+			}
+		}
+	}
+
+	if len(paths) > 0 {
+		args := append([]string{"-qf", "--qf", "%{NAME}\n"}, paths...)
+		if out, err := runCmd(ctx, "rpm", args...); len(out) > 0 && (err == nil || strings.Contains(string(out), "not owned")) {
+			lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+			// rpm prints one line per path, in order.
+			if len(lines) == len(paths) {
+				owned := false
+				for i, l := range lines {
+					if l != "" && !strings.Contains(l, " ") {
+						owners[paths[i]] = l
+						owned = true
+					}
+				}
+				if owned {
+					managers = append(managers, "rpm")
+				}
+			}
+		}
+	}
+	return owners, managers
+}
+
+// usrMergeAliases returns p and its spelling on the other side of the
+// /usr merge, since package databases may record either.
+func usrMergeAliases(p string) []string {
+	for _, d := range []string{"/bin/", "/sbin/", "/lib/", "/lib64/"} {
+		if strings.HasPrefix(p, d) {
+			return []string{p, "/usr" + p}
+		}
+		if strings.HasPrefix(p, "/usr"+d) {
+			return []string{p, strings.TrimPrefix(p, "/usr")}
+		}
+	}
+	return []string{p}
+}
@@ -1,15 +1,108 @@
 package linux
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"iron-sentinel/collectors"
 	"iron-sentinel/evidence"
 )
 
+const (
+	PersistenceCron      = "cron"
+	PersistenceSystemd   = "systemd"
+	PersistenceRC        = "rc"
+	PersistenceProfile   = "profile"
+	PersistencePreload   = "preload"
+	PersistenceAutostart = "autostart"
+
+	// maxPersistenceLines caps the lines recorded per file.
+	maxPersistenceLines = 5000
+)
+
+// PersistenceEntry is one line of persistence/entries.jsonl. Package names
+// the installed package owning the file, when a package database knew it.
+type PersistenceEntry struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Size    int64  `json:"size"`
+	Mode    string `json:"mode"`
+	ModTime string `json:"mod_time"`
+	UID     int    `json:"uid"`
+	SHA256  string `json:"sha256,omitempty"`
+	Target  string `json:"target,omitempty"`
+	Package string `json:"package,omitempty"`
+}
+
+// PersistenceLine is one line of persistence/lines.jsonl: an executable line
+// of a persistence file. Comments and blank lines are left out, and for
+// systemd units only the Exec* and Environment* settings are kept.
+type PersistenceLine struct {
+	Path string `json:"path"`
+	Kind string `json:"kind"`
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// persistenceLocation is a persistence file or directory. Files with keep
+// set are also copied verbatim.
+type persistenceLocation struct {
+	path string
+	kind string
+	keep bool
+}
+
+// persistenceLocations are system-wide autostart points. Directories are
+// listed one level deep.
+var persistenceLocations = []persistenceLocation{
+	{"/etc/crontab", PersistenceCron, true},
+	{"/etc/anacrontab", PersistenceCron, false},
+	{"/etc/cron.d", PersistenceCron, false},
+	{"/etc/cron.daily", PersistenceCron, false},
+	{"/etc/cron.hourly", PersistenceCron, false},
+	{"/etc/cron.weekly", PersistenceCron, false},
+	{"/etc/cron.monthly", PersistenceCron, false},
+	{"/var/spool/cron/crontabs", PersistenceCron, false},
+	{"/var/spool/cron", PersistenceCron, false},
+	{"/etc/systemd/system", PersistenceSystemd, false},
+	{"/usr/local/lib/systemd/system", PersistenceSystemd, false},
+	{"/lib/systemd/system", PersistenceSystemd, false},
+	{"/usr/lib/systemd/system", PersistenceSystemd, false},
+	{"/etc/rc.local", PersistenceRC, false},
+	{"/etc/rc.d/rc.local", PersistenceRC, false},
+	{"/etc/init.d", PersistenceRC, false},
+	{"/etc/profile", PersistenceProfile, false},
+	{"/etc/profile.d", PersistenceProfile, false},
+	{"/etc/bash.bashrc", PersistenceProfile, false},
+	{"/etc/bashrc", PersistenceProfile, false},
+	{"/etc/zsh/zshrc", PersistenceProfile, false},
+	{"/etc/zshrc", PersistenceProfile, false},
+	{"/etc/environment", PersistenceProfile, false},
+	{"/etc/ld.so.preload", PersistencePreload, false},
+	{"/etc/xdg/autostart", PersistenceAutostart, false},
+}
+
+// userPersistenceFiles are the per-user autostart points, relative to each
+// home directory.
+var userPersistenceFiles = []persistenceLocation{
+	{".bashrc", PersistenceProfile, false},
+	{".bash_profile", PersistenceProfile, false},
+	{".bash_login", PersistenceProfile, false},
+	{".bash_logout", PersistenceProfile, false},
+	{".profile", PersistenceProfile, false},
+	{".zshrc", PersistenceProfile, false},
+	{".zprofile", PersistenceProfile, false},
+	{".zshenv", PersistenceProfile, false},
+	{".config/systemd/user", PersistenceSystemd, false},
+	{".config/autostart", PersistenceAutostart, false},
+}
+
 type PersistenceCollector struct{}
 
 func NewPersistenceCollector() *PersistenceCollector { return &PersistenceCollector{} }
@@ -17,36 +110,57 @@ func NewPersistenceCollector() *PersistenceCollector { return &PersistenceCollec
 func (c *PersistenceCollector) Name() string { return "persistence" }
 
 func (c *PersistenceCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
-	_ = ctx
-
-	paths := []string{
-		"/etc/crontab",
-		"/etc/cron.d",
-		"/etc/cron.daily",
-		"/etc/cron.hourly",
-		"/etc/cron.weekly",
-		"/etc/cron.monthly",
-		"/etc/systemd/system",
-		"/lib/systemd/system",
+	locations := append([]persistenceLocation{}, persistenceLocations...)
+	for _, h := range listHomeDirs("/etc/passwd") {
+		for _, f := range userPersistenceFiles {
+			locations = append(locations, persistenceLocation{filepath.Join(h.Dir, f.path), f.kind, false})
+		}
 	}
 
 	var artifacts []collectors.Artifact
-	for _, p := range paths {
+	var entries []PersistenceEntry
+	var lines []PersistenceLine
+	seenDirs := map[string]bool{}
+	add := func(path, kind string) {
+		pe, ok := persistenceEntry(path, kind)
+		if !ok {
+			return
+		}
+		entries = append(entries, pe)
+		if pe.Target == "" {
+			lines = append(lines, persistenceLines(path, kind)...)
+		}
+	}
+	for _, l := range locations {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		p := l.path
 		info, err := os.Stat(p)
 		if err != nil {
 			continue
 		}
 
 		if info.IsDir() {
-			entries, err := os.ReadDir(p)
+			// /lib is a link to /usr/lib on merged-/usr systems.
+			real, err := filepath.EvalSymlinks(p)
+			if err != nil || seenDirs[real] {
+				continue
+			}
+			seenDirs[real] = true
+			dirEntries, err := os.ReadDir(p)
 			if err != nil {
 				continue
 			}
 			var listing []byte
-			for _, e := range entries {
+			for _, e := range dirEntries {
 				listing = append(listing, []byte(e.Name()+"\n")...)
+				add(filepath.Join(p, e.Name()), l.kind)
 			}
-			rel := filepath.ToSlash(filepath.Join("persistence", filepath.Base(p)+"_listing.txt"))
+			rel := filepath.ToSlash(filepath.Join("persistence", listingName(p)))
 			out := filepath.Join(rc.OutputDir, rel)
 			if err := evidence.WriteFileAtomic(out, listing, 0o600); err != nil {
 				return nil, err
@@ -66,6 +180,10 @@ func (c *PersistenceCollector) Collect(ctx context.Context, rc collectors.RunCon
 			continue
 		}
 
+		add(p, l.kind)
+		if !l.keep {
+			continue
+		}
 		b, err := os.ReadFile(p)
 		if err != nil {
 			continue
@@ -89,5 +207,142 @@ func (c *PersistenceCollector) Collect(ctx context.Context, rc collectors.RunCon
 		})
 	}
 
+	var paths []string
+	for _, e := range entries {
+		if e.Target == "" {
+			paths = append(paths, e.Path)
+		}
+	}
+	owners, managers := packageOwners(ctx, paths)
+	for i := range entries {
+		entries[i].Package = owners[entries[i].Path]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return nil, err
+		}
+	}
+	rel := filepath.ToSlash(filepath.Join("persistence", "entries.jsonl"))
+	out := filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err := evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	md := map[string]string{"entries": intToString(len(entries))}
+	if len(managers) > 0 {
+		md["package_managers"] = strings.Join(managers, ",")
+	}
+	artifacts = append(artifacts, collectors.Artifact{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata:     md,
+	})
+
+	buf.Reset()
+	for _, l := range lines {
+		if err := enc.Encode(l); err != nil {
+			return nil, err
+		}
+	}
+	rel = filepath.ToSlash(filepath.Join("persistence", "lines.jsonl"))
+	out = filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err = evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	artifacts = append(artifacts, collectors.Artifact{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata:     map[string]string{"lines": intToString(len(lines))},
+	})
+
 	return artifacts, nil
 }
+
+// listingName names the listing of dir after its full path, since
+// /etc/systemd/system and /lib/systemd/system share a base name.
+func listingName(dir string) string {
+	return strings.ReplaceAll(strings.Trim(filepath.ToSlash(dir), "/"), "/", "_") + "_listing.txt"
+}
+
+// persistenceEntry describes one file in a persistence location. Symlinks
+// are recorded with their target rather than followed, since enabled systemd
+// units are mostly links.
+func persistenceEntry(path, kind string) (PersistenceEntry, bool) {
+	info, err := os.Lstat(path)
+	if err != nil || info.IsDir() {
+		return PersistenceEntry{}, false
+	}
+	e := PersistenceEntry{
+		Path:    path,
+		Kind:    kind,
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		ModTime: info.ModTime().UTC().Format(time.RFC3339Nano),
+		UID:     fileOwner(info),
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		e.Target, _ = os.Readlink(path)
+		return e, true
+	}
+	if info.Mode().IsRegular() {
+		e.SHA256, _ = sha256Path(path)
+	}
+	return e, true
+}
+
+// persistenceLines reads the executable lines of a persistence file,
+// joining backslash continuations onto the line they start on.
+func persistenceLines(path, kind string) []PersistenceLine {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	var out []PersistenceLine
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	n, start := 0, 0
+	var cont strings.Builder
+	for s.Scan() && len(out) < maxPersistenceLines {
+		n++
+		line := s.Text()
+		if cont.Len() == 0 {
+			start = n
+		}
+		if strings.HasSuffix(line, "\\") {
+			cont.WriteString(strings.TrimSuffix(line, "\\"))
+			continue
+		}
+		cont.WriteString(line)
+		text := strings.TrimSpace(cont.String())
+		cont.Reset()
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+		if kind == PersistenceSystemd && !strings.HasPrefix(text, "Exec") && !strings.HasPrefix(text, "Environment") {
+			continue
+		}
+		out = append(out, PersistenceLine{Path: path, Kind: kind, Line: start, Text: text})
+	}
+	return out
+}
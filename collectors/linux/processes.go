@@ -0,0 +1,264 @@
+package linux
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// clockTicks is USER_HZ, which is 100 on every Linux architecture we collect
+// from; /proc/<pid>/stat reports CPU and start times in these units.
+const clockTicks = 100
+
+// maxExeHashBytes bounds hashing of process images.
+const maxExeHashBytes = 64 * 1024 * 1024
+
+// Process is one line of proc/processes.jsonl.
+type Process struct {
+	PID          int      `json:"pid"`
+	PPID         int      `json:"ppid"`
+	Name         string   `json:"name"`
+	Exe          string   `json:"exe,omitempty"`
+	ExeDeleted   bool     `json:"exe_deleted,omitempty"`
+	ExeSHA256    string   `json:"exe_sha256,omitempty"`
+	Cmdline      []string `json:"cmdline,omitempty"`
+	Cwd          string   `json:"cwd,omitempty"`
+	UID          int      `json:"uid"`
+	EUID         int      `json:"euid"`
+	User         string   `json:"user,omitempty"`
+	State        string   `json:"state"`
+	Threads      int      `json:"threads"`
+	RSSBytes     int64    `json:"rss_bytes"`
+	CPUSeconds   float64  `json:"cpu_seconds"`
+	CPUPercent   float64  `json:"cpu_percent"`
+	StartTime    string   `json:"start_time,omitempty"`
+	KernelThread bool     `json:"kernel_thread,omitempty"`
+}
+
+// ProcessesCollector records a structured process inventory read directly
+// from /proc, independent of the ps output kept by proc_summary.
+type ProcessesCollector struct{}
+
+func NewProcessesCollector() *ProcessesCollector { return &ProcessesCollector{} }
+
+func (c *ProcessesCollector) Name() string { return "processes" }
+
+func (c *ProcessesCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+
+	bootTime := procBootTime()
+	uptime := procUptime()
+	users := passwdNames("/etc/passwd")
+	pageSize := int64(os.Getpagesize())
+	exeHashes := map[string]string{}
+
+	var procs []Process
+	for _, e := range entries {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		p, ok := readProcess(pid, bootTime, uptime, pageSize)
+		if !ok {
+			continue
+		}
+		p.User = users[p.UID]
+		if p.Exe != "" && !p.ExeDeleted {
+			sum, seen := exeHashes[p.Exe]
+			if !seen {
+				sum = hashExe(pid)
+				exeHashes[p.Exe] = sum
+			}
+			p.ExeSHA256 = sum
+		} else if p.ExeDeleted {
+			p.ExeSHA256 = hashExe(pid)
+		}
+		procs = append(procs, p)
+	}
+	sort.Slice(procs, func(i, j int) bool { return procs[i].PID < procs[j].PID })
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, p := range procs {
+		if err := enc.Encode(p); err != nil {
+			return nil, err
+		}
+	}
+
+	rel := filepath.ToSlash(filepath.Join("proc", "processes.jsonl"))
+	out := filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err := evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	return []collectors.Artifact{{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata:     map[string]string{"processes": intToString(len(procs))},
+	}}, nil
+}
+
+func readProcess(pid int, bootTime int64, uptime float64, pageSize int64) (Process, bool) {
+	dir := filepath.Join("/proc", strconv.Itoa(pid))
+	stat, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return Process{}, false
+	}
+	// comm may itself contain spaces and parentheses, so split on the last
+	// closing parenthesis.
+	s := string(stat)
+	open := strings.IndexByte(s, '(')
+	end := strings.LastIndexByte(s, ')')
+	if open < 0 || end < open {
+		return Process{}, false
+	}
+	fields := strings.Fields(s[end+1:])
+	if len(fields) < 22 {
+		return Process{}, false
+	}
+	field := func(n int) string { return fields[n-3] }
+	atoi := func(n int) int64 {
+		v, _ := strconv.ParseInt(field(n), 10, 64)
+		return v
+	}
+
+	p := Process{
+		PID:      pid,
+		PPID:     int(atoi(4)),
+		Name:     s[open+1 : end],
+		State:    field(3),
+		Threads:  int(atoi(20)),
+		RSSBytes: atoi(24) * pageSize,
+	}
+	cpu := float64(atoi(14)+atoi(15)) / clockTicks
+	p.CPUSeconds = cpu
+	start := float64(atoi(22)) / clockTicks
+	if bootTime > 0 {
+		p.StartTime = time.Unix(bootTime, 0).Add(time.Duration(start * float64(time.Second))).UTC().Format(time.RFC3339)
+	}
+	if elapsed := uptime - start; uptime > 0 && elapsed > 0 {
+		p.CPUPercent = float64(int(cpu/elapsed*10000)) / 100
+	}
+	// PF_KTHREAD in the flags field marks kernel threads.
+	if flags, err := strconv.ParseUint(field(9), 10, 64); err == nil && flags&0x00200000 != 0 {
+		p.KernelThread = true
+	}
+
+	if b, err := os.ReadFile(filepath.Join(dir, "status")); err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			if rest, ok := strings.CutPrefix(line, "Uid:"); ok {
+				ids := strings.Fields(rest)
+				if len(ids) >= 2 {
+					p.UID, _ = strconv.Atoi(ids[0])
+					p.EUID, _ = strconv.Atoi(ids[1])
+				}
+				break
+			}
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil && len(b) > 0 {
+		p.Cmdline = strings.Split(strings.TrimRight(string(b), "\x00"), "\x00")
+	}
+	if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+		if trimmed, ok := strings.CutSuffix(exe, " (deleted)"); ok {
+			p.Exe = trimmed
+			p.ExeDeleted = true
+		} else {
+			p.Exe = exe
+		}
+	}
+	if cwd, err := os.Readlink(filepath.Join(dir, "cwd")); err == nil {
+		p.Cwd = cwd
+	}
+	return p, true
+}
+
+// hashExe hashes the image through /proc/<pid>/exe, which still works when
+// the file has been deleted from disk.
+func hashExe(pid int) string {
+	path := filepath.Join("/proc", strconv.Itoa(pid), "exe")
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxExeHashBytes {
+		return ""
+	}
+	sum, err := sha256Path(path)
+	if err != nil {
+		return ""
+	}
+	return sum
+}
+
+func procBootTime() int64 {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if rest, ok := strings.CutPrefix(s.Text(), "btime "); ok {
+			v, _ := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			return v
+		}
+	}
+	return 0
+}
+
+func procUptime() float64 {
+	b, err := os.ReadFile("/proc/uptime")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(fields[0], 64)
+	return v
+}
+
+// passwdNames maps uids to user names.
+func passwdNames(passwdPath string) map[int]string {
+	out := map[int]string{}
+	b, err := os.ReadFile(passwdPath)
+	if err != nil {
+		return out
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil {
+			if _, dup := out[uid]; !dup {
+				out[uid] = fields[0]
+			}
+		}
+	}
+	return out
+}
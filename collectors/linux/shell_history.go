@@ -0,0 +1,115 @@
+package linux
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+type ShellHistoryCollector struct {
+	maxBytes int64
+}
+
+func NewShellHistoryCollector() *ShellHistoryCollector {
+	return &ShellHistoryCollector{maxBytes: 10 * 1024 * 1024}
+}
+
+func (c *ShellHistoryCollector) Name() string { return "shell_history" }
+
+type homeDir struct {
+	User string
+	Dir  string
+}
+
+func (c *ShellHistoryCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	files := []struct {
+		name  string
+		shell string
+	}{
+		{name: ".bash_history", shell: "bash"},
+		{name: ".zsh_history", shell: "zsh"},
+		{name: ".history", shell: "sh"},
+		{name: ".sh_history", shell: "sh"},
+		{name: ".ash_history", shell: "ash"},
+	}
+
+	var artifacts []collectors.Artifact
+	for _, h := range listHomeDirs("/etc/passwd") {
+		select {
+		case <-ctx.Done():
+			return artifacts, ctx.Err()
+		default:
+		}
+
+		for _, f := range files {
+			src := filepath.Join(h.Dir, f.name)
+			rel := filepath.ToSlash(filepath.Join("history", h.User+"_"+strings.TrimPrefix(f.name, ".")))
+			dst := filepath.Join(rc.OutputDir, rel)
+			truncated, err := copyTail(src, dst, c.maxBytes, 0)
+			if err != nil {
+				continue
+			}
+			sha, size, err := evidence.SHA256File(dst)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, collectors.Artifact{
+				RelativePath: rel,
+				Collector:    c.Name(),
+				CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+				SizeBytes:    size,
+				SHA256:       sha,
+				Metadata: map[string]string{
+					"source":    src,
+					"kind":      "shell_history",
+					"shell":     f.shell,
+					"user":      h.User,
+					"truncated": boolToString(truncated),
+				},
+			})
+		}
+	}
+	return artifacts, nil
+}
+
+func listHomeDirs(passwdPath string) []homeDir {
+	seen := map[string]bool{}
+	var out []homeDir
+
+	if f, err := os.Open(passwdPath); err == nil {
+		s := bufio.NewScanner(f)
+		for s.Scan() {
+			fields := strings.Split(s.Text(), ":")
+			if len(fields) < 7 || fields[5] == "" || fields[5] == "/" {
+				continue
+			}
+			if seen[fields[5]] {
+				continue
+			}
+			seen[fields[5]] = true
+			out = append(out, homeDir{User: fields[0], Dir: fields[5]})
+		}
+		_ = f.Close()
+	}
+
+	if entries, err := os.ReadDir("/home"); err == nil {
+		for _, e := range entries {
+			dir := filepath.Join("/home", e.Name())
+			if !e.IsDir() || seen[dir] {
+				continue
+			}
+			seen[dir] = true
+			out = append(out, homeDir{User: e.Name(), Dir: dir})
+		}
+	}
+	if !seen["/root"] {
+		out = append(out, homeDir{User: "root", Dir: "/root"})
+	}
+	return out
+}
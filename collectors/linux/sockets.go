@@ -0,0 +1,223 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// Socket is one line of network/sockets.jsonl.
+type Socket struct {
+	Proto      string `json:"proto"`
+	LocalAddr  string `json:"local_addr"`
+	LocalPort  int    `json:"local_port"`
+	RemoteAddr string `json:"remote_addr"`
+	RemotePort int    `json:"remote_port"`
+	State      string `json:"state"`
+	UID        int    `json:"uid"`
+	Inode      uint64 `json:"inode"`
+	PID        int    `json:"pid,omitempty"`
+	Process    string `json:"process,omitempty"`
+}
+
+// Listening reports whether the socket accepts connections: TCP sockets in
+// LISTEN and unconnected UDP sockets.
+func (s Socket) Listening() bool {
+	return s.State == "LISTEN" || (s.State == "UNCONN" && s.RemotePort == 0)
+}
+
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+}
+
+// SocketsCollector parses the kernel socket tables in /proc/net and resolves
+// each socket inode to its owning process.
+type SocketsCollector struct{}
+
+func NewSocketsCollector() *SocketsCollector { return &SocketsCollector{} }
+
+func (c *SocketsCollector) Name() string { return "sockets" }
+
+func (c *SocketsCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	owners := socketOwners(ctx)
+
+	var socks []Socket
+	read := 0
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		b, err := os.ReadFile(filepath.Join("/proc/net", proto))
+		if err != nil {
+			continue
+		}
+		read++
+		for _, line := range strings.Split(string(b), "\n")[1:] {
+			s, ok := parseSocketLine(proto, line)
+			if !ok {
+				continue
+			}
+			if o, ok := owners[s.Inode]; ok {
+				s.PID, s.Process = o.pid, o.name
+			}
+			socks = append(socks, s)
+		}
+	}
+	if read == 0 {
+		return nil, os.ErrNotExist
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	listening := 0
+	for _, s := range socks {
+		if s.Listening() {
+			listening++
+		}
+		if err := enc.Encode(s); err != nil {
+			return nil, err
+		}
+	}
+
+	rel := filepath.ToSlash(filepath.Join("network", "sockets.jsonl"))
+	out := filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err := evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	return []collectors.Artifact{{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata: map[string]string{
+			"sockets":   intToString(len(socks)),
+			"listening": intToString(listening),
+		},
+	}}, nil
+}
+
+func parseSocketLine(proto string, line string) (Socket, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 10 {
+		return Socket{}, false
+	}
+	laddr, lport, ok1 := parseProcAddr(fields[1])
+	raddr, rport, ok2 := parseProcAddr(fields[2])
+	if !ok1 || !ok2 {
+		return Socket{}, false
+	}
+	s := Socket{
+		Proto:      proto,
+		LocalAddr:  laddr,
+		LocalPort:  lport,
+		RemoteAddr: raddr,
+		RemotePort: rport,
+	}
+	if strings.HasPrefix(proto, "udp") {
+		s.State = "UNCONN"
+		if fields[3] == "01" {
+			s.State = "ESTABLISHED"
+		}
+	} else if st, ok := tcpStates[fields[3]]; ok {
+		s.State = st
+	} else {
+		s.State = fields[3]
+	}
+	s.UID, _ = strconv.Atoi(fields[7])
+	s.Inode, _ = strconv.ParseUint(fields[9], 10, 64)
+	return s, true
+}
+
+// parseProcAddr decodes "0100007F:0016". Addresses are stored as host-order
+// 32-bit words, which is little-endian on the platforms we support.
+func parseProcAddr(s string) (string, int, bool) {
+	h, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return "", 0, false
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return "", 0, false
+	}
+	raw, err := hex.DecodeString(h)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return "", 0, false
+	}
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		binary.BigEndian.PutUint32(ip[i:], binary.LittleEndian.Uint32(raw[i:]))
+	}
+	return ip.String(), int(port), true
+}
+
+type socketOwner struct {
+	pid  int
+	name string
+}
+
+// socketOwners maps socket inodes to the first process holding them open.
+func socketOwners(ctx context.Context) map[uint64]socketOwner {
+	out := map[uint64]socketOwner{}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			return out
+		}
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		fdDir := filepath.Join("/proc", e.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		var name string
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(target, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(target[len("socket:["):], "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, seen := out[inode]; seen {
+				continue
+			}
+			if name == "" {
+				if b, err := os.ReadFile(filepath.Join("/proc", e.Name(), "comm")); err == nil {
+					name = strings.TrimSpace(string(b))
+				}
+			}
+			out[inode] = socketOwner{pid: pid, name: name}
+		}
+	}
+	return out
+}
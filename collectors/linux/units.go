@@ -0,0 +1,187 @@
+package linux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// unitDirs are the system unit search paths in systemd's precedence order.
+var unitDirs = []string{
+	"/etc/systemd/system",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+var unitSuffixes = []string{".service", ".socket", ".timer", ".path", ".mount", ".target"}
+
+// Unit is one line of systemd/units.jsonl. WantedBy lists the targets whose
+// .wants or .requires directories link the unit, which is how systemctl
+// enable records it.
+type Unit struct {
+	Name      string   `json:"name"`
+	Path      string   `json:"path"`
+	SHA256    string   `json:"sha256,omitempty"`
+	ModTime   string   `json:"mod_time,omitempty"`
+	Enabled   bool     `json:"enabled"`
+	Masked    bool     `json:"masked,omitempty"`
+	WantedBy  []string `json:"wanted_by,omitempty"`
+	ExecStart []string `json:"exec_start,omitempty"`
+}
+
+// UnitsCollector inventories systemd unit files from disk, so it also works
+// on hosts where systemd is not running.
+type UnitsCollector struct{}
+
+func NewUnitsCollector() *UnitsCollector { return &UnitsCollector{} }
+
+func (c *UnitsCollector) Name() string { return "units" }
+
+func (c *UnitsCollector) Collect(ctx context.Context, rc collectors.RunContext) ([]collectors.Artifact, error) {
+	units := map[string]*Unit{}
+	seenDirs := map[string]bool{}
+	var wants [][2]string
+	for _, dir := range unitDirs {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil || seenDirs[real] {
+			continue
+		}
+		seenDirs[real] = true
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			path := filepath.Join(dir, name)
+			if e.IsDir() {
+				target, ok := strings.CutSuffix(name, ".wants")
+				if !ok {
+					target, ok = strings.CutSuffix(name, ".requires")
+				}
+				if !ok {
+					continue
+				}
+				links, _ := os.ReadDir(path)
+				for _, l := range links {
+					wants = append(wants, [2]string{l.Name(), target})
+				}
+				continue
+			}
+			if !hasUnitSuffix(name) || units[name] != nil {
+				continue
+			}
+			units[name] = readUnit(name, path)
+		}
+	}
+	if len(seenDirs) == 0 {
+		return nil, errors.New("no systemd unit directories found")
+	}
+	for _, w := range wants {
+		u, ok := units[w[0]]
+		if !ok {
+			// getty@tty1.service is an instance of getty@.service.
+			at, dot := strings.IndexByte(w[0], '@'), strings.LastIndexByte(w[0], '.')
+			if at > 0 && dot > at {
+				u, ok = units[w[0][:at+1]+w[0][dot:]]
+			}
+		}
+		if ok {
+			u.Enabled = true
+			u.WantedBy = append(u.WantedBy, w[1])
+		}
+	}
+
+	names := make([]string, 0, len(units))
+	for n := range units {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enabled := 0
+	for _, n := range names {
+		u := units[n]
+		sort.Strings(u.WantedBy)
+		if u.Enabled {
+			enabled++
+		}
+		if err := enc.Encode(u); err != nil {
+			return nil, err
+		}
+	}
+	rel := filepath.ToSlash(filepath.Join("systemd", "units.jsonl"))
+	out := filepath.Join(rc.OutputDir, rel)
+	if err := evidence.WriteFileAtomic(out, buf.Bytes(), 0o600); err != nil {
+		return nil, err
+	}
+	sha, size, err := evidence.SHA256File(out)
+	if err != nil {
+		return nil, err
+	}
+	return []collectors.Artifact{{
+		RelativePath: rel,
+		Collector:    c.Name(),
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata: map[string]string{
+			"units":   intToString(len(names)),
+			"enabled": intToString(enabled),
+		},
+	}}, nil
+}
+
+func hasUnitSuffix(name string) bool {
+	for _, s := range unitSuffixes {
+		if strings.HasSuffix(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func readUnit(name, path string) *Unit {
+	u := &Unit{Name: name, Path: path}
+	if target, err := os.Readlink(path); err == nil && target == "/dev/null" {
+		u.Masked = true
+		return u
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return u
+	}
+	u.ModTime = info.ModTime().UTC().Format(time.RFC3339Nano)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return u
+	}
+	sum := sha256.Sum256(b)
+	u.SHA256 = hex.EncodeToString(sum[:])
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := strings.CutPrefix(line, "ExecStart="); ok && v != "" {
+			u.ExecStart = append(u.ExecStart, v)
+		}
+	}
+	return u
+}
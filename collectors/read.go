@@ -0,0 +1,65 @@
+package collectors
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// MaxLine is the longest line ReadLines accepts. Longer lines are an error
+// rather than the silent end of the artifact.
+const MaxLine = 16 * 1024 * 1024
+
+// Latest returns the newest artifact called name written by collector.
+// Artifacts recorded without a hash are ignored.
+func Latest(artifacts []Artifact, collector, name string) (Artifact, bool) {
+	for i := len(artifacts) - 1; i >= 0; i-- {
+		a := artifacts[i]
+		if a.Collector == collector && path.Base(a.RelativePath) == name && a.SHA256 != "" {
+			return a, true
+		}
+	}
+	return Artifact{}, false
+}
+
+// ReadLines calls fn for every non-blank line of artifact a in the case
+// directory dir. It stops at the first error of fn and returns it; read
+// errors, including a line longer than MaxLine, and a cancelled ctx are
+// returned too.
+func ReadLines(ctx context.Context, dir string, a Artifact, fn func(line []byte) error) error {
+	f, err := os.Open(filepath.Join(dir, filepath.FromSlash(a.RelativePath)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), MaxLine)
+	for s.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		line := s.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
+
+// ReadLatest runs ReadLines on the newest artifact called name written by
+// collector. It returns the artifact it read, or false when the case has
+// none.
+func ReadLatest(ctx context.Context, dir string, artifacts []Artifact, collector, name string, fn func(line []byte) error) (Artifact, bool, error) {
+	a, ok := Latest(artifacts, collector, name)
+	if !ok {
+		return Artifact{}, false, nil
+	}
+	return a, true, ReadLines(ctx, dir, a, fn)
+}
@@ -3,8 +3,10 @@ package system
 import (
 	"context"
 	"encoding/json"
+	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"iron-sentinel/collectors"
@@ -24,6 +26,29 @@ func (c *HostInfoCollector) Collect(ctx context.Context, rc collectors.RunContex
 		"goos":   runtime.GOOS,
 		"goarch": runtime.GOARCH,
 	}
+	if h, err := os.Hostname(); err == nil {
+		data["hostname"] = h
+	}
+	for key, src := range map[string]string{
+		"kernel":         "/proc/sys/kernel/osrelease",
+		"kernel_version": "/proc/sys/kernel/version",
+		"machine_id":     "/etc/machine-id",
+		"boot_id":        "/proc/sys/kernel/random/boot_id",
+		"timezone":       "/etc/timezone",
+	} {
+		if b, err := os.ReadFile(src); err == nil {
+			if v := strings.TrimSpace(string(b)); v != "" {
+				data[key] = v
+			}
+		}
+	}
+	if _, ok := data["timezone"]; !ok {
+		if target, err := os.Readlink("/etc/localtime"); err == nil {
+			if _, zone, ok := strings.Cut(target, "zoneinfo/"); ok {
+				data["timezone"] = zone
+			}
+		}
+	}
 	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return nil, err
@@ -0,0 +1,334 @@
+package analyze
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+	"iron-sentinel/findings"
+	"iron-sentinel/version"
+)
+
+type Case struct {
+	Dir      string
+	Manifest evidence.Manifest
+	run      *evidence.AnalysisRun
+	pending  []findings.Finding
+}
+
+type Analyzer interface {
+	Name() string
+	Analyze(ctx context.Context, c *Case) error
+}
+
+type Options struct {
+	CaseID     string
+	StartedAt  time.Time
+	IOCFile    string
+	YARARules  []string
+	YARAPaths  []string
+	SigmaRules []string
+	// DiffAgainst is the case directory the diff analyzer compares with.
+	DiffAgainst string
+	// Baseline is a baseline file the baseline analyzer reports drift from.
+	Baseline string
+	// Live is set when analyzing on the host the case was collected from,
+	// which lets the ELF analyzer read the binaries the case refers to and
+	// the miner analyzer read configuration next to suspect processes.
+	Live bool
+	// WebRoots are document roots for the webshell analyzer in addition to
+	// the defaults and those in nginx and Apache configuration.
+	WebRoots []string
+	Workers  int
+	// IOCMaxMatches caps the IOC matches recorded per artifact; see
+	// ioc.Options.MaxMatches.
+	IOCMaxMatches int
+}
+
+func Available() []string {
+	return []string{"ioc", "yara", "sigma", "diff", "baseline", "persistence", "process", "miner", "elf", "filetype", "ransomware", "webshell", "timeline"}
+}
+
+// Defaults picks the analyzers to run when none are requested explicitly.
+// IOC, YARA and Sigma scanning, case diffs and baseline drift need their
+// inputs, so they only run when given; the persistence, process, miner,
+// ELF, file type, ransomware and webshell analyzers and the timeline always
+// run.
+func Defaults(opts Options) []string {
+	var names []string
+	if opts.IOCFile != "" {
+		names = append(names, "ioc")
+	}
+	if len(opts.YARARules) > 0 {
+		names = append(names, "yara")
+	}
+	if len(opts.SigmaRules) > 0 {
+		names = append(names, "sigma")
+	}
+	if opts.DiffAgainst != "" {
+		names = append(names, "diff")
+	}
+	if opts.Baseline != "" {
+		names = append(names, "baseline")
+	}
+	return append(names, "persistence", "process", "miner", "elf", "filetype", "ransomware", "webshell", "timeline")
+}
+
+func Build(names []string, opts Options) ([]Analyzer, error) {
+	var out []Analyzer
+	for _, n := range names {
+		switch n {
+		case "ioc":
+			if opts.IOCFile == "" {
+				return nil, fmt.Errorf("analyzer %q requires an IOC file", n)
+			}
+			out = append(out, &iocAnalyzer{iocFile: opts.IOCFile, maxMatches: opts.IOCMaxMatches, workers: opts.Workers})
+		case "yara":
+			if len(opts.YARARules) == 0 {
+				return nil, fmt.Errorf("analyzer %q requires YARA rules", n)
+			}
+			out = append(out, &yaraAnalyzer{rules: opts.YARARules, paths: opts.YARAPaths, workers: opts.Workers})
+		case "sigma":
+			if len(opts.SigmaRules) == 0 {
+				return nil, fmt.Errorf("analyzer %q requires Sigma rules", n)
+			}
+			out = append(out, &sigmaAnalyzer{rules: opts.SigmaRules})
+		case "diff":
+			if opts.DiffAgainst == "" {
+				return nil, fmt.Errorf("analyzer %q requires a case to compare against", n)
+			}
+			out = append(out, &diffAnalyzer{against: opts.DiffAgainst})
+		case "baseline":
+			if opts.Baseline == "" {
+				return nil, fmt.Errorf("analyzer %q requires a baseline file", n)
+			}
+			out = append(out, &baselineAnalyzer{path: opts.Baseline})
+		case "persistence":
+			out = append(out, &persistenceAnalyzer{})
+		case "process":
+			out = append(out, &processAnalyzer{})
+		case "miner":
+			out = append(out, &minerAnalyzer{live: opts.Live})
+		case "elf":
+			out = append(out, &elfAnalyzer{live: opts.Live, workers: opts.Workers})
+		case "filetype":
+			out = append(out, &filetypeAnalyzer{})
+		case "ransomware":
+			out = append(out, &ransomwareAnalyzer{})
+		case "webshell":
+			out = append(out, &webshellAnalyzer{live: opts.Live, roots: opts.WebRoots, workers: opts.Workers})
+		case "timeline":
+			out = append(out, &timelineAnalyzer{caseID: opts.CaseID, startedAt: opts.StartedAt})
+		default:
+			return nil, fmt.Errorf("unknown analyzer %q (available: %s)", n, strings.Join(Available(), ", "))
+		}
+	}
+	return out, nil
+}
+
+func Open(dir string) (*Case, error) {
+	m, err := evidence.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Case{Dir: dir, Manifest: m}, nil
+}
+
+// Run executes analyzers against the case and records the run in the
+// manifest. Analyzer failures are recorded on the run rather than aborting the
+// remaining analyzers; the caller is responsible for writing the manifest.
+func Run(ctx context.Context, c *Case, analyzers []Analyzer, options map[string]string) (evidence.AnalysisRun, error) {
+	run := evidence.AnalysisRun{
+		ID:        fmt.Sprintf("%d", len(c.Manifest.AnalysisRuns)+1),
+		StartedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Options:   options,
+	}
+	c.run = &run
+	c.pending = nil
+	defer func() { c.run, c.pending = nil, nil }()
+
+	if err := os.MkdirAll(filepath.Join(c.Dir, "analysis"), 0o755); err != nil {
+		return run, err
+	}
+
+	for _, a := range analyzers {
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		default:
+		}
+
+		run.Analyzers = append(run.Analyzers, a.Name())
+		if err := a.Analyze(ctx, c); err != nil {
+			if run.Errors == nil {
+				run.Errors = map[string]string{}
+			}
+			run.Errors[a.Name()] = err.Error()
+		}
+	}
+
+	if err := c.writeFindings(); err != nil {
+		if run.Errors == nil {
+			run.Errors = map[string]string{}
+		}
+		run.Errors["findings"] = err.Error()
+	}
+
+	run.FinishedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	detail := map[string]string{"run": run.ID, "analyzers": strings.Join(run.Analyzers, ","), "mode": options["mode"]}
+	if len(run.Errors) > 0 {
+		detail["errors"] = fmt.Sprintf("%d", len(run.Errors))
+	}
+	if _, err := evidence.AppendCustody(c.Dir, evidence.CustodyAnalyze, evidence.LocalActor(version.Tool), detail); err != nil {
+		if run.Errors == nil {
+			run.Errors = map[string]string{}
+		}
+		run.Errors["custody"] = err.Error()
+	}
+	c.Manifest.AnalysisRuns = append(c.Manifest.AnalysisRuns, run)
+	return run, nil
+}
+
+// AddFindings queues findings for the run's findings.jsonl.
+func (c *Case) AddFindings(fs ...findings.Finding) {
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+	for _, f := range fs {
+		if f.ID == "" {
+			f.SetID()
+		}
+		if c.run != nil {
+			f.AnalysisRun = c.run.ID
+		}
+		f.CreatedAt = now
+		c.pending = append(c.pending, f)
+	}
+}
+
+// writeFindings writes the case's current findings: those from this run plus
+// earlier findings of analyzers that did not run successfully this time, so
+// the latest findings.jsonl always holds the complete set.
+func (c *Case) writeFindings() error {
+	rerun := map[string]bool{}
+	for _, name := range c.run.Analyzers {
+		if _, failed := c.run.Errors[name]; !failed {
+			rerun[name] = true
+		}
+	}
+	all, err := c.Findings()
+	if err != nil {
+		return err
+	}
+	var out []findings.Finding
+	for _, f := range all {
+		if !rerun[f.Analyzer] {
+			out = append(out, f)
+		}
+	}
+	out = append(out, c.pending...)
+	findings.Sort(out)
+
+	rel, version := c.OutputPath("findings.jsonl")
+	if err := findings.WriteJSONL(c.Path(rel), out); err != nil {
+		return err
+	}
+	c.SetMetadata("findings", fmt.Sprintf("%d", len(out)))
+	return c.AddArtifact(rel, "findings", version, map[string]string{
+		"findings": fmt.Sprintf("%d", len(out)),
+		"new":      fmt.Sprintf("%d", len(c.pending)),
+	})
+}
+
+// Findings reads the most recent findings.jsonl of the case, if any.
+func (c *Case) Findings() ([]findings.Finding, error) {
+	a, ok := c.Manifest.Latest("findings")
+	if !ok {
+		return nil, nil
+	}
+	return findings.ReadJSONL(c.Path(a.RelativePath))
+}
+
+// Collected returns the artifacts produced by collectors, skipping analysis
+// output and collector error placeholders.
+func (c *Case) Collected() []collectors.Artifact {
+	var out []collectors.Artifact
+	for _, a := range c.Manifest.Artifacts {
+		if strings.HasPrefix(a.RelativePath, "analysis/") || a.SHA256 == "" {
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// OutputPath returns a fresh relative path under analysis/ for name. Earlier
+// results are never overwritten: the second ioc_scan.json becomes
+// ioc_scan.2.json, and so on.
+func (c *Case) OutputPath(name string) (string, int) {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for v := 1; ; v++ {
+		file := name
+		if v > 1 {
+			file = fmt.Sprintf("%s.%d%s", base, v, ext)
+		}
+		rel := filepath.ToSlash(filepath.Join("analysis", file))
+		if c.hasArtifact(rel) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(c.Dir, filepath.FromSlash(rel))); err == nil {
+			continue
+		}
+		return rel, v
+	}
+}
+
+func (c *Case) hasArtifact(rel string) bool {
+	for _, a := range c.Manifest.Artifacts {
+		if a.RelativePath == rel {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Case) Path(rel string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(rel))
+}
+
+// AddArtifact hashes an analysis output and appends it to the manifest.
+func (c *Case) AddArtifact(rel string, collector string, version int, metadata map[string]string) error {
+	sha, size, err := evidence.SHA256File(c.Path(rel))
+	if err != nil {
+		return err
+	}
+	md := map[string]string{}
+	for k, v := range metadata {
+		md[k] = v
+	}
+	md["version"] = fmt.Sprintf("%d", version)
+	if c.run != nil {
+		md["analysis_run"] = c.run.ID
+		c.run.Artifacts = append(c.run.Artifacts, rel)
+	}
+	c.Manifest.Artifacts = append(c.Manifest.Artifacts, collectors.Artifact{
+		RelativePath: rel,
+		Collector:    collector,
+		CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
+		SizeBytes:    size,
+		SHA256:       sha,
+		Metadata:     md,
+	})
+	return nil
+}
+
+func (c *Case) SetMetadata(key string, value string) {
+	if c.Manifest.Metadata == nil {
+		c.Manifest.Metadata = map[string]string{}
+	}
+	c.Manifest.Metadata[key] = value
+}
@@ -0,0 +1,377 @@
+package analyze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/diff"
+	"iron-sentinel/analyzers/elfscan"
+	"iron-sentinel/analyzers/filetype"
+	"iron-sentinel/analyzers/ioc"
+	"iron-sentinel/analyzers/miner"
+	"iron-sentinel/analyzers/persistence"
+	"iron-sentinel/analyzers/process"
+	"iron-sentinel/analyzers/ransomware"
+	"iron-sentinel/analyzers/sigma"
+	"iron-sentinel/analyzers/timeline"
+	"iron-sentinel/analyzers/webshell"
+	"iron-sentinel/analyzers/yara"
+	"iron-sentinel/core/internal/baseline"
+	"iron-sentinel/evidence"
+	"iron-sentinel/findings"
+)
+
+// output is what an analyzer run leaves in the case: a JSON document under
+// analysis/, a counter in the manifest metadata and its findings.
+type output struct {
+	file     string
+	kind     string
+	value    interface{}
+	counter  string
+	count    int
+	findings []findings.Finding
+	metadata map[string]string
+}
+
+// save writes o.value to a fresh analysis/ path and records it, its counter
+// and its findings in the manifest.
+func (c *Case) save(o output) error {
+	b, err := json.MarshalIndent(o.value, "", "  ")
+	if err != nil {
+		return err
+	}
+	rel, version := c.OutputPath(o.file)
+	if err := os.WriteFile(c.Path(rel), b, 0o600); err != nil {
+		return err
+	}
+	c.SetMetadata(o.counter, fmt.Sprintf("%d", o.count))
+	c.AddFindings(o.findings...)
+	return c.AddArtifact(rel, o.kind, version, o.metadata)
+}
+
+type iocAnalyzer struct {
+	iocFile    string
+	maxMatches int
+	workers    int
+}
+
+func (a *iocAnalyzer) Name() string { return "ioc" }
+
+func (a *iocAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := ioc.ScanArtifacts(ctx, c.Dir, c.Collected(), ioc.Options{IOCFile: a.iocFile, Workers: a.workers, MaxMatches: a.maxMatches})
+	if err != nil {
+		return err
+	}
+	c.SetMetadata("ioc_rejected", fmt.Sprintf("%d", len(res.Rejected)))
+	return c.save(output{
+		file:     "ioc_scan.json",
+		kind:     "ioc_scan",
+		value:    res,
+		counter:  "ioc_matches",
+		count:    len(res.Matches),
+		findings: ioc.Findings(res),
+		metadata: map[string]string{"ioc_file": a.iocFile},
+	})
+}
+
+type yaraAnalyzer struct {
+	rules   []string
+	paths   []string
+	workers int
+}
+
+func (a *yaraAnalyzer) Name() string { return "yara" }
+
+func (a *yaraAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := yara.ScanArtifacts(ctx, c.Dir, c.Collected(), yara.Options{RuleFiles: a.rules, Paths: a.paths, Workers: a.workers})
+	if err != nil {
+		return err
+	}
+	md := map[string]string{"rules": strings.Join(a.rules, ",")}
+	if len(a.paths) > 0 {
+		md["live_paths"] = strings.Join(a.paths, ",")
+	}
+	return c.save(output{
+		file:     "yara_scan.json",
+		kind:     "yara_scan",
+		value:    res,
+		counter:  "yara_matches",
+		count:    len(res.Matches),
+		findings: yara.Findings(res),
+		metadata: md,
+	})
+}
+
+type sigmaAnalyzer struct {
+	rules []string
+}
+
+func (a *sigmaAnalyzer) Name() string { return "sigma" }
+
+func (a *sigmaAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := sigma.ScanArtifacts(ctx, c.Dir, c.Collected(), sigma.Options{RuleFiles: a.rules})
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "sigma_scan.json",
+		kind:     "sigma_scan",
+		value:    res,
+		counter:  "sigma_matches",
+		count:    len(res.Matches),
+		findings: sigma.Findings(res),
+		metadata: map[string]string{"rules": strings.Join(a.rules, ",")},
+	})
+}
+
+type diffAnalyzer struct {
+	against string
+}
+
+func (a *diffAnalyzer) Name() string { return "diff" }
+
+func (a *diffAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	m, err := evidence.ReadManifest(a.against)
+	if err != nil {
+		return err
+	}
+	before, err := diff.Load(ctx, a.against, m.Artifacts)
+	if err != nil {
+		return err
+	}
+	before.CaseID, before.CreatedAt = m.CaseID, m.CreatedAt
+	after, err := diff.Load(ctx, c.Dir, c.Collected())
+	if err != nil {
+		return err
+	}
+	after.CaseID, after.CreatedAt = c.Manifest.CaseID, c.Manifest.CreatedAt
+
+	rep := diff.Compare(before, after)
+	return c.save(output{
+		file:     "diff.json",
+		kind:     "diff",
+		value:    rep,
+		counter:  "diff_changes",
+		count:    len(rep.Changes),
+		findings: diff.Findings(rep),
+		metadata: map[string]string{"against": a.against, "against_case": m.CaseID},
+	})
+}
+
+type baselineAnalyzer struct {
+	path string
+}
+
+func (a *baselineAnalyzer) Name() string { return "baseline" }
+
+func (a *baselineAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	bf, err := baseline.Read(a.path)
+	if err != nil {
+		return err
+	}
+	before := bf.State
+	before.Source, before.CaseID, before.CreatedAt = a.path, "", bf.CreatedAt
+	after, err := diff.Load(ctx, c.Dir, c.Collected())
+	if err != nil {
+		return err
+	}
+	after.CaseID, after.CreatedAt = c.Manifest.CaseID, c.Manifest.CreatedAt
+
+	rep := diff.Compare(before, after)
+	return c.save(output{
+		file:     "drift.json",
+		kind:     "drift",
+		value:    rep,
+		counter:  "drift_changes",
+		count:    len(rep.Changes),
+		findings: diff.DriftFindings(rep),
+		metadata: map[string]string{"baseline": a.path, "baseline_name": bf.Name},
+	})
+}
+
+type persistenceAnalyzer struct{}
+
+func (a *persistenceAnalyzer) Name() string { return "persistence" }
+
+func (a *persistenceAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	collected, err := time.Parse(time.RFC3339Nano, c.Manifest.CreatedAt)
+	if err != nil {
+		collected = time.Now().UTC()
+	}
+	res, err := persistence.Scan(ctx, c.Dir, c.Collected(), collected)
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "persistence_scan.json",
+		kind:     "persistence_scan",
+		value:    res,
+		counter:  "persistence_hits",
+		count:    len(res.Hits),
+		findings: persistence.Findings(res),
+	})
+}
+
+type processAnalyzer struct{}
+
+func (a *processAnalyzer) Name() string { return "process" }
+
+func (a *processAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := process.Scan(ctx, c.Dir, c.Collected())
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "process_scan.json",
+		kind:     "process_scan",
+		value:    res,
+		counter:  "process_anomalies",
+		count:    len(res.Anomalies),
+		findings: process.Findings(res),
+	})
+}
+
+type minerAnalyzer struct {
+	live bool
+}
+
+func (a *minerAnalyzer) Name() string { return "miner" }
+
+func (a *minerAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := miner.Scan(ctx, c.Dir, c.Collected(), miner.Options{Live: a.live})
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "miner_scan.json",
+		kind:     "miner_scan",
+		value:    res,
+		counter:  "miners",
+		count:    len(res.Miners),
+		findings: miner.Findings(res),
+		metadata: map[string]string{"live": fmt.Sprintf("%t", a.live)},
+	})
+}
+
+type filetypeAnalyzer struct{}
+
+func (a *filetypeAnalyzer) Name() string { return "filetype" }
+
+func (a *filetypeAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := filetype.Scan(ctx, c.Dir, c.Collected())
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "filetype_scan.json",
+		kind:     "filetype_scan",
+		value:    res,
+		counter:  "filetype_hits",
+		count:    len(res.Hits),
+		findings: filetype.Findings(res),
+	})
+}
+
+type ransomwareAnalyzer struct{}
+
+func (a *ransomwareAnalyzer) Name() string { return "ransomware" }
+
+func (a *ransomwareAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := ransomware.Scan(ctx, c.Dir, c.Collected())
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "ransomware.json",
+		kind:     "ransomware",
+		value:    res,
+		counter:  "ransomware_encrypted_files",
+		count:    res.Encrypted,
+		findings: ransomware.Findings(res),
+		metadata: map[string]string{"detected": fmt.Sprintf("%t", res.Detected)},
+	})
+}
+
+type elfAnalyzer struct {
+	live    bool
+	workers int
+}
+
+func (a *elfAnalyzer) Name() string { return "elf" }
+
+func (a *elfAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := elfscan.ScanArtifacts(ctx, c.Dir, c.Collected(), elfscan.Options{Live: a.live, Workers: a.workers})
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "elf_scan.json",
+		kind:     "elf_scan",
+		value:    res,
+		counter:  "elf_binaries",
+		count:    len(res.Binaries),
+		findings: elfscan.Findings(res),
+		metadata: map[string]string{"live": fmt.Sprintf("%t", a.live)},
+	})
+}
+
+type webshellAnalyzer struct {
+	live    bool
+	roots   []string
+	workers int
+}
+
+func (a *webshellAnalyzer) Name() string { return "webshell" }
+
+func (a *webshellAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	res, err := webshell.ScanArtifacts(ctx, c.Dir, c.Collected(), webshell.Options{Live: a.live, Roots: a.roots, Workers: a.workers})
+	if err != nil {
+		return err
+	}
+	return c.save(output{
+		file:     "webshell_scan.json",
+		kind:     "webshell_scan",
+		value:    res,
+		counter:  "webshell_candidates",
+		count:    len(res.Files),
+		findings: webshell.Findings(res),
+		metadata: map[string]string{"live": fmt.Sprintf("%t", a.live), "roots": strings.Join(res.Roots, ",")},
+	})
+}
+
+type timelineAnalyzer struct {
+	caseID    string
+	startedAt time.Time
+}
+
+func (a *timelineAnalyzer) Name() string { return "timeline" }
+
+func (a *timelineAnalyzer) Analyze(ctx context.Context, c *Case) error {
+	caseID := a.caseID
+	if caseID == "" {
+		caseID = c.Manifest.CaseID
+	}
+	opts := timeline.Options{CaseID: caseID, StartedAt: a.startedAt}
+	if opts.StartedAt.IsZero() {
+		// Re-analysis of an existing case: bracket the timeline with the
+		// original collection window rather than the time of this run.
+		for _, art := range c.Collected() {
+			t, err := time.Parse(time.RFC3339Nano, art.CollectedAt)
+			if err == nil && (opts.StartedAt.IsZero() || t.Before(opts.StartedAt)) {
+				opts.StartedAt = t
+			}
+		}
+		opts.FinishedAt, _ = time.Parse(time.RFC3339Nano, c.Manifest.CreatedAt)
+	}
+
+	rel, version := c.OutputPath("timeline.jsonl")
+	opts.RelativePath = rel
+	if _, err := timeline.WriteJSONL(ctx, c.Dir, c.Collected(), opts); err != nil {
+		return err
+	}
+	return c.AddArtifact(rel, "timeline", version, nil)
+}
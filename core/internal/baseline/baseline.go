@@ -0,0 +1,160 @@
+package baseline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"iron-sentinel/analyzers/diff"
+	"iron-sentinel/collectors"
+	"iron-sentinel/collectors/linux"
+	"iron-sentinel/collectors/system"
+	"iron-sentinel/evidence"
+)
+
+const Format = "iron-sentinel-baseline/1"
+
+// DefaultPaths are hashed when no paths are given: configuration, binaries
+// and the boot partition, which a golden image should never change.
+var DefaultPaths = []string{
+	"/etc",
+	"/bin",
+	"/sbin",
+	"/usr/bin",
+	"/usr/sbin",
+	"/usr/local/bin",
+	"/usr/local/sbin",
+	"/boot",
+}
+
+// File is a known-good profile of a host. Processes are left out: they are
+// too volatile to drift against.
+type File struct {
+	Format    string            `json:"format"`
+	Name      string            `json:"name,omitempty"`
+	CreatedAt string            `json:"created_at"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	State     diff.State        `json:"state"`
+}
+
+type Options struct {
+	Name string
+	// FromCase builds the baseline from an existing case instead of the
+	// live host.
+	FromCase     string
+	Paths        []string
+	MaxFileBytes int64
+	MaxFiles     int
+}
+
+func Create(ctx context.Context, opts Options) (File, error) {
+	f := File{Format: Format, Name: opts.Name}
+
+	if opts.FromCase != "" {
+		m, err := evidence.ReadManifest(opts.FromCase)
+		if err != nil {
+			return File{}, err
+		}
+		s, err := diff.Load(ctx, opts.FromCase, m.Artifacts)
+		if err != nil {
+			return File{}, err
+		}
+		s.CaseID, s.CreatedAt = m.CaseID, m.CreatedAt
+		f.State = s
+	} else {
+		tmp, err := os.MkdirTemp("", "iron-sentinel-baseline-")
+		if err != nil {
+			return File{}, err
+		}
+		defer os.RemoveAll(tmp)
+
+		paths := opts.Paths
+		if len(paths) == 0 {
+			paths = DefaultPaths
+		}
+		maxFiles := opts.MaxFiles
+		if maxFiles <= 0 {
+			maxFiles = 200000
+		}
+		cols := []collectors.Collector{
+			system.NewHostInfoCollector(),
+			linux.NewAccountsCollector(),
+			linux.NewSocketsCollector(),
+			linux.NewPersistenceCollector(),
+			linux.NewUnitsCollector(),
+			linux.NewPackagesCollector(),
+			linux.NewFilesystemSnapshotCollector(linux.SnapshotOptions{
+				Paths:        existing(paths),
+				Mode:         linux.SnapshotMetadataOnly,
+				HashFiles:    true,
+				MaxFileBytes: opts.MaxFileBytes,
+				MaxFiles:     maxFiles,
+			}),
+		}
+		rc := collectors.RunContext{CaseID: "baseline", OutputDir: tmp}
+		var artifacts []collectors.Artifact
+		for _, c := range cols {
+			arts, err := c.Collect(ctx, rc)
+			if err != nil {
+				if ctx.Err() != nil {
+					return File{}, ctx.Err()
+				}
+				if f.Errors == nil {
+					f.Errors = map[string]string{}
+				}
+				f.Errors[c.Name()] = err.Error()
+				continue
+			}
+			artifacts = append(artifacts, arts...)
+		}
+		s, err := diff.Load(ctx, tmp, artifacts)
+		if err != nil {
+			return File{}, err
+		}
+		s.Source = "live"
+		if s.Hostname != "" {
+			s.Source = "live:" + s.Hostname
+		}
+		f.State = s
+	}
+
+	f.State.Processes = nil
+	delete(f.State.Artifacts, diff.KindProcess)
+	f.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	return f, nil
+}
+
+func existing(paths []string) []string {
+	var out []string
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err == nil {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func Write(path string, f File) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return evidence.WriteFileAtomic(path, b, 0o600)
+}
+
+func Read(path string) (File, error) {
+	var f File
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Format != Format {
+		return f, fmt.Errorf("%s: not a baseline file (format %q)", path, f.Format)
+	}
+	return f, nil
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/analyzers/ioc"
+	"iron-sentinel/core/internal/analyze"
+)
+
+func NewAnalyzeCmd() *cobra.Command {
+	var names []string
+	var iocFile string
+	var iocMaxMatches int
+	var yaraRules []string
+	var sigmaRules []string
+	var diffAgainst string
+	var baselineFile string
+	var live bool
+	var webRoots []string
+	var workers int
+	var signKey string
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "analyze <case-dir>",
+		Short: "Re-run analyzers against an existing case",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			key, err := readSignKey(signKey)
+			if err != nil {
+				return err
+			}
+			c, err := analyze.Open(args[0])
+			if err != nil {
+				return err
+			}
+			if err := checkResign(c.Dir, key); err != nil {
+				return err
+			}
+
+			opts := analyze.Options{CaseID: c.Manifest.CaseID, IOCFile: iocFile, IOCMaxMatches: iocMaxMatches, YARARules: yaraRules, SigmaRules: sigmaRules, DiffAgainst: diffAgainst, Baseline: baselineFile, Live: live, WebRoots: webRoots, Workers: workers}
+			if len(names) == 0 {
+				names = analyze.Defaults(opts)
+			}
+			analyzers, err := analyze.Build(names, opts)
+			if err != nil {
+				return err
+			}
+
+			runOpts := map[string]string{"mode": "offline"}
+			if iocFile != "" {
+				runOpts["ioc_file"] = iocFile
+			}
+			if len(yaraRules) > 0 {
+				runOpts["yara_rules"] = strings.Join(yaraRules, ",")
+			}
+			if len(sigmaRules) > 0 {
+				runOpts["sigma_rules"] = strings.Join(sigmaRules, ",")
+			}
+			if diffAgainst != "" {
+				runOpts["diff_against"] = diffAgainst
+			}
+			if baselineFile != "" {
+				runOpts["baseline"] = baselineFile
+			}
+			if live {
+				runOpts["live"] = "true"
+			}
+			if len(webRoots) > 0 {
+				runOpts["web_roots"] = strings.Join(webRoots, ",")
+			}
+			run, err := analyze.Run(ctx, c, analyzers, runOpts)
+			if err != nil {
+				return err
+			}
+			if err := writeManifest(c.Dir, c.Manifest, key); err != nil {
+				return err
+			}
+
+			fmt.Printf("case=%s run=%s analyzers=%s artifacts=%d\n", c.Manifest.CaseID, run.ID, strings.Join(run.Analyzers, ","), len(run.Artifacts))
+			for name, msg := range run.Errors {
+				fmt.Printf("error analyzer=%s: %s\n", name, msg)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&names, "analyzer", nil, "Analyzer to run (repeatable; available: "+strings.Join(analyze.Available(), ", ")+")")
+	cmd.Flags().StringVar(&iocFile, "ioc-file", "", "IOC list file for the ioc analyzer")
+	cmd.Flags().IntVar(&iocMaxMatches, "ioc-max-matches", ioc.DefaultMaxMatches, "Distinct IOC matches recorded per artifact (-1: unlimited)")
+	cmd.Flags().StringArrayVar(&yaraRules, "yara-rules", nil, "YARA rule file or directory for the yara analyzer (repeatable)")
+	cmd.Flags().StringArrayVar(&sigmaRules, "sigma-rules", nil, "Sigma rule file or directory for the sigma analyzer (repeatable)")
+	cmd.Flags().StringVar(&diffAgainst, "diff-against", "", "Earlier case directory for the diff analyzer")
+	cmd.Flags().StringVar(&baselineFile, "baseline", "", "Baseline file for the baseline analyzer")
+	cmd.Flags().BoolVar(&live, "live", false, "Case was collected on this host; let the elf, miner and webshell analyzers read files from it")
+	cmd.Flags().StringArrayVar(&webRoots, "web-root", nil, "Web root for the webshell analyzer (repeatable)")
+	cmd.Flags().IntVar(&workers, "workers", runtime.NumCPU(), "Artifacts to scan in parallel")
+	cmd.Flags().StringVar(&signKey, "sign-key", "", "Ed25519 private key to re-sign the updated manifest with")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "Overall analysis timeout")
+	return cmd
+}
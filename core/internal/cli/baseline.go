@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/analyzers/diff"
+	"iron-sentinel/core/internal/baseline"
+)
+
+func NewBaselineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Work with known-good host baselines",
+	}
+	cmd.AddCommand(newBaselineCreateCmd())
+	return cmd
+}
+
+func newBaselineCreateCmd() *cobra.Command {
+	var output string
+	var name string
+	var fromCase string
+	var paths []string
+	var maxFileBytes int64
+	var maxFiles int
+	var timeout time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Record files, packages, units, users and listening ports of this host (or a case) as a baseline",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := context.Background()
+			if timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeout)
+				defer cancel()
+			}
+
+			f, err := baseline.Create(ctx, baseline.Options{
+				Name:         name,
+				FromCase:     fromCase,
+				Paths:        paths,
+				MaxFileBytes: maxFileBytes,
+				MaxFiles:     maxFiles,
+			})
+			if err != nil {
+				return err
+			}
+			if err := baseline.Write(output, f); err != nil {
+				return err
+			}
+
+			s := f.State
+			fmt.Printf("baseline=%s source=%s files=%d packages=%d units=%d users=%d listeners=%d\n",
+				output, s.Source, len(s.Files), len(s.Packages), len(s.Units), len(s.Users), len(s.Listeners))
+			for _, kind := range diff.Kinds {
+				if _, ok := s.Artifacts[kind]; !ok && kind != diff.KindProcess {
+					fmt.Printf("warning: %s not recorded\n", kind)
+				}
+			}
+			for c, msg := range f.Errors {
+				fmt.Printf("error collector=%s: %s\n", c, msg)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "baseline.json", "Baseline file to write")
+	cmd.Flags().StringVar(&name, "name", "", "Baseline name, e.g. the image it describes")
+	cmd.Flags().StringVar(&fromCase, "from-case", "", "Build the baseline from an existing case instead of this host")
+	cmd.Flags().StringArrayVar(&paths, "path", nil, "Path to hash into the baseline (repeatable; default: /etc, /bin, /sbin, /usr/bin, /usr/sbin, /usr/local/bin, /usr/local/sbin, /boot)")
+	cmd.Flags().Int64Var(&maxFileBytes, "max-file-bytes", 25*1024*1024, "Max single file size to hash")
+	cmd.Flags().IntVar(&maxFiles, "max-files", 200000, "Max number of filesystem entries to walk")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Minute, "Overall timeout")
+	return cmd
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/analyzers/diff"
+	"iron-sentinel/core/internal/analyze"
+)
+
+func NewDiffCmd() *cobra.Command {
+	var signKey string
+
+	cmd := &cobra.Command{
+		Use:   "diff <case-a> <case-b>",
+		Short: "Compare two collections of the same host and record the changes in the later case",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key, err := readSignKey(signKey)
+			if err != nil {
+				return err
+			}
+			c, err := analyze.Open(args[1])
+			if err != nil {
+				return err
+			}
+			if err := checkResign(c.Dir, key); err != nil {
+				return err
+			}
+			opts := analyze.Options{CaseID: c.Manifest.CaseID, DiffAgainst: args[0]}
+			analyzers, err := analyze.Build([]string{"diff"}, opts)
+			if err != nil {
+				return err
+			}
+			run, err := analyze.Run(context.Background(), c, analyzers, map[string]string{"mode": "diff", "diff_against": args[0]})
+			if err != nil {
+				return err
+			}
+			if msg, failed := run.Errors["diff"]; failed {
+				return fmt.Errorf("diff: %s", msg)
+			}
+			if err := writeManifest(c.Dir, c.Manifest, key); err != nil {
+				return err
+			}
+
+			a, ok := c.Manifest.Latest("diff")
+			if !ok {
+				return fmt.Errorf("diff report missing from manifest")
+			}
+			b, err := os.ReadFile(c.Path(a.RelativePath))
+			if err != nil {
+				return err
+			}
+			var rep diff.Report
+			if err := json.Unmarshal(b, &rep); err != nil {
+				return err
+			}
+
+			fmt.Printf("before=%s after=%s run=%s report=%s findings=%s\n", rep.Before.CaseID, rep.After.CaseID, run.ID, a.RelativePath, c.Manifest.Metadata["findings"])
+			for _, w := range rep.Warnings {
+				fmt.Printf("warning: %s\n", w)
+			}
+			for _, kind := range diff.Kinds {
+				if reason, ok := rep.Skipped[kind]; ok {
+					fmt.Printf("%-12s skipped (%s)\n", kind, reason)
+					continue
+				}
+				n := rep.Summary[kind]
+				fmt.Printf("%-12s added=%d removed=%d modified=%d\n", kind, n.Added, n.Removed, n.Modified)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&signKey, "sign-key", "", "Ed25519 private key to re-sign the updated manifest with")
+	return cmd
+}
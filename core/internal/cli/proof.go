@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/evidence"
+)
+
+func NewProofCmd() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "proof <case-dir> <relative-path>",
+		Short: "Export a Merkle inclusion proof for sharing one artifact of a case",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := evidence.ReadManifest(args[0])
+			if err != nil {
+				return err
+			}
+			p, err := m.Proof(args[1])
+			if err != nil {
+				return err
+			}
+			b, err := json.MarshalIndent(p, "", "  ")
+			if err != nil {
+				return err
+			}
+			b = append(b, '\n')
+			if output != "" && output != "-" {
+				if err := os.WriteFile(output, b, 0o600); err != nil {
+					return err
+				}
+			} else if _, err := os.Stdout.Write(b); err != nil {
+				return err
+			}
+			recordExport(args[0], map[string]string{"command": "proof", "artifact": args[1], "output": output})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&output, "output", "", "Output file (default: stdout)")
+	return cmd
+}
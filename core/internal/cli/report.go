@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/core/internal/report"
+	"iron-sentinel/evidence"
+)
+
+func NewReportCmd() *cobra.Command {
+	var format string
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "report <case-dir>",
+		Short: "Render a self-contained HTML or Markdown case report",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := report.Build(args[0])
+			if err != nil {
+				return err
+			}
+
+			err = writeOutput(output, func(w io.Writer) error {
+				return report.Render(w, d, format)
+			})
+			if err != nil {
+				return err
+			}
+			recordExport(args[0], map[string]string{"command": "report", "format": format, "output": output})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "html", "Report format ("+strings.Join(report.Formats, "|")+")")
+	cmd.Flags().StringVar(&output, "output", "", "Output file (default: stdout)")
+	return cmd
+}
+
+// writeOutput renders into memory first, so that a bad --format or a failed
+// render leaves an existing --output file alone, and then replaces the file
+// atomically or writes to stdout.
+func writeOutput(output string, render func(w io.Writer) error) error {
+	var buf bytes.Buffer
+	if err := render(&buf); err != nil {
+		return err
+	}
+	if output == "" || output == "-" {
+		_, err := os.Stdout.Write(buf.Bytes())
+		return err
+	}
+	return evidence.WriteFileAtomic(output, buf.Bytes(), 0o600)
+}
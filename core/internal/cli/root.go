@@ -6,7 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"iron-sentinel/core/internal/version"
+	"iron-sentinel/version"
 )
 
 func NewRootCmd() *cobra.Command {
@@ -18,6 +18,14 @@ func NewRootCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(NewTriageCmd())
+	cmd.AddCommand(NewAnalyzeCmd())
+	cmd.AddCommand(NewDiffCmd())
+	cmd.AddCommand(NewBaselineCmd())
+	cmd.AddCommand(NewTimelineCmd())
+	cmd.AddCommand(NewReportCmd())
+	cmd.AddCommand(NewVerifyCmd())
+	cmd.AddCommand(NewProofCmd())
+	cmd.AddCommand(NewKeysCmd())
 	cmd.AddCommand(NewServerCmd())
 	cmd.AddCommand(NewDeployAgentCmd())
 	cmd.AddCommand(NewInstallCmd())
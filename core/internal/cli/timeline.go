@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/analyzers/timeline"
+	"iron-sentinel/evidence"
+)
+
+func NewTimelineCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "timeline",
+		Short: "Work with case timelines",
+	}
+	cmd.AddCommand(newTimelineExportCmd())
+	return cmd
+}
+
+func newTimelineExportCmd() *cobra.Command {
+	var format string
+	var output string
+	var from string
+	var to string
+	var types []string
+
+	cmd := &cobra.Command{
+		Use:   "export <case-dir>",
+		Short: "Export a case timeline as bodyfile, mactime CSV, l2tcsv or Timesketch JSONL",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filter timeline.Filter
+			var err error
+			if filter.From, err = parseTimeFlag(from, false); err != nil {
+				return fmt.Errorf("--from: %w", err)
+			}
+			if filter.To, err = parseTimeFlag(to, true); err != nil {
+				return fmt.Errorf("--to: %w", err)
+			}
+			filter.Types = types
+
+			rel := filepath.ToSlash(filepath.Join("analysis", "timeline.jsonl"))
+			if m, err := evidence.ReadManifest(args[0]); err == nil {
+				if a, ok := m.Latest("timeline"); ok {
+					rel = a.RelativePath
+				}
+			}
+			events, err := timeline.ReadJSONL(filepath.Join(args[0], filepath.FromSlash(rel)))
+			if err != nil {
+				return err
+			}
+			events = filter.Apply(events)
+
+			err = writeOutput(output, func(w io.Writer) error {
+				return timeline.Export(w, events, format)
+			})
+			if err != nil {
+				return err
+			}
+			recordExport(args[0], map[string]string{"command": "timeline export", "format": format, "output": output, "events": fmt.Sprintf("%d", len(events))})
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&format, "format", "", "Export format ("+strings.Join(timeline.ExportFormats, "|")+")")
+	cmd.Flags().StringVar(&output, "output", "", "Output file (default: stdout)")
+	cmd.Flags().StringVar(&from, "from", "", "Only events at or after this time (RFC3339 or YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "Only events at or before this time (RFC3339, or YYYY-MM-DD for the whole day)")
+	cmd.Flags().StringArrayVar(&types, "type", nil, "Only events of this type (repeatable, e.g. file_macb, auth_log, login)")
+	_ = cmd.MarkFlagRequired("format")
+	return cmd
+}
+
+// parseTimeFlag parses an RFC3339 time or a date. A date means the start of
+// that day, or with endOfDay its last instant, so that --to 2024-05-01
+// includes all of May 1.
+func parseTimeFlag(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil || !endOfDay {
+		return t, err
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
@@ -3,11 +3,13 @@ package cli
 import (
 	"context"
 	"fmt"
+	"runtime"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
+	"iron-sentinel/analyzers/ioc"
 	"iron-sentinel/core/internal/triage"
 )
 
@@ -15,12 +17,24 @@ func NewTriageCmd() *cobra.Command {
 	var output string
 	var caseID string
 	var iocFile string
+	var iocMaxMatches int
+	var yaraRules []string
+	var yaraPaths []string
+	var sigmaRules []string
+	var baselineFile string
+	var webRoots []string
 	var snapshotPaths []string
 	var snapshotMode string
 	var snapshotHash bool
+	var snapshotClassify bool
 	var snapshotMaxFileBytes int64
 	var snapshotMaxTotalBytes int64
 	var snapshotMaxFiles int
+	var redactOn bool
+	var redactRules []string
+	var redactOriginals string
+	var signKey string
+	var workers int
 	var timeout time.Duration
 
 	cmd := &cobra.Command{
@@ -41,12 +55,24 @@ func NewTriageCmd() *cobra.Command {
 				CaseID:                caseID,
 				Output:                output,
 				IOCFile:               iocFile,
+				IOCMaxMatches:         iocMaxMatches,
+				YARARules:             yaraRules,
+				YARAPaths:             yaraPaths,
+				SigmaRules:            sigmaRules,
+				Baseline:              baselineFile,
+				WebRoots:              webRoots,
 				SnapshotPaths:         snapshotPaths,
 				SnapshotMode:          snapshotMode,
 				SnapshotHashFiles:     snapshotHash,
+				SnapshotClassify:      snapshotClassify,
 				SnapshotMaxFileBytes:  snapshotMaxFileBytes,
 				SnapshotMaxTotalBytes: snapshotMaxTotalBytes,
 				SnapshotMaxFiles:      snapshotMaxFiles,
+				Redact:                redactOn,
+				RedactRules:           redactRules,
+				RedactOriginals:       redactOriginals,
+				SignKey:               signKey,
+				Workers:               workers,
 				StartedAt:             time.Now().UTC(),
 			})
 			if err != nil {
@@ -59,13 +85,25 @@ func NewTriageCmd() *cobra.Command {
 
 	cmd.Flags().StringVar(&output, "output", "./evidence", "Evidence output directory")
 	cmd.Flags().StringVar(&caseID, "case-id", "", "Case ID (default: random UUID)")
-	cmd.Flags().StringVar(&iocFile, "ioc-file", "", "IOC list file (one pattern per line)")
+	cmd.Flags().StringVar(&iocFile, "ioc-file", "", "IOC file (text, one IOC per line, or JSON)")
+	cmd.Flags().IntVar(&iocMaxMatches, "ioc-max-matches", ioc.DefaultMaxMatches, "Distinct IOC matches recorded per artifact (-1: unlimited)")
+	cmd.Flags().StringArrayVar(&yaraRules, "yara-rules", nil, "YARA rule file or directory (repeatable)")
+	cmd.Flags().StringArrayVar(&yaraPaths, "yara-path", nil, "Live file or directory to scan with the YARA rules (repeatable)")
+	cmd.Flags().StringArrayVar(&sigmaRules, "sigma-rules", nil, "Sigma rule file or directory, searched recursively (repeatable)")
+	cmd.Flags().StringVar(&baselineFile, "baseline", "", "Baseline file to report drift from (snapshots the baseline's paths unless --snapshot-path is given)")
+	cmd.Flags().StringArrayVar(&webRoots, "web-root", nil, "Web root for the webshell analyzer in addition to the defaults and server configuration (repeatable)")
 	cmd.Flags().StringArrayVar(&snapshotPaths, "snapshot-path", nil, "Filesystem snapshot path (repeatable)")
 	cmd.Flags().StringVar(&snapshotMode, "snapshot-mode", "metadata", "Snapshot mode (metadata|copy)")
 	cmd.Flags().BoolVar(&snapshotHash, "snapshot-hash", false, "Hash regular files during snapshot (best-effort)")
+	cmd.Flags().BoolVar(&snapshotClassify, "snapshot-classify", false, "Record magic type and entropy of regular files during snapshot")
 	cmd.Flags().Int64Var(&snapshotMaxFileBytes, "snapshot-max-file-bytes", 25*1024*1024, "Max single file size to hash/copy")
 	cmd.Flags().Int64Var(&snapshotMaxTotalBytes, "snapshot-max-total-bytes", 250*1024*1024, "Max total bytes to copy into tar.gz (copy mode)")
 	cmd.Flags().IntVar(&snapshotMaxFiles, "snapshot-max-files", 20000, "Max number of filesystem entries to walk")
+	cmd.Flags().BoolVar(&redactOn, "redact", false, "Redact secrets (keys, tokens, passwords) from text artifacts before analysis")
+	cmd.Flags().StringArrayVar(&redactRules, "redact-rules", nil, "Custom redaction rule file, JSON or one \"name: regex\" per line (repeatable, implies --redact)")
+	cmd.Flags().StringVar(&redactOriginals, "redact-originals", "restrict", "What to do with originals of redacted artifacts (restrict|exclude)")
+	cmd.Flags().StringVar(&signKey, "sign-key", "", "Ed25519 private key to sign the manifest with (see iron-sentinel keys)")
+	cmd.Flags().IntVar(&workers, "workers", runtime.NumCPU(), "Artifacts to scan in parallel during analysis")
 	cmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Overall triage timeout")
 	return cmd
 }
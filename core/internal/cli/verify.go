@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"iron-sentinel/evidence"
+	"iron-sentinel/version"
+)
+
+func NewVerifyCmd() *cobra.Command {
+	var asJSON bool
+	var keyFiles []string
+	var proofFile string
+
+	cmd := &cobra.Command{
+		Use:   "verify <case-dir|case.tar.gz|artifact-file>",
+		Short: "Re-hash the artifacts of a case and check them against its manifest",
+		Long: "Re-hash the artifacts of a case and check them against its manifest.\n\n" +
+			"With --proof, check a single shared artifact file against an inclusion proof from \"iron-sentinel proof\" instead.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var opts evidence.VerifyOptions
+			for _, p := range keyFiles {
+				pub, err := evidence.ReadPublicKey(p)
+				if err != nil {
+					return err
+				}
+				opts.TrustedKeys = append(opts.TrustedKeys, pub)
+			}
+			if proofFile != "" {
+				return verifyProof(args[0], proofFile, opts)
+			}
+			v, err := evidence.Verify(args[0], opts)
+			if err != nil {
+				return err
+			}
+			// Verifying an archive leaves it untouched; a case directory
+			// records the verification in its custody log.
+			if st, err := os.Stat(args[0]); err == nil && st.IsDir() {
+				detail := map[string]string{
+					"valid":           fmt.Sprintf("%t", v.Valid),
+					"manifest_sha256": v.ManifestSHA256,
+					"verified":        fmt.Sprintf("%d", v.Verified),
+					"missing":         fmt.Sprintf("%d", len(v.Missing)),
+					"modified":        fmt.Sprintf("%d", len(v.Modified)),
+					"unlisted":        fmt.Sprintf("%d", len(v.Unlisted)),
+				}
+				if v.SignedBy != "" {
+					detail["signed_by"] = v.SignedBy
+				}
+				if _, err := evidence.AppendCustody(args[0], evidence.CustodyVerify, evidence.LocalActor(version.Tool), detail); err != nil {
+					fmt.Fprintf(os.Stderr, "warning: custody log not updated: %v\n", err)
+				}
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(v); err != nil {
+					return err
+				}
+			} else {
+				fmt.Printf("case=%s source=%s manifest_sha256=%s\n", v.CaseID, v.Source, v.ManifestSHA256)
+				if v.SignedBy != "" {
+					fmt.Printf("signed_by=%s\n", v.SignedBy)
+				}
+				fmt.Printf("artifacts=%d verified=%d missing=%d modified=%d unlisted=%d\n", v.Artifacts, v.Verified, len(v.Missing), len(v.Modified), len(v.Unlisted))
+				for _, rel := range v.Missing {
+					fmt.Printf("missing   %s\n", rel)
+				}
+				for _, m := range v.Modified {
+					fmt.Printf("modified  %s expected=%s actual=%s size=%d->%d\n", m.RelativePath, m.ExpectedSHA256, m.SHA256, m.ExpectedSize, m.Size)
+				}
+				for _, rel := range v.Unlisted {
+					fmt.Printf("unlisted  %s\n", rel)
+				}
+				for _, c := range v.Checks {
+					fmt.Printf("check     %s %s", c.Name, c.Status)
+					if c.Detail != "" {
+						fmt.Printf(" (%s)", c.Detail)
+					}
+					fmt.Println()
+				}
+			}
+
+			if !v.Valid {
+				msg := fmt.Sprintf("%d missing, %d modified, %d unlisted", len(v.Missing), len(v.Modified), len(v.Unlisted))
+				for _, c := range v.Checks {
+					if c.Status != evidence.CheckOK {
+						msg += ", " + c.Name + " " + c.Status
+					}
+				}
+				return fmt.Errorf("verification failed: %s", msg)
+			}
+			if !asJSON {
+				fmt.Println("result=valid")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&keyFiles, "key", nil, "Trusted public key; the manifest must be signed by one of them (repeatable)")
+	cmd.Flags().StringVar(&proofFile, "proof", "", "Inclusion proof to check a single artifact file against")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the verification report as JSON")
+	return cmd
+}
+
+func verifyProof(file, proofFile string, opts evidence.VerifyOptions) error {
+	b, err := os.ReadFile(proofFile)
+	if err != nil {
+		return err
+	}
+	var p evidence.ArtifactProof
+	if err := json.Unmarshal(b, &p); err != nil {
+		return fmt.Errorf("parse proof: %w", err)
+	}
+	sha, n, err := evidence.SHA256File(file)
+	if err != nil {
+		return err
+	}
+	if err := evidence.VerifyArtifactProof(p, sha, n, opts.TrustedKeys); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	fmt.Printf("case=%s artifact=%s sha256=%s root=%s leaves=%d\n", p.CaseID, p.RelativePath, sha, p.Root, p.Leaves)
+	if p.Signature != nil {
+		fmt.Printf("signed_by=%s\n", p.Signature.KeyFingerprint)
+	}
+	fmt.Println("result=valid")
+	return nil
+}
+
+// recordExport notes in the custody log of a case that data left it. A
+// read-only case, such as mounted evidence, only gets a warning.
+func recordExport(dir string, detail map[string]string) {
+	if detail["output"] == "" {
+		detail["output"] = "-"
+	}
+	if _, err := evidence.AppendCustody(dir, evidence.CustodyExport, evidence.LocalActor(version.Tool), detail); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: custody log not updated: %v\n", err)
+	}
+}
@@ -6,7 +6,7 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"iron-sentinel/core/internal/version"
+	"iron-sentinel/version"
 )
 
 func NewVersionCmd() *cobra.Command {
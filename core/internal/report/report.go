@@ -0,0 +1,251 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"iron-sentinel/analyzers/timeline"
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+	"iron-sentinel/findings"
+	"iron-sentinel/version"
+)
+
+var Formats = []string{"html", "md"}
+
+// maxKeyEvents bounds the timeline events listed in the report.
+const maxKeyEvents = 50
+
+// keyEventTypes are the timeline event types worth listing individually;
+// everything else is only counted.
+var keyEventTypes = map[string]bool{
+	"login":         true,
+	"failed_login":  true,
+	"boot":          true,
+	"shell_command": true,
+	"auth_log":      true,
+}
+
+type KV struct {
+	Key   string
+	Value string
+}
+
+type SeverityGroup struct {
+	Severity findings.Severity
+	Findings []findings.Finding
+}
+
+type CollectionError struct {
+	Source  string
+	Message string
+}
+
+// Data is everything a report template renders.
+type Data struct {
+	CaseID      string
+	CreatedAt   string
+	GeneratedAt string
+	ToolVersion string
+	Hostname    string
+	OS          string
+	Host        []KV
+
+	CollectionStart string
+	CollectionEnd   string
+	ArtifactCount   int
+	TotalBytes      int64
+
+	FindingCounts []KV
+	FindingTotal  int
+	TopFindings   []findings.Finding
+	Groups        []SeverityGroup
+	Techniques    []KV
+
+	Artifacts []collectors.Artifact
+	Analysis  []collectors.Artifact
+	Errors    []CollectionError
+
+	TimelineEvents int
+	TimelineStart  string
+	TimelineEnd    string
+	EventTypes     []KV
+	KeyEvents      []timeline.Event
+
+	ManifestSHA256 string
+	Runs           []evidence.AnalysisRun
+}
+
+// Build gathers report data from a case directory. Missing findings or
+// timeline outputs leave their sections empty.
+func Build(dir string) (*Data, error) {
+	m, err := evidence.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	d := &Data{
+		CaseID:      m.CaseID,
+		CreatedAt:   m.CreatedAt,
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		ToolVersion: version.Version,
+		Runs:        m.AnalysisRuns,
+	}
+	if sha, _, err := evidence.SHA256File(filepath.Join(dir, "manifest.json")); err == nil {
+		d.ManifestSHA256 = sha
+	}
+
+	for _, a := range m.Artifacts {
+		if msg := a.Metadata["error"]; msg != "" {
+			d.Errors = append(d.Errors, CollectionError{Source: a.Collector, Message: msg})
+			continue
+		}
+		if strings.HasPrefix(a.RelativePath, "analysis/") {
+			d.Analysis = append(d.Analysis, a)
+			continue
+		}
+		d.Artifacts = append(d.Artifacts, a)
+		d.ArtifactCount++
+		d.TotalBytes += a.SizeBytes
+		if d.CollectionStart == "" || a.CollectedAt < d.CollectionStart {
+			d.CollectionStart = a.CollectedAt
+		}
+		if a.CollectedAt > d.CollectionEnd {
+			d.CollectionEnd = a.CollectedAt
+		}
+	}
+	for _, r := range m.AnalysisRuns {
+		var names []string
+		for name := range r.Errors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			d.Errors = append(d.Errors, CollectionError{Source: "analysis run " + r.ID + ": " + name, Message: r.Errors[name]})
+		}
+	}
+
+	d.hostDetails(dir)
+
+	if a, ok := m.Latest("findings"); ok {
+		fs, err := findings.ReadJSONL(filepath.Join(dir, filepath.FromSlash(a.RelativePath)))
+		if err != nil {
+			return nil, fmt.Errorf("read findings: %w", err)
+		}
+		d.addFindings(fs)
+	}
+	if a, ok := m.Latest("timeline"); ok {
+		events, err := timeline.ReadJSONL(filepath.Join(dir, filepath.FromSlash(a.RelativePath)))
+		if err != nil {
+			return nil, fmt.Errorf("read timeline: %w", err)
+		}
+		d.addTimeline(events)
+	}
+	return d, nil
+}
+
+func (d *Data) hostDetails(dir string) {
+	info := map[string]string{}
+	if b, err := os.ReadFile(filepath.Join(dir, "system", "host_info.json")); err == nil {
+		_ = json.Unmarshal(b, &info)
+	}
+	d.Hostname = info["hostname"]
+	for _, k := range []string{"hostname", "kernel", "kernel_version", "goos", "goarch", "timezone", "machine_id", "boot_id"} {
+		if v := info[k]; v != "" {
+			d.Host = append(d.Host, KV{Key: k, Value: v})
+		}
+	}
+
+	f, err := os.Open(filepath.Join(dir, "system", "os-release.txt"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), "=")
+		if ok && (k == "PRETTY_NAME" || (k == "NAME" && d.OS == "")) {
+			d.OS = strings.Trim(v, `"'`)
+		}
+	}
+	if d.OS != "" {
+		d.Host = append(d.Host, KV{Key: "os", Value: d.OS})
+	}
+}
+
+func (d *Data) addFindings(fs []findings.Finding) {
+	findings.Sort(fs)
+	d.FindingTotal = len(fs)
+	counts := findings.Count(fs)
+	techniques := map[string]int{}
+	for _, sev := range findings.Severities {
+		d.FindingCounts = append(d.FindingCounts, KV{Key: string(sev), Value: fmt.Sprintf("%d", counts[sev])})
+		var g SeverityGroup
+		g.Severity = sev
+		for _, f := range fs {
+			if f.Severity == sev {
+				g.Findings = append(g.Findings, f)
+			}
+		}
+		if len(g.Findings) > 0 {
+			d.Groups = append(d.Groups, g)
+		}
+	}
+	for _, f := range fs {
+		for _, t := range f.Attack {
+			techniques[t]++
+		}
+	}
+	for t, n := range techniques {
+		d.Techniques = append(d.Techniques, KV{Key: t, Value: fmt.Sprintf("%d", n)})
+	}
+	sort.Slice(d.Techniques, func(i, j int) bool { return d.Techniques[i].Key < d.Techniques[j].Key })
+	for _, f := range fs {
+		if len(d.TopFindings) == 5 || f.Severity.Rank() < findings.SeverityMedium.Rank() {
+			break
+		}
+		d.TopFindings = append(d.TopFindings, f)
+	}
+}
+
+func (d *Data) addTimeline(events []timeline.Event) {
+	d.TimelineEvents = len(events)
+	types := map[string]int{}
+	for _, ev := range events {
+		types[ev.Type]++
+		if d.TimelineStart == "" || ev.Time < d.TimelineStart {
+			d.TimelineStart = ev.Time
+		}
+		if ev.Time > d.TimelineEnd {
+			d.TimelineEnd = ev.Time
+		}
+		if keyEventTypes[ev.Type] {
+			d.KeyEvents = append(d.KeyEvents, ev)
+		}
+	}
+	for t, n := range types {
+		d.EventTypes = append(d.EventTypes, KV{Key: t, Value: fmt.Sprintf("%d", n)})
+	}
+	sort.Slice(d.EventTypes, func(i, j int) bool { return d.EventTypes[i].Key < d.EventTypes[j].Key })
+	// Keep the most recent key events; the timeline is sorted by time.
+	if len(d.KeyEvents) > maxKeyEvents {
+		d.KeyEvents = d.KeyEvents[len(d.KeyEvents)-maxKeyEvents:]
+	}
+}
+
+// Render writes the report in the given format (html or md).
+func Render(w io.Writer, d *Data, format string) error {
+	switch format {
+	case "html":
+		return htmlTemplate.Execute(w, d)
+	case "md", "markdown":
+		return markdownTemplate.Execute(w, d)
+	}
+	return fmt.Errorf("unknown report format %q (supported: %s)", format, strings.Join(Formats, ", "))
+}
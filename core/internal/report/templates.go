@@ -0,0 +1,265 @@
+package report
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"iron-sentinel/findings"
+)
+
+var funcs = map[string]interface{}{
+	"evidence": evidenceRef,
+	"join":     strings.Join,
+	"bytes":    humanBytes,
+	"md":       mdEscape,
+	"attack":   attackURL,
+}
+
+// attackURL links an ATT&CK technique; sub-techniques such as T1059.004
+// live under /techniques/T1059/004/.
+func attackURL(id string) string {
+	return "https://attack.mitre.org/techniques/" + strings.ReplaceAll(id, ".", "/") + "/"
+}
+
+func evidenceRef(e findings.Evidence) string {
+	s := e.Artifact
+	if e.Path != "" {
+		if s != "" {
+			s += " → "
+		}
+		s += e.Path
+	}
+	switch {
+	case e.Line > 0:
+		s += ":" + strconv.Itoa(e.Line)
+	case e.Offset != nil:
+		s += " @" + strconv.FormatInt(*e.Offset, 10)
+	}
+	return s
+}
+
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+var mdEscaper = strings.NewReplacer("|", `\|`, "\n", " ", "\r", "", "`", "'", "<", "&lt;", ">", "&gt;")
+
+func mdEscape(s string) string { return mdEscaper.Replace(s) }
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("report.html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Iron Sentinel case report {{.CaseID}}</title>
+<style>
+body{font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;margin:2em auto;max-width:1100px;color:#222;padding:0 1em}
+h1{border-bottom:3px solid #333;padding-bottom:.3em}
+h2{border-bottom:1px solid #ccc;padding-bottom:.2em;margin-top:2em}
+table{border-collapse:collapse;width:100%;margin:.5em 0;font-size:.9em}
+th,td{border:1px solid #ddd;padding:4px 8px;text-align:left;vertical-align:top}
+th{background:#f4f4f4}
+code,.mono{font-family:Menlo,Consolas,monospace;font-size:.85em;word-break:break-all}
+.sev{display:inline-block;padding:1px 8px;border-radius:3px;color:#fff;font-weight:bold;font-size:.85em}
+.sev-critical{background:#7b1fa2}.sev-high{background:#c62828}.sev-medium{background:#ef6c00}.sev-low{background:#1565c0}.sev-info{background:#607d8b}
+.finding{border:1px solid #ddd;border-radius:4px;padding:.6em 1em;margin:.8em 0}
+.finding h4{margin:.2em 0}
+.tag{display:inline-block;background:#eceff1;border-radius:3px;padding:0 6px;margin-right:4px;font-size:.85em}
+.muted{color:#777}
+</style>
+</head>
+<body>
+<h1>Case report: {{.CaseID}}</h1>
+<p class="muted">Generated {{.GeneratedAt}} by Iron Sentinel {{.ToolVersion}}</p>
+
+<h2>Executive summary</h2>
+<table>
+<tr><th>Host</th><td>{{if .Hostname}}{{.Hostname}}{{else}}unknown{{end}}{{if .OS}} ({{.OS}}){{end}}</td></tr>
+<tr><th>Collection window</th><td>{{.CollectionStart}} – {{.CollectionEnd}}</td></tr>
+<tr><th>Artifacts</th><td>{{.ArtifactCount}} ({{bytes .TotalBytes}})</td></tr>
+<tr><th>Findings</th><td>{{.FindingTotal}}{{range .FindingCounts}} <span class="sev sev-{{.Key}}">{{.Key}}: {{.Value}}</span>{{end}}</td></tr>
+{{if .Techniques}}<tr><th>ATT&amp;CK techniques</th><td>{{range .Techniques}}<span class="tag">{{.Key}} ×{{.Value}}</span>{{end}}</td></tr>{{end}}
+<tr><th>Collection errors</th><td>{{len .Errors}}</td></tr>
+</table>
+{{if .TopFindings}}<p><strong>Most significant findings:</strong></p>
+<ul>{{range .TopFindings}}<li><span class="sev sev-{{.Severity}}">{{.Severity}}</span> {{.Title}}</li>{{end}}</ul>
+{{else}}<p>No findings of medium severity or above.</p>{{end}}
+
+<h2>Host details</h2>
+{{if .Host}}<table>{{range .Host}}<tr><th>{{.Key}}</th><td class="mono">{{.Value}}</td></tr>{{end}}</table>
+{{else}}<p class="muted">No host information was collected.</p>{{end}}
+
+<h2>Findings</h2>
+{{if not .Groups}}<p class="muted">No findings.</p>{{end}}
+{{range .Groups}}<h3><span class="sev sev-{{.Severity}}">{{.Severity}}</span> {{len .Findings}} finding(s)</h3>
+{{range .Findings}}<div class="finding">
+<h4>{{.Title}}</h4>
+<p class="muted">{{.Analyzer}}{{if .Rule}} · <code>{{.Rule}}</code>{{end}} · confidence {{.Confidence}}{{if .Time}} · {{.Time}}{{end}} · id <code>{{.ID}}</code></p>
+{{if .Description}}<p>{{.Description}}</p>{{end}}
+{{if .Attack}}<p>{{range .Attack}}<a class="tag" href="{{attack .}}">{{.}}</a>{{end}}</p>{{end}}
+<ul>{{range .Evidence}}<li><code>{{evidence .}}</code>{{if .SHA256}} sha256 <code>{{.SHA256}}</code>{{end}}{{if .Excerpt}}<br><code>{{.Excerpt}}</code>{{end}}</li>{{end}}</ul>
+</div>{{end}}{{end}}
+
+{{if .TimelineEvents}}<h2>Timeline</h2>
+<p>{{.TimelineEvents}} events from {{.TimelineStart}} to {{.TimelineEnd}}.</p>
+<table><tr><th>Event type</th><th>Count</th></tr>{{range .EventTypes}}<tr><td>{{.Key}}</td><td>{{.Value}}</td></tr>{{end}}</table>
+{{if .KeyEvents}}<h3>Recent logins and commands</h3>
+<table><tr><th>Time</th><th>Type</th><th>Message</th><th>Artifact</th></tr>
+{{range .KeyEvents}}<tr><td class="mono">{{.Time}}</td><td>{{.Type}}</td><td class="mono">{{.Message}}</td><td class="mono">{{.Artifact}}</td></tr>{{end}}
+</table>{{end}}{{end}}
+
+<h2>Artifacts</h2>
+<table><tr><th>Path</th><th>Collector</th><th>Size</th><th>SHA-256</th></tr>
+{{range .Artifacts}}<tr><td class="mono">{{.RelativePath}}</td><td>{{.Collector}}</td><td>{{bytes .SizeBytes}}</td><td class="mono">{{.SHA256}}</td></tr>{{end}}
+</table>
+{{if .Analysis}}<h3>Analysis outputs</h3>
+<table><tr><th>Path</th><th>Analyzer</th><th>Run</th><th>SHA-256</th></tr>
+{{range .Analysis}}<tr><td class="mono">{{.RelativePath}}</td><td>{{.Collector}}</td><td>{{index .Metadata "analysis_run"}}</td><td class="mono">{{.SHA256}}</td></tr>{{end}}
+</table>{{end}}
+
+<h2>Collection errors</h2>
+{{if .Errors}}<table><tr><th>Source</th><th>Error</th></tr>{{range .Errors}}<tr><td>{{.Source}}</td><td class="mono">{{.Message}}</td></tr>{{end}}</table>
+{{else}}<p class="muted">None.</p>{{end}}
+
+<h2>Chain of custody</h2>
+<table>
+<tr><th>Case ID</th><td class="mono">{{.CaseID}}</td></tr>
+<tr><th>Manifest created</th><td>{{.CreatedAt}}</td></tr>
+<tr><th>manifest.json SHA-256</th><td class="mono">{{.ManifestSHA256}}</td></tr>
+</table>
+{{if .Runs}}<table><tr><th>Analysis run</th><th>Started</th><th>Finished</th><th>Analyzers</th><th>Options</th></tr>
+{{range .Runs}}<tr><td>{{.ID}}</td><td>{{.StartedAt}}</td><td>{{.FinishedAt}}</td><td>{{join .Analyzers ", "}}</td><td class="mono">{{range $k, $v := .Options}}{{$k}}={{$v}} {{end}}</td></tr>{{end}}
+</table>{{end}}
+</body>
+</html>
+`))
+
+var markdownTemplate = texttemplate.Must(texttemplate.New("report.md").Funcs(funcs).Parse(`# Case report: {{.CaseID}}
+
+_Generated {{.GeneratedAt}} by Iron Sentinel {{.ToolVersion}}_
+
+## Executive summary
+
+| | |
+| --- | --- |
+| Host | {{if .Hostname}}{{md .Hostname}}{{else}}unknown{{end}}{{if .OS}} ({{md .OS}}){{end}} |
+| Collection window | {{.CollectionStart}} – {{.CollectionEnd}} |
+| Artifacts | {{.ArtifactCount}} ({{bytes .TotalBytes}}) |
+| Findings | {{.FindingTotal}}{{range .FindingCounts}} · {{.Key}}: {{.Value}}{{end}} |
+{{- if .Techniques}}
+| ATT&CK techniques | {{range $i, $t := .Techniques}}{{if $i}}, {{end}}{{$t.Key}} ×{{$t.Value}}{{end}} |
+{{- end}}
+| Collection errors | {{len .Errors}} |
+{{if .TopFindings}}
+Most significant findings:
+{{range .TopFindings}}
+- **{{.Severity}}** {{md .Title}}
+{{- end}}
+{{else}}
+No findings of medium severity or above.
+{{end}}
+## Host details
+{{if .Host}}
+| | |
+| --- | --- |
+{{- range .Host}}
+| {{.Key}} | ` + "`{{md .Value}}`" + ` |
+{{- end}}
+{{else}}
+No host information was collected.
+{{end}}
+## Findings
+{{if not .Groups}}
+No findings.
+{{end}}
+{{- range .Groups}}
+### {{.Severity}} ({{len .Findings}})
+{{range .Findings}}
+#### {{md .Title}}
+
+- Analyzer: {{.Analyzer}}{{if .Rule}} (` + "`{{md .Rule}}`" + `){{end}}, confidence {{.Confidence}}{{if .Time}}, {{.Time}}{{end}}
+- ID: ` + "`{{.ID}}`" + `
+{{- if .Attack}}
+- ATT&CK: {{join .Attack ", "}}
+{{- end}}
+{{- if .Description}}
+- {{md .Description}}
+{{- end}}
+- Evidence:
+{{- range .Evidence}}
+  - ` + "`{{md (evidence .)}}`" + `{{if .SHA256}} sha256 ` + "`{{.SHA256}}`" + `{{end}}{{if .Excerpt}}: ` + "`{{md .Excerpt}}`" + `{{end}}
+{{- end}}
+{{end}}
+{{- end}}
+{{- if .TimelineEvents}}
+## Timeline
+
+{{.TimelineEvents}} events from {{.TimelineStart}} to {{.TimelineEnd}}.
+
+| Event type | Count |
+| --- | --- |
+{{- range .EventTypes}}
+| {{.Key}} | {{.Value}} |
+{{- end}}
+{{if .KeyEvents}}
+### Recent logins and commands
+
+| Time | Type | Message | Artifact |
+| --- | --- | --- | --- |
+{{- range .KeyEvents}}
+| {{.Time}} | {{.Type}} | ` + "`{{md .Message}}`" + ` | {{md .Artifact}} |
+{{- end}}
+{{end}}
+{{- end}}
+## Artifacts
+
+| Path | Collector | Size | SHA-256 |
+| --- | --- | --- | --- |
+{{- range .Artifacts}}
+| {{md .RelativePath}} | {{.Collector}} | {{bytes .SizeBytes}} | ` + "`{{.SHA256}}`" + ` |
+{{- end}}
+{{if .Analysis}}
+### Analysis outputs
+
+| Path | Analyzer | Run | SHA-256 |
+| --- | --- | --- | --- |
+{{- range .Analysis}}
+| {{md .RelativePath}} | {{.Collector}} | {{index .Metadata "analysis_run"}} | ` + "`{{.SHA256}}`" + ` |
+{{- end}}
+{{end}}
+## Collection errors
+{{if .Errors}}
+| Source | Error |
+| --- | --- |
+{{- range .Errors}}
+| {{md .Source}} | {{md .Message}} |
+{{- end}}
+{{else}}
+None.
+{{end}}
+## Chain of custody
+
+| | |
+| --- | --- |
+| Case ID | ` + "`{{.CaseID}}`" + ` |
+| Manifest created | {{.CreatedAt}} |
+| manifest.json SHA-256 | ` + "`{{.ManifestSHA256}}`" + ` |
+{{if .Runs}}
+| Analysis run | Started | Finished | Analyzers | Options |
+| --- | --- | --- | --- | --- |
+{{- range .Runs}}
+| {{.ID}} | {{.StartedAt}} | {{.FinishedAt}} | {{join .Analyzers ", "}} | {{range $k, $v := .Options}}{{$k}}={{md $v}} {{end}}|
+{{- end}}
+{{end -}}
+`))
@@ -1,6 +1,7 @@
 package serverapp
 
 import (
+	"crypto/ed25519"
 	"crypto/subtle"
 	"encoding/json"
 	"errors"
@@ -15,6 +16,9 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"iron-sentinel/evidence"
+	"iron-sentinel/version"
 )
 
 type Config struct {
@@ -38,6 +42,9 @@ type Agent struct {
 	Enrolled string `json:"enrolled"`
 	LastSeen string `json:"last_seen"`
 	Token    string `json:"token"`
+	// PublicKey is the PEM key the agent signs case manifests with.
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"key_fingerprint,omitempty"`
 }
 
 type Job struct {
@@ -49,12 +56,24 @@ type Job struct {
 	ClaimedAt string            `json:"claimed_at,omitempty"`
 	DoneAt    string            `json:"done_at,omitempty"`
 	Status    string            `json:"status"` // queued|claimed|done
+	// Verification summarizes the check of the uploaded case.
+	Verification *UploadVerification `json:"verification,omitempty"`
+}
+
+// UploadVerification is the outcome of verifying an uploaded case against
+// its manifest and the signing key of the agent that enrolled.
+type UploadVerification struct {
+	Valid    bool   `json:"valid"`
+	SignedBy string `json:"signed_by,omitempty"`
+	Report   string `json:"report,omitempty"`
+	Error    string `json:"error,omitempty"`
 }
 
 type enrollRequest struct {
-	Hostname string `json:"hostname"`
-	OS       string `json:"os"`
-	Arch     string `json:"arch"`
+	Hostname  string `json:"hostname"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	PublicKey string `json:"public_key,omitempty"`
 }
 
 type enrollResponse struct {
@@ -136,6 +155,16 @@ func (s *Server) handleEnroll(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var fingerprint string
+	if req.PublicKey != "" {
+		pub, err := evidence.ParsePublicKey([]byte(req.PublicKey))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fingerprint = evidence.Fingerprint(pub)
+	}
+
 	agentID := uuid.NewString()
 	token := uuid.NewString()
 
@@ -147,6 +176,9 @@ func (s *Server) handleEnroll(w http.ResponseWriter, r *http.Request) {
 		Enrolled: time.Now().UTC().Format(time.RFC3339Nano),
 		LastSeen: time.Now().UTC().Format(time.RFC3339Nano),
 		Token:    token,
+
+		PublicKey:   req.PublicKey,
+		Fingerprint: fingerprint,
 	}
 
 	s.mu.Lock()
@@ -253,7 +285,7 @@ func (s *Server) handleJobResults(w http.ResponseWriter, r *http.Request) {
 	}
 	jobID := parts[2]
 
-	_, ok := s.authenticateAgent(r)
+	agent, ok := s.authenticateAgent(r)
 	if !ok {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
@@ -291,11 +323,14 @@ func (s *Server) handleJobResults(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	uv := s.verifyUpload(agent, saved)
+
 	s.mu.Lock()
 	for i := range s.jobs {
 		if s.jobs[i].JobID == jobID {
 			s.jobs[i].Status = "done"
 			s.jobs[i].DoneAt = time.Now().UTC().Format(time.RFC3339Nano)
+			s.jobs[i].Verification = &uv
 			break
 		}
 	}
@@ -303,7 +338,57 @@ func (s *Server) handleJobResults(w http.ResponseWriter, r *http.Request) {
 	s.mu.Unlock()
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{"saved": saved})
+	_ = json.NewEncoder(w).Encode(map[string]any{"saved": saved, "verification": uv})
+}
+
+// verifyUpload checks an uploaded case and writes the full report next to
+// it. Agents that enrolled with a key must have signed the manifest with
+// it. The upload is kept either way; a failed check is evidence too.
+func (s *Server) verifyUpload(a Agent, saved string) UploadVerification {
+	var opts evidence.VerifyOptions
+	if a.PublicKey != "" {
+		pub, err := evidence.ParsePublicKey([]byte(a.PublicKey))
+		if err != nil {
+			return UploadVerification{Error: err.Error()}
+		}
+		opts.TrustedKeys = []ed25519.PublicKey{pub}
+	}
+	var uv UploadVerification
+	v, err := evidence.Verify(saved, opts)
+	if err != nil {
+		uv.Error = err.Error()
+	} else {
+		uv.Valid, uv.SignedBy = v.Valid, v.SignedBy
+		b, _ := json.MarshalIndent(v, "", "  ")
+		report := filepath.Join(filepath.Dir(saved), "verification.json")
+		if err := os.WriteFile(report, b, 0o600); err == nil {
+			uv.Report = report
+		}
+	}
+
+	// The archive stays as uploaded; ingestion extends the case's custody
+	// chain in a log next to it.
+	detail := map[string]string{
+		"agent_id":        a.AgentID,
+		"hostname":        a.Hostname,
+		"archive":         filepath.Base(saved),
+		"manifest_sha256": v.ManifestSHA256,
+		"valid":           fmt.Sprintf("%t", v.Valid),
+	}
+	if sha, _, err := evidence.SHA256File(saved); err == nil {
+		detail["archive_sha256"] = sha
+	}
+	if v.SignedBy != "" {
+		detail["signed_by"] = v.SignedBy
+	}
+	if uv.Error != "" {
+		detail["error"] = uv.Error
+	}
+	custody := filepath.Join(filepath.Dir(saved), evidence.CustodyFile)
+	if _, err := evidence.ContinueCustody(custody, v.CustodyEntries, v.CustodyHead, evidence.CustodyIngest, evidence.LocalActor(version.Tool), detail); err != nil && uv.Error == "" {
+		uv.Error = err.Error()
+	}
+	return uv
 }
 
 func (s *Server) saveUpload(jobID string, part *multipart.Part) (string, error) {
@@ -2,30 +2,46 @@ package triage
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"crypto/ed25519"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
-	"iron-sentinel/analyzers/ioc"
-	"iron-sentinel/analyzers/timeline"
 	"iron-sentinel/collectors"
 	"iron-sentinel/collectors/linux"
 	"iron-sentinel/collectors/system"
+	"iron-sentinel/core/internal/analyze"
+	"iron-sentinel/core/internal/baseline"
 	"iron-sentinel/evidence"
+	"iron-sentinel/evidence/redact"
+	"iron-sentinel/version"
 )
 
 type Options struct {
 	CaseID                string
 	Output                string
 	IOCFile               string
+	IOCMaxMatches         int
+	YARARules             []string
+	YARAPaths             []string
+	SigmaRules            []string
+	Baseline              string
+	WebRoots              []string
 	SnapshotPaths         []string
 	SnapshotMode          string
 	SnapshotHashFiles     bool
+	SnapshotClassify      bool
 	SnapshotMaxFileBytes  int64
 	SnapshotMaxTotalBytes int64
 	SnapshotMaxFiles      int
+	Redact                bool
+	RedactRules           []string
+	RedactOriginals       string
+	SignKey               string
+	Workers               int
 	StartedAt             time.Time
 }
 
@@ -36,6 +52,14 @@ type Result struct {
 }
 
 func Run(ctx context.Context, opts Options) (Result, error) {
+	var key ed25519.PrivateKey
+	if opts.SignKey != "" {
+		var err error
+		if key, err = evidence.ReadPrivateKey(opts.SignKey); err != nil {
+			return Result{}, err
+		}
+	}
+
 	outDir := filepath.Join(opts.Output, opts.CaseID)
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
 		return Result{}, err
@@ -48,11 +72,26 @@ func Run(ctx context.Context, opts Options) (Result, error) {
 		system.NewOSReleaseCollector(),
 	}
 
+	// Without explicit snapshot paths, drift detection snapshots and hashes
+	// the paths the baseline covers.
+	if opts.Baseline != "" && len(opts.SnapshotPaths) == 0 {
+		bf, err := baseline.Read(opts.Baseline)
+		if err != nil {
+			return Result{}, err
+		}
+		opts.SnapshotPaths = bf.State.SnapshotRoots
+		opts.SnapshotHashFiles = true
+		if n := len(bf.State.Files) + len(bf.State.Files)/10; n > opts.SnapshotMaxFiles {
+			opts.SnapshotMaxFiles = n
+		}
+	}
+
 	if len(opts.SnapshotPaths) > 0 {
 		cols = append(cols, linux.NewFilesystemSnapshotCollector(linux.SnapshotOptions{
 			Paths:         opts.SnapshotPaths,
 			Mode:          linux.SnapshotMode(opts.SnapshotMode),
 			HashFiles:     opts.SnapshotHashFiles,
+			Classify:      opts.SnapshotClassify,
 			MaxFileBytes:  opts.SnapshotMaxFileBytes,
 			MaxTotalBytes: opts.SnapshotMaxTotalBytes,
 			MaxFiles:      opts.SnapshotMaxFiles,
@@ -61,9 +100,16 @@ func Run(ctx context.Context, opts Options) (Result, error) {
 
 	cols = append(cols,
 		linux.NewProcSummaryCollector(),
+		linux.NewProcessesCollector(),
 		linux.NewNetworkSummaryCollector(),
+		linux.NewSocketsCollector(),
 		linux.NewUserSessionsCollector(),
+		linux.NewAccountsCollector(),
 		linux.NewPersistenceCollector(),
+		linux.NewUnitsCollector(),
+		linux.NewPackagesCollector(),
+		linux.NewHostLogsCollector(),
+		linux.NewShellHistoryCollector(),
 	)
 
 	var artifacts []collectors.Artifact
@@ -90,52 +136,95 @@ func Run(ctx context.Context, opts Options) (Result, error) {
 		artifacts = append(artifacts, arts...)
 	}
 
+	actor := evidence.LocalActor(version.Tool)
+	collected := map[string]string{
+		"case_id":    opts.CaseID,
+		"started_at": opts.StartedAt.UTC().Format(time.RFC3339Nano),
+		"artifacts":  strconv.Itoa(len(artifacts)),
+	}
+	if _, err := evidence.AppendCustody(outDir, evidence.CustodyCollect, actor, collected); err != nil {
+		return Result{}, err
+	}
+
 	manifest := evidence.Manifest{
 		CaseID:    opts.CaseID,
 		CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
 		Artifacts: artifacts,
 	}
 
-	analysisDir := filepath.Join(outDir, "analysis")
-	_ = os.MkdirAll(analysisDir, 0o755)
-
-	if opts.IOCFile != "" {
-		iocRes, err := ioc.ScanArtifacts(ctx, outDir, artifacts, ioc.Options{IOCFile: opts.IOCFile})
-		if err == nil {
-			b, _ := json.MarshalIndent(iocRes, "", "  ")
-			p := filepath.Join(analysisDir, "ioc_scan.json")
-			_ = os.WriteFile(p, b, 0o600)
-			sha, size, herr := evidence.SHA256File(p)
-			if herr == nil {
-				manifest.Artifacts = append(manifest.Artifacts, collectors.Artifact{
-					RelativePath: filepath.ToSlash(filepath.Join("analysis", "ioc_scan.json")),
-					Collector:    "ioc_scan",
-					CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
-					SizeBytes:    size,
-					SHA256:       sha,
-				})
+	// Redaction runs before analysis so that findings cite the redacted
+	// artifacts the export carries.
+	if opts.Redact || len(opts.RedactRules) > 0 {
+		rules := redact.DefaultRules()
+		for _, p := range opts.RedactRules {
+			custom, err := redact.LoadRules(p)
+			if err != nil {
+				return Result{}, err
 			}
-			manifest.Metadata = map[string]string{
-				"ioc_matches": fmt.Sprintf("%d", len(iocRes.Matches)),
+			rules = append(rules, custom...)
+		}
+		rres, err := redact.Artifacts(ctx, outDir, artifacts, redact.Options{Rules: rules, Originals: opts.RedactOriginals})
+		if err != nil {
+			return Result{}, err
+		}
+		artifacts = rres.Artifacts
+		manifest.Artifacts = artifacts
+		manifest.Redactions = rres.Redactions
+		manifest.Metadata = map[string]string{"redacted_artifacts": strconv.Itoa(len(rres.Redactions))}
+		if len(rres.Skipped) > 0 {
+			manifest.Metadata["redaction_skipped"] = strings.Join(rres.Skipped, ",")
+		}
+		originals := opts.RedactOriginals
+		if originals == "" {
+			originals = redact.OriginalsRestrict
+		}
+		redacted := map[string]string{"artifacts": strconv.Itoa(len(rres.Redactions)), "originals": originals}
+		if _, err := evidence.AppendCustody(outDir, evidence.CustodyRedact, actor, redacted); err != nil {
+			return Result{}, err
+		}
+		if len(rres.Errors) > 0 {
+			var errs []string
+			for rel, msg := range rres.Errors {
+				errs = append(errs, rel+": "+msg)
 			}
+			sort.Strings(errs)
+			manifest.Metadata["redaction_errors"] = strings.Join(errs, "; ")
 		}
 	}
 
-	if rel, err := timeline.WriteJSONL(ctx, outDir, manifest.Artifacts, timeline.Options{CaseID: opts.CaseID, StartedAt: opts.StartedAt}); err == nil {
-		p := filepath.Join(outDir, filepath.FromSlash(rel))
-		sha, size, herr := evidence.SHA256File(p)
-		if herr == nil {
-			manifest.Artifacts = append(manifest.Artifacts, collectors.Artifact{
-				RelativePath: rel,
-				Collector:    "timeline",
-				CollectedAt:  time.Now().UTC().Format(time.RFC3339Nano),
-				SizeBytes:    size,
-				SHA256:       sha,
-			})
-		}
+	c := &analyze.Case{Dir: outDir, Manifest: manifest}
+	aopts := analyze.Options{CaseID: opts.CaseID, StartedAt: opts.StartedAt, IOCFile: opts.IOCFile, IOCMaxMatches: opts.IOCMaxMatches, YARARules: opts.YARARules, YARAPaths: opts.YARAPaths, SigmaRules: opts.SigmaRules, Baseline: opts.Baseline, Live: true, WebRoots: opts.WebRoots, Workers: opts.Workers}
+	analyzers, err := analyze.Build(analyze.Defaults(aopts), aopts)
+	if err != nil {
+		return Result{}, err
+	}
+	runOpts := map[string]string{"mode": "triage"}
+	if opts.IOCFile != "" {
+		runOpts["ioc_file"] = opts.IOCFile
+	}
+	if len(opts.YARARules) > 0 {
+		runOpts["yara_rules"] = strings.Join(opts.YARARules, ",")
+	}
+	if len(opts.SigmaRules) > 0 {
+		runOpts["sigma_rules"] = strings.Join(opts.SigmaRules, ",")
+	}
+	if opts.Baseline != "" {
+		runOpts["baseline"] = opts.Baseline
 	}
+	if len(opts.WebRoots) > 0 {
+		runOpts["web_roots"] = strings.Join(opts.WebRoots, ",")
+	}
+	if _, err := analyze.Run(ctx, c, analyzers, runOpts); err != nil {
+		return Result{}, err
+	}
+	manifest = c.Manifest
 
-	if err := evidence.WriteManifest(outDir, manifest); err != nil {
+	if key != nil {
+		err = evidence.WriteSignedManifest(outDir, manifest, key)
+	} else {
+		err = evidence.WriteManifest(outDir, manifest)
+	}
+	if err != nil {
 		return Result{}, err
 	}
 
@@ -1,3 +0,0 @@
-package version
-
-const Version = "0.1.0"
@@ -0,0 +1,233 @@
+package evidence
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustodyFile is the append-only chain of custody log of a case.
+const CustodyFile = "custody.jsonl"
+
+// Custody actions.
+const (
+	CustodyCollect = "collect"
+	CustodyRedact  = "redact"
+	CustodyAnalyze = "analyze"
+	CustodyVerify  = "verify"
+	CustodyExport  = "export"
+	CustodyUpload  = "upload"
+	CustodyIngest  = "ingest"
+)
+
+// Actor is who performed a custody action, and with what.
+type Actor struct {
+	User    string   `json:"user,omitempty"`
+	UID     string   `json:"uid,omitempty"`
+	Host    string   `json:"host,omitempty"`
+	Tool    string   `json:"tool,omitempty"`
+	Command []string `json:"command,omitempty"`
+}
+
+// CustodyEntry is one line of the custody log. Hash covers the entry with
+// Hash empty, including PrevHash, so that changing or removing an entry
+// breaks every later one.
+type CustodyEntry struct {
+	Seq      int               `json:"seq"`
+	Time     string            `json:"time"`
+	Action   string            `json:"action"`
+	Actor    Actor             `json:"actor"`
+	Detail   map[string]string `json:"detail,omitempty"`
+	PrevHash string            `json:"prev_hash"`
+	Hash     string            `json:"hash"`
+}
+
+// CustodyRef ties a manifest to the custody log as it stood when the
+// manifest was written; later entries extend the chain from Head.
+type CustodyRef struct {
+	File    string `json:"file"`
+	Entries int    `json:"entries"`
+	Head    string `json:"head"`
+}
+
+// secretFlags are command-line flags whose values stay out of the log.
+var secretFlags = map[string]bool{"psk": true, "password": true, "passwd": true, "token": true, "secret": true}
+
+// LocalActor describes the current process running tool.
+func LocalActor(tool string) Actor {
+	a := Actor{Tool: tool, Command: maskArgs(os.Args)}
+	if u, err := user.Current(); err == nil {
+		a.User, a.UID = u.Username, u.Uid
+	} else {
+		a.UID = strconv.Itoa(os.Getuid())
+	}
+	a.Host, _ = os.Hostname()
+	return a
+}
+
+// maskArgs replaces the values of secretFlags, given as "-flag value" or
+// "--flag=value".
+func maskArgs(args []string) []string {
+	out := make([]string, len(args))
+	copy(out, args)
+	for i := 1; i < len(out); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(out[i], "-"), "=")
+		if !strings.HasPrefix(out[i], "-") || !secretFlags[strings.ToLower(name)] {
+			continue
+		}
+		if hasValue {
+			out[i] = out[i][:strings.IndexByte(out[i], '=')+1] + "[REDACTED]"
+		} else if i+1 < len(out) {
+			out[i+1] = "[REDACTED]"
+			i++
+		}
+	}
+	return out
+}
+
+// AppendCustody records an action in the custody log of a case directory.
+func AppendCustody(dir, action string, actor Actor, detail map[string]string) (CustodyEntry, error) {
+	return ContinueCustody(filepath.Join(dir, CustodyFile), 0, "", action, actor, detail)
+}
+
+// ContinueCustody appends to the custody log at path. An empty log starts
+// after seq and prev, which lets a log kept apart from its case, such as
+// next to an upload on the server, extend the case's chain. The log is
+// locked from reading the last entry until the new one is written, so that
+// concurrent commands on a case cannot fork the chain.
+func ContinueCustody(path string, seq int, prev, action string, actor Actor, detail map[string]string) (CustodyEntry, error) {
+	if err := EnsureParent(path); err != nil {
+		return CustodyEntry{}, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return CustodyEntry{}, err
+	}
+	e, err := appendCustody(f, seq, prev, action, actor, detail)
+	if err != nil {
+		f.Close()
+		return e, err
+	}
+	return e, f.Close()
+}
+
+func appendCustody(f *os.File, seq int, prev, action string, actor Actor, detail map[string]string) (CustodyEntry, error) {
+	if err := lockFile(f); err != nil {
+		return CustodyEntry{}, fmt.Errorf("lock %s: %w", f.Name(), err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return CustodyEntry{}, err
+	}
+	entries, err := ParseCustody(b)
+	if err != nil {
+		return CustodyEntry{}, err
+	}
+	if n := len(entries); n > 0 {
+		seq, prev = entries[n-1].Seq, entries[n-1].Hash
+	}
+	e := CustodyEntry{
+		Seq:      seq + 1,
+		Time:     time.Now().UTC().Format(time.RFC3339Nano),
+		Action:   action,
+		Actor:    actor,
+		Detail:   detail,
+		PrevHash: prev,
+	}
+	if e.Hash, err = e.digest(); err != nil {
+		return e, err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return e, err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return e, err
+}
+
+func (e CustodyEntry) digest() (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func ReadCustody(path string) ([]CustodyEntry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCustody(b)
+}
+
+func ParseCustody(b []byte) ([]CustodyEntry, error) {
+	var out []CustodyEntry
+	s := bufio.NewScanner(bytes.NewReader(b))
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	n := 0
+	for s.Scan() {
+		n++
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var e CustodyEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return out, fmt.Errorf("custody line %d: %w", n, err)
+		}
+		out = append(out, e)
+	}
+	return out, s.Err()
+}
+
+// VerifyCustody checks that entries form an unbroken chain: consecutive
+// sequence numbers, each entry's hash over its content, and each linked
+// to the hash of the one before. The first entry may continue a chain
+// kept elsewhere.
+func VerifyCustody(entries []CustodyEntry) error {
+	for i, e := range entries {
+		h, err := e.digest()
+		if err != nil {
+			return err
+		}
+		if h != e.Hash {
+			return fmt.Errorf("entry %d: hash does not match its content", e.Seq)
+		}
+		if i == 0 {
+			if e.Seq == 1 && e.PrevHash != "" {
+				return fmt.Errorf("entry 1: unexpected previous hash")
+			}
+			continue
+		}
+		if e.Seq != entries[i-1].Seq+1 {
+			return fmt.Errorf("entry %d follows entry %d", e.Seq, entries[i-1].Seq)
+		}
+		if e.PrevHash != entries[i-1].Hash {
+			return fmt.Errorf("entry %d: previous hash does not match entry %d", e.Seq, entries[i-1].Seq)
+		}
+	}
+	return nil
+}
+
+// custodyRef returns the reference to the custody log of a case directory,
+// or nil when it has none.
+func custodyRef(dir string) *CustodyRef {
+	entries, err := ReadCustody(filepath.Join(dir, CustodyFile))
+	if err != nil || len(entries) == 0 {
+		return nil
+	}
+	last := entries[len(entries)-1]
+	return &CustodyRef{File: CustodyFile, Entries: last.Seq, Head: last.Hash}
+}
@@ -0,0 +1,147 @@
+package evidence
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAppendCustodyConcurrent(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the custody log is only locked on linux")
+	}
+	dir := t.TempDir()
+	const writers, each = 8, 25
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*each)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				if _, err := AppendCustody(dir, CustodyAnalyze, Actor{Tool: "test"}, nil); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadCustody(filepath.Join(dir, CustodyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != writers*each {
+		t.Fatalf("entries = %d, want %d", len(entries), writers*each)
+	}
+	if err := VerifyCustody(entries); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContinueCustody(t *testing.T) {
+	dir := t.TempDir()
+	for _, action := range []string{CustodyCollect, CustodyExport} {
+		if _, err := AppendCustody(dir, action, Actor{Tool: "test"}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	caseLog, err := ReadCustody(filepath.Join(dir, CustodyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := caseLog[len(caseLog)-1]
+
+	server := filepath.Join(t.TempDir(), "uploads", CustodyFile)
+	e, err := ContinueCustody(server, head.Seq, head.Hash, CustodyUpload, Actor{Tool: "server"}, map[string]string{"from": "agent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Seq != 3 || e.PrevHash != head.Hash {
+		t.Errorf("continued entry seq=%d prev=%s, want 3 and %s", e.Seq, e.PrevHash, head.Hash)
+	}
+	// Once the log has entries, seq and prev are ignored.
+	if e, err = ContinueCustody(server, 99, "x", CustodyIngest, Actor{Tool: "server"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if e.Seq != 4 {
+		t.Errorf("seq = %d, want 4", e.Seq)
+	}
+
+	serverLog, err := ReadCustody(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyCustody(append(caseLog, serverLog...)); err != nil {
+		t.Errorf("joined chain: %v", err)
+	}
+	if err := VerifyCustody(serverLog); err != nil {
+		t.Errorf("server log alone: %v", err)
+	}
+}
+
+func TestVerifyCustodyTampering(t *testing.T) {
+	dir := t.TempDir()
+	for _, action := range []string{CustodyCollect, CustodyRedact, CustodyAnalyze, CustodyExport} {
+		if _, err := AppendCustody(dir, action, Actor{Tool: "test", User: "alice"}, map[string]string{"n": action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b, err := os.ReadFile(filepath.Join(dir, CustodyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(bytes.TrimSpace(b), []byte("\n"))
+
+	tests := []struct {
+		name   string
+		tamper func() []byte
+		want   string
+	}{
+		{"edited", func() []byte { return bytes.Replace(b, []byte(`"user":"alice"`), []byte(`"user":"mallory"`), 1) }, "hash does not match"},
+		{"removed", func() []byte { return bytes.Join([][]byte{lines[0], lines[2], lines[3]}, nil) }, "follows entry"},
+		{"reordered", func() []byte { return bytes.Join([][]byte{lines[0], lines[2], lines[1], lines[3]}, nil) }, "follows entry"},
+		{"truncated head", func() []byte { return bytes.Join(lines[1:], nil) }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseCustody(tt.tamper())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = VerifyCustody(entries)
+			if tt.want == "" {
+				// A log may continue a chain kept elsewhere, so a missing head
+				// is only caught against the manifest's custody reference.
+				if err != nil {
+					t.Errorf("err = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseCustody([]byte("{}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("malformed line: err = %v", err)
+	}
+}
+
+func TestMaskArgs(t *testing.T) {
+	got := maskArgs([]string{"is", "upload", "--psk", "s3cret", "--token=abc", "-Password", "p", "--output", "ev", "--psk"})
+	want := []string{"is", "upload", "--psk", "[REDACTED]", "--token=[REDACTED]", "-Password", "[REDACTED]", "--output", "ev", "--psk"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("maskArgs = %q, want %q", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package evidence
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"os"
 	"path/filepath"
@@ -9,13 +10,55 @@ import (
 )
 
 type Manifest struct {
-	CaseID    string                `json:"case_id"`
-	CreatedAt string                `json:"created_at"`
-	Artifacts []collectors.Artifact `json:"artifacts"`
-	Metadata  map[string]string     `json:"metadata,omitempty"`
+	CaseID       string                `json:"case_id"`
+	CreatedAt    string                `json:"created_at"`
+	Artifacts    []collectors.Artifact `json:"artifacts"`
+	Metadata     map[string]string     `json:"metadata,omitempty"`
+	AnalysisRuns []AnalysisRun         `json:"analysis_runs,omitempty"`
+	Redactions   []Redaction           `json:"redactions,omitempty"`
+	Custody      *CustodyRef           `json:"custody,omitempty"`
+	Merkle       *Merkle               `json:"merkle,omitempty"`
 }
 
+type AnalysisRun struct {
+	ID         string            `json:"id"`
+	StartedAt  string            `json:"started_at"`
+	FinishedAt string            `json:"finished_at"`
+	Analyzers  []string          `json:"analyzers"`
+	Options    map[string]string `json:"options,omitempty"`
+	Artifacts  []string          `json:"artifacts,omitempty"`
+	Errors     map[string]string `json:"errors,omitempty"`
+}
+
+// RestrictedDir holds the originals of redacted artifacts inside a case
+// directory. It is readable by the owner only and is never exported.
+const RestrictedDir = "restricted"
+
+// Redaction records the secrets removed from one artifact. Rules counts
+// the matches per rule, Members lists the archive members that changed and
+// Original is the relative path of the kept original, empty when it was
+// deleted.
+type Redaction struct {
+	RelativePath   string         `json:"relative_path"`
+	Rules          map[string]int `json:"rules"`
+	Members        []string       `json:"members,omitempty"`
+	OriginalSHA256 string         `json:"original_sha256"`
+	OriginalSize   int64          `json:"original_size_bytes"`
+	Original       string         `json:"original,omitempty"`
+}
+
+// WriteManifest writes manifest.json with a Merkle tree over its artifacts,
+// referencing the current head of the case's custody log.
 func WriteManifest(outputDir string, m Manifest) error {
+	return writeManifest(outputDir, m, nil)
+}
+
+func writeManifest(outputDir string, m Manifest, key ed25519.PrivateKey) error {
+	m.Custody = custodyRef(outputDir)
+	m.Merkle = BuildMerkle(m.Artifacts)
+	if m.Merkle != nil && key != nil {
+		m.Merkle.Signature = signRoot(m.Merkle.Root, key)
+	}
 	b, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return err
@@ -23,3 +66,23 @@ func WriteManifest(outputDir string, m Manifest) error {
 	path := filepath.Join(outputDir, "manifest.json")
 	return os.WriteFile(path, b, 0o600)
 }
+
+func ReadManifest(outputDir string) (Manifest, error) {
+	var m Manifest
+	b, err := os.ReadFile(filepath.Join(outputDir, "manifest.json"))
+	if err != nil {
+		return m, err
+	}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
+
+// Latest returns the most recently appended artifact written by collector.
+func (m Manifest) Latest(collector string) (collectors.Artifact, bool) {
+	for i := len(m.Artifacts) - 1; i >= 0; i-- {
+		if m.Artifacts[i].Collector == collector {
+			return m.Artifacts[i], true
+		}
+	}
+	return collectors.Artifact{}, false
+}
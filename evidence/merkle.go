@@ -0,0 +1,267 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"iron-sentinel/collectors"
+)
+
+// MerkleAlgorithm names the tree construction: RFC 6962 hashing with
+// 0x00-prefixed leaves and 0x01-prefixed interior nodes over SHA-256.
+const MerkleAlgorithm = "sha256-rfc6962"
+
+// Merkle is a Merkle tree over the hashed artifacts of a manifest, sorted
+// by relative path. An artifact with its proof and the root is enough to
+// show it belongs to the case without disclosing the other artifacts.
+type Merkle struct {
+	Algorithm string           `json:"algorithm"`
+	Leaves    int              `json:"leaves"`
+	Root      string           `json:"root"`
+	Signature *RootSignature   `json:"signature,omitempty"`
+	Proofs    []InclusionProof `json:"proofs"`
+}
+
+// RootSignature is an Ed25519 signature over the hex root, made with the
+// key that signs the manifest, so that a root can be trusted on its own.
+type RootSignature struct {
+	KeyFingerprint string `json:"key_fingerprint"`
+	PublicKey      string `json:"public_key"`
+	Signature      string `json:"signature"`
+}
+
+// InclusionProof is the audit path of one leaf: the sibling hashes from
+// the leaf up to the root.
+type InclusionProof struct {
+	RelativePath string   `json:"relative_path"`
+	Index        int      `json:"index"`
+	Path         []string `json:"path"`
+}
+
+// ArtifactProof is a self-contained proof that one artifact belongs to a
+// case, for sharing the artifact alone.
+type ArtifactProof struct {
+	CaseID       string         `json:"case_id"`
+	RelativePath string         `json:"relative_path"`
+	SHA256       string         `json:"sha256"`
+	SizeBytes    int64          `json:"size_bytes"`
+	Algorithm    string         `json:"algorithm"`
+	Leaves       int            `json:"leaves"`
+	Index        int            `json:"index"`
+	Path         []string       `json:"path"`
+	Root         string         `json:"root"`
+	Signature    *RootSignature `json:"root_signature,omitempty"`
+}
+
+// merkleLeaves returns the hashed artifacts sorted by relative path.
+func merkleLeaves(artifacts []collectors.Artifact) []collectors.Artifact {
+	var out []collectors.Artifact
+	for _, a := range artifacts {
+		if a.SHA256 != "" {
+			out = append(out, a)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].RelativePath < out[j].RelativePath })
+	return out
+}
+
+// LeafHash commits to an artifact's path, content hash and size.
+func LeafHash(relativePath, sha string, size int64) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write([]byte(relativePath))
+	h.Write([]byte{0})
+	h.Write([]byte(sha))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(size, 10)))
+	return h.Sum(nil)
+}
+
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// BuildMerkle computes the tree over the hashed artifacts, or nil when
+// there are none.
+func BuildMerkle(artifacts []collectors.Artifact) *Merkle {
+	leaves := merkleLeaves(artifacts)
+	if len(leaves) == 0 {
+		return nil
+	}
+	hashes := make([][]byte, len(leaves))
+	for i, a := range leaves {
+		hashes[i] = LeafHash(a.RelativePath, a.SHA256, a.SizeBytes)
+	}
+	paths := make([][][]byte, len(leaves))
+	root := merkleBuild(hashes, paths, 0)
+
+	m := &Merkle{Algorithm: MerkleAlgorithm, Leaves: len(leaves), Root: hex.EncodeToString(root)}
+	for i, a := range leaves {
+		p := InclusionProof{RelativePath: a.RelativePath, Index: i, Path: make([]string, len(paths[i]))}
+		for j, h := range paths[i] {
+			p.Path[j] = hex.EncodeToString(h)
+		}
+		m.Proofs = append(m.Proofs, p)
+	}
+	return m
+}
+
+// merkleBuild returns the root of hashes and appends, for every leaf, the
+// sibling subtree roots from the bottom up. offset is the index of the
+// first leaf in paths.
+func merkleBuild(hashes [][]byte, paths [][][]byte, offset int) []byte {
+	n := len(hashes)
+	if n == 1 {
+		return hashes[0]
+	}
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	left := merkleBuild(hashes[:k], paths, offset)
+	right := merkleBuild(hashes[k:], paths, offset+k)
+	for i := offset; i < offset+k; i++ {
+		paths[i] = append(paths[i], right)
+	}
+	for i := offset + k; i < offset+n; i++ {
+		paths[i] = append(paths[i], left)
+	}
+	return nodeHash(left, right)
+}
+
+// VerifyInclusion checks that leaf is at index of a tree of size leaves
+// with the given root, following RFC 9162 section 2.1.3.2.
+func VerifyInclusion(leaf []byte, index, leaves int, path []string, root string) error {
+	if index < 0 || index >= leaves {
+		return fmt.Errorf("index %d out of range for %d leaves", index, leaves)
+	}
+	fn, sn := index, leaves-1
+	r := leaf
+	for _, s := range path {
+		p, err := hex.DecodeString(s)
+		if err != nil || len(p) != sha256.Size {
+			return errors.New("invalid hash in proof")
+		}
+		if sn == 0 {
+			return errors.New("proof is longer than the tree is deep")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return errors.New("proof is shorter than the tree is deep")
+	}
+	if hex.EncodeToString(r) != root {
+		return errors.New("proof does not lead to the root")
+	}
+	return nil
+}
+
+func signRoot(root string, key ed25519.PrivateKey) *RootSignature {
+	pub := key.Public().(ed25519.PublicKey)
+	return &RootSignature{
+		KeyFingerprint: Fingerprint(pub),
+		PublicKey:      base64.StdEncoding.EncodeToString(pub),
+		Signature:      base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(root))),
+	}
+}
+
+// VerifyRootSignature checks a root signature. With trusted keys, the
+// signing key must be one of them.
+func VerifyRootSignature(root string, sig *RootSignature, trusted []ed25519.PublicKey) error {
+	pub, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return errors.New("invalid public key in root signature")
+	}
+	if Fingerprint(pub) != sig.KeyFingerprint {
+		return errors.New("key fingerprint does not match the embedded public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil || !ed25519.Verify(pub, []byte(root), raw) {
+		return errors.New("root signature does not match")
+	}
+	if len(trusted) == 0 {
+		return nil
+	}
+	for _, k := range trusted {
+		if bytes.Equal(k, pub) {
+			return nil
+		}
+	}
+	return fmt.Errorf("root signed by untrusted key %s", sig.KeyFingerprint)
+}
+
+// Proof extracts the proof for one artifact of the manifest.
+func (m Manifest) Proof(relativePath string) (ArtifactProof, error) {
+	if m.Merkle == nil {
+		return ArtifactProof{}, errors.New("manifest has no Merkle tree")
+	}
+	var art *collectors.Artifact
+	for i := range m.Artifacts {
+		if m.Artifacts[i].RelativePath == relativePath && m.Artifacts[i].SHA256 != "" {
+			art = &m.Artifacts[i]
+		}
+	}
+	if art == nil {
+		return ArtifactProof{}, fmt.Errorf("%s is not a hashed artifact of the case", relativePath)
+	}
+	for _, p := range m.Merkle.Proofs {
+		if p.RelativePath == relativePath {
+			return ArtifactProof{
+				CaseID:       m.CaseID,
+				RelativePath: relativePath,
+				SHA256:       art.SHA256,
+				SizeBytes:    art.SizeBytes,
+				Algorithm:    m.Merkle.Algorithm,
+				Leaves:       m.Merkle.Leaves,
+				Index:        p.Index,
+				Path:         p.Path,
+				Root:         m.Merkle.Root,
+				Signature:    m.Merkle.Signature,
+			}, nil
+		}
+	}
+	return ArtifactProof{}, fmt.Errorf("no proof for %s", relativePath)
+}
+
+// VerifyArtifactProof checks that a file with the given hash and size is
+// the artifact the proof is for and that it belongs to the proof's root.
+// With trusted keys, the root must be signed by one of them.
+func VerifyArtifactProof(p ArtifactProof, sha string, size int64, trusted []ed25519.PublicKey) error {
+	if p.Algorithm != MerkleAlgorithm {
+		return fmt.Errorf("unsupported Merkle algorithm %q", p.Algorithm)
+	}
+	if sha != p.SHA256 || size != p.SizeBytes {
+		return fmt.Errorf("file does not match %s: sha256 %s size %d, expected %s size %d", p.RelativePath, sha, size, p.SHA256, p.SizeBytes)
+	}
+	if err := VerifyInclusion(LeafHash(p.RelativePath, p.SHA256, p.SizeBytes), p.Index, p.Leaves, p.Path, p.Root); err != nil {
+		return err
+	}
+	if p.Signature != nil {
+		return VerifyRootSignature(p.Root, p.Signature, trusted)
+	}
+	if len(trusted) > 0 {
+		return errors.New("root is not signed")
+	}
+	return nil
+}
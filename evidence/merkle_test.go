@@ -0,0 +1,183 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"iron-sentinel/collectors"
+)
+
+func testArtifacts(n int) []collectors.Artifact {
+	var out []collectors.Artifact
+	for i := n - 1; i >= 0; i-- {
+		out = append(out, collectors.Artifact{
+			RelativePath: fmt.Sprintf("a/%03d.json", i),
+			SHA256:       fmt.Sprintf("%064x", i+1),
+			SizeBytes:    int64(i * 10),
+		})
+	}
+	return out
+}
+
+// referenceRoot is the RFC 6962 Merkle Tree Hash written out directly.
+func referenceRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := 1
+	for k*2 < len(leaves) {
+		k *= 2
+	}
+	return nodeHash(referenceRoot(leaves[:k]), referenceRoot(leaves[k:]))
+}
+
+func TestBuildMerkle(t *testing.T) {
+	for n := 1; n <= 17; n++ {
+		arts := testArtifacts(n)
+		m := BuildMerkle(arts)
+		if m.Leaves != n || len(m.Proofs) != n {
+			t.Fatalf("n=%d: leaves=%d proofs=%d", n, m.Leaves, len(m.Proofs))
+		}
+
+		var leaves [][]byte
+		for i := 0; i < n; i++ {
+			a := arts[n-1-i]
+			leaves = append(leaves, LeafHash(a.RelativePath, a.SHA256, a.SizeBytes))
+		}
+		if want := hex.EncodeToString(referenceRoot(leaves)); m.Root != want {
+			t.Fatalf("n=%d: root %s, want %s", n, m.Root, want)
+		}
+
+		for i, p := range m.Proofs {
+			if p.Index != i || p.RelativePath != fmt.Sprintf("a/%03d.json", i) {
+				t.Fatalf("n=%d: proof %d is for %s at %d", n, i, p.RelativePath, p.Index)
+			}
+			if err := VerifyInclusion(leaves[i], i, n, p.Path, m.Root); err != nil {
+				t.Errorf("n=%d leaf %d: %v", n, i, err)
+			}
+			if n > 1 {
+				if err := VerifyInclusion(leaves[(i+1)%n], i, n, p.Path, m.Root); err == nil {
+					t.Errorf("n=%d leaf %d: proof accepted another leaf", n, i)
+				}
+			}
+		}
+	}
+}
+
+func TestBuildMerkleSkipsUnhashed(t *testing.T) {
+	arts := append(testArtifacts(3), collectors.Artifact{RelativePath: "a/000.json"}, collectors.Artifact{RelativePath: "b"})
+	if m := BuildMerkle(arts); m.Leaves != 3 {
+		t.Errorf("leaves = %d, want 3", m.Leaves)
+	}
+	if m := BuildMerkle([]collectors.Artifact{{RelativePath: "b"}}); m != nil {
+		t.Errorf("tree over no hashed artifacts = %+v, want nil", m)
+	}
+}
+
+func TestVerifyInclusionRejects(t *testing.T) {
+	arts := testArtifacts(6)
+	m := BuildMerkle(arts)
+	a := arts[len(arts)-1-3]
+	leaf := LeafHash(a.RelativePath, a.SHA256, a.SizeBytes)
+	p := m.Proofs[3].Path
+
+	flipped := append([]string(nil), p...)
+	b, _ := hex.DecodeString(flipped[0])
+	b[0] ^= 1
+	flipped[0] = hex.EncodeToString(b)
+
+	tests := []struct {
+		name   string
+		index  int
+		leaves int
+		path   []string
+		root   string
+		want   string
+	}{
+		{"wrong index", 2, 6, p, m.Root, "root"},
+		{"index out of range", 6, 6, p, m.Root, "out of range"},
+		{"negative index", -1, 6, p, m.Root, "out of range"},
+		{"wrong size", 3, 9, p, m.Root, ""},
+		{"short path", 3, 6, p[:len(p)-1], m.Root, "shorter"},
+		{"long path", 3, 6, append(append([]string(nil), p...), p[0]), m.Root, "longer"},
+		{"tampered sibling", 3, 6, flipped, m.Root, "root"},
+		{"bad hex", 3, 6, []string{"zz", p[1], p[2]}, m.Root, "invalid hash"},
+		{"other root", 3, 6, p, strings.Repeat("0", 64), "root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifyInclusion(leaf, tt.index, tt.leaves, tt.path, tt.root)
+			if err == nil {
+				t.Fatal("proof accepted")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestArtifactProof(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	otherPub := other.Public().(ed25519.PublicKey)
+
+	arts := testArtifacts(5)
+	m := Manifest{CaseID: "c", Artifacts: arts, Merkle: BuildMerkle(arts)}
+	m.Merkle.Signature = signRoot(m.Merkle.Root, key)
+
+	p, err := m.Proof("a/002.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sha, size := p.SHA256, p.SizeBytes
+	if err := VerifyArtifactProof(p, sha, size, nil); err != nil {
+		t.Errorf("untrusted: %v", err)
+	}
+	if err := VerifyArtifactProof(p, sha, size, []ed25519.PublicKey{pub}); err != nil {
+		t.Errorf("trusted key: %v", err)
+	}
+	if err := VerifyArtifactProof(p, sha, size, []ed25519.PublicKey{otherPub}); err == nil {
+		t.Error("proof accepted with another trusted key")
+	}
+	if err := VerifyArtifactProof(p, sha, size+1, nil); err == nil {
+		t.Error("proof accepted for a file of another size")
+	}
+
+	renamed := p
+	renamed.RelativePath = "a/003.json"
+	if err := VerifyArtifactProof(renamed, sha, size, nil); err == nil {
+		t.Error("proof accepted under another path")
+	}
+
+	forged := p
+	forged.Signature = signRoot(p.Root, other)
+	forged.Signature.PublicKey = p.Signature.PublicKey
+	if err := VerifyArtifactProof(forged, sha, size, nil); err == nil {
+		t.Error("proof accepted with a signature by another key")
+	}
+
+	unsigned := p
+	unsigned.Signature = nil
+	if err := VerifyArtifactProof(unsigned, sha, size, []ed25519.PublicKey{pub}); err == nil || !strings.Contains(err.Error(), "not signed") {
+		t.Errorf("unsigned root with trusted keys: err = %v", err)
+	}
+
+	if _, err := m.Proof("missing"); err == nil {
+		t.Error("proof for an artifact not in the case")
+	}
+	if !bytes.Equal(LeafHash("a", "b", 1), LeafHash("a", "b", 1)) || bytes.Equal(LeafHash("a", "b", 1), LeafHash("a", "b", 10)) {
+		t.Error("leaf hash does not commit to the size")
+	}
+}
@@ -0,0 +1,166 @@
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// applyFile redacts the contents of the file called name. JSON and JSON
+// Lines files are decoded and only their string values are redacted, so
+// that a rule never matches across JSON escaping and the output still
+// parses. Everything else, including lines that were not JSON to begin
+// with, is redacted as raw bytes.
+func applyFile(rules []Rule, name string, b []byte, counts map[string]int) ([]byte, error) {
+	switch strings.ToLower(path.Ext(strings.TrimSuffix(strings.ToLower(name), ".gz"))) {
+	case ".json":
+		return applyJSON(rules, b, counts)
+	case ".jsonl", ".ndjson":
+		return applyJSONLines(rules, b, counts)
+	}
+	return Apply(rules, b, counts), nil
+}
+
+func applyJSON(rules []Rule, b []byte, counts map[string]int) ([]byte, error) {
+	v, err := decodeJSON(b)
+	if err != nil {
+		return Apply(rules, b, counts), nil
+	}
+	local := map[string]int{}
+	v = redactValue(rules, v, local)
+	if len(local) == 0 {
+		return b, nil
+	}
+	indent := ""
+	if bytes.Contains(bytes.TrimSpace(b), []byte("\n")) {
+		indent = "  "
+	}
+	out, err := encodeJSON(v, indent)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasSuffix(b, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	for k, n := range local {
+		counts[k] += n
+	}
+	return out, nil
+}
+
+func applyJSONLines(rules []Rule, b []byte, counts map[string]int) ([]byte, error) {
+	lines := bytes.SplitAfter(b, []byte("\n"))
+	out := make([]byte, 0, len(b))
+	for i, line := range lines {
+		body := bytes.TrimRight(line, "\r\n")
+		eol := line[len(body):]
+		if len(bytes.TrimSpace(body)) == 0 {
+			out = append(out, line...)
+			continue
+		}
+		v, err := decodeJSON(body)
+		if err != nil {
+			out = append(out, Apply(rules, body, counts)...)
+			out = append(out, eol...)
+			continue
+		}
+		local := map[string]int{}
+		v = redactValue(rules, v, local)
+		if len(local) == 0 {
+			out = append(out, line...)
+			continue
+		}
+		enc, err := encodeJSON(v, "")
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		out = append(out, enc...)
+		out = append(out, eol...)
+		for k, n := range local {
+			counts[k] += n
+		}
+	}
+	return out, nil
+}
+
+// decodeJSON decodes a single JSON value, keeping numbers as written.
+func decodeJSON(b []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("trailing data after JSON value")
+	}
+	return v, nil
+}
+
+func encodeJSON(v interface{}, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", indent)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	out := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	if !json.Valid(out) {
+		return nil, errors.New("redacted JSON no longer parses")
+	}
+	return out, nil
+}
+
+// redactValue redacts the strings in a decoded JSON value. The elements of
+// an array of strings, such as an argv or environ, are redacted together,
+// joined by NULs as in /proc, so that "--password", "secret" still matches
+// a flag followed by its value.
+func redactValue(rules []Rule, v interface{}, counts map[string]int) interface{} {
+	switch t := v.(type) {
+	case string:
+		return string(Apply(rules, []byte(t), counts))
+	case map[string]interface{}:
+		for k, e := range t {
+			t[k] = redactValue(rules, e, counts)
+		}
+	case []interface{}:
+		if joined, ok := joinStrings(t); ok {
+			local := map[string]int{}
+			parts := strings.Split(string(Apply(rules, []byte(joined), local)), "\x00")
+			if len(parts) == len(t) {
+				for i, p := range parts {
+					t[i] = p
+				}
+				for k, n := range local {
+					counts[k] += n
+				}
+				return t
+			}
+		}
+		for i, e := range t {
+			t[i] = redactValue(rules, e, counts)
+		}
+	}
+	return v
+}
+
+// joinStrings joins an array whose elements are all strings without NULs.
+func joinStrings(a []interface{}) (string, bool) {
+	if len(a) < 2 {
+		return "", false
+	}
+	parts := make([]string, len(a))
+	for i, e := range a {
+		s, ok := e.(string)
+		if !ok || strings.Contains(s, "\x00") {
+			return "", false
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, "\x00"), true
+}
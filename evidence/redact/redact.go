@@ -0,0 +1,323 @@
+package redact
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"iron-sentinel/collectors"
+	"iron-sentinel/evidence"
+)
+
+// What happens to the original of a redacted artifact.
+const (
+	// OriginalsRestrict moves it under evidence.RestrictedDir.
+	OriginalsRestrict = "restrict"
+	// OriginalsExclude deletes it.
+	OriginalsExclude = "exclude"
+)
+
+// DefaultMaxBytes bounds the uncompressed size of a file or archive member
+// that is redacted; redaction holds one in memory at a time.
+const DefaultMaxBytes = 64 * 1024 * 1024
+
+// maxMembers caps the archive members listed per redaction.
+const maxMembers = 100
+
+// textSample is how much of a file decides whether it is text.
+const textSample = 8192
+
+type Options struct {
+	Rules     []Rule
+	Originals string
+	MaxBytes  int64
+}
+
+// Result holds the artifacts with their redacted hashes and sizes, what
+// was redacted, the files too large to redact, and per-artifact errors.
+type Result struct {
+	Artifacts  []collectors.Artifact
+	Redactions []evidence.Redaction
+	Skipped    []string
+	Errors     map[string]string
+}
+
+// Artifacts redacts the text artifacts under outDir in place. A redacted
+// copy replaces each artifact with a match, and the original is moved
+// under evidence.RestrictedDir or deleted. Gzip files and tar.gz archives
+// are rewritten with their text members redacted.
+func Artifacts(ctx context.Context, outDir string, artifacts []collectors.Artifact, opts Options) (Result, error) {
+	res := Result{Artifacts: append([]collectors.Artifact(nil), artifacts...), Errors: map[string]string{}}
+	if len(opts.Rules) == 0 {
+		return res, errors.New("no redaction rules")
+	}
+	switch opts.Originals {
+	case "":
+		opts.Originals = OriginalsRestrict
+	case OriginalsRestrict, OriginalsExclude:
+	default:
+		return res, fmt.Errorf("invalid originals mode %q (want %s or %s)", opts.Originals, OriginalsRestrict, OriginalsExclude)
+	}
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = DefaultMaxBytes
+	}
+
+	for i, a := range res.Artifacts {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		if a.SHA256 == "" {
+			continue
+		}
+		red, err := redactArtifact(outDir, a, opts, &res.Skipped)
+		if err != nil {
+			res.Errors[a.RelativePath] = err.Error()
+			continue
+		}
+		if red == nil {
+			continue
+		}
+		full := filepath.Join(outDir, filepath.FromSlash(a.RelativePath))
+		sum, n, err := evidence.SHA256File(full)
+		if err != nil {
+			res.Errors[a.RelativePath] = err.Error()
+			continue
+		}
+		md := map[string]string{}
+		for k, v := range a.Metadata {
+			md[k] = v
+		}
+		md["redacted"] = "true"
+		res.Artifacts[i].SHA256, res.Artifacts[i].SizeBytes, res.Artifacts[i].Metadata = sum, n, md
+		res.Redactions = append(res.Redactions, *red)
+	}
+	return res, nil
+}
+
+// redactArtifact rewrites one artifact and disposes of its original. It
+// returns nil when nothing matched.
+func redactArtifact(outDir string, a collectors.Artifact, opts Options, skipped *[]string) (*evidence.Redaction, error) {
+	full := filepath.Join(outDir, filepath.FromSlash(a.RelativePath))
+	st, err := os.Stat(full)
+	if err != nil || !st.Mode().IsRegular() {
+		return nil, err
+	}
+
+	tmp := full + ".redact"
+	red := &evidence.Redaction{RelativePath: a.RelativePath, Rules: map[string]int{}}
+	lower := strings.ToLower(a.RelativePath)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		err = redactTarGz(full, tmp, a.RelativePath, opts, red, skipped)
+	case strings.HasSuffix(lower, ".gz"):
+		err = redactGzip(full, tmp, a.RelativePath, opts, red, skipped)
+	default:
+		err = redactPlain(full, tmp, st.Size(), a.RelativePath, opts, red, skipped)
+	}
+	if err != nil || len(red.Rules) == 0 {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+
+	if red.OriginalSHA256, red.OriginalSize, err = evidence.SHA256File(full); err != nil {
+		_ = os.Remove(tmp)
+		return nil, err
+	}
+	switch opts.Originals {
+	case OriginalsRestrict:
+		rel := path.Join(evidence.RestrictedDir, a.RelativePath)
+		dst := filepath.Join(outDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			_ = os.Remove(tmp)
+			return nil, err
+		}
+		_ = os.Chmod(filepath.Join(outDir, evidence.RestrictedDir), 0o700)
+		if err := os.Rename(full, dst); err != nil {
+			_ = os.Remove(tmp)
+			return nil, err
+		}
+		_ = os.Chmod(dst, 0o600)
+		red.Original = rel
+	case OriginalsExclude:
+		if err := os.Remove(full); err != nil {
+			_ = os.Remove(tmp)
+			return nil, err
+		}
+	}
+	if err := os.Rename(tmp, full); err != nil {
+		return nil, err
+	}
+	return red, nil
+}
+
+func redactPlain(full, tmp string, size int64, rel string, opts Options, red *evidence.Redaction, skipped *[]string) error {
+	if size > opts.MaxBytes {
+		*skipped = append(*skipped, rel)
+		return nil
+	}
+	b, err := os.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	if !isText(b) {
+		return nil
+	}
+	out, err := applyFile(opts.Rules, rel, b, red.Rules)
+	if err != nil || len(red.Rules) == 0 {
+		return err
+	}
+	return os.WriteFile(tmp, out, 0o600)
+}
+
+func redactGzip(full, tmp string, rel string, opts Options, red *evidence.Redaction, skipped *[]string) error {
+	in, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+	b, err := io.ReadAll(io.LimitReader(zr, opts.MaxBytes+1))
+	if err != nil {
+		return nil
+	}
+	if int64(len(b)) > opts.MaxBytes {
+		*skipped = append(*skipped, rel)
+		return nil
+	}
+	if !isText(b) {
+		return nil
+	}
+	out, err := applyFile(opts.Rules, rel, b, red.Rules)
+	if err != nil || len(red.Rules) == 0 {
+		return err
+	}
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(f)
+	zw.Header = zr.Header
+	if _, err := zw.Write(out); err != nil {
+		f.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func redactTarGz(full, tmp string, rel string, opts Options, red *evidence.Redaction, skipped *[]string) error {
+	in, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return nil
+	}
+	defer zr.Close()
+
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zw := gzip.NewWriter(f)
+	tw := tar.NewWriter(zw)
+
+	tr := tar.NewReader(zr)
+	var members []string
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Size > opts.MaxBytes {
+			if hdr.Typeflag == tar.TypeReg {
+				*skipped = append(*skipped, rel+"!"+hdr.Name)
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := io.Copy(tw, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+		if isText(b) {
+			counts := map[string]int{}
+			out, err := applyFile(opts.Rules, hdr.Name, b, counts)
+			if err != nil {
+				return fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if len(counts) > 0 {
+				b = out
+				hdr.Size = int64(len(b))
+				members = append(members, hdr.Name)
+				for k, v := range counts {
+					red.Rules[k] += v
+				}
+			}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(b); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	sort.Strings(members)
+	if len(members) > maxMembers {
+		members = members[:maxMembers]
+	}
+	red.Members = members
+	return nil
+}
+
+// isText reports whether a sample of b looks like text. NULs are allowed
+// in moderation so that /proc environ and cmdline files qualify.
+func isText(b []byte) bool {
+	if len(b) > textSample {
+		b = b[:textSample]
+	}
+	nul := 0
+	for _, c := range b {
+		switch {
+		case c == 0:
+			nul++
+		case c < '\t' || c > '\r' && c < ' ' && c != 0x1b:
+			return false
+		}
+	}
+	return nul*10 <= len(b)
+}
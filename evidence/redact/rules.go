@@ -0,0 +1,189 @@
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Rule replaces every match of Pattern with Replace, a regexp template in
+// which $1-style groups keep the parts of a match that are not secret. An
+// empty Replace substitutes the whole match with Marker(Name).
+type Rule struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Replace string
+}
+
+// Marker is what a secret matched by the named rule is replaced with.
+func Marker(name string) string {
+	return "[REDACTED:" + name + "]"
+}
+
+// markerPrefix keeps later rules from matching what earlier ones redacted.
+var markerPrefix = []byte("[REDACTED:")
+
+// sep matches what separates a flag from its value: '=', whitespace, the
+// NUL of /proc environ and cmdline files, or the "," between elements of a
+// JSON string array.
+const sep = `(?:=|\s+|\x00|",\s*")`
+
+// lead matches what precedes a flag or variable name.
+const lead = `(?:^|[\s"'\x00=;&|])`
+
+// value matches a quoted or bare secret.
+const value = `("[^"\n]*"|'[^'\n]*'|[^\s"'\x00;&|]+)`
+
+// defaultRules are ordered from specific token formats to generic names,
+// so a known token is labelled by its format.
+var defaultRules = []struct{ name, pattern, replace string }{
+	{"pem_private_key", `-----BEGIN[A-Z0-9 ]*PRIVATE KEY-----[\s\S]*?-----END[A-Z0-9 ]*PRIVATE KEY-----`, ""},
+	{"aws_access_key_id", `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA)[A-Z0-9]{16}\b`, ""},
+	{"aws_secret_access_key", `(?i)(aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`, "${1}%s"},
+	{"jwt", `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`, ""},
+	{"github_token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})`, ""},
+	{"slack_token", `\bxox[abposr]-[A-Za-z0-9-]{10,}`, ""},
+	{"google_api_key", `\bAIza[0-9A-Za-z_-]{35}`, ""},
+	{"url_credentials", `\b([A-Za-z][A-Za-z0-9+.-]*://[^/\s:@"']+:)[^@\s/"']+(@)`, "${1}%s${2}"},
+	{"password_arg", `(?i)(` + lead + `--?(?:password|passwd|pass|pwd|token|secret|api[-_]?key|access[-_]?key)` + sep + `)` + value, "${1}%s"},
+	{"mysql_password", `(\bmysql(?:dump|admin)?\b[^\n\x00]*?[\s"\x00]-p)([^\s"'\x00]+)`, "${1}%s"},
+	{"sshpass", `(\bsshpass(?:\s+|",\s*"|\x00)-p(?:\s+|",\s*"|\x00)?)([^\s"'\x00]+)`, "${1}%s"},
+	{"env_secret", `(` + lead + `(?:export\s+)?[A-Za-z0-9_]*(?i:password|passwd|secret|token|api_?key|access_?key|private_?key|credentials?)[A-Za-z0-9_]*=)` + value, "${1}%s"},
+}
+
+// DefaultRules returns the built-in rules: PEM private keys, AWS, GitHub,
+// Slack and Google credentials, JWTs, passwords in URLs and on command
+// lines, and variables whose names mark them as secrets.
+func DefaultRules() []Rule {
+	out := make([]Rule, 0, len(defaultRules))
+	for _, r := range defaultRules {
+		replace := r.replace
+		if strings.Contains(replace, "%s") {
+			replace = fmt.Sprintf(replace, Marker(r.name))
+		}
+		out = append(out, Rule{Name: r.name, Pattern: regexp.MustCompile(r.pattern), Replace: replace})
+	}
+	return out
+}
+
+type ruleJSON struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace,omitempty"`
+}
+
+// LoadRules reads custom rules from a JSON array of {name, pattern,
+// replace} objects, or from text with one "name: pattern" or bare pattern
+// per line. Lines starting with '#' are comments.
+func LoadRules(path string) ([]Rule, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []ruleJSON
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, err
+		}
+	} else {
+		s := bufio.NewScanner(bytes.NewReader(b))
+		s.Buffer(make([]byte, 64*1024), 1024*1024)
+		n := 0
+		for s.Scan() {
+			n++
+			line := strings.TrimSpace(s.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			r := ruleJSON{Name: fmt.Sprintf("custom_%d", n), Pattern: line}
+			if name, pattern, ok := strings.Cut(line, ": "); ok && validName(name) {
+				r.Name, r.Pattern = name, strings.TrimSpace(pattern)
+			}
+			raw = append(raw, r)
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	out := make([]Rule, 0, len(raw))
+	for i, r := range raw {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("custom_%d", i+1)
+		}
+		if !validName(r.Name) {
+			return nil, fmt.Errorf("rule %q: name may only contain letters, digits, '_' and '-'", r.Name)
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+		}
+		if re.MatchString("") {
+			return nil, fmt.Errorf("rule %s: pattern matches the empty string", r.Name)
+		}
+		out = append(out, Rule{Name: r.Name, Pattern: re, Replace: r.Replace})
+	}
+	if len(out) == 0 {
+		return nil, errors.New("redaction rule file contained no rules")
+	}
+	return out, nil
+}
+
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// apply redacts b and returns the result with the number of matches.
+// Matches that already contain a redaction marker are left alone.
+func (r Rule) apply(b []byte) ([]byte, int) {
+	idx := r.Pattern.FindAllSubmatchIndex(b, -1)
+	if len(idx) == 0 {
+		return b, 0
+	}
+	replace := []byte(r.Replace)
+	if r.Replace == "" {
+		replace = []byte(Marker(r.Name))
+	}
+	out := make([]byte, 0, len(b))
+	last, n := 0, 0
+	for _, m := range idx {
+		if bytes.Contains(b[m[0]:m[1]], markerPrefix) {
+			continue
+		}
+		out = append(out, b[last:m[0]]...)
+		out = r.Pattern.Expand(out, replace, b, m)
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return b, 0
+	}
+	return append(out, b[last:]...), n
+}
+
+// Apply runs rules over b in order and counts the matches per rule name.
+func Apply(rules []Rule, b []byte, counts map[string]int) []byte {
+	for _, r := range rules {
+		var n int
+		b, n = r.apply(b)
+		if n > 0 {
+			counts[r.Name] += n
+		}
+	}
+	return b
+}
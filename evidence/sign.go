@@ -0,0 +1,204 @@
+package evidence
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SignatureFile is the detached manifest signature next to manifest.json.
+const SignatureFile = "manifest.sig"
+
+// Signature is an Ed25519 signature over the exact bytes of manifest.json.
+// The public key is embedded so that any copy of the case can be checked;
+// proving who signed it takes comparing KeyFingerprint with a trusted key.
+type Signature struct {
+	Algorithm      string `json:"algorithm"`
+	KeyFingerprint string `json:"key_fingerprint"`
+	PublicKey      string `json:"public_key"`
+	ManifestSHA256 string `json:"manifest_sha256"`
+	Signature      string `json:"signature"`
+	SignedAt       string `json:"signed_at"`
+}
+
+// GenerateKey returns a new Ed25519 signing key.
+func GenerateKey() (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	return key, err
+}
+
+// Fingerprint identifies a public key in the style of OpenSSH.
+func Fingerprint(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// WritePrivateKey stores key as a PKCS#8 PEM file readable by the owner
+// only. It refuses to overwrite an existing file.
+func WritePrivateKey(path string, key ed25519.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := EnsureParent(path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MarshalPublicKey encodes pub as a PKIX PEM block.
+func MarshalPublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s: not a PEM private key", path)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	key, ok := k.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s: not an Ed25519 key", path)
+	}
+	return key, nil
+}
+
+// ReadPublicKey reads a public key file, or the public half of a private
+// key file.
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(b); block != nil && block.Type == "PRIVATE KEY" {
+		key, err := ReadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return key.Public().(ed25519.PublicKey), nil
+	}
+	pub, err := ParsePublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, nil
+}
+
+// ParsePublicKey decodes a PKIX PEM Ed25519 public key.
+func ParsePublicKey(b []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("not a PEM public key")
+	}
+	k, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := k.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return pub, nil
+}
+
+// WriteSignedManifest writes the manifest and signs it and its Merkle root
+// with key.
+func WriteSignedManifest(outputDir string, m Manifest, key ed25519.PrivateKey) error {
+	if err := writeManifest(outputDir, m, key); err != nil {
+		return err
+	}
+	return SignManifest(outputDir, key)
+}
+
+// SignManifest writes SignatureFile for the manifest.json in outputDir.
+func SignManifest(outputDir string, key ed25519.PrivateKey) error {
+	b, err := os.ReadFile(filepath.Join(outputDir, "manifest.json"))
+	if err != nil {
+		return err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	sum := sha256.Sum256(b)
+	sig := Signature{
+		Algorithm:      "ed25519",
+		KeyFingerprint: Fingerprint(pub),
+		PublicKey:      base64.StdEncoding.EncodeToString(pub),
+		ManifestSHA256: hex.EncodeToString(sum[:]),
+		Signature:      base64.StdEncoding.EncodeToString(ed25519.Sign(key, b)),
+		SignedAt:       time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	out, err := json.MarshalIndent(sig, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(outputDir, SignatureFile), append(out, '\n'), 0o600)
+}
+
+// VerifySignature checks a SignatureFile against the manifest bytes. With
+// trusted keys, the signing key must be one of them.
+func VerifySignature(manifest, sigFile []byte, trusted []ed25519.PublicKey) (Signature, error) {
+	var sig Signature
+	if err := json.Unmarshal(sigFile, &sig); err != nil {
+		return sig, fmt.Errorf("parse signature: %w", err)
+	}
+	if sig.Algorithm != "ed25519" {
+		return sig, fmt.Errorf("unsupported signature algorithm %q", sig.Algorithm)
+	}
+	pub, err := base64.StdEncoding.DecodeString(sig.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return sig, errors.New("invalid public key in signature")
+	}
+	if Fingerprint(pub) != sig.KeyFingerprint {
+		return sig, errors.New("key fingerprint does not match the embedded public key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(sig.Signature)
+	if err != nil {
+		return sig, errors.New("invalid signature encoding")
+	}
+	if !ed25519.Verify(pub, manifest, raw) {
+		sum := sha256.Sum256(manifest)
+		if hex.EncodeToString(sum[:]) != sig.ManifestSHA256 {
+			return sig, errors.New("manifest changed since it was signed")
+		}
+		return sig, errors.New("signature does not match the manifest")
+	}
+	if len(trusted) > 0 {
+		for _, k := range trusted {
+			if bytes.Equal(k, pub) {
+				return sig, nil
+			}
+		}
+		return sig, fmt.Errorf("signed by untrusted key %s", sig.KeyFingerprint)
+	}
+	return sig, nil
+}
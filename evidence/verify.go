@@ -0,0 +1,481 @@
+package evidence
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Results of an integrity check.
+const (
+	CheckOK     = "ok"
+	CheckFailed = "failed"
+)
+
+// maxCaseFile bounds each case file, such as manifest.json, that
+// verification reads from an archive, and maxCaseFiles all of them.
+const (
+	maxCaseFile  = 64 * 1024 * 1024
+	maxCaseFiles = 2 * maxCaseFile
+)
+
+// readCaseFiles are the only archive members whose content verification
+// needs; every other member is hashed as it streams past.
+var readCaseFiles = map[string]bool{"manifest.json": true, SignatureFile: true, CustodyFile: true}
+
+// FileCheck is an artifact whose content does not match the manifest.
+type FileCheck struct {
+	RelativePath   string `json:"relative_path"`
+	ExpectedSHA256 string `json:"expected_sha256"`
+	SHA256         string `json:"sha256,omitempty"`
+	ExpectedSize   int64  `json:"expected_size_bytes"`
+	Size           int64  `json:"size_bytes,omitempty"`
+}
+
+// Check is the result of one integrity check beyond artifact hashes.
+type Check struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Verification is the outcome of checking a case against its manifest.
+// Artifacts counts the hashed artifacts listed; placeholders without a hash,
+// such as collector errors, only need to be present.
+type Verification struct {
+	Source         string      `json:"source"`
+	CaseID         string      `json:"case_id"`
+	ManifestSHA256 string      `json:"manifest_sha256"`
+	SignedBy       string      `json:"signed_by,omitempty"`
+	CustodyEntries int         `json:"custody_entries,omitempty"`
+	CustodyHead    string      `json:"custody_head,omitempty"`
+	VerifiedAt     string      `json:"verified_at"`
+	Artifacts      int         `json:"artifacts"`
+	Verified       int         `json:"verified"`
+	Missing        []string    `json:"missing"`
+	Modified       []FileCheck `json:"modified"`
+	Unlisted       []string    `json:"unlisted"`
+	Checks         []Check     `json:"checks,omitempty"`
+	Valid          bool        `json:"valid"`
+}
+
+// VerifyOptions configures verification. With TrustedKeys, the manifest
+// must carry a signature by one of them.
+type VerifyOptions struct {
+	TrustedKeys []ed25519.PublicKey
+}
+
+// caseFiles gives verification access to the files of a case, whether a
+// directory or an archive.
+type caseFiles interface {
+	// list returns the relative paths of all regular files.
+	list() []string
+	hash(rel string) (string, int64, error)
+	read(rel string) ([]byte, error)
+}
+
+// Verify checks a case directory or a .tar.gz of one, as uploaded by the
+// agent, against its manifest.
+func Verify(source string, opts VerifyOptions) (Verification, error) {
+	st, err := os.Stat(source)
+	if err != nil {
+		return Verification{}, err
+	}
+	if st.IsDir() {
+		v, err := verifyFiles(dirFiles{root: source}, opts)
+		v.Source = source
+		return v, err
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		return Verification{}, err
+	}
+	defer f.Close()
+	v, err := VerifyArchive(f, opts)
+	v.Source = source
+	return v, err
+}
+
+// VerifyArchive checks a gzip-compressed tar of a case. The case may sit at
+// the archive root or in one top-level directory.
+func VerifyArchive(r io.Reader, opts VerifyOptions) (Verification, error) {
+	af, err := readArchive(r)
+	if err != nil {
+		return Verification{}, err
+	}
+	return verifyFiles(af, opts)
+}
+
+func verifyFiles(cf caseFiles, opts VerifyOptions) (Verification, error) {
+	v := Verification{
+		VerifiedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Missing:    []string{},
+		Modified:   []FileCheck{},
+		Unlisted:   []string{},
+	}
+	raw, err := cf.read("manifest.json")
+	if err != nil {
+		return v, fmt.Errorf("read manifest: %w", err)
+	}
+	sum := sha256.Sum256(raw)
+	v.ManifestSHA256 = hex.EncodeToString(sum[:])
+	var m Manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return v, fmt.Errorf("parse manifest: %w", err)
+	}
+	v.CaseID = m.CaseID
+
+	present := map[string]bool{}
+	for _, rel := range cf.list() {
+		present[rel] = true
+	}
+	expected := map[string]bool{"manifest.json": true}
+
+	if present[SignatureFile] {
+		expected[SignatureFile] = true
+		c := Check{Name: "signature", Status: CheckOK}
+		sigFile, err := cf.read(SignatureFile)
+		if err != nil {
+			return v, err
+		}
+		sig, err := VerifySignature(raw, sigFile, opts.TrustedKeys)
+		v.SignedBy = sig.KeyFingerprint
+		if err != nil {
+			c.Status, c.Detail = CheckFailed, err.Error()
+		} else {
+			c.Detail = "signed by " + sig.KeyFingerprint + " at " + sig.SignedAt
+		}
+		v.Checks = append(v.Checks, c)
+	} else if len(opts.TrustedKeys) > 0 {
+		v.Checks = append(v.Checks, Check{Name: "signature", Status: CheckFailed, Detail: "manifest is not signed"})
+	}
+
+	for _, a := range m.Artifacts {
+		rel := a.RelativePath
+		expected[rel] = true
+		if a.SHA256 == "" {
+			if !present[rel] {
+				v.Missing = append(v.Missing, rel)
+			}
+			continue
+		}
+		v.Artifacts++
+		if !present[rel] {
+			v.Missing = append(v.Missing, rel)
+			continue
+		}
+		sha, n, err := cf.hash(rel)
+		if err != nil {
+			return v, err
+		}
+		if sha != a.SHA256 || n != a.SizeBytes {
+			v.Modified = append(v.Modified, FileCheck{RelativePath: rel, ExpectedSHA256: a.SHA256, SHA256: sha, ExpectedSize: a.SizeBytes, Size: n})
+			continue
+		}
+		v.Verified++
+	}
+
+	if m.Merkle != nil {
+		v.Checks = append(v.Checks, checkMerkle(m, opts.TrustedKeys))
+	}
+
+	if c, ok := checkCustody(cf, m, present, expected, &v); ok {
+		v.Checks = append(v.Checks, c)
+	}
+
+	if c, ok, err := checkOriginals(cf, m, present, expected); err != nil {
+		return v, err
+	} else if ok {
+		v.Checks = append(v.Checks, c)
+	}
+
+	for rel := range present {
+		if !expected[rel] {
+			v.Unlisted = append(v.Unlisted, rel)
+		}
+	}
+	sort.Strings(v.Missing)
+	sort.Strings(v.Unlisted)
+	sort.Slice(v.Modified, func(i, j int) bool { return v.Modified[i].RelativePath < v.Modified[j].RelativePath })
+
+	v.Valid = len(v.Missing) == 0 && len(v.Modified) == 0 && len(v.Unlisted) == 0
+	for _, c := range v.Checks {
+		if c.Status != CheckOK {
+			v.Valid = false
+		}
+	}
+	return v, nil
+}
+
+// checkMerkle recomputes the Merkle tree from the manifest's artifacts,
+// whose hashes are checked against the files separately, and checks the
+// recorded root, every inclusion proof and the root signature.
+func checkMerkle(m Manifest, trusted []ed25519.PublicKey) Check {
+	c := Check{Name: "merkle_root", Status: CheckFailed}
+	if m.Merkle.Algorithm != MerkleAlgorithm {
+		c.Detail = fmt.Sprintf("unsupported algorithm %q", m.Merkle.Algorithm)
+		return c
+	}
+	want := BuildMerkle(m.Artifacts)
+	if want == nil || want.Root != m.Merkle.Root || want.Leaves != m.Merkle.Leaves {
+		c.Detail = "root does not match the artifacts"
+		return c
+	}
+	leaves := map[string][]byte{}
+	for _, a := range merkleLeaves(m.Artifacts) {
+		leaves[a.RelativePath] = LeafHash(a.RelativePath, a.SHA256, a.SizeBytes)
+	}
+	if len(m.Merkle.Proofs) != m.Merkle.Leaves {
+		c.Detail = fmt.Sprintf("%d proofs for %d leaves", len(m.Merkle.Proofs), m.Merkle.Leaves)
+		return c
+	}
+	for _, p := range m.Merkle.Proofs {
+		leaf, ok := leaves[p.RelativePath]
+		if !ok {
+			c.Detail = "proof for unknown artifact " + p.RelativePath
+			return c
+		}
+		if err := VerifyInclusion(leaf, p.Index, m.Merkle.Leaves, p.Path, m.Merkle.Root); err != nil {
+			c.Detail = p.RelativePath + ": " + err.Error()
+			return c
+		}
+	}
+	c.Detail = fmt.Sprintf("%d leaves, root %s", m.Merkle.Leaves, m.Merkle.Root)
+	if sig := m.Merkle.Signature; sig != nil {
+		if err := VerifyRootSignature(m.Merkle.Root, sig, trusted); err != nil {
+			c.Detail = err.Error()
+			return c
+		}
+		c.Detail += ", signed by " + sig.KeyFingerprint
+	}
+	c.Status = CheckOK
+	return c
+}
+
+// checkCustody verifies the hash chain of the custody log and that the
+// manifest's reference to it is part of the chain.
+func checkCustody(cf caseFiles, m Manifest, present, expected map[string]bool, v *Verification) (Check, bool) {
+	c := Check{Name: "custody_chain", Status: CheckFailed}
+	if !present[CustodyFile] {
+		if m.Custody == nil {
+			return c, false
+		}
+		c.Detail = "manifest references " + CustodyFile + " but it is missing"
+		return c, true
+	}
+	expected[CustodyFile] = true
+	b, err := cf.read(CustodyFile)
+	if err != nil {
+		c.Detail = err.Error()
+		return c, true
+	}
+	entries, err := ParseCustody(b)
+	if err != nil {
+		c.Detail = err.Error()
+		return c, true
+	}
+	if len(entries) > 0 && entries[0].Seq != 1 {
+		c.Detail = fmt.Sprintf("log starts at entry %d", entries[0].Seq)
+		return c, true
+	}
+	if err := VerifyCustody(entries); err != nil {
+		c.Detail = err.Error()
+		return c, true
+	}
+	if n := len(entries); n > 0 {
+		v.CustodyEntries, v.CustodyHead = entries[n-1].Seq, entries[n-1].Hash
+	}
+	if r := m.Custody; r != nil && (r.Entries < 1 || r.Entries > len(entries) || entries[r.Entries-1].Hash != r.Head) {
+		c.Detail = fmt.Sprintf("manifest references entry %d with a hash not in the log", r.Entries)
+		return c, true
+	}
+	c.Status, c.Detail = CheckOK, fmt.Sprintf("%d entries", len(entries))
+	return c, true
+}
+
+// checkOriginals verifies the originals that redaction kept under
+// RestrictedDir. They normally stay on the collecting host, so an archive
+// without them passes; any that are present must match.
+func checkOriginals(cf caseFiles, m Manifest, present, expected map[string]bool) (Check, bool, error) {
+	c := Check{Name: "redacted_originals", Status: CheckOK}
+	kept, checked := 0, 0
+	var bad []string
+	for _, r := range m.Redactions {
+		if r.Original == "" {
+			continue
+		}
+		kept++
+		expected[r.Original] = true
+		if !present[r.Original] {
+			continue
+		}
+		checked++
+		sha, n, err := cf.hash(r.Original)
+		if err != nil {
+			return c, false, err
+		}
+		if sha != r.OriginalSHA256 || n != r.OriginalSize {
+			bad = append(bad, r.Original)
+		}
+	}
+	if kept == 0 {
+		return c, false, nil
+	}
+	c.Detail = fmt.Sprintf("%d of %d originals present", checked, kept)
+	if len(bad) > 0 {
+		c.Status = CheckFailed
+		c.Detail += "; modified: " + strings.Join(bad, ", ")
+	}
+	return c, true, nil
+}
+
+type dirFiles struct {
+	root string
+}
+
+func (d dirFiles) list() []string {
+	var out []string
+	_ = filepath.WalkDir(d.root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return nil
+		}
+		if rel, err := filepath.Rel(d.root, p); err == nil {
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return out
+}
+
+func (d dirFiles) hash(rel string) (string, int64, error) {
+	return SHA256File(filepath.Join(d.root, filepath.FromSlash(rel)))
+}
+
+func (d dirFiles) read(rel string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(d.root, filepath.FromSlash(rel)))
+}
+
+type archiveEntry struct {
+	sha  string
+	size int64
+	data []byte
+}
+
+// archiveFiles holds the hashes of every regular member of an archive and
+// the content of the case files at its root or in the first top-level
+// directory holding one, read in a single pass.
+type archiveFiles struct {
+	entries map[string]archiveEntry
+}
+
+func readArchive(r io.Reader) (archiveFiles, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return archiveFiles{}, err
+	}
+	defer zr.Close()
+
+	all := map[string]archiveEntry{}
+	caseDir, kept := "", int64(0)
+	tr := tar.NewReader(zr)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return archiveFiles{}, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		h := sha256.New()
+		var w io.Writer = h
+		var buf *bytes.Buffer
+		if dir := path.Dir(name); readCaseFiles[path.Base(name)] && strings.Count(name, "/") <= 1 {
+			if dir != "." && caseDir == "" {
+				caseDir = dir
+			}
+			if (dir == "." || dir == caseDir) && hdr.Size <= maxCaseFile && kept+hdr.Size <= maxCaseFiles {
+				kept += hdr.Size
+				buf = &bytes.Buffer{}
+				w = io.MultiWriter(h, buf)
+			}
+		}
+		n, err := io.Copy(w, tr)
+		if err != nil {
+			return archiveFiles{}, err
+		}
+		e := archiveEntry{sha: hex.EncodeToString(h.Sum(nil)), size: n}
+		if buf != nil {
+			e.data = buf.Bytes()
+		}
+		all[name] = e
+	}
+
+	// The agent archives the case directory itself, so the case is rooted
+	// at the archive root or in its first top-level directory.
+	root := "."
+	if _, ok := all["manifest.json"]; !ok {
+		if _, ok := all[caseDir+"/manifest.json"]; caseDir == "" || !ok {
+			return archiveFiles{}, errors.New("archive contains no manifest.json")
+		}
+		root = caseDir
+	}
+	af := archiveFiles{entries: map[string]archiveEntry{}}
+	for name, e := range all {
+		rel := name
+		if root != "." {
+			if !strings.HasPrefix(name, root+"/") {
+				// Outside the case directory: reported as unlisted.
+				rel = "../" + name
+			} else {
+				rel = strings.TrimPrefix(name, root+"/")
+			}
+		}
+		af.entries[rel] = e
+	}
+	return af, nil
+}
+
+func (a archiveFiles) list() []string {
+	out := make([]string, 0, len(a.entries))
+	for rel := range a.entries {
+		out = append(out, rel)
+	}
+	return out
+}
+
+func (a archiveFiles) hash(rel string) (string, int64, error) {
+	e, ok := a.entries[rel]
+	if !ok {
+		return "", 0, fs.ErrNotExist
+	}
+	return e.sha, e.size, nil
+}
+
+func (a archiveFiles) read(rel string) ([]byte, error) {
+	e, ok := a.entries[rel]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	if e.data == nil {
+		return nil, fmt.Errorf("%s: too large to read from archive", rel)
+	}
+	return e.data, nil
+}
@@ -0,0 +1,172 @@
+package findings
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+// Severities lists severities from most to least severe.
+var Severities = []Severity{SeverityCritical, SeverityHigh, SeverityMedium, SeverityLow, SeverityInfo}
+
+// Rank orders severities; higher is more severe and unknown values rank 0.
+func (s Severity) Rank() int {
+	for i, v := range Severities {
+		if v == s {
+			return len(Severities) - i
+		}
+	}
+	return 0
+}
+
+// ParseSeverity normalizes the severity and level names used by IOC feeds,
+// YARA meta and Sigma rules. Unknown values map to def.
+func ParseSeverity(s string, def Severity) Severity {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "info", "informational", "information":
+		return SeverityInfo
+	case "low":
+		return SeverityLow
+	case "medium", "moderate":
+		return SeverityMedium
+	case "high":
+		return SeverityHigh
+	case "critical":
+		return SeverityCritical
+	}
+	return def
+}
+
+const (
+	ConfidenceLow    = "low"
+	ConfidenceMedium = "medium"
+	ConfidenceHigh   = "high"
+)
+
+// Evidence points at the data supporting a finding. Path names a file
+// inside the artifact (an archive member or scan target) when it differs
+// from the artifact itself.
+type Evidence struct {
+	Artifact string `json:"artifact,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Offset   *int64 `json:"offset,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Excerpt  string `json:"excerpt,omitempty"`
+}
+
+// At returns a pointer to off, for Evidence.Offset.
+func At(off int64) *int64 { return &off }
+
+type Finding struct {
+	ID          string            `json:"id"`
+	Analyzer    string            `json:"analyzer"`
+	Rule        string            `json:"rule,omitempty"`
+	Severity    Severity          `json:"severity"`
+	Confidence  string            `json:"confidence"`
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Time        string            `json:"time,omitempty"`
+	Evidence    []Evidence        `json:"evidence"`
+	Attack      []string          `json:"attack,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	AnalysisRun string            `json:"analysis_run,omitempty"`
+	CreatedAt   string            `json:"created_at"`
+}
+
+// SetID derives a stable ID from the analyzer, rule, title and evidence
+// locations, so the same detection keeps its ID across analysis runs.
+func (f *Finding) SetID() {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s", f.Analyzer, f.Rule, f.Title)
+	for _, e := range f.Evidence {
+		fmt.Fprintf(h, "\x00%s\x00%s\x00%d", e.Artifact, e.Path, e.Line)
+		if e.Offset != nil {
+			fmt.Fprintf(h, "@%d", *e.Offset)
+		}
+	}
+	f.ID = hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// Sort orders findings by severity, most severe first, then by time and ID.
+func Sort(fs []Finding) {
+	sort.SliceStable(fs, func(i, j int) bool {
+		if a, b := fs[i].Severity.Rank(), fs[j].Severity.Rank(); a != b {
+			return a > b
+		}
+		if fs[i].Time != fs[j].Time {
+			return fs[i].Time < fs[j].Time
+		}
+		return fs[i].ID < fs[j].ID
+	})
+}
+
+// Count returns the number of findings per severity.
+func Count(fs []Finding) map[Severity]int {
+	out := map[Severity]int{}
+	for _, f := range fs {
+		out[f.Severity]++
+	}
+	return out
+}
+
+func WriteJSONL(path string, fs []Finding) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, x := range fs {
+		if err := enc.Encode(x); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func ReadJSONL(path string) ([]Finding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+func Decode(r io.Reader) ([]Finding, error) {
+	var out []Finding
+	dec := json.NewDecoder(r)
+	for {
+		var x Finding
+		err := dec.Decode(&x)
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, x)
+	}
+}
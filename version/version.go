@@ -0,0 +1,6 @@
+package version
+
+const Version = "0.1.0"
+
+// Tool names this build in chain of custody records.
+const Tool = "iron-sentinel " + Version